@@ -0,0 +1,301 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"sigs.k8s.io/yaml"
+)
+
+// where a collect setting came from, highest precedence first
+const (
+	configSourceFlag     = "flag"
+	configSourceEnv      = "env"
+	configSourceFile     = "file"
+	configSourcePreset   = "preset"
+	configSourceDefault  = "default"
+	configSourceResolved = "resolved" // changed by ddc itself, e.g. an interactive answer
+)
+
+const (
+	configFlagName  = "config"
+	configEnvPrefix = "DDC_"
+)
+
+// configEnvAliases keeps environment variables that predate the DDC_<FLAG> naming
+var configEnvAliases = map[string]string{
+	"dremio-pat-token": "DDC_PAT_TOKEN",
+}
+
+// configSources records the source of every flag resolved by resolveConfig
+var configSources map[string]string
+
+// configEnvName returns the environment variable overriding a flag,
+// e.g. DDC_SERVER_LOGS_NUM_DAYS for --server-logs-num-days.
+func configEnvName(flagName string) string {
+	if alias, ok := configEnvAliases[flagName]; ok {
+		return alias
+	}
+	return configEnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// secretConfigKeys are the settings that are credentials or may carry one,
+// whatever their name: a proxy url often embeds user:password.
+var secretConfigKeys = map[string]bool{
+	conf.KeyDremioPatToken: true,
+	conf.KeyHTTPProxy:      true,
+}
+
+// isSecretConfigKey reports whether a setting must be masked before it is logged or archived.
+func isSecretConfigKey(name string) bool {
+	if secretConfigKeys[name] {
+		return true
+	}
+	lower := strings.ToLower(name)
+	return strings.Contains(lower, "token") || strings.Contains(lower, "passw") || strings.Contains(lower, "secret")
+}
+
+// maskConfigValue returns a setting as it may be logged or archived: secrets are
+// REDACTED and the user info of any url is replaced, so credentials in e.g.
+// --dremio-endpoint never leave the process.
+func maskConfigValue(name, value string) string {
+	if value == "" {
+		return value
+	}
+	if isSecretConfigKey(name) {
+		return "REDACTED"
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil || u.Host == "" {
+		return value
+	}
+	u.User = url.User("REDACTED")
+	return u.String()
+}
+
+// collectFlagNames returns every flag accepted by any collect command, across all
+// transports and both modes, so one config file can serve several of them.
+func collectFlagNames() map[string]bool {
+	names := make(map[string]bool)
+	add := func(f *pflag.Flag) { names[f.Name] = true }
+	RootCmd.PersistentFlags().VisitAll(add)
+	CollectCmd.PersistentFlags().VisitAll(add)
+	for _, transport := range CollectCmd.Commands() {
+		transport.PersistentFlags().VisitAll(add)
+		for _, mode := range transport.Commands() {
+			mode.LocalFlags().VisitAll(add)
+		}
+	}
+	return names
+}
+
+// loadConfigFile reads a YAML or JSON file mapping flag names (the conf key names,
+// e.g. server-logs-num-days) to values. Lists are joined with commas so they read
+// like the comma separated flags they stand for. A key that no collect command
+// accepts is an error, to catch typos before a long collection starts.
+func loadConfigFile(path string, known map[string]bool) (map[string]string, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file %v: %w", path, err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %v: %w", path, err)
+	}
+	values := make(map[string]string, len(raw))
+	for key, v := range raw {
+		if !known[key] || key == configFlagName {
+			return nil, fmt.Errorf("unknown key '%v' in config file %v: keys are collect flag names without the leading --", key, path)
+		}
+		if v == nil {
+			continue
+		}
+		value, err := configValueString(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for '%v' in config file %v: %w", key, path, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+func configValueString(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		if val == float64(int64(val)) {
+			return strconv.FormatInt(int64(val), 10), nil
+		}
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			s, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("expected a string, number, boolean or list but got %T", v)
+	}
+}
+
+// applyConfigSources fills every flag not given on the command line from its DDC_*
+// environment variable, then from the config file. Values are applied with Set, so
+// they count as explicitly passed for the rest of the run. When resetDefaults is
+// true the remaining flags are put back to the preset's value from presetDefaults,
+// or else the default registered on this command: the standard and diagnosis
+// commands share their variables, so without this a standard run would see the
+// defaults of the diagnosis flags registered last.
+func applyConfigSources(flags *pflag.FlagSet, file map[string]string, lookupEnv func(string) (string, bool), presetDefaults map[string]string, resetDefaults bool) (map[string]string, error) {
+	sources := make(map[string]string)
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Name == "help" {
+			return
+		}
+		if f.Changed {
+			sources[f.Name] = configSourceFlag
+			return
+		}
+		if value, ok := lookupEnv(configEnvName(f.Name)); ok {
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for %v: %w", configEnvName(f.Name), setErr)
+				return
+			}
+			sources[f.Name] = configSourceEnv
+			return
+		}
+		if value, ok := file[f.Name]; ok {
+			if setErr := flags.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid value for '%v' in config file: %w", f.Name, setErr)
+				return
+			}
+			sources[f.Name] = configSourceFile
+			return
+		}
+		sources[f.Name] = configSourceDefault
+		if !resetDefaults {
+			return
+		}
+		value := f.DefValue
+		if v, ok := presetDefaults[f.Name]; ok {
+			value = v
+			sources[f.Name] = configSourcePreset
+		}
+		if setErr := f.Value.Set(value); setErr != nil {
+			err = fmt.Errorf("unable to apply the default of --%v: %w", f.Name, setErr)
+		}
+	})
+	return sources, err
+}
+
+// resolveConfig applies the environment, the --config (or DDC_CONFIG) file and the
+// --preset to the flags of cmd. Precedence is flag > env > file > preset > mode default.
+func resolveConfig(cmd *cobra.Command, leaf bool) error {
+	path := configFile
+	if path == "" {
+		path = os.Getenv(configEnvName(configFlagName))
+	}
+	var file map[string]string
+	if path != "" {
+		var err error
+		file, err = loadConfigFile(path, collectFlagNames())
+		if err != nil {
+			return err
+		}
+	}
+	flags := buildMergedFlagSet(cmd)
+	var presetDefaults map[string]string
+	activePreset = nil
+	name, presetPath := configSetting(flags, file, conf.KeyPreset), configSetting(flags, file, conf.KeyPresetFile)
+	if !leaf {
+		// the TUI picks the mode later; keep DDC_PRESET or the file's preset to preselect it
+		presetName, presetFile = name, presetPath
+	} else if name != "" {
+		preset, err := resolvePreset(name, presetPath, collectionMode)
+		if err != nil {
+			return err
+		}
+		activePreset = preset
+		presetDefaults = presetFlagDefaults(flags, *preset)
+	}
+	sources, err := applyConfigSources(flags, file, os.LookupEnv, presetDefaults, leaf)
+	if err != nil {
+		return err
+	}
+	configSources = sources
+	return nil
+}
+
+// effectiveSetting is one entry of the archived configuration
+type effectiveSetting struct {
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// effectiveConfig returns the merged configuration of cmd with secrets masked, as
+// YAML. Values ddc changed after resolveConfig (interactive answers, derived paths)
+// are marked as resolved.
+func effectiveConfig(cmd *cobra.Command) ([]byte, error) {
+	settings := make(map[string]effectiveSetting)
+	buildMergedFlagSet(cmd).VisitAll(func(f *pflag.Flag) {
+		source, ok := configSources[f.Name]
+		if !ok {
+			return
+		}
+		value := f.Value.String()
+		expected := f.DefValue
+		if source == configSourcePreset && activePreset != nil {
+			expected = presetFlagValue(activePreset.Settings[flagConfKey(f.Name)])
+		}
+		if (source == configSourceDefault || source == configSourcePreset) && value != expected {
+			source = configSourceResolved
+		}
+		settings[f.Name] = effectiveSetting{Value: maskConfigValue(f.Name, value), Source: source}
+	})
+	out, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("unable to render the effective configuration: %w", err)
+	}
+	header := "# effective ddc configuration, precedence: flag > env > file > preset > default\n"
+	return append([]byte(header), out...), nil
+}
+
+// logConfigSources logs every setting that came from the environment or the config file
+func logConfigSources(cmd *cobra.Command) {
+	buildMergedFlagSet(cmd).VisitAll(func(f *pflag.Flag) {
+		source := configSources[f.Name]
+		if source != configSourceEnv && source != configSourceFile {
+			return
+		}
+		simplelog.Infof("--%v=%v from %v", f.Name, maskConfigValue(f.Name, f.Value.String()), source)
+	})
+}
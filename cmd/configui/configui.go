@@ -16,7 +16,6 @@
 package configui
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
 	"runtime"
@@ -28,6 +27,7 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/restclient"
 )
 
 // bannerStyle matches the status screen's rounded-border title box.
@@ -87,6 +87,9 @@ type StandardConfig struct {
 	SystemTables         []string
 	CollectContainerLogs bool
 
+	Preset     *conf.Preset // emitted as --preset in the CLI command
+	PresetFile string
+
 	Cancelled        bool
 	ShowCLICmd       bool
 	GeneratedCommand string
@@ -103,6 +106,7 @@ type DiagnosisConfig struct {
 	SSHUser     string
 	K8sContext  string
 	DremioHome  string
+	RestClient  restclient.ClientConfig // TLS/proxy flags, not shown in form
 
 	CoordinatorLogDir string
 	ExecutorLogDir    string
@@ -118,6 +122,7 @@ type DiagnosisConfig struct {
 	CollectTop           bool
 	CollectAsyncProfiler bool
 	CollectHeapDump      bool
+	CollectNMT           bool
 	DiagTimeSeconds      int
 
 	CollectServerLogs     bool
@@ -144,6 +149,9 @@ type DiagnosisConfig struct {
 	SelectedCoordinators []string
 	SelectedExecutors    []string
 
+	Preset     *conf.Preset // emitted as --preset in the CLI command
+	PresetFile string
+
 	Cancelled        bool
 	ShowCLICmd       bool
 	GeneratedCommand string
@@ -237,12 +245,41 @@ type DetectedPaths struct {
 	SSHUser     string
 	K8sContext  string
 	DremioHome  string
+
+	// RestClient carries the REST TLS/proxy flags so the PAT check matches the collection.
+	RestClient restclient.ClientConfig
+
+	// Preset picked before the config screen; its settings replace the mode defaults.
+	Preset     *conf.Preset
+	PresetFile string
+}
+
+// screenDefaults returns the defaults a config screen starts from: the picked
+// preset's, or else the mode's.
+func screenDefaults(detected *DetectedPaths, modeDefaults func() map[string]interface{}) map[string]interface{} {
+	if detected != nil && detected.Preset != nil {
+		return detected.Preset.DefaultMap()
+	}
+	return modeDefaults()
+}
+
+// presetCLIFlags returns the --preset (and --preset-file) flags of the picked preset,
+// empty without one.
+func presetCLIFlags(preset *conf.Preset, presetFile string) string {
+	if preset == nil {
+		return ""
+	}
+	line := fmt.Sprintf("--preset=%s", preset.Name)
+	if presetFile != "" {
+		line += fmt.Sprintf(" --preset-file=%s", presetFile)
+	}
+	return line
 }
 
 // RunStandardConfigScreen displays the interactive standard mode config form.
 // If paths were detected from a target node, they are used as defaults.
 func RunStandardConfigScreen(detected *DetectedPaths, _ string) (*StandardConfig, error) {
-	stdDef := conf.StandardDefaultMap()
+	stdDef := screenDefaults(detected, conf.StandardDefaultMap)
 	coordinatorLogDir := conf.GetStringDefault(stdDef, conf.KeyDremioLogDir)
 	executorLogDir := conf.GetStringDefault(stdDef, conf.KeyDremioLogDir)
 	confDir := conf.GetStringDefault(stdDef, conf.KeyDremioConfDir)
@@ -288,6 +325,7 @@ func RunStandardConfigScreen(detected *DetectedPaths, _ string) (*StandardConfig
 		CollectQueriesPerf: conf.GetBoolDefault(stdDef, conf.KeyCollectQueriesPerfJSON),
 		QueriesPerfDays:    conf.GetIntDefault(stdDef, conf.KeyQueriesPerfNumDays),
 		CollectWLM:         conf.GetBoolDefault(stdDef, conf.KeyCollectWLM),
+		SystemTables:       conf.GetStringSliceDefault(stdDef, conf.KeySysTables),
 		Cancelled:          true,
 	}
 	// Set transport info for CLI command generation.
@@ -300,6 +338,8 @@ func RunStandardConfigScreen(detected *DetectedPaths, _ string) (*StandardConfig
 		cfg.SSHUser = detected.SSHUser
 		cfg.K8sContext = detected.K8sContext
 		cfg.DremioHome = detected.DremioHome
+		cfg.Preset = detected.Preset
+		cfg.PresetFile = detected.PresetFile
 	}
 
 	// Log collection uses select-based day choices (0 = skip)
@@ -371,7 +411,7 @@ func RunStandardConfigScreen(detected *DetectedPaths, _ string) (*StandardConfig
 // discoveredCoordinators and discoveredExecutors are the node names found by
 // discovery — they populate the Node Selection multi-select page.
 func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discoveredCoordinators, discoveredExecutors []string) (*DiagnosisConfig, error) {
-	diagDef := conf.DiagnosisDefaultMap()
+	diagDef := screenDefaults(detected, conf.DiagnosisDefaultMap)
 	coordinatorLogDir := conf.GetStringDefault(diagDef, conf.KeyDremioLogDir)
 	executorLogDir := conf.GetStringDefault(diagDef, conf.KeyDremioLogDir)
 	confDir := conf.GetStringDefault(diagDef, conf.KeyDremioConfDir)
@@ -417,6 +457,7 @@ func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discovere
 		CollectTop:                 conf.GetBoolDefault(diagDef, conf.KeyCollectTop),
 		CollectAsyncProfiler:       conf.GetBoolDefault(diagDef, conf.KeyCollectAsyncProfiler),
 		CollectHeapDump:            conf.GetBoolDefault(diagDef, conf.KeyCaptureHeapDump),
+		CollectNMT:                 conf.GetBoolDefault(diagDef, conf.KeyCollectNMT),
 		DiagTimeSeconds:            conf.GetIntDefault(diagDef, conf.KeyDiagTimeSeconds),
 		CollectServerLogs:          conf.GetBoolDefault(diagDef, conf.KeyCollectServerLogs),
 		CollectGCLogs:              conf.GetBoolDefault(diagDef, conf.KeyCollectGCLogs),
@@ -435,6 +476,7 @@ func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discovere
 		CollectKVStore:             conf.GetBoolDefault(diagDef, conf.KeyCollectKVStoreReport),
 		CollectProblematicProfiles: conf.GetBoolDefault(diagDef, conf.KeyCollectProblematicProfiles),
 		CollectSystemTables:        conf.GetBoolDefault(diagDef, conf.KeyCollectSystemTablesExport),
+		SystemTables:               conf.GetStringSliceDefault(diagDef, conf.KeySysTables),
 		Cancelled:                  true,
 	}
 	// Set transport info for CLI command generation.
@@ -447,6 +489,13 @@ func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discovere
 		cfg.SSHUser = detected.SSHUser
 		cfg.K8sContext = detected.K8sContext
 		cfg.DremioHome = detected.DremioHome
+		cfg.RestClient = detected.RestClient
+		cfg.Preset = detected.Preset
+		cfg.PresetFile = detected.PresetFile
+		// a custom CA means the server should be verified
+		if detected.RestClient.CACertFile != "" {
+			cfg.AllowInsecureSSL = false
+		}
 	}
 
 	days := newIntStr(cfg.Days, &cfg.Days)
@@ -456,6 +505,18 @@ func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discovere
 	proceed := true
 	var diagLogTypes []string
 	var selectedTools []string
+	// start from the defaults so the CLI command matches before the tools page is visited
+	for _, t := range []struct {
+		name    string
+		enabled bool
+	}{
+		{"top", cfg.CollectTop}, {"async-profiler", cfg.CollectAsyncProfiler}, {"JFR", cfg.CollectJFR},
+		{"jstack", cfg.CollectJStack}, {"NMT", cfg.CollectNMT},
+	} {
+		if t.enabled {
+			selectedTools = append(selectedTools, t.name)
+		}
+	}
 
 	// Build combined node list sorted master-first for the Node Selection page.
 	allNodes := make([]string, 0, len(discoveredCoordinators)+len(discoveredExecutors))
@@ -549,7 +610,9 @@ func RunDiagnosisConfigScreen(detected *DetectedPaths, version string, discovere
 					if s == "" {
 						return fmt.Errorf("PAT token is required when collecting job profiles or KV store")
 					}
-					result := ValidatePAT(cfg.DremioEndpoint, s, cfg.AllowInsecureSSL)
+					restConfig := cfg.RestClient
+					restConfig.AllowInsecureSSL = cfg.AllowInsecureSSL
+					result := ValidatePAT(cfg.DremioEndpoint, s, restConfig)
 					if strings.Contains(result, "failed") {
 						return fmt.Errorf("%s", result)
 					}
@@ -649,10 +712,11 @@ var allSystemTables = []struct {
 }
 
 // buildSystemTablesMultiSelect returns the system tables multi-select field used by both
-// the standard and diagnosis config screens. Selected state comes from conf.SystemTableList().
+// the standard and diagnosis config screens. Selected state comes from the current value,
+// which the screens fill from the mode or preset defaults (conf.SystemTableList() unless a preset narrows it).
 func buildSystemTablesMultiSelect(value *[]string) *huh.MultiSelect[string] {
-	defaults := make(map[string]bool, len(conf.SystemTableList()))
-	for _, t := range conf.SystemTableList() {
+	defaults := make(map[string]bool, len(*value))
+	for _, t := range *value {
 		defaults[t] = true
 	}
 	var opts []huh.Option[string]
@@ -727,10 +791,11 @@ func buildDiagnosticsToolsGroup(selectedTools *[]string, diagDur *intStr, cfg *D
 		huh.NewMultiSelect[string]().
 			Title("Diagnostic tools to run (parallel on all nodes)").Height(5).
 			Options(
-				huh.NewOption("top (process snapshots)", "top"),
-				huh.NewOption("async-profiler (CPU + native memory)", "async-profiler"),
-				huh.NewOption("JFR (Java Flight Recorder)", "JFR"),
-				huh.NewOption("jstack (thread dumps)", "jstack"),
+				huh.NewOption("top (process snapshots)", "top").Selected(cfg.CollectTop),
+				huh.NewOption("async-profiler (CPU + native memory)", "async-profiler").Selected(cfg.CollectAsyncProfiler),
+				huh.NewOption("JFR (Java Flight Recorder)", "JFR").Selected(cfg.CollectJFR),
+				huh.NewOption("jstack (thread dumps)", "jstack").Selected(cfg.CollectJStack),
+				huh.NewOption("NMT (native memory summary, needs -XX:NativeMemoryTracking)", "NMT").Selected(cfg.CollectNMT),
 			).Value(selectedTools),
 		huh.NewInput().Title("Diagnostic tools time (sec)").Value(&diagDur.str).CharLimit(5).Validate(validateInt),
 		huh.NewConfirm().Title("Heap dump (after diagnostic tools)").Value(&cfg.CollectHeapDump).Inline(true).Affirmative("Yes").Negative("No"),
@@ -803,6 +868,9 @@ func buildStandardCLICommand(cfg *StandardConfig, queryDays, queriesPerfDays, se
 	var parts []string
 
 	parts = append(parts, bin+" collect "+cfg.Transport+" standard"+cont)
+	if flags := presetCLIFlags(cfg.Preset, cfg.PresetFile); flags != "" {
+		parts = append(parts, "  "+flags+cont)
+	}
 	parts = appendTransportAndPathFlags(parts, cfg.Transport, cfg.Namespace, cfg.K8sContext, cfg.Kubeconfig, cfg.Coordinator, cfg.Executors, cfg.SSHUser, cfg.DremioHome, cfg.CoordinatorLogDir, cfg.ExecutorLogDir, cfg.DremioConfDir, cfg.DremioRocksDBDir, cont)
 
 	// Log collection
@@ -867,6 +935,7 @@ func mapSelectedTools(selectedTools []string, cfg *DiagnosisConfig) {
 	cfg.CollectJStack = toolSet["jstack"]
 	cfg.CollectTop = toolSet["top"]
 	cfg.CollectAsyncProfiler = toolSet["async-profiler"]
+	cfg.CollectNMT = toolSet["NMT"]
 	// CollectHeapDump is set directly by the Confirm field, not the tool multi-select.
 }
 
@@ -875,6 +944,9 @@ func buildDiagnosisCLICommand(cfg *DiagnosisConfig, tools *[]string, nodes *[]st
 	var parts []string
 
 	parts = append(parts, bin+" collect "+cfg.Transport+" diagnosis"+cont)
+	if flags := presetCLIFlags(cfg.Preset, cfg.PresetFile); flags != "" {
+		parts = append(parts, "  "+flags+cont)
+	}
 	parts = appendTransportAndPathFlags(parts, cfg.Transport, cfg.Namespace, cfg.K8sContext, cfg.Kubeconfig, cfg.Coordinator, cfg.Executors, cfg.SSHUser, cfg.DremioHome, cfg.CoordinatorLogDir, cfg.ExecutorLogDir, cfg.DremioConfDir, cfg.DremioRocksDBDir, cont)
 	// Start date + days on one line
 	dateDaysLine := "  "
@@ -891,6 +963,7 @@ func buildDiagnosisCLICommand(cfg *DiagnosisConfig, tools *[]string, nodes *[]st
 	hasJStack := tools != nil && sliceContains(*tools, "jstack")
 	hasTop := tools != nil && sliceContains(*tools, "top")
 	hasAP := tools != nil && sliceContains(*tools, "async-profiler")
+	hasNMT := tools != nil && sliceContains(*tools, "NMT")
 	hasHeap := cfg.CollectHeapDump
 
 	parts = append(parts, fmt.Sprintf("  --diag-jfr=%t --diag-jstack=%t --diag-top=%t"+cont, hasJFR, hasJStack, hasTop))
@@ -898,7 +971,7 @@ func buildDiagnosisCLICommand(cfg *DiagnosisConfig, tools *[]string, nodes *[]st
 	if diagDur != nil {
 		diagLine += fmt.Sprintf(" --diag-time-seconds=%s", *diagDur)
 	}
-	diagLine += fmt.Sprintf(" --diag-heap-dump=%t", hasHeap)
+	diagLine += fmt.Sprintf(" --diag-heap-dump=%t --diag-nmt=%t", hasHeap, hasNMT)
 	parts = append(parts, diagLine+cont)
 
 	// Log collection toggles
@@ -925,6 +998,9 @@ func buildDiagnosisCLICommand(cfg *DiagnosisConfig, tools *[]string, nodes *[]st
 	// Endpoint and PAT
 	if cfg.PATToken != "" {
 		parts = append(parts, fmt.Sprintf("  --dremio-endpoint=%s --allow-insecure-ssl=%t"+cont, cfg.DremioEndpoint, cfg.AllowInsecureSSL))
+		if tlsFlags := restClientFlags(cfg.RestClient); tlsFlags != "" {
+			parts = append(parts, "  "+tlsFlags+cont)
+		}
 		parts = append(parts, "  --dremio-pat-token="+patVar)
 	} else {
 		// Remove trailing continuation from last line
@@ -956,16 +1032,35 @@ func cliShellFormat() (binary, continuation, patEnvVar string) {
 	return "ddc", " \\", "$DDC_PAT_TOKEN"
 }
 
+// restClientFlags renders the non-empty REST TLS/proxy settings as CLI flags.
+func restClientFlags(rc restclient.ClientConfig) string {
+	var flags []string
+	if rc.CACertFile != "" {
+		flags = append(flags, "--"+conf.KeyDremioCACert+"="+rc.CACertFile)
+	}
+	if rc.ClientCertFile != "" {
+		flags = append(flags, "--"+conf.KeyDremioClientCert+"="+rc.ClientCertFile)
+	}
+	if rc.ClientKeyFile != "" {
+		flags = append(flags, "--"+conf.KeyDremioClientKey+"="+rc.ClientKeyFile)
+	}
+	if rc.HTTPProxy != "" {
+		flags = append(flags, "--"+conf.KeyHTTPProxy+"="+rc.HTTPProxy)
+	}
+	return strings.Join(flags, " ")
+}
+
 // ValidatePAT performs an HTTP request to the Dremio API to check if the PAT token is valid.
+// It uses the same TLS and proxy configuration as the API collections.
 // Returns "" on success, or an error message on failure.
-func ValidatePAT(endpoint, pat string, allowInsecure bool) string {
+func ValidatePAT(endpoint, pat string, restConfig restclient.ClientConfig) string {
+	tr, err := restclient.NewTransport(restConfig)
+	if err != nil {
+		return fmt.Sprintf("PAT validation failed: %v", err)
+	}
 	client := &http.Client{
-		Timeout: 3 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: allowInsecure, //nolint:gosec
-			},
-		},
+		Timeout:   3 * time.Second,
+		Transport: tr,
 	}
 
 	url := strings.TrimRight(endpoint, "/") + "/api/v3/catalog"
@@ -17,6 +17,8 @@ package configui
 import (
 	"strings"
 	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
 )
 
 // allTools is the default selected tools list used by CLI command tests.
@@ -617,3 +619,29 @@ func TestSyncDiagLogTypes_MetaRefresh(t *testing.T) {
 		t.Error("expected CollectMetaRefresh=false when 'meta-refresh' is not selected")
 	}
 }
+
+func TestBuildCLICommand_Preset(t *testing.T) {
+	days, dur := "1", "60"
+	tools := []string{"NMT"}
+	diagCfg := &DiagnosisConfig{
+		Transport:       "local",
+		Preset:          &conf.Preset{Name: conf.PresetOOM},
+		CollectHeapDump: true,
+	}
+	out := buildDiagnosisCLICommand(diagCfg, &tools, nil, &days, &dur, new(string))
+	if !strings.Contains(out, "--preset=oom") || strings.Contains(out, "--preset-file") {
+		t.Errorf("expected --preset=oom without --preset-file, got:\n%s", out)
+	}
+	if !strings.Contains(out, "--diag-heap-dump=true --diag-nmt=true") {
+		t.Errorf("expected --diag-nmt=true, got:\n%s", out)
+	}
+
+	stdCfg := &StandardConfig{Transport: "local", Preset: &conf.Preset{Name: "mine"}, PresetFile: "/tmp/presets.yaml"}
+	out = buildStandardCLICommand(stdCfg, 0, 0, 0, 0, 0)
+	if !strings.Contains(out, "--preset=mine --preset-file=/tmp/presets.yaml") {
+		t.Errorf("expected the custom preset and its file, got:\n%s", out)
+	}
+	if out := buildStandardCLICommand(&StandardConfig{Transport: "local"}, 0, 0, 0, 0, 0); strings.Contains(out, "--preset") {
+		t.Errorf("expected no --preset without a preset, got:\n%s", out)
+	}
+}
@@ -0,0 +1,106 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// extract package unpacks a DDC archive and restores the files that were stored once
+package extract
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/archive"
+	"github.com/spf13/cobra"
+)
+
+var ExtractCmd = &cobra.Command{
+	Use:   "extract <archive.tgz|directory> [destination]",
+	Short: "Unpack a DDC archive and restore deduplicated files",
+	Long: `Unpack a DDC archive and restore the full per-node layout. A collection run
+with --dedup stores files that were identical across nodes once in the archive and
+lists them in dedup-index.json; extract copies them back to every node directory.
+
+The destination defaults to the archive name without its extension. Given an
+already unpacked directory, extract only restores the deduplicated files.
+
+examples:
+
+	ddc extract diag-20240501-101500.tgz
+	ddc extract diag-20240501-101500.tgz /tmp/diag
+`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(_ *cobra.Command, args []string) error {
+		dest := ""
+		if len(args) == 2 {
+			dest = args[1]
+		}
+		dir, restored, err := Extract(args[0], dest)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("extracted to %v (%d deduplicated files restored)\n", dir, restored)
+		return nil
+	},
+}
+
+// Extract unpacks archivePath into dest (derived from the archive name when
+// empty) and rehydrates it. When archivePath is a directory it is rehydrated in
+// place. It returns the directory and the number of files restored.
+func Extract(archivePath, dest string) (string, int, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return "", 0, err
+	}
+	if info.IsDir() {
+		dest = archivePath
+	} else {
+		if dest == "" {
+			dest = strings.TrimSuffix(strings.TrimSuffix(filepath.Base(archivePath), ".tgz"), ".tar.gz")
+		}
+		if err := os.MkdirAll(dest, helpers.DirPerms); err != nil {
+			return "", 0, err
+		}
+		if err := archive.ExtractTarGz(archivePath, dest); err != nil {
+			return "", 0, fmt.Errorf("unable to extract %v: %w", archivePath, err)
+		}
+	}
+	restored, err := Rehydrate(dest)
+	return dest, restored, err
+}
+
+// Rehydrate restores deduplicated files in dir and in the collection directories
+// directly below it, where the archive places them next to summary.json.
+func Rehydrate(dir string) (int, error) {
+	total, err := helpers.RehydrateTree(dir)
+	if err != nil {
+		return total, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return total, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		n, err := helpers.RehydrateTree(filepath.Join(dir, e.Name()))
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
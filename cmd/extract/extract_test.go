@@ -0,0 +1,70 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extract
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+)
+
+func TestExtractRestoresDeduplicatedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	cs := helpers.NewHCCopyStrategy(helpers.NewRealFileSystem(), &helpers.RealTimeService{}, filepath.Join(tmp, "staging"))
+	cs.Dedup = true
+	conf := []byte("paths.local: /data\n")
+	for _, node := range []string{"node1", "node2"} {
+		dir, err := cs.CreatePath("configuration", node, "executors")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "dremio.conf"), conf, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if index := cs.Deduplicate(nil); index == nil || len(index.Files) != 1 {
+		t.Fatalf("expected dremio.conf stored once, got %+v", index)
+	}
+	archivePath := filepath.Join(tmp, "diag-"+time.Now().Format("20060102-150405")+".tgz")
+	if err := cs.ArchiveDiag("{}", archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := filepath.Join(tmp, "out")
+	dir, restored, err := Extract(archivePath, dest)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if dir != dest || restored != 1 {
+		t.Errorf("expected 1 file restored in %v, got %d in %v", dest, restored, dir)
+	}
+	for _, node := range []string{"node1-E", "node2-E"} {
+		b, err := os.ReadFile(filepath.Join(dest, cs.BaseDir, "configuration", node, "dremio.conf"))
+		if err != nil || string(b) != string(conf) {
+			t.Errorf("%v: expected the config restored, got %q, %v", node, b, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "summary.json")); err != nil {
+		t.Errorf("expected summary.json next to the collection: %v", err)
+	}
+
+	// running it again on the unpacked directory is a no-op
+	if _, restored, err := Extract(dest, ""); err != nil || restored != 0 {
+		t.Errorf("expected nothing left to restore, got %d, %v", restored, err)
+	}
+}
@@ -83,4 +83,18 @@ const (
 	KeyTrackerJSONNumDays         = "tracker-json-num-days"
 	KeyVacuumLogNumDays           = "vacuum-log-num-days"
 	KeyCollectProblematicProfiles = "collect-problematic-profiles"
+	KeyCollectCatalogInventory    = "collect-catalog-inventory"
+	KeyCatalogMaxDepth            = "catalog-max-depth"
+	KeyCatalogMaxItems            = "catalog-max-items"
+	KeyDremioCACert               = "dremio-ca-cert"
+	KeyDremioClientCert           = "dremio-client-cert"
+	KeyDremioClientKey            = "dremio-client-key"
+	KeyHTTPProxy                  = "http-proxy"
+	KeyRocksDBTypes               = "rocksdb-types"
+	KeyRocksDBTypesConfig         = "rocksdb-types-config"
+	KeyHelperTimeoutSeconds       = "helper-timeout-seconds"
+	KeyHelperMemoryLimitMB        = "helper-memory-limit-mb"
+	KeyCollectNMT                 = "collect-nmt"
+	KeyPreset                     = "preset"
+	KeyPresetFile                 = "preset-file"
 )
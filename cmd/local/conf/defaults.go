@@ -15,6 +15,8 @@
 package conf
 
 import (
+	"strings"
+
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
 )
 
@@ -54,6 +56,9 @@ func Defaults(confData map[string]interface{}, hostName string) {
 	setDefault(confData, KeyDisableFreeSpaceCheck, false)
 	setDefault(confData, KeyCollectClusterIDTimeoutSeconds, 60)
 	setDefault(confData, KeyNumberThreads, 1)
+	// Guards for uploaded helper binaries (rocksdb-viewer, asprof)
+	setDefault(confData, KeyHelperTimeoutSeconds, 1800)
+	setDefault(confData, KeyHelperMemoryLimitMB, 0) // 0 = DefaultHelperMemoryLimitMB, cgroup scope only
 }
 
 // DiagnosisCollectionProfile sets defaults for diagnosis mode.
@@ -72,6 +77,7 @@ func DiagnosisCollectionProfile(confData map[string]interface{}, hostName string
 	setDefault(confData, KeyCollectTop, false)
 	setDefault(confData, KeyCollectAsyncProfiler, false)
 	setDefault(confData, KeyCaptureHeapDump, false) // opt-in via --collect-heap-dump
+	setDefault(confData, KeyCollectNMT, false)      // needs -XX:NativeMemoryTracking on the Dremio JVM
 
 	// JVM tool timing — fixed at 60s regardless of defaultCaptureSeconds
 	setDefault(confData, KeyDiagTimeSeconds, 60)
@@ -115,6 +121,7 @@ func StandardCollectionProfile(confData map[string]interface{}, hostName string,
 	setDefault(confData, KeyCollectTop, false)
 	setDefault(confData, KeyCollectAsyncProfiler, false)
 	setDefault(confData, KeyCaptureHeapDump, false)
+	setDefault(confData, KeyCollectNMT, false)
 
 	// JVM tool timing defaults (tools are disabled but values needed if toggled via flags)
 	setDefault(confData, KeyDiagTimeSeconds, defaultCaptureSeconds)
@@ -146,6 +153,11 @@ func StandardCollectionProfile(confData map[string]interface{}, hostName string,
 	// WLM enabled in standard mode (collected via dremio-rocksdb-viewer)
 	setDefault(confData, KeyCollectWLM, true)
 
+	// Catalog, source and reflection inventory over REST (requires a PAT)
+	setDefault(confData, KeyCollectCatalogInventory, true)
+	setDefault(confData, KeyCatalogMaxDepth, 5)
+	setDefault(confData, KeyCatalogMaxItems, 10000)
+
 	// Transfer rate limiting
 	setDefault(confData, KeyDiskBandwidthLimitPct, 20)
 
@@ -209,3 +221,18 @@ func GetStringDefault(m map[string]interface{}, key string) string {
 	}
 	return ""
 }
+
+// GetStringSliceDefault reads a string list from a defaults map, returning nil if missing or wrong type.
+// A comma separated string, as custom presets may give, is split.
+func GetStringSliceDefault(m map[string]interface{}, key string) []string {
+	switch v := m[key].(type) {
+	case []string:
+		return v
+	case string:
+		if v == "" {
+			return nil
+		}
+		return strings.Split(v, ",")
+	}
+	return nil
+}
@@ -89,6 +89,8 @@ func TestSetViperDefaultsWithDiagnosis(t *testing.T) {
 		{conf.KeyAllowInsecureSSL, true},
 		{conf.KeyNodeName, hostName},
 		{conf.KeyAcceptCollectionConsent, true},
+		{conf.KeyHelperTimeoutSeconds, 1800},
+		{conf.KeyHelperMemoryLimitMB, 0},
 		{conf.KeyCollectSystemTablesTimeoutSeconds, 120},
 	}
 
@@ -143,6 +145,11 @@ func TestSetViperDefaultsWithStandard(t *testing.T) {
 		{conf.KeyCollectWLM, true},
 		{conf.KeyCollectKVStoreReport, false},
 
+		// Catalog inventory enabled (PAT-gated at runtime)
+		{conf.KeyCollectCatalogInventory, true},
+		{conf.KeyCatalogMaxDepth, 5},
+		{conf.KeyCatalogMaxItems, 10000},
+
 		// Transfer rate limiting
 		{conf.KeyDiskBandwidthLimitPct, 20},
 
@@ -158,6 +165,8 @@ func TestSetViperDefaultsWithStandard(t *testing.T) {
 		{conf.KeyAllowInsecureSSL, true},
 		{conf.KeyNodeName, hostName},
 		{conf.KeyAcceptCollectionConsent, true},
+		{conf.KeyHelperTimeoutSeconds, 1800},
+		{conf.KeyHelperMemoryLimitMB, 0},
 		{conf.KeyCollectSystemTablesTimeoutSeconds, 120},
 	}
 
@@ -0,0 +1,228 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"sigs.k8s.io/yaml"
+)
+
+// Built-in preset names
+const (
+	PresetOOM            = "oom"
+	PresetPerformance    = "performance"
+	PresetCrash          = "crash"
+	PresetSecurityReview = "security-review"
+	PresetConfigOnly     = "config-only"
+)
+
+// Preset is a targeted collection: the settings it changes are applied first and
+// the profile of its base mode fills every key it leaves alone, so a preset only
+// lists what differs from standard or diagnosis.
+type Preset struct {
+	Name        string                  `json:"name"`
+	Description string                  `json:"description"`
+	Mode        collects.CollectionMode `json:"mode"`
+	Settings    map[string]interface{}  `json:"settings"`
+}
+
+// Profile sets the preset's defaults, like DiagnosisCollectionProfile does for diagnosis mode.
+func (p Preset) Profile(confData map[string]interface{}, hostName string, defaultCaptureSeconds int) {
+	for k, v := range p.Settings {
+		setDefault(confData, k, v)
+	}
+	SetViperDefaults(confData, hostName, defaultCaptureSeconds, p.Mode)
+}
+
+// DefaultMap returns the preset's defaults as a map.
+func (p Preset) DefaultMap() map[string]interface{} {
+	m := make(map[string]interface{})
+	p.Profile(m, "", 0)
+	return m
+}
+
+// allLogsOff turns off every log type both modes know about; presets turn back on what they need.
+func allLogsOff(settings map[string]interface{}) map[string]interface{} {
+	for _, key := range []string{
+		KeyCollectServerLogs, KeyCollectGCLogs, KeyCollectHSErrFiles, KeyCollectQueriesJSON,
+		KeyCollectQueriesPerfJSON, KeyCollectTrackerJSON, KeyCollectVacuumLog, KeyCollectMetaRefreshLog,
+		KeyCollectReflectionLog, KeyCollectAccelerationLog, KeyCollectAccessLog, KeyCollectHiveDeprecatedLog,
+	} {
+		if _, ok := settings[key]; !ok {
+			settings[key] = false
+		}
+	}
+	return settings
+}
+
+// BuiltinPresets returns the presets shipped with ddc.
+func BuiltinPresets() []Preset {
+	return []Preset{
+		{
+			Name:        PresetOOM,
+			Description: "suspected memory leak or OutOfMemoryError: heap dump, NMT, GC logs, hs_err files and JVM flags",
+			Mode:        collects.DiagnosisCollection,
+			Settings: allLogsOff(map[string]interface{}{
+				KeyCaptureHeapDump:   true,
+				KeyCollectNMT:        true,
+				KeyCollectJVMFlags:   true,
+				KeyCollectGCLogs:     true,
+				KeyCollectHSErrFiles: true,
+				KeyCollectServerLogs: true,
+				KeyDremioLogsNumDays: 1,
+				KeyCollectWLM:        false,
+				KeySysTables:         []string{"version", "options"},
+			}),
+		},
+		{
+			Name:        PresetPerformance,
+			Description: "slow query escalation: jstack, top, JFR, async-profiler and the last day of queries.json",
+			Mode:        collects.DiagnosisCollection,
+			Settings: allLogsOff(map[string]interface{}{
+				KeyCollectJStack:              true,
+				KeyCollectTop:                 true,
+				KeyCollectJFR:                 true,
+				KeyCollectAsyncProfiler:       true,
+				KeyCollectQueriesJSON:         true,
+				KeyCollectQueriesPerfJSON:     true,
+				KeyCollectServerLogs:          true,
+				KeyCollectGCLogs:              true,
+				KeyCollectProblematicProfiles: true,
+				KeyDremioLogsNumDays:          1,
+				KeySysTables:                  []string{"version", "options", "reflections", "materializations", "refreshes"},
+			}),
+		},
+		{
+			Name:        PresetCrash,
+			Description: "JVM crash or unexpected restart: hs_err files, server and GC logs and queries.json of the last day",
+			Mode:        collects.DiagnosisCollection,
+			Settings: allLogsOff(map[string]interface{}{
+				KeyCollectHSErrFiles:  true,
+				KeyCollectServerLogs:  true,
+				KeyCollectGCLogs:      true,
+				KeyCollectQueriesJSON: true,
+				KeyDremioLogsNumDays:  1,
+				KeyCollectWLM:         false,
+				KeySysTables:          []string{"version", "options"},
+			}),
+		},
+		{
+			Name:        PresetSecurityReview,
+			Description: "configuration, roles, memberships, privileges, WLM rules and the source inventory (credentials masked), no logs",
+			Mode:        collects.StandardCollection,
+			Settings: allLogsOff(map[string]interface{}{
+				KeyCollectWLM:              true,
+				KeyCollectCatalogInventory: true,
+				KeySysTables:               []string{"version", "roles", "membership", "privileges"},
+			}),
+		},
+		{
+			Name:        PresetConfigOnly,
+			Description: "configuration files, sys.version and sys.options only",
+			Mode:        collects.StandardCollection,
+			Settings: allLogsOff(map[string]interface{}{
+				KeyCollectWLM:              false,
+				KeyCollectCatalogInventory: false,
+				KeySysTables:               []string{"version", "options"},
+			}),
+		},
+	}
+}
+
+// presetFile is the layout of a --preset-file
+type presetFile struct {
+	Presets []Preset `json:"presets"`
+}
+
+// LoadPresets reads custom presets from a YAML or JSON file with a presets list.
+// Settings are keyed by conf key; numbers are read as ints and lists as string
+// lists so they match the built-in defaults.
+func LoadPresets(path string) ([]Preset, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read preset file %v: %w", path, err)
+	}
+	var f presetFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse preset file %v: %w", path, err)
+	}
+	builtin := BuiltinPresets()
+	var names []string
+	for i := range f.Presets {
+		p := &f.Presets[i]
+		if p.Name == "" {
+			return nil, fmt.Errorf("preset %d in %v has no name", i+1, path)
+		}
+		if slices.ContainsFunc(builtin, func(b Preset) bool { return b.Name == p.Name }) || slices.Contains(names, p.Name) {
+			return nil, fmt.Errorf("preset '%v' in %v is already defined", p.Name, path)
+		}
+		names = append(names, p.Name)
+		if p.Mode != collects.StandardCollection && p.Mode != collects.DiagnosisCollection {
+			return nil, fmt.Errorf("preset '%v' in %v: mode must be %v or %v, got '%v'", p.Name, path, collects.StandardCollection, collects.DiagnosisCollection, p.Mode)
+		}
+		for k, v := range p.Settings {
+			value, err := presetValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("preset '%v' in %v: invalid value for '%v': %w", p.Name, path, k, err)
+			}
+			p.Settings[k] = value
+		}
+	}
+	return f.Presets, nil
+}
+
+func presetValue(v interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case string, bool:
+		return val, nil
+	case float64:
+		if val != float64(int(val)) {
+			return nil, fmt.Errorf("expected a whole number, got %v", val)
+		}
+		return int(val), nil
+	case []interface{}:
+		list := make([]string, 0, len(val))
+		for _, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, got %v", item)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("expected a string, number, boolean or list but got %T", v)
+	}
+}
+
+// FindPreset returns the built-in or custom preset with the given name.
+func FindPreset(name string, custom []Preset) (Preset, error) {
+	all := append(BuiltinPresets(), custom...)
+	for _, p := range all {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	names := make([]string, 0, len(all))
+	for _, p := range all {
+		names = append(names, p.Name)
+	}
+	return Preset{}, fmt.Errorf("unknown preset '%v': available presets are %v", name, names)
+}
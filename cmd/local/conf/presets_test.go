@@ -0,0 +1,139 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conf_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+)
+
+func TestPresetDefaultMap(t *testing.T) {
+	oom, err := conf.FindPreset(conf.PresetOOM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := oom.DefaultMap()
+	for _, key := range []string{conf.KeyCaptureHeapDump, conf.KeyCollectNMT, conf.KeyCollectGCLogs, conf.KeyCollectHSErrFiles, conf.KeyCollectJVMFlags} {
+		if !conf.GetBoolDefault(m, key) {
+			t.Errorf("expected %v on for the oom preset", key)
+		}
+	}
+	if conf.GetBoolDefault(m, conf.KeyCollectQueriesJSON) || conf.GetBoolDefault(m, conf.KeyCollectJFR) {
+		t.Error("expected queries.json and JFR off for the oom preset")
+	}
+	if got := conf.GetIntDefault(m, conf.KeyDremioLogsNumDays); got != 1 {
+		t.Errorf("expected 1 day of logs, got %v", got)
+	}
+	// keys the preset leaves alone come from its base mode
+	diag := conf.DiagnosisDefaultMap()
+	if m[conf.KeyDiagTimeSeconds] != diag[conf.KeyDiagTimeSeconds] || m[conf.KeyDremioLogDir] != diag[conf.KeyDremioLogDir] {
+		t.Error("expected the diagnosis defaults for keys the preset does not set")
+	}
+
+	perf, err := conf.FindPreset(conf.PresetPerformance, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m = perf.DefaultMap()
+	for _, key := range []string{conf.KeyCollectJStack, conf.KeyCollectTop, conf.KeyCollectJFR, conf.KeyCollectAsyncProfiler, conf.KeyCollectQueriesJSON} {
+		if !conf.GetBoolDefault(m, key) {
+			t.Errorf("expected %v on for the performance preset", key)
+		}
+	}
+	if conf.GetBoolDefault(m, conf.KeyCaptureHeapDump) || conf.GetBoolDefault(m, conf.KeyCollectAccessLog) {
+		t.Error("expected no heap dump and no access log for the performance preset")
+	}
+}
+
+func TestBuiltinPresetModes(t *testing.T) {
+	want := map[string]collects.CollectionMode{
+		conf.PresetOOM:            collects.DiagnosisCollection,
+		conf.PresetPerformance:    collects.DiagnosisCollection,
+		conf.PresetCrash:          collects.DiagnosisCollection,
+		conf.PresetSecurityReview: collects.StandardCollection,
+		conf.PresetConfigOnly:     collects.StandardCollection,
+	}
+	presets := conf.BuiltinPresets()
+	if len(presets) != len(want) {
+		t.Fatalf("expected %d presets, got %d", len(want), len(presets))
+	}
+	for _, p := range presets {
+		if want[p.Name] != p.Mode {
+			t.Errorf("expected %v to be a %v preset, got %v", p.Name, want[p.Name], p.Mode)
+		}
+	}
+	cfg, _ := conf.FindPreset(conf.PresetConfigOnly, nil)
+	if tables := conf.GetStringSliceDefault(cfg.DefaultMap(), conf.KeySysTables); !slices.Equal(tables, []string{"version", "options"}) {
+		t.Errorf("expected only sys.version and sys.options, got %v", tables)
+	}
+}
+
+func TestLoadPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.yaml")
+	content := `presets:
+  - name: reflections
+    description: reflection refresh problems
+    mode: diagnosis
+    settings:
+      collect-reflection-log: true
+      dremio-logs-num-days: 2
+      system-tables: [version, reflections]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	presets, err := conf.LoadPresets(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := conf.FindPreset("reflections", presets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := p.DefaultMap()
+	if !conf.GetBoolDefault(m, conf.KeyCollectReflectionLog) || conf.GetIntDefault(m, conf.KeyDremioLogsNumDays) != 2 {
+		t.Errorf("expected the custom settings, got %v %v", m[conf.KeyCollectReflectionLog], m[conf.KeyDremioLogsNumDays])
+	}
+	if tables := conf.GetStringSliceDefault(m, conf.KeySysTables); !slices.Equal(tables, []string{"version", "reflections"}) {
+		t.Errorf("unexpected system tables %v", tables)
+	}
+
+	for content, wantErr := range map[string]string{
+		"presets:\n  - name: oom\n    mode: diagnosis\n":                                              "already defined",
+		"presets:\n  - name: x\n    mode: everything\n":                                               "mode must be",
+		"presets:\n  - mode: standard\n":                                                              "has no name",
+		"presets:\n  - name: x\n    mode: standard\n    settings:\n      dremio-logs-num-days: 1.5\n": "whole number",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conf.LoadPresets(path); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("expected an error containing %q for %q, got %v", wantErr, content, err)
+		}
+	}
+}
+
+func TestFindPresetUnknown(t *testing.T) {
+	_, err := conf.FindPreset("nope", nil)
+	if err == nil || !strings.Contains(err.Error(), conf.PresetSecurityReview) {
+		t.Errorf("expected the available presets listed, got %v", err)
+	}
+}
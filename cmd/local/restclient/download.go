@@ -0,0 +1,232 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package restclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// maxDownloadAttempts bounds how many times an interrupted download is resumed.
+const maxDownloadAttempts = 5
+
+// ProgressFunc receives the bytes written so far and the expected total
+// (0 when the server did not send a Content-Length).
+type ProgressFunc func(written, total int64)
+
+// statusError is returned for non-success HTTP status codes; these are never retried.
+type statusError struct {
+	status string
+}
+
+func (s statusError) Error() string {
+	return s.status
+}
+
+// downloader holds the state of a single DownloadToFile call across resume attempts.
+type downloader struct {
+	hook     shutdown.CancelHook
+	url      string
+	pat      string
+	request  string
+	headers  map[string]string
+	file     *os.File
+	progress ProgressFunc
+	written  int64
+	total    int64 // -1 when unknown
+}
+
+// Write implements io.Writer so the response body is copied straight to disk
+// while the byte counter and progress callback are kept up to date.
+func (d *downloader) Write(p []byte) (int, error) {
+	n, err := d.file.Write(p)
+	d.written += int64(n)
+	if d.progress != nil {
+		total := d.total
+		if total < 0 {
+			total = 0
+		}
+		d.progress(d.written, total)
+	}
+	return n, err
+}
+
+// DownloadToFile performs a REST request and streams the response body to outFile
+// without buffering it in memory. The number of bytes written is checked against
+// Content-Length, and when a transfer is interrupted the download is resumed with
+// a Range request if the server supports it (otherwise it is restarted from zero
+// only when the server ignores the range). On failure the partial file is removed.
+func DownloadToFile(hook shutdown.CancelHook, url, pat, request string, headers map[string]string, outFile string, progress ProgressFunc) (int64, error) {
+	if client == nil {
+		return 0, errors.New("critical error call InitClient first")
+	}
+	f, err := os.OpenFile(outFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("unable to create %v: %w", outFile, err)
+	}
+	d := &downloader{
+		hook:     hook,
+		url:      url,
+		pat:      pat,
+		request:  request,
+		headers:  headers,
+		file:     f,
+		progress: progress,
+		total:    -1,
+	}
+	written, err := d.run()
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("unable to close %v: %w", outFile, closeErr)
+	}
+	if err != nil {
+		if removeErr := os.Remove(outFile); removeErr != nil {
+			simplelog.Warningf("unable to remove partial download %v: %v", outFile, removeErr)
+		}
+		return written, err
+	}
+	return written, nil
+}
+
+func (d *downloader) run() (int64, error) {
+	for attempt := 1; ; attempt++ {
+		resumable, err := d.fetch()
+		if err == nil {
+			if d.total < 0 || d.written == d.total {
+				simplelog.Debugf("downloaded %d bytes from %s", d.written, d.url)
+				return d.written, nil
+			}
+			err = fmt.Errorf("incomplete download from %v: received %d of %d bytes", d.url, d.written, d.total)
+		}
+		var sErr statusError
+		if errors.As(err, &sErr) || !resumable || attempt >= maxDownloadAttempts || d.hook.GetContext().Err() != nil {
+			return d.written, err
+		}
+		simplelog.Warningf("download from %v interrupted at %d bytes (attempt %d/%d), resuming: %v", d.url, d.written, attempt, maxDownloadAttempts, err)
+	}
+}
+
+// fetch issues one request, resuming from d.written when it is non-zero, and copies
+// the body to disk. It reports whether the server supports byte ranges.
+func (d *downloader) fetch() (bool, error) {
+	// making sure the global timeout does not get overridden
+	ctx, timeout := context.WithTimeoutCause(d.hook.GetContext(), client.Timeout, fmt.Errorf("download from url %v exceeded timeout %v", d.url, client.Timeout))
+	defer timeout()
+	req, err := http.NewRequestWithContext(ctx, d.request, d.url, nil)
+	if err != nil {
+		return false, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+d.pat)
+	for key, value := range d.headers {
+		req.Header.Set(key, value)
+	}
+	if d.written > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.written))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return d.written > 0, context.Cause(ctx)
+		}
+		return d.written > 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	resumable := res.Header.Get("Accept-Ranges") == "bytes"
+	switch res.StatusCode {
+	case http.StatusOK:
+		if d.written > 0 {
+			// the server ignored the range so start over
+			simplelog.Warningf("server for %v does not support ranges, restarting download", d.url)
+			if err := d.restart(); err != nil {
+				return false, err
+			}
+		}
+		if res.ContentLength >= 0 {
+			d.total = res.ContentLength
+		}
+	case http.StatusPartialContent:
+		resumable = true
+		start, total, err := parseContentRange(res.Header.Get("Content-Range"))
+		if err != nil {
+			return false, err
+		}
+		if start != d.written {
+			return false, fmt.Errorf("server resumed %v at byte %d but %d bytes were already written", d.url, start, d.written)
+		}
+		if total >= 0 {
+			d.total = total
+		}
+	default:
+		return false, statusError{status: res.Status}
+	}
+
+	if _, err := io.Copy(d, res.Body); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return resumable, context.Cause(ctx)
+		}
+		return resumable, err
+	}
+	return resumable, nil
+}
+
+func (d *downloader) restart() error {
+	if err := d.file.Truncate(0); err != nil {
+		return fmt.Errorf("unable to truncate %v: %w", d.file.Name(), err)
+	}
+	if _, err := d.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("unable to rewind %v: %w", d.file.Name(), err)
+	}
+	d.written = 0
+	d.total = -1
+	return nil
+}
+
+// parseContentRange parses a "bytes start-end/total" header, returning -1 for an unknown ("*") total.
+func parseContentRange(header string) (start int64, total int64, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !ok {
+		return 0, 0, fmt.Errorf("unsupported Content-Range %q", header)
+	}
+	rangePart, totalPart, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	startPart, _, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+	start, err = strconv.ParseInt(startPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", header, err)
+	}
+	if totalPart == "*" {
+		return start, -1, nil
+	}
+	total, err = strconv.ParseInt(totalPart, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid Content-Range %q: %w", header, err)
+	}
+	return start, total, nil
+}
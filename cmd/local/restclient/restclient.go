@@ -17,34 +17,108 @@ package restclient
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"golang.org/x/net/http/httpproxy"
 )
 
 var client *http.Client
 
-func InitClient(allowInsecureSSL bool, restHTTPTimeout int) {
-	tr := &http.Transport{
+// ClientConfig describes how REST calls to Dremio are secured and routed. It is
+// shared by the PAT pre-check and every API collection so they behave the same.
+type ClientConfig struct {
+	AllowInsecureSSL bool
+	CACertFile       string // PEM bundle used to verify the Dremio server certificate
+	ClientCertFile   string // PEM client certificate for mutual TLS
+	ClientKeyFile    string // PEM private key matching ClientCertFile
+	HTTPProxy        string // explicit proxy URL; HTTPS_PROXY/HTTP_PROXY are used when empty
+}
+
+// NewTransport builds an http.Transport from the client configuration. Proxies
+// always honour NO_PROXY, an explicit HTTPProxy replaces HTTPS_PROXY/HTTP_PROXY.
+func NewTransport(cfg ClientConfig) (*http.Transport, error) {
+	//nolint:all
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.AllowInsecureSSL}
+	if cfg.CACertFile != "" {
+		pem, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificate %v: %w", cfg.CACertFile, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in %v", cfg.CACertFile)
+		}
+		tlsConfig.RootCAs = pool
+		if cfg.AllowInsecureSSL {
+			simplelog.Warningf("CA certificate %v was provided but insecure SSL is allowed so it will not be used to verify the server", cfg.CACertFile)
+		}
+	}
+	if cfg.ClientCertFile != "" || cfg.ClientKeyFile != "" {
+		if cfg.ClientCertFile == "" || cfg.ClientKeyFile == "" {
+			return nil, errors.New("both a client certificate and a client key are required for mutual TLS")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCertFile, cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate %v and key %v: %w", cfg.ClientCertFile, cfg.ClientKeyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	proxyConfig := httpproxy.FromEnvironment()
+	if cfg.HTTPProxy != "" {
+		if _, err := url.Parse(cfg.HTTPProxy); err != nil {
+			return nil, fmt.Errorf("invalid proxy url %v: %w", cfg.HTTPProxy, err)
+		}
+		proxyConfig.HTTPProxy = cfg.HTTPProxy
+		proxyConfig.HTTPSProxy = cfg.HTTPProxy
+	}
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	return &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		},
 		MaxIdleConns:          10,
 		IdleConnTimeout:       time.Duration(30) * time.Second,
 		ResponseHeaderTimeout: time.Duration(30) * time.Second,
 		TLSHandshakeTimeout:   time.Duration(30) * time.Second,
 		ExpectContinueTimeout: time.Duration(30) * time.Second,
-		//nolint:all
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: allowInsecureSSL},
+		TLSClientConfig:       tlsConfig,
+	}, nil
+}
+
+// InitClient initializes the shared client with only the insecure SSL toggle set.
+func InitClient(allowInsecureSSL bool, restHTTPTimeout int) {
+	if err := InitClientWithConfig(ClientConfig{AllowInsecureSSL: allowInsecureSSL}, restHTTPTimeout); err != nil {
+		simplelog.Errorf("unable to initialize REST client: %v", err)
+	}
+}
+
+// InitClientWithConfig initializes the shared client used by APIRequest, PostQuery and DownloadToFile.
+func InitClientWithConfig(cfg ClientConfig, restHTTPTimeout int) error {
+	tr, err := NewTransport(cfg)
+	if err != nil {
+		return err
 	}
 	client = &http.Client{
 		Transport: tr,
 		Timeout:   time.Duration(restHTTPTimeout) * time.Second,
 	}
+	return nil
 }
 
 func APIRequest(hook shutdown.CancelHook, url string, pat string, request string, headers map[string]string) ([]byte, error) {
@@ -15,9 +15,12 @@
 package restclient
 
 import (
+	"encoding/pem"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -105,3 +108,208 @@ func TestClientTimeout(t *testing.T) {
 		t.Fatalf("Expected timeout error, got %v", err)
 	}
 }
+
+func TestDownloadToFile(t *testing.T) {
+	payload := strings.Repeat("profile-bytes-", 1000)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer token" {
+			rw.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		rw.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(payload))
+	}))
+	defer server.Close()
+
+	InitClient(true, 10)
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	outFile := filepath.Join(t.TempDir(), "out.zip")
+	var lastWritten, lastTotal int64
+	n, err := DownloadToFile(hook, server.URL, "token", "GET", map[string]string{}, outFile, func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Errorf("expected %d bytes, got %d", len(payload), n)
+	}
+	if lastWritten != int64(len(payload)) || lastTotal != int64(len(payload)) {
+		t.Errorf("expected final progress %d/%d, got %d/%d", len(payload), len(payload), lastWritten, lastTotal)
+	}
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("unable to read output: %v", err)
+	}
+	if string(data) != payload {
+		t.Error("downloaded content does not match payload")
+	}
+}
+
+func TestDownloadToFileResumesWithRange(t *testing.T) {
+	payload := strings.Repeat("0123456789", 1000)
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		requests = append(requests, req.Header.Get("Range"))
+		rw.Header().Set("Accept-Ranges", "bytes")
+		if rangeHeader := req.Header.Get("Range"); rangeHeader != "" {
+			var start int
+			if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			rw.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload)))
+			rw.Header().Set("Content-Length", fmt.Sprint(len(payload)-start))
+			rw.WriteHeader(http.StatusPartialContent)
+			_, _ = rw.Write([]byte(payload[start:]))
+			return
+		}
+		// first request: advertise the full length but drop the connection half way
+		rw.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte(payload[:len(payload)/2]))
+		rw.(http.Flusher).Flush()
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	InitClient(true, 10)
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	outFile := filepath.Join(t.TempDir(), "out.zip")
+	n, err := DownloadToFile(hook, server.URL, "token", "GET", map[string]string{}, outFile, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != int64(len(payload)) {
+		t.Errorf("expected %d bytes, got %d", len(payload), n)
+	}
+	if len(requests) != 2 || requests[1] != fmt.Sprintf("bytes=%d-", len(payload)/2) {
+		t.Errorf("expected a single resumed range request, got %v", requests)
+	}
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatalf("unable to read output: %v", err)
+	}
+	if string(data) != payload {
+		t.Error("resumed content does not match payload")
+	}
+}
+
+func TestDownloadToFileShortBodyWithoutRanges(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Length", "100")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("short"))
+		rw.(http.Flusher).Flush()
+		conn, _, err := rw.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer server.Close()
+
+	InitClient(true, 10)
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	outFile := filepath.Join(t.TempDir(), "out.zip")
+	if _, err := DownloadToFile(hook, server.URL, "token", "GET", map[string]string{}, outFile, nil); err == nil {
+		t.Fatal("Expected error for truncated body, got nil")
+	}
+	if _, err := os.Stat(outFile); !os.IsNotExist(err) {
+		t.Errorf("expected partial file to be removed, stat returned %v", err)
+	}
+}
+
+func TestDownloadToFileBadStatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	InitClient(true, 10)
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	_, err := DownloadToFile(hook, server.URL, "token", "GET", map[string]string{}, filepath.Join(t.TempDir(), "out.zip"), nil)
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "404") {
+		t.Fatalf("Expected '404' in error message, got %v", err)
+	}
+}
+
+func TestInitClientWithConfigVerifiesCustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatalf("unable to write CA file: %v", err)
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	// without the CA verification must fail
+	if err := InitClientWithConfig(ClientConfig{}, 10); err != nil {
+		t.Fatalf("unexpected init error %v", err)
+	}
+	if _, err := APIRequest(hook, server.URL, "token", "GET", map[string]string{}); err == nil {
+		t.Error("expected certificate verification failure without a CA")
+	}
+
+	if err := InitClientWithConfig(ClientConfig{CACertFile: caFile}, 10); err != nil {
+		t.Fatalf("unexpected init error %v", err)
+	}
+	if _, err := APIRequest(hook, server.URL, "token", "GET", map[string]string{}); err != nil {
+		t.Errorf("expected request verified by custom CA to succeed, got %v", err)
+	}
+}
+
+func TestNewTransportRejectsBadTLSFiles(t *testing.T) {
+	if _, err := NewTransport(ClientConfig{CACertFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+	if _, err := NewTransport(ClientConfig{ClientCertFile: "client.pem"}); err == nil {
+		t.Error("expected error when the client key is missing")
+	}
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	if _, err := NewTransport(ClientConfig{CACertFile: notPEM}); err == nil {
+		t.Error("expected error for a CA file without certificates")
+	}
+}
+
+func TestNewTransportExplicitProxy(t *testing.T) {
+	t.Setenv("NO_PROXY", "internal.example.com")
+	tr, err := NewTransport(ClientConfig{HTTPProxy: "http://proxy.example.com:3128"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	req, _ := http.NewRequest("GET", "https://dremio.example.com:9047/api/v3/catalog", nil)
+	proxyURL, err := tr.Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected proxy error %v", err)
+	}
+	if proxyURL == nil || proxyURL.Host != "proxy.example.com:3128" {
+		t.Errorf("expected explicit proxy to be used, got %v", proxyURL)
+	}
+	req, _ = http.NewRequest("GET", "https://internal.example.com:9047/api/v3/catalog", nil)
+	proxyURL, err = tr.Proxy(req)
+	if err != nil {
+		t.Fatalf("unexpected proxy error %v", err)
+	}
+	if proxyURL != nil {
+		t.Errorf("expected NO_PROXY host to bypass the proxy, got %v", proxyURL)
+	}
+}
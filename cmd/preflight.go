@@ -0,0 +1,186 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/docker"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubectl"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubernetes"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/local"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/ssh"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	preflightRun  bool
+	preflightJSON bool
+)
+
+var PreflightCmd = &cobra.Command{
+	Use:   "preflight",
+	Short: "Check that every node is ready for a collection, without changing anything",
+	Long: `Run the discovery steps of a collection on every node and report what would
+work: connectivity, sudo (ssh) or RBAC (k8s), log and configuration discovery,
+sha256sum, gzip and tar, the Dremio PID, jcmd attachability, free space in /tmp for
+a heap dump of -Xmx and the RocksDB catalog on coordinators. Only read-only commands
+run on the nodes: nothing is uploaded, started or written.
+
+ddc preflight <transport> [standard|diagnosis] is ddc collect <transport> <mode>
+--preflight: it takes every flag, preset and --config setting of that collection
+(diagnosis when no mode is given) and sizes the files it would pull with them.
+The report is a pass/warn/fail matrix followed by the files and bytes of each log
+category. A fail exits non-zero.
+
+examples:
+
+	ddc preflight ssh --coordinator 10.0.0.1 --executors 10.0.0.2,10.0.0.3 --ssh-user ubuntu --ssh-key ~/.ssh/id_rsa
+	ddc preflight k8s standard --namespace dremio --json
+`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		return cmd.Help()
+	},
+}
+
+// preflightCollectArgs rewrites "ddc preflight <transport> [mode] ..." into the
+// "ddc collect <transport> <mode> --preflight ..." it stands for, so preflight is
+// dispatched, defaulted and configured like the collection it checks. Other
+// command lines are returned as they are.
+func preflightCollectArgs(args []string) []string {
+	if len(args) < 3 || args[1] != "preflight" {
+		return args
+	}
+	transport := args[2]
+	switch transport {
+	case "ssh", "k8s", "local", "local-k8s", "docker":
+	default:
+		return args
+	}
+	rest := args[3:]
+	mode := "diagnosis"
+	if len(rest) > 0 && (rest[0] == "standard" || rest[0] == "diagnosis") {
+		mode = rest[0]
+		rest = rest[1:]
+	}
+	rewritten := []string{args[0], "collect", transport, mode, "--preflight"}
+	return append(rewritten, rest...)
+}
+
+// runPreflight builds the collector of the transport the way RemoteCollect does,
+// without a debug container, checks every node with it and writes the report
+// to the output of RootCmd as a table or, with --json, as JSON. It returns an
+// error when any check failed.
+func runPreflight(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs kubernetes.KubeArgs, dockerArgs docker.Args, fallbackEnabled bool, hook shutdown.Hook) error {
+	var c collection.Collector
+	var cluster []collection.PreflightCheck
+	var nodeCheck collection.NodeCheck
+	switch {
+	case fallbackEnabled:
+		c = local.NewLocalCollector(hook, filepath.Join(dremioConfDir, "dremio.conf"), dremioHome)
+	case transportCmd == "docker":
+		dockerActions, err := docker.NewCmdDockerActions(dockerArgs, hook)
+		if err != nil {
+			return err
+		}
+		c = dockerActions
+	case kubeArgs.Namespace != "":
+		// preflight never attaches ephemeral containers, but checks it may
+		noDebug := kubeArgs
+		noDebug.DebugImage = ""
+		k8sActions, err := kubernetes.NewK8sAPI(noDebug, hook)
+		if err != nil {
+			return err
+		}
+		c = k8sActions
+		if enableKubeCtl {
+			potentialStrategy, err := kubectl.NewKubectlK8sActions(hook, noDebug)
+			if err != nil {
+				simplelog.Warningf("kubectl not available, using embedded k8s api: %v", err)
+			} else {
+				c = potentialStrategy
+			}
+		}
+		cluster = append(cluster, preflightRBAC(kubeArgs))
+	default:
+		if err := validateSSHParameters(sshArgs); err != nil {
+			return fmt.Errorf("invalid command flag detected: %w", err)
+		}
+		c = ssh.NewCmdSSHActions(sshArgs, hook)
+		if sshArgs.SudoUser != "" {
+			nodeCheck = func(host string) []collection.PreflightCheck {
+				check := collection.PreflightCheck{Name: collection.PreflightCheckSudo, Status: collection.PreflightPass, Detail: "sudo -u " + sshArgs.SudoUser}
+				if err := ssh.CheckSudo(host, sshArgs.SSHUser, sshArgs.SSHKeyLoc, sshArgs.SudoUser, 5*time.Second); err != nil {
+					check.Status = collection.PreflightFail
+					check.Detail = err.Error()
+				}
+				return []collection.PreflightCheck{check}
+			}
+		}
+	}
+
+	report, err := collection.RunPreflight(c, collectionArgs, nodeCheck)
+	if err != nil {
+		return err
+	}
+	report.Cluster = cluster
+	w := RootCmd.OutOrStdout()
+	if preflightJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else if err := report.WriteTable(w); err != nil {
+		return err
+	}
+	if report.Failed() {
+		return fmt.Errorf("preflight failed: fix the checks marked fail before collecting")
+	}
+	return nil
+}
+
+// preflightRBAC checks every permission the K8s collection uses: a missing
+// required one fails, a missing one that only skips part of the collection warns.
+func preflightRBAC(kubeArgs kubernetes.KubeArgs) collection.PreflightCheck {
+	check := collection.PreflightCheck{Name: collection.PreflightCheckRBAC, Status: collection.PreflightPass, Detail: "every permission the collection uses in " + kubeArgs.Namespace}
+	missing, err := kubernetes.CheckRBAC(kubeArgs)
+	if err != nil {
+		check.Status = collection.PreflightFail
+		check.Detail = err.Error()
+		return check
+	}
+	if len(missing) == 0 {
+		return check
+	}
+	check.Status = collection.PreflightWarn
+	var details []string
+	for _, p := range missing {
+		if p.Required {
+			check.Status = collection.PreflightFail
+		}
+		details = append(details, fmt.Sprintf("missing %v (%v)", p, p.Purpose))
+	}
+	check.Detail = strings.Join(details, ", ")
+	return check
+}
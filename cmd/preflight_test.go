@@ -0,0 +1,129 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+)
+
+func TestPreflightCollectArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"ddc", "preflight", "k8s", "--namespace", "dremio"}, []string{"ddc", "collect", "k8s", "diagnosis", "--preflight", "--namespace", "dremio"}},
+		{[]string{"ddc", "preflight", "ssh", "standard", "--json"}, []string{"ddc", "collect", "ssh", "standard", "--preflight", "--json"}},
+		{[]string{"ddc", "preflight"}, []string{"ddc", "preflight"}},
+		{[]string{"ddc", "preflight", "--help"}, []string{"ddc", "preflight", "--help"}},
+		{[]string{"ddc", "collect", "ssh", "standard"}, []string{"ddc", "collect", "ssh", "standard"}},
+	}
+	for _, tt := range tests {
+		if got := preflightCollectArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("preflightCollectArgs(%v) = %v, want %v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestLocalPreflightJSON(t *testing.T) {
+	tmp := t.TempDir()
+	logDir := filepath.Join(tmp, "log")
+	confDir := filepath.Join(tmp, "conf")
+	for dir, files := range map[string]map[string]string{
+		logDir:  {"server.log": "2026-03-20 14:05:00,000 [main] INFO started\n", "tracker.json": "{}\n"},
+		confDir: {"dremio.conf": "paths.local: " + filepath.Join(tmp, "data") + "\n"},
+	} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	oldTracker, oldConfDir, oldLogDir, oldOutput := collectTrackerJSON, dremioConfDir, localLogDir, outputLoc
+	defer func() {
+		preflightRun, preflightJSON, enableFallback = false, false, false
+		collectTrackerJSON, dremioConfDir, localLogDir, outputLoc = oldTracker, oldConfDir, oldLogDir, oldOutput
+		RootCmd.SetOut(nil)
+	}()
+
+	preflight := func(args ...string) collection.PreflightReport {
+		t.Helper()
+		var out bytes.Buffer
+		RootCmd.SetOut(&out)
+		base := []string{"ddc", "preflight", "local"}
+		base = append(base, args...)
+		base = append(base, "--local-log-dir", logDir, "--dremio-conf-dir", confDir, "--output-file", filepath.Join(tmp, "out.tgz"), "--json")
+		if err := Execute(base); err != nil {
+			t.Fatalf("unexpected error %v: %v", err, out.String())
+		}
+		var report collection.PreflightReport
+		if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+			t.Fatalf("expected a JSON report, got %q: %v", out.String(), err)
+		}
+		if len(report.Nodes) != 1 {
+			t.Fatalf("expected this node only, got %+v", report.Nodes)
+		}
+		return report
+	}
+	categories := func(report collection.PreflightReport) map[string]collection.PreflightCategory {
+		sizes := make(map[string]collection.PreflightCategory)
+		for _, cat := range report.Nodes[0].Categories {
+			sizes[cat.Category] = cat
+		}
+		return sizes
+	}
+
+	report := preflight()
+	sizes := categories(report)
+	if got := sizes["server"]; got.Files != 1 || got.Bytes != 44 {
+		t.Errorf("unexpected server log size %+v", got)
+	}
+	if got := sizes["tracker"]; got.Files != 1 {
+		t.Errorf("unexpected tracker.json size %+v", got)
+	}
+	if got := sizes["config"]; got.Files != 1 {
+		t.Errorf("unexpected config size %+v", got)
+	}
+	for _, c := range report.Nodes[0].Checks {
+		if c.Name == "discovery" && c.Status != collection.PreflightPass {
+			t.Errorf("expected discovery to pass with the given directories, got %+v", c)
+		}
+	}
+
+	// the collect flags of the mode size the report
+	sizes = categories(preflight("standard", "--collect-tracker-json=false"))
+	if got, ok := sizes["tracker"]; ok {
+		t.Errorf("expected tracker.json left out with --collect-tracker-json=false, got %+v", got)
+	}
+	if got := sizes["server"]; got.Files != 1 {
+		t.Errorf("unexpected server log size %+v", got)
+	}
+
+	if entries, err := os.ReadDir(logDir); err != nil || len(entries) != 2 {
+		t.Errorf("expected the log directory left as it was, got %v, %v", entries, err)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "out.tgz")); !os.IsNotExist(err) {
+		t.Errorf("expected no archive written by preflight, got %v", err)
+	}
+}
@@ -0,0 +1,176 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/spf13/pflag"
+)
+
+// activePreset is the preset selected with --preset or in the TUI, nil for plain mode defaults
+var activePreset *conf.Preset
+
+// presetFlagKeys maps the flags whose name differs from the conf key they set
+var presetFlagKeys = map[string]string{
+	"diag-jfr":            conf.KeyCollectJFR,
+	"diag-jstack":         conf.KeyCollectJStack,
+	"diag-top":            conf.KeyCollectTop,
+	"diag-heap-dump":      conf.KeyCaptureHeapDump,
+	"diag-async-profiler": conf.KeyCollectAsyncProfiler,
+	"diag-nmt":            conf.KeyCollectNMT,
+	"days":                conf.KeyDremioLogsNumDays,
+}
+
+// flagConfKey returns the conf key a flag sets
+func flagConfKey(flagName string) string {
+	if key, ok := presetFlagKeys[flagName]; ok {
+		return key
+	}
+	return flagName
+}
+
+// presetFlagValue renders a preset default the way it is passed on the command line
+func presetFlagValue(v interface{}) string {
+	switch val := v.(type) {
+	case bool:
+		return strconv.FormatBool(val)
+	case int:
+		return strconv.Itoa(val)
+	case []string:
+		return strings.Join(val, ",")
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// presetFlagDefaults returns the flag values a preset changes, keyed by flag name.
+// Settings without a flag (e.g. collect-jvm-flags) only reach the conf map.
+func presetFlagDefaults(flags *pflag.FlagSet, p conf.Preset) map[string]string {
+	defaults := make(map[string]string)
+	flags.VisitAll(func(f *pflag.Flag) {
+		if v, ok := p.Settings[flagConfKey(f.Name)]; ok {
+			defaults[f.Name] = presetFlagValue(v)
+		}
+	})
+	return defaults
+}
+
+// loadCustomPresets reads --preset-file and checks that every setting names a
+// collect flag, by flag name (diag-jstack) or by conf key (collect-jstack).
+func loadCustomPresets(path string) ([]conf.Preset, error) {
+	if path == "" {
+		return nil, nil
+	}
+	presets, err := conf.LoadPresets(path)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	for name := range collectFlagNames() {
+		known[flagConfKey(name)] = true
+	}
+	for _, p := range presets {
+		for k, v := range p.Settings {
+			key := flagConfKey(k)
+			if !known[key] || key == conf.KeyPreset || key == conf.KeyPresetFile || key == configFlagName {
+				return nil, fmt.Errorf("preset '%v' in %v: unknown setting '%v': settings are collect flag names without the leading --", p.Name, path, k)
+			}
+			if key != k {
+				delete(p.Settings, k)
+				p.Settings[key] = v
+			}
+		}
+	}
+	return presets, nil
+}
+
+// resolvePreset finds a preset and checks that it matches the collection mode
+func resolvePreset(name, path string, mode collects.CollectionMode) (*conf.Preset, error) {
+	custom, err := loadCustomPresets(path)
+	if err != nil {
+		return nil, err
+	}
+	p, err := conf.FindPreset(name, custom)
+	if err != nil {
+		return nil, err
+	}
+	if p.Mode != mode {
+		return nil, fmt.Errorf("preset '%v' is a %v preset: use 'ddc collect <transport> %v --preset %v'", p.Name, p.Mode, p.Mode, p.Name)
+	}
+	return &p, nil
+}
+
+// presetsForMode lists the built-in and --preset-file presets of a mode, for the TUI
+func presetsForMode(mode collects.CollectionMode) ([]conf.Preset, error) {
+	custom, err := loadCustomPresets(presetFile)
+	if err != nil {
+		return nil, err
+	}
+	var presets []conf.Preset
+	for _, p := range append(conf.BuiltinPresets(), custom...) {
+		if p.Mode == mode {
+			presets = append(presets, p)
+		}
+	}
+	return presets, nil
+}
+
+// configSetting returns a flag's value from the command line, the environment or
+// the config file, in that order, before the flags are filled in.
+func configSetting(flags *pflag.FlagSet, file map[string]string, name string) string {
+	if f := flags.Lookup(name); f != nil && f.Changed {
+		return f.Value.String()
+	}
+	if v, ok := os.LookupEnv(configEnvName(name)); ok {
+		return v
+	}
+	return file[name]
+}
+
+// applyPresetFlags sets the defaults of the active preset on the flags of the
+// transport's mode command, for the TUI where the mode is picked interactively.
+// Flags passed explicitly keep their value.
+func applyPresetFlags(transport string) error {
+	if activePreset == nil {
+		return nil
+	}
+	cmd, _, err := CollectCmd.Find([]string{transport, string(activePreset.Mode)})
+	if err != nil {
+		return err
+	}
+	flags := buildMergedFlagSet(cmd)
+	for name, value := range presetFlagDefaults(flags, *activePreset) {
+		if f := flags.Lookup(name); !f.Changed {
+			if err := f.Value.Set(value); err != nil {
+				return fmt.Errorf("preset '%v': invalid value for --%v: %w", activePreset.Name, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// presetNameOf returns the preset name recorded in summary.json, empty without a preset
+func presetNameOf(p *conf.Preset) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/spf13/pflag"
+)
+
+func TestResolvePresetModeMismatch(t *testing.T) {
+	if _, err := resolvePreset(conf.PresetOOM, "", collects.StandardCollection); err == nil || !strings.Contains(err.Error(), "diagnosis --preset oom") {
+		t.Errorf("expected a mode mismatch error, got %v", err)
+	}
+	p, err := resolvePreset(conf.PresetOOM, "", collects.DiagnosisCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presetNameOf(p) != conf.PresetOOM || presetNameOf(nil) != "" {
+		t.Errorf("unexpected preset name %v", presetNameOf(p))
+	}
+}
+
+func TestPresetFlagDefaults(t *testing.T) {
+	p, err := conf.FindPreset(conf.PresetPerformance, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := presetFlagDefaults(buildMergedFlagSet(SSHDiagnosisCmd), p)
+	want := map[string]string{
+		"diag-jstack":         "true",
+		"diag-async-profiler": "true",
+		"collect-access-log":  "false",
+		"days":                "1",
+		"system-tables":       "version,options,reflections,materializations,refreshes",
+	}
+	for name, value := range want {
+		if defaults[name] != value {
+			t.Errorf("expected --%v=%v, got %q", name, value, defaults[name])
+		}
+	}
+}
+
+func TestLoadCustomPresets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "presets.yaml")
+	content := "presets:\n  - name: threads\n    mode: diagnosis\n    settings:\n      diag-jstack: true\n      days: 1\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	p, err := resolvePreset("threads", path, collects.DiagnosisCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Settings[conf.KeyCollectJStack] != true || p.Settings[conf.KeyDremioLogsNumDays] != 1 {
+		t.Errorf("expected flag names translated to conf keys, got %v", p.Settings)
+	}
+
+	if err := os.WriteFile(path, []byte("presets:\n  - name: typo\n    mode: standard\n    settings:\n      diag-jstak: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCustomPresets(path); err == nil || !strings.Contains(err.Error(), "unknown setting 'diag-jstak'") {
+		t.Errorf("expected an unknown setting error, got %v", err)
+	}
+}
+
+func TestApplyConfigSourcesPresetDefaults(t *testing.T) {
+	p, err := conf.FindPreset(conf.PresetOOM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	var heapDump, jfr bool
+	var days int
+	flags.BoolVar(&heapDump, "diag-heap-dump", false, "")
+	flags.BoolVar(&jfr, "diag-jfr", false, "")
+	flags.IntVar(&days, "days", 3, "")
+	sources, err := applyConfigSources(flags, map[string]string{"days": "2"}, func(string) (string, bool) { return "", false }, presetFlagDefaults(flags, p), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !heapDump || jfr || days != 2 {
+		t.Errorf("expected the preset below the config file, got heap=%v jfr=%v days=%v", heapDump, jfr, days)
+	}
+	if sources["diag-heap-dump"] != configSourcePreset || sources["days"] != configSourceFile {
+		t.Errorf("unexpected sources %v %v", sources["diag-heap-dump"], sources["days"])
+	}
+}
@@ -35,17 +35,22 @@ import (
 
 	"github.com/charmbracelet/huh"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/configui"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/extract"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/restclient"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/docker"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubectl"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubernetes"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/local"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/ssh"
 	version "github.com/dremio/dremio-diagnostic-collector/v4/cmd/version"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/watch"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/dirs"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/validation"
@@ -75,6 +80,14 @@ var (
 	k8sContext            string
 	kubeconfigPath        string
 	disableFreeSpaceCheck bool
+	disableBatchStreaming bool
+	dedup                 bool
+	sinceLast             string
+	dryRun                bool
+	planFile              string
+	configFile            string
+	presetName            string
+	presetFile            string
 	enableKubeCtl         bool
 	collectionMode        collects.CollectionMode
 	transportCmd          string // "ssh", "k8s", "local", or "local-k8s", set from command path or TUI
@@ -86,7 +99,15 @@ var (
 	collectorTimeout  string
 	startDate         string
 	daysFlag          int
+	trimToWindow      bool
+	sinceFlag         string
+	untilFlag         string
+	trimSince         time.Time // parsed --since
+	trimUntil         time.Time // parsed --until
+	logTimezoneFlag   string
+	logTimezone       *time.Location // parsed --log-timezone, nil = each node's offset
 	collectHeapDump   bool
+	collectNMT        bool
 	allowInsecureSSL  bool
 	diagTimeSeconds   int
 	progressFormat    string
@@ -97,12 +118,19 @@ var (
 	localLogDir       string // set from --local-log-dir flag on LocalCmd; maps to both coordinator/executor log dirs
 	dremioHome        string // set from --dremio-home flag on LocalCmd; default /opt/dremio
 	// dremioGCLogsDir removed — autodetected via conf.go; --dremio-gclogs-dir flag deleted
-	dremioEndpoint       string
-	systemTables         string
-	nodesFlag            string
-	excludeNodesFlag     string
-	collectContainerLogs bool
-	sshStrictHostKeys    bool
+	dremioEndpoint         string
+	systemTables           string
+	nodesFlag              string
+	excludeNodesFlag       string
+	collectContainerLogs   bool
+	sshStrictHostKeys      bool
+	metricsIntervalSecs    int    // --metrics-interval-seconds on K8s transports
+	debugContainerImage    string // --debug-container-image on the K8s transport
+	containerLogLimitBytes int64  // --container-log-limit-bytes on K8s transports
+	kubectlBinary          string // --kubectl-binary on the K8s transport: kubectl, oc or a path
+	containerCLI           string // --container-cli on the docker transport: docker, podman or a path
+	coordinatorFilter      string // --coordinator-filter on the docker transport
+	executorFilter         string // --executor-filter on the docker transport
 
 	// per-log day counts (standard mode)
 	serverLogsNumDays  int
@@ -133,6 +161,25 @@ var (
 	collectWLM                 bool
 	collectKVStoreReport       bool
 	collectProblematicProfiles bool
+
+	// catalog inventory (standard mode, REST)
+	collectCatalogInventory bool
+	catalogMaxDepth         int
+	catalogMaxItems         int
+
+	// REST client TLS and proxy settings
+	dremioCACert     string
+	dremioClientCert string
+	dremioClientKey  string
+	httpProxy        string
+
+	// rocksdb-viewer type selection
+	rocksDBTypes       string
+	rocksDBTypesConfig string
+
+	// guards for uploaded helper binaries
+	helperTimeoutSeconds int
+	helperMemoryLimitMB  int
 )
 
 // RootCmd represents the base command when called without any subcommands
@@ -160,6 +207,17 @@ for local collection (on this host):
 
 for local collection inside a Kubernetes pod:
 	ddc collect local-k8s standard
+
+for docker-compose or Podman deployments on this host:
+	ddc collect docker standard
+	ddc collect docker diagnosis --container-cli podman
+
+to check every node is ready for a collection without changing anything:
+	ddc preflight ssh --coordinator 10.0.0.19 --executors 10.0.0.20 --ssh-user myuser --ssh-key ~/.ssh/mykey --sudo-user dremio
+	ddc preflight k8s standard --namespace mynamespace --json
+
+to collect from a node as soon as it runs out of heap, pauses on GC or logs an OOM:
+	ddc watch k8s --namespace mynamespace --cooldown 30m
 `,
 	Run: func(_ *cobra.Command, _ []string) {
 	},
@@ -171,12 +229,13 @@ var CollectCmd = &cobra.Command{
 	Use:   "collect",
 	Short: "Run non-interactive collection with provided flags",
 	Long: `Run a non-interactive collection using the provided CLI flags.
-Requires a transport subcommand (ssh or k8s) and a mode subcommand (standard or diagnosis).
+Requires a transport subcommand (ssh, k8s, local, local-k8s or docker) and a mode subcommand (standard or diagnosis).
 
 examples:
 
 	ddc collect k8s standard --namespace mynamespace
 	ddc collect ssh diagnosis --coordinator 10.0.0.1 --ssh-user myuser --ssh-key ~/.ssh/mykey
+	ddc collect docker standard
 `,
 }
 
@@ -192,6 +251,12 @@ var K8sCmd = &cobra.Command{
 	Short: "Collect via Kubernetes API",
 }
 
+// DockerCmd is the docker / podman transport subcommand under collect.
+var DockerCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Collect from Dremio containers via the docker or podman CLI",
+}
+
 // SSHStandardCmd runs a standard collection via SSH.
 var SSHStandardCmd = &cobra.Command{
 	Use:   "standard",
@@ -236,6 +301,28 @@ var K8sDiagnosisCmd = &cobra.Command{
 	Run: func(_ *cobra.Command, _ []string) {},
 }
 
+// DockerStandardCmd runs a standard collection via docker or podman.
+var DockerStandardCmd = &cobra.Command{
+	Use:   "standard",
+	Short: "Usage data collection",
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		collectionMode = collects.StandardCollection
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {},
+}
+
+// DockerDiagnosisCmd runs a diagnosis collection via docker or podman.
+var DockerDiagnosisCmd = &cobra.Command{
+	Use:   "diagnosis",
+	Short: "Full diagnostics [Support only]",
+	PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+		collectionMode = collects.DiagnosisCollection
+		return nil
+	},
+	Run: func(_ *cobra.Command, _ []string) {},
+}
+
 // LocalCmd is the local transport subcommand under collect.
 var LocalCmd = &cobra.Command{
 	Use:   "local",
@@ -379,7 +466,7 @@ func startTicker() (stop func()) {
 	}
 }
 
-func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs kubernetes.KubeArgs, fallbackEnabled bool, hook shutdown.Hook, cliMode bool, localK8sMode bool) error {
+func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs kubernetes.KubeArgs, dockerArgs docker.Args, fallbackEnabled bool, hook shutdown.Hook, cliMode bool, localK8sMode bool) error {
 	consoleprint.UpdateCollectionMode(collectionArgs.CollectionMode)
 
 	outputDir, err := filepath.Abs(filepath.Dir(outputLoc))
@@ -390,6 +477,7 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 	}
 
 	cs := helpers.NewHCCopyStrategy(collectionArgs.DDCfs, &helpers.RealTimeService{}, outputDir)
+	cs.Dedup = collectionArgs.Dedup
 	hook.AddFinalSteps(cs.Close, "running cleanup on copy strategy")
 	clusterCollect := func() {}
 	var collectorStrategy collection.Collector
@@ -439,15 +527,21 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 			simplelog.Warningf("local-k8s: namespace detection failed (%v) and no fallback available — skipping cluster resource and container log collection", nsErr)
 		} else if clientSet != nil {
 			simplelog.Infof("local-k8s: K8s API available, namespace=%s — collecting cluster resources and container logs", detectedNS)
+			collectionArgs.ClusterData = "Kubernetes API"
 			clusterCollect = func() {
+				metricsDone := startK8sMetrics(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs)
+				defer metricsDone()
 				if err := collection.ClusterK8sExecute(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs); err != nil {
 					simplelog.Errorf("local-k8s: error collecting K8s resources: %v", err)
 				}
-				if err := collection.GetPreviousLogsForRestartedPods(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs, ""); err != nil {
+				if err := collection.GetPreviousLogsForRestartedPods(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs, "", collection.ContainerLogOptionsFor(collectionArgs)); err != nil {
 					simplelog.Errorf("local-k8s: error collecting previous container logs for restarted pods: %v", err)
 				}
+				if err := collection.WritePodRestartReport(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs, ""); err != nil {
+					simplelog.Errorf("local-k8s: error writing pod restart report: %v", err)
+				}
 				if collectContainerLogs {
-					if err := collection.GetClusterLogs(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs, ""); err != nil {
+					if err := collection.GetClusterLogs(hook, detectedNS, clientSet, cs, collectionArgs.DDCfs, "", collection.ContainerLogOptionsFor(collectionArgs)); err != nil {
 						simplelog.Errorf("local-k8s: error collecting container logs: %v", err)
 					}
 				} else {
@@ -479,19 +573,54 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 			0,
 			0,
 		)
+	} else if transportCmd == "docker" {
+		simplelog.Info("using docker / podman based collection")
+		dockerActions, err := docker.NewCmdDockerActions(dockerArgs, hook)
+		if err != nil {
+			return err
+		}
+		collectorStrategy = dockerActions
+		consoleprint.UpdateCollectionArgs(fmt.Sprintf("cli: %v, coordinator-filter: '%v', executor-filter: '%v'", dockerActions.Name(), dockerArgs.CoordinatorFilter, dockerArgs.ExecutorFilter))
+		consoleprint.UpdateRuntime(
+			versions.GetCLIVersion(),
+			simplelog.GetLogLoc(),
+			0,
+			0,
+			0,
+		)
+
+		// Default container logs: enabled for diagnosis, disabled for standard.
+		if cliMode && !DockerCmd.PersistentFlags().Changed("collect-container-logs") {
+			collectContainerLogs = (collectionMode == collects.DiagnosisCollection)
+		}
+
+		collectionArgs.ClusterData = dockerActions.Name() + " CLI"
+		clusterCollect = func() {
+			if err := dockerActions.ClusterExecute(cs, collectionArgs.DDCfs, collectContainerLogs, collection.ContainerLogOptionsFor(collectionArgs)); err != nil {
+				simplelog.Errorf("when getting container info, the following error was returned: %v", err)
+			}
+		}
 	} else if kubeArgs.Namespace != "" {
 		cs.IsK8s = true
 		simplelog.Info("using Kubernetes api based collection")
 		consoleprint.UpdateCollectionArgs(fmt.Sprintf("namespace: '%v', detect-label-selector: '%v'", kubeArgs.Namespace, kubeArgs.DetectLabelSelector))
-		collectorStrategy, err = kubernetes.NewK8sAPI(kubeArgs, hook)
+		k8sActions, err := kubernetes.NewK8sAPI(kubeArgs, hook)
 		if err != nil {
 			return err
 		}
+		if kubeArgs.DebugImage != "" {
+			hook.Add(k8sActions.StopDebugContainers, "stopping ephemeral debug containers")
+		}
+		collectorStrategy = k8sActions
 		// K8s RBAC pre-check
 		_ = spinner.New().
 			Title("Checking Kubernetes permissions...").
 			Action(func() {
-				if err := kubernetes.CheckRBAC(kubeArgs.K8SContext, kubeArgs.Namespace, kubeArgs.KubeconfigPath); err != nil {
+				missing, err := kubernetes.CheckRBAC(kubeArgs)
+				if err == nil {
+					err = kubernetes.RBACError(kubeArgs.Namespace, missing)
+				}
+				if err != nil {
 					simplelog.Warningf("RBAC check: %v", err)
 				}
 			}).
@@ -519,23 +648,30 @@ func RemoteCollect(collectionArgs collection.Args, sshArgs ssh.Args, kubeArgs ku
 			collectContainerLogs = (collectionMode == collects.DiagnosisCollection)
 		}
 
+		collectionArgs.ClusterData = "Kubernetes API"
 		clusterCollect = func() {
 			clientSet, _, err := kubernetes.GetClientset(k8sContext, kubeconfigPath)
 			if err != nil {
 				simplelog.Errorf("when getting Kubernetes info, the following error was returned: %v", err)
 				return
 			}
+			metricsDone := startK8sMetrics(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs)
+			defer metricsDone()
 			err = collection.ClusterK8sExecute(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs)
 			if err != nil {
 				simplelog.Errorf("when getting Kubernetes info, the following error was returned: %v", err)
 			}
 			// Always collect previous logs for pods that have restarted
-			err = collection.GetPreviousLogsForRestartedPods(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs, containerLogLabelSelector)
+			err = collection.GetPreviousLogsForRestartedPods(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs, containerLogLabelSelector, collection.ContainerLogOptionsFor(collectionArgs))
 			if err != nil {
 				simplelog.Errorf("when getting previous container logs for restarted pods, the following error was returned: %v", err)
 			}
+			err = collection.WritePodRestartReport(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs, containerLogLabelSelector)
+			if err != nil {
+				simplelog.Errorf("when writing the pod restart report, the following error was returned: %v", err)
+			}
 			if collectContainerLogs {
-				err = collection.GetClusterLogs(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs, containerLogLabelSelector)
+				err = collection.GetClusterLogs(hook, kubeArgs.Namespace, clientSet, cs, collectionArgs.DDCfs, containerLogLabelSelector, collection.ContainerLogOptionsFor(collectionArgs))
 				if err != nil {
 					simplelog.Errorf("when getting container logs, the following error was returned: %v", err)
 				}
@@ -634,8 +770,12 @@ func resolveMetaRefresh(skipPromptUI bool, global bool, confData map[string]inte
 // All settings come from flags or mode profile defaults.
 func BuildConfData(cmd *cobra.Command, collectionMode collects.CollectionMode) map[string]interface{} {
 	confData := make(map[string]interface{})
-	// Apply mode defaults first
-	conf.SetViperDefaults(confData, "", 0, collectionMode)
+	// Apply mode (or preset) defaults first
+	if activePreset != nil {
+		activePreset.Profile(confData, "", 0)
+	} else {
+		conf.SetViperDefaults(confData, "", 0, collectionMode)
+	}
 	// Override with any CLI flags that were explicitly set
 	if coordinatorLogDir != "" {
 		confData[conf.KeyDremioLogDir] = coordinatorLogDir
@@ -679,9 +819,12 @@ func BuildConfData(cmd *cobra.Command, collectionMode collects.CollectionMode) m
 		if cmd.Flags().Changed(conf.KeyCollectMetaRefreshLog) {
 			confData[conf.KeyCollectMetaRefreshLog] = collectMetaRefresh
 		}
+		if cmd.Flags().Changed(conf.KeyCollectCatalogInventory) {
+			confData[conf.KeyCollectCatalogInventory] = collectCatalogInventory
+		}
 	}
 	// Log the configuration
-	simplelog.Infof("v4 configuration for mode %v:", collectionMode)
+	simplelog.Infof("v4 configuration for mode %v (preset %q):", collectionMode, presetNameOf(activePreset))
 	for k, v := range confData {
 		if k == conf.KeyDremioPatToken && v != "" {
 			simplelog.Debugf("conf key '%v':'REDACTED'", k)
@@ -702,10 +845,12 @@ func Execute(args []string) error {
 		}
 	}()
 
+	// "ddc preflight <transport>" runs as the collection it checks
+	args = preflightCollectArgs(args)
 	foundCmd, _, err := RootCmd.Find(args[1:])
 	// Handle subcommand detection — leaf commands are standard/diagnosis under ssh/k8s
 	isLeafCmd := err == nil && (foundCmd.Use == "standard" || foundCmd.Use == "diagnosis") &&
-		foundCmd.Parent() != nil && (foundCmd.Parent().Use == "ssh" || foundCmd.Parent().Use == "k8s" || foundCmd.Parent().Use == "local" || foundCmd.Parent().Use == "local-k8s")
+		foundCmd.Parent() != nil && (foundCmd.Parent().Use == "ssh" || foundCmd.Parent().Use == "k8s" || foundCmd.Parent().Use == "local" || foundCmd.Parent().Use == "local-k8s" || foundCmd.Parent().Use == "docker")
 	isCollectSubCmd := isLeafCmd
 	isRootCmd := err == nil && foundCmd.Use == RootCmd.Use
 
@@ -743,8 +888,8 @@ func Execute(args []string) error {
 		return CollectCmd.Help()
 	}
 
-	// Bare "ddc collect ssh" or "ddc collect k8s" or "ddc collect local" or "ddc collect local-k8s" without mode — show subcommand help
-	if err == nil && (foundCmd.Use == "ssh" || foundCmd.Use == "k8s" || foundCmd.Use == "local" || foundCmd.Use == "local-k8s") && foundCmd.Parent() != nil && foundCmd.Parent().Use == "collect" {
+	// Bare "ddc collect ssh" or "ddc collect k8s" or "ddc collect local" or "ddc collect local-k8s" or "ddc collect docker" without mode — show subcommand help
+	if err == nil && (foundCmd.Use == "ssh" || foundCmd.Use == "k8s" || foundCmd.Use == "local" || foundCmd.Use == "local-k8s" || foundCmd.Use == "docker") && foundCmd.Parent() != nil && foundCmd.Parent().Use == "collect" {
 		return foundCmd.Help()
 	}
 
@@ -775,6 +920,11 @@ func Execute(args []string) error {
 			return err
 		}
 
+		// Fill the flags that were not passed from DDC_* variables and --config
+		if err := resolveConfig(foundCmd, isCollectSubCmd); err != nil {
+			return err
+		}
+
 		// Early validation — before creating the shutdown hook so that CLI
 		// errors exit cleanly without rendering the status screen.
 		if nonInteractive {
@@ -878,6 +1028,35 @@ func Execute(args []string) error {
 				}
 			}
 
+			// Step 1b: optional preset for the chosen mode
+			presets, err := presetsForMode(collectionMode)
+			if err != nil {
+				return err
+			}
+			presetOptions := []huh.Option[string]{huh.NewOption(fmt.Sprintf("None — %v defaults", collectionMode), "")}
+			for _, p := range presets {
+				presetOptions = append(presetOptions, huh.NewOption(fmt.Sprintf("%-16s— %v", p.Name, p.Description), p.Name))
+			}
+			selectedPreset := presetName
+			if err := huh.NewForm(
+				huh.NewGroup(
+					huh.NewSelect[string]().
+						Title("Preset").
+						Description("Targeted collection defaults, every setting can still be changed on the next screens").
+						Options(presetOptions...).
+						Value(&selectedPreset),
+					huh.NewNote().Description("\n\n\n\n\n\n\n\n\n\n"),
+				),
+			).WithTheme(huh.ThemeCharm()).Run(); err != nil {
+				fmt.Println("\nCancelled")
+				os.Exit(0)
+			}
+			if selectedPreset != "" {
+				if activePreset, err = resolvePreset(selectedPreset, presetFile, collectionMode); err != nil {
+					return err
+				}
+			}
+
 			// Step 2: Transport selection
 			var transport string
 			if err := huh.NewForm(
@@ -1030,6 +1209,7 @@ func Execute(args []string) error {
 		}
 
 		// Interactive mode: run path discovery on a target node, then show config screen
+		configScreenShown := false
 		if !skipPromptUI {
 			var detected *configui.DetectedPaths
 			if transportCmd == "local" || transportCmd == "local-k8s" {
@@ -1037,15 +1217,21 @@ func Execute(args []string) error {
 			} else {
 				detected = runPathDiscovery(namespace, coordinatorStr, sshUser, sshKeyLoc, k8sContext, kubeconfigPath)
 			}
+			// settings of the preset that the config screens do not show
+			if err := applyPresetFlags(transportCmd); err != nil {
+				return err
+			}
 			switch collectionMode {
 			case collects.StandardCollection:
 				if err := runStandardConfigScreen(detected); err != nil {
 					return err
 				}
+				configScreenShown = true
 			case collects.DiagnosisCollection:
 				if err := runDiagnosisConfigScreen(detected); err != nil {
 					return err
 				}
+				configScreenShown = true
 			}
 		}
 
@@ -1059,6 +1245,7 @@ func Execute(args []string) error {
 
 		simplelog.Info(versions.GetCLIVersion())
 		simplelog.Infof("user cli command: %v", strings.Join(args, " "))
+		logConfigSources(foundCmd)
 
 		// Version check — for non-interactive runs, log the update notice to ddc.log.
 		// Interactive mode already shows the update in the TUI banner.
@@ -1068,14 +1255,6 @@ func Execute(args []string) error {
 			}
 		}
 
-		// PAT token: CLI flag > env var > stdin > config
-		if cliAuthToken == "" {
-			if envPAT := os.Getenv("DDC_PAT_TOKEN"); envPAT != "" {
-				cliAuthToken = envPAT
-				simplelog.Info("using PAT token from DDC_PAT_TOKEN environment variable")
-			}
-		}
-
 		if skipPromptUI && collectionMode == "" {
 			return fmt.Errorf("collection mode is required. Use 'ddc collect standard' or 'ddc collect diagnosis'")
 		}
@@ -1084,13 +1263,21 @@ func Execute(args []string) error {
 			collectionMode = collects.StandardCollection
 		}
 
+		// A custom CA means the server should be verified, so only keep insecure
+		// SSL when it was asked for explicitly, on the command line or in the
+		// config screens.
+		if dremioCACert != "" && !configScreenShown && !foundCmd.Flags().Changed(conf.KeyAllowInsecureSSL) {
+			allowInsecureSSL = false
+		}
+
 		// Validate v4 flag combinations
 		if err := validateV4Flags(collectionMode, skipPromptUI); err != nil {
 			return err
 		}
 
 		confData := BuildConfData(foundCmd, collectionMode)
-		if !disableFreeSpaceCheck {
+		// a dry run only writes the plan and preflight writes nothing
+		if !disableFreeSpaceCheck && !dryRun && !preflightRun {
 			abs, err := filepath.Abs(outputLoc)
 			if err != nil {
 				return err
@@ -1105,7 +1292,7 @@ func Execute(args []string) error {
 			}
 		}
 
-		// PAT resolution: CLI flag/env > stdin > config
+		// PAT resolution: CLI flag/env/config file > stdin
 		dremioPAT := ""
 		if cliAuthToken != "" {
 			dremioPAT = cliAuthToken
@@ -1133,7 +1320,7 @@ func Execute(args []string) error {
 
 		// Pre-check PAT validity in non-interactive mode — fail fast before starting collection.
 		if dremioPAT != "" && dremioEndpoint != "" && nonInteractive {
-			result := configui.ValidatePAT(dremioEndpoint, dremioPAT, allowInsecureSSL)
+			result := configui.ValidatePAT(dremioEndpoint, dremioPAT, restClientConfig())
 			if strings.Contains(result, "failed") {
 				return fmt.Errorf("PAT pre-check failed against %s: %s", dremioEndpoint, result)
 			}
@@ -1156,7 +1343,7 @@ func Execute(args []string) error {
 				if value, ok := v.(bool); ok {
 					// check pat so they end up in the right column
 					if !patSet {
-						if k == conf.KeyCollectKVStoreReport {
+						if k == conf.KeyCollectKVStoreReport || k == conf.KeyCollectCatalogInventory {
 							disabled = append(disabled, newName)
 							continue
 						}
@@ -1175,8 +1362,11 @@ func Execute(args []string) error {
 		if progressFormat == "json" {
 			consoleprint.EnableStatusOutput()
 		}
-		stop := startTicker()
-		hook.AddUIStop(stop)
+		// preflight prints its report instead of the status screen
+		if !preflightRun {
+			stop := startTicker()
+			hook.AddUIStop(stop)
+		}
 		// Parse system tables list
 		var systemTablesList []string
 		if systemTables != "" {
@@ -1188,6 +1378,28 @@ func Execute(args []string) error {
 			}
 		}
 		simplelog.Debugf("system tables to collect (%d): %v", len(systemTablesList), systemTablesList)
+		rocksDBTypesList, err := collection.ParseRocksDBTypes(rocksDBTypes)
+		if err != nil {
+			return fmt.Errorf("invalid --%s: %w", conf.KeyRocksDBTypes, err)
+		}
+		var rocksDBTypeOverrides map[string]collection.RocksDBTypeSpec
+		if rocksDBTypesConfig != "" {
+			rocksDBTypeOverrides, err = collection.LoadRocksDBTypesConfig(rocksDBTypesConfig)
+			if err != nil {
+				return err
+			}
+		}
+		var plan *collection.CollectionPlan
+		if planFile != "" {
+			plan, err = collection.LoadCollectionPlan(planFile)
+			if err != nil {
+				return err
+			}
+		}
+		effective, err := effectiveConfig(foundCmd)
+		if err != nil {
+			return err
+		}
 		simplelog.Infof("collection args resolved: mode=%s daysFlag=%d diagLogDays=%d queriesPerfNumDays=%d queriesJSONNumDays=%d serverLogsNumDays=%d trackerJSONNumDays=%d vacuumLogNumDays=%d startDate=%q",
 			collectionMode, daysFlag, diagLogDays(), queriesPerfNumDays, queriesJSONNumDays, serverLogsNumDays, trackerJSONNumDays, vacuumLogNumDays, startDate)
 		collectionArgs := collection.Args{
@@ -1197,7 +1409,10 @@ func Execute(args []string) error {
 			Enabled:               enabled,
 			Disabled:              disabled,
 			DisableFreeSpaceCheck: disableFreeSpaceCheck,
+			DisableBatchStreaming: disableBatchStreaming,
+			Dedup:                 dedup,
 			CollectionMode:        collectionMode,
+			Preset:                presetNameOf(activePreset),
 			CollectionThreads:     collectionThreads,
 			CoordinatorLogDir:     coordinatorLogDir,
 			ExecutorLogDir:        executorLogDir,
@@ -1211,19 +1426,29 @@ func Execute(args []string) error {
 			StartDate:             startDate,
 			// API collections (run from orchestrator)
 			DremioEndpoint:             dremioEndpoint,
-			AllowInsecureSSL:           allowInsecureSSL,
+			RestClient:                 restClientConfig(),
 			RestHTTPTimeout:            30,
 			CollectWLM:                 collectWLM,
 			CollectKVStoreReport:       collectKVStoreReport,
 			CollectProblematicProfiles: collectProblematicProfiles && collectionMode == collects.DiagnosisCollection,
+			CollectCatalogInventory:    collectCatalogInventory && collectionMode == collects.StandardCollection,
+			CatalogMaxDepth:            catalogMaxDepth,
+			CatalogMaxItems:            catalogMaxItems,
 			CollectSystemTables:        len(systemTablesList) > 0,
 			SystemTables:               systemTablesList,
+			RocksDBTypes:               rocksDBTypesList,
+			RocksDBTypeOverrides:       rocksDBTypeOverrides,
+			HelperGuard: collection.HelperGuardConfig{
+				TimeoutSeconds: helperTimeoutSeconds,
+				MemoryLimitMB:  helperMemoryLimitMB,
+			},
 			// JVM collection (diagnosis mode only)
 			CollectJStack:        collectJStack && collectionMode == collects.DiagnosisCollection,
 			CollectTop:           collectTop && collectionMode == collects.DiagnosisCollection,
 			CollectJVMFlags:      collectionMode == collects.DiagnosisCollection,
 			CollectJFR:           collectJFR && collectionMode == collects.DiagnosisCollection,
 			CollectHeapDump:      collectHeapDump && collectionMode == collects.DiagnosisCollection,
+			CollectNMT:           collectNMT && collectionMode == collects.DiagnosisCollection,
 			CollectAsyncProfiler: collectAsyncProfiler && collectionMode == collects.DiagnosisCollection,
 			DiagTimeSeconds:      diagTimeSeconds,
 			CollectQueriesPerf:   collectQueriesPerf,
@@ -1242,6 +1467,20 @@ func Execute(args []string) error {
 			// Node filtering (from TUI node selection or --nodes/--exclude-nodes flags)
 			IncludeNodes: parseNodeList(nodesFlag),
 			ExcludeNodes: parseNodeList(excludeNodesFlag),
+			// Container logs (K8s transports)
+			ContainerLogLimitBytes: containerLogLimitBytes,
+			// Line-level log trimming (diagnosis mode)
+			TrimToWindow: trimToWindow,
+			TrimSince:    trimSince,
+			TrimUntil:    trimUntil,
+			LogTimezone:  logTimezone,
+			// Incremental collection
+			SinceLast: sinceLast,
+			// Collection plans
+			DryRun: dryRun,
+			Plan:   plan,
+			// Archived as ddc-config.yaml
+			EffectiveConfig: effective,
 		}
 		sshArgs := ssh.Args{
 			SSHKeyLoc:      sshKeyLoc,
@@ -1250,11 +1489,24 @@ func Execute(args []string) error {
 			ExecutorStr:    executorsStr,
 			CoordinatorStr: coordinatorStr,
 		}
+		dockerArgs := docker.Args{
+			CLIBinary:         containerCLI,
+			CoordinatorFilter: coordinatorFilter,
+			ExecutorFilter:    executorFilter,
+		}
 		kubeArgs := kubernetes.KubeArgs{
 			Namespace:           namespace,
 			DetectLabelSelector: detectLabelSelector,
 			K8SContext:          k8sContext,
 			KubeconfigPath:      kubeconfigPath,
+			DebugImage:          debugContainerImage,
+			CLIBinary:           kubectlBinary,
+		}
+		// a dry run changes nothing on the pods: the plan records where the debug
+		// container would be attached instead
+		if dryRun {
+			collectionArgs.DebugContainerImage = kubeArgs.DebugImage
+			kubeArgs.DebugImage = ""
 		}
 		// Local transport uses the fallback (local collector) path in RemoteCollect.
 		if transportCmd == "local" {
@@ -1277,8 +1529,11 @@ func Execute(args []string) error {
 				collectionArgs.ExecutorLogDir = localLogDir
 			}
 		}
+		if preflightRun {
+			return runPreflight(collectionArgs, sshArgs, kubeArgs, dockerArgs, enableFallback, hook)
+		}
 		localK8sMode := transportCmd == "local-k8s"
-		if err := RemoteCollect(collectionArgs, sshArgs, kubeArgs, enableFallback, hook, skipPromptUI, localK8sMode); err != nil {
+		if err := RemoteCollect(collectionArgs, sshArgs, kubeArgs, dockerArgs, enableFallback, hook, skipPromptUI, localK8sMode); err != nil {
 			// Detect user cancellation (Ctrl+C in TUI forms, cancelled config screens)
 			// and show a clean message instead of the full error in the status screen.
 			errMsg := err.Error()
@@ -1309,6 +1564,24 @@ func (u unableToGetHomeDir) Error() string {
 	return fmt.Sprintf("unable to get home dir '%v'", u.Err)
 }
 
+// startK8sMetrics samples metrics.k8s.io in the background, over the diagnostic window in
+// diagnosis mode and once in standard mode, so it overlaps the JVM tools rather than
+// delaying resource and container log collection. The returned func waits for it.
+func startK8sMetrics(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs collection.CopyStrategy, ddfs helpers.Filesystem) func() {
+	var window time.Duration
+	if collectionMode == collects.DiagnosisCollection {
+		window = time.Duration(diagTimeSeconds) * time.Second
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := collection.CollectK8sMetrics(hook, namespace, clientSet, cs, ddfs, window, time.Duration(metricsIntervalSecs)*time.Second); err != nil {
+			simplelog.Errorf("when sampling K8s resource usage, the following error was returned: %v", err)
+		}
+	}()
+	return func() { <-done }
+}
+
 // sshDefault returns the default .ssh key typically used on most deployments
 
 func sshDefault() (string, error) {
@@ -1336,22 +1609,47 @@ func init() {
 	K8sCmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "path to kubeconfig file (overrides $KUBECONFIG and ~/.kube/config)")
 	K8sCmd.PersistentFlags().StringVar(&detectLabelSelector, "detect-label-selector", "role=dremio-cluster-pod", "label selector used to identify Dremio coordinator/executor pods for file streaming; follows kubernetes label syntax (https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#label-selectors)")
 	K8sCmd.PersistentFlags().StringVarP(&containerLogLabelSelector, "container-log-label-selector", "l", "", "label selector to filter which pods' container logs are collected (default: empty = all pods in the namespace); follows kubernetes label syntax")
-	K8sCmd.PersistentFlags().BoolVarP(&enableKubeCtl, "enable-kubectl", "d", false, "uses the kubectl CLI for transfers and copying instead of the embedded k8s api client")
+	K8sCmd.PersistentFlags().BoolVarP(&enableKubeCtl, "enable-kubectl", "d", false, "uses the kubectl CLI (or oc on OpenShift) for transfers and copying instead of the embedded k8s api client")
+	K8sCmd.PersistentFlags().StringVar(&kubectlBinary, "kubectl-binary", "", "CLI used with --enable-kubectl and for the RBAC check: kubectl, oc or a path to either (default: kubectl, falling back to oc)")
 	K8sCmd.PersistentFlags().BoolVar(&collectContainerLogs, "collect-container-logs", false, "collect Kubernetes container logs (default: disabled for standard, enabled for diagnosis)")
 	K8sCmd.PersistentFlags().StringVar(&nodesFlag, "nodes", "", "comma-separated list of nodes to collect from")
 	K8sCmd.PersistentFlags().StringVar(&excludeNodesFlag, "exclude-nodes", "", "comma-separated list of nodes to exclude (mutually exclusive with --nodes)")
+	K8sCmd.PersistentFlags().StringVar(&debugContainerImage, "debug-container-image", "", "image for an ephemeral debug container attached to Dremio pods whose container lacks jcmd, gzip, sha256sum, find, stat or top; those commands then run in it against the Dremio container's filesystem (default: empty = disabled; needs update on pods/ephemeralcontainers)")
+	K8sCmd.PersistentFlags().Int64Var(&containerLogLimitBytes, "container-log-limit-bytes", 0, "keep only the last N bytes of each container log, current and previous (default: 0 = whole log within the collection window)")
+	K8sCmd.PersistentFlags().IntVar(&metricsIntervalSecs, "metrics-interval-seconds", 10, "how often pod and node usage is sampled from metrics.k8s.io during the --diag-time-seconds window (sampled once in standard mode)")
 
 	// ── Local transport flags — on LocalCmd.PersistentFlags() ──
 	LocalCmd.PersistentFlags().StringVar(&dremioHome, "dremio-home", "/opt/dremio", "Dremio installation directory")
 	LocalCmd.PersistentFlags().StringVar(&localLogDir, "local-log-dir", "", "Log directory on this node (autodetected if not specified)")
 
+	// ── Docker transport flags — on DockerCmd.PersistentFlags() ──
+	DockerCmd.PersistentFlags().StringVar(&containerCLI, "container-cli", "", "container CLI to use: docker, podman or a path to either (default: docker, falling back to podman)")
+	DockerCmd.PersistentFlags().StringVar(&coordinatorFilter, "coordinator-filter", "", "comma separated 'docker ps --filter' expressions matching the coordinator containers, e.g. label=com.docker.compose.service=dremio-coordinator (default: running containers with a dremio image whose name lacks 'executor')")
+	DockerCmd.PersistentFlags().StringVar(&executorFilter, "executor-filter", "", "comma separated 'docker ps --filter' expressions matching the executor containers (default: running containers with a dremio image whose name contains 'executor')")
+	DockerCmd.PersistentFlags().BoolVar(&collectContainerLogs, "collect-container-logs", false, "collect container logs (default: disabled for standard, enabled for diagnosis)")
+	DockerCmd.PersistentFlags().StringVar(&nodesFlag, "nodes", "", "comma-separated list of containers to collect from")
+	DockerCmd.PersistentFlags().StringVar(&excludeNodesFlag, "exclude-nodes", "", "comma-separated list of containers to exclude (mutually exclusive with --nodes)")
+	DockerCmd.PersistentFlags().Int64Var(&containerLogLimitBytes, "container-log-limit-bytes", 0, "keep only the last N bytes of each container log (default: 0 = whole log within the collection window)")
+
 	// ── Local-K8s transport flags — on LocalK8sCmd.PersistentFlags() ──
 	LocalK8sCmd.PersistentFlags().StringVar(&dremioHome, "dremio-home", "/opt/dremio", "Dremio installation directory")
 	LocalK8sCmd.PersistentFlags().StringVar(&localLogDir, "local-log-dir", "", "Log directory on this node (autodetected if not specified)")
 	LocalK8sCmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "path to kubeconfig file used when in-cluster config is unavailable")
+	LocalK8sCmd.PersistentFlags().Int64Var(&containerLogLimitBytes, "container-log-limit-bytes", 0, "keep only the last N bytes of each container log, current and previous (default: 0 = whole log within the collection window)")
+	LocalK8sCmd.PersistentFlags().IntVar(&metricsIntervalSecs, "metrics-interval-seconds", 10, "how often pod and node usage is sampled from metrics.k8s.io during the --diag-time-seconds window (sampled once in standard mode)")
 
 	// ── Shared flags — on CollectCmd.PersistentFlags(), inherited by all leaf commands ──
 	CollectCmd.PersistentFlags().BoolVar(&disableFreeSpaceCheck, conf.KeyDisableFreeSpaceCheck, false, "disables the free space check for the output directory")
+	CollectCmd.PersistentFlags().BoolVar(&disableBatchStreaming, "disable-batch-streaming", false, "stream each node's files one remote command per file instead of in a single tar stream")
+	CollectCmd.PersistentFlags().StringVar(&sinceLast, "since-last", "", "state file of the previous collection: skip log files unchanged since then, fetch only what was appended to active logs, and update the file for the next run")
+	CollectCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "run discovery and all filtering, then write every file, tool and REST call the collection would run to plan.json next to --output-file instead of collecting")
+	CollectCmd.PersistentFlags().BoolVar(&preflightRun, "preflight", false, "only run the read-only readiness checks of this collection on every node and print the report (what ddc preflight runs)")
+	CollectCmd.PersistentFlags().BoolVar(&preflightJSON, "json", false, "with --preflight, print the report as JSON")
+	CollectCmd.PersistentFlags().StringVar(&planFile, "plan", "", "plan.json written by --dry-run: collect exactly the files, tools and REST calls it lists")
+	CollectCmd.PersistentFlags().StringVar(&configFile, "config", "", "YAML or JSON file of collect flag names to values, e.g. server-logs-num-days: 3 (env: DDC_CONFIG); precedence: flag > DDC_* env > file > mode default")
+	CollectCmd.PersistentFlags().StringVar(&presetName, conf.KeyPreset, "", "targeted collection preset that replaces the mode defaults: oom, performance, crash (diagnosis), security-review, config-only (standard) or a preset from --preset-file")
+	CollectCmd.PersistentFlags().StringVar(&presetFile, conf.KeyPresetFile, "", "YAML or JSON file with a presets list of name, description, mode and settings (collect flag names to values)")
+	CollectCmd.PersistentFlags().BoolVar(&dedup, "dedup", false, "store files that are identical across nodes once and list the other copies in dedup-index.json; readers must run ddc extract to restore them")
 	CollectCmd.PersistentFlags().StringVar(&pid, "pid", "", "write a pid")
 	if err := CollectCmd.PersistentFlags().MarkHidden("pid"); err != nil {
 		simplelog.Errorf("unable to mark flag hidden critical error %v", err)
@@ -1372,7 +1670,7 @@ func init() {
 	diagDef := conf.DiagnosisDefaultMap()
 
 	// ── Collection toggles — standard commands use stdDef, diagnosis commands use diagDef ──
-	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd} {
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd} {
 		cmd.Flags().BoolVar(&collectQueriesJSON, "collect-queries-json", conf.GetBoolDefault(stdDef, conf.KeyCollectQueriesJSON), "collect queries.json files")
 		cmd.Flags().BoolVar(&collectQueriesPerf, "collect-queries-perf-json", conf.GetBoolDefault(stdDef, conf.KeyCollectQueriesPerfJSON), "collect queries performance data from RocksDB")
 		cmd.Flags().BoolVar(&collectServerLogs, "collect-server-logs", conf.GetBoolDefault(stdDef, conf.KeyCollectServerLogs), "collect server.log files")
@@ -1383,7 +1681,17 @@ func init() {
 		cmd.Flags().StringVar(&systemTables, "system-tables", strings.Join(conf.SystemTableList(), ","), "comma-separated list of system tables to collect")
 		cmd.Flags().IntVar(&queriesPerfNumDays, conf.KeyQueriesPerfNumDays, conf.GetIntDefault(stdDef, conf.KeyQueriesPerfNumDays), "number of days of queries performance data to collect")
 	}
-	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd} {
+
+	// ── Catalog inventory over REST — standard only ──
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd} {
+		cmd.Flags().StringVar(&cliAuthToken, "dremio-pat-token", "", "Dremio PAT token for API-based collection (env: DDC_PAT_TOKEN)")
+		cmd.Flags().StringVar(&dremioEndpoint, "dremio-endpoint", "", "Dremio REST API endpoint (e.g. http://localhost:9047)")
+		cmd.Flags().BoolVar(&allowInsecureSSL, "allow-insecure-ssl", true, "allow insecure SSL connections to Dremio REST API")
+		cmd.Flags().BoolVar(&collectCatalogInventory, conf.KeyCollectCatalogInventory, conf.GetBoolDefault(stdDef, conf.KeyCollectCatalogInventory), "collect sources (credentials masked), reflections and space/folder/VDS/PDS counts (requires --dremio-pat-token)")
+		cmd.Flags().IntVar(&catalogMaxDepth, conf.KeyCatalogMaxDepth, conf.GetIntDefault(stdDef, conf.KeyCatalogMaxDepth), "maximum catalog depth walked for the catalog inventory")
+		cmd.Flags().IntVar(&catalogMaxItems, conf.KeyCatalogMaxItems, conf.GetIntDefault(stdDef, conf.KeyCatalogMaxItems), "maximum number of catalog entries visited for the catalog inventory")
+	}
+	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
 		cmd.Flags().BoolVar(&collectKVStoreReport, "collect-kvstore-report", conf.GetBoolDefault(diagDef, conf.KeyCollectKVStoreReport), "collect KV store report (requires --dremio-pat-token)")
 		cmd.Flags().BoolVar(&collectQueriesJSON, "collect-queries-json", conf.GetBoolDefault(diagDef, conf.KeyCollectQueriesJSON), "collect queries.json files")
 		cmd.Flags().BoolVar(&collectQueriesPerf, "collect-queries-perf-json", conf.GetBoolDefault(diagDef, conf.KeyCollectQueriesPerfJSON), "collect queries performance data from RocksDB")
@@ -1396,12 +1704,32 @@ func init() {
 	}
 
 	// ── --collect-hs-err-files — diagnosis only ──
-	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd} {
+	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
 		cmd.Flags().BoolVar(&collectHSErrFiles, "collect-hs-err-files", conf.GetBoolDefault(diagDef, conf.KeyCollectHSErrFiles), "collect hs_err crash dump files")
 	}
 
+	// ── REST client TLS and proxy — everywhere a PAT can be supplied ──
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd, SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
+		cmd.Flags().StringVar(&dremioCACert, conf.KeyDremioCACert, "", "PEM CA bundle used to verify the Dremio REST API certificate (enables verification unless --allow-insecure-ssl is set explicitly or in the config screens)")
+		cmd.Flags().StringVar(&dremioClientCert, conf.KeyDremioClientCert, "", "PEM client certificate for mutual TLS with the Dremio REST API (requires --dremio-client-key)")
+		cmd.Flags().StringVar(&dremioClientKey, conf.KeyDremioClientKey, "", "PEM client key for mutual TLS with the Dremio REST API (requires --dremio-client-cert)")
+		cmd.Flags().StringVar(&httpProxy, conf.KeyHTTPProxy, "", "proxy url for Dremio REST API calls (default: HTTPS_PROXY/HTTP_PROXY, NO_PROXY is always honoured)")
+	}
+
+	// ── RocksDB viewer types — both modes ──
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd, SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
+		cmd.Flags().StringVar(&rocksDBTypes, conf.KeyRocksDBTypes, "", "comma-separated rocksdb-viewer types to extract (e.g. cluster_stats,sys.options,wlm_rules,queries_perf); replaces the selection from --system-tables, --collect-wlm and --collect-queries-perf-json")
+		cmd.Flags().StringVar(&rocksDBTypesConfig, conf.KeyRocksDBTypesConfig, "", "YAML or JSON file with a rocksdbTypes section mapping viewer types to dir, file, timeoutSeconds and maxBytes; types it defines beyond the built-in ones are extracted too")
+	}
+
+	// ── Guards for uploaded helpers (rocksdb-viewer, asprof) — both modes, shared defaults ──
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd, SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
+		cmd.Flags().IntVar(&helperTimeoutSeconds, conf.KeyHelperTimeoutSeconds, conf.GetIntDefault(stdDef, conf.KeyHelperTimeoutSeconds), "wall-clock limit in seconds for each rocksdb-viewer/asprof run on a node (asprof always gets its profiling duration plus 60s)")
+		cmd.Flags().IntVar(&helperMemoryLimitMB, conf.KeyHelperMemoryLimitMB, conf.GetIntDefault(stdDef, conf.KeyHelperMemoryLimitMB), "memory ceiling in MB for rocksdb-viewer/asprof runs, applied with a systemd cgroup scope when running as root or ulimit -v otherwise (0 = 2048 MB, cgroup scope only; -1 disables)")
+	}
+
 	// ── Per-log day counts — standard mode only ──
-	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd} {
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd} {
 		cmd.Flags().IntVar(&queriesJSONNumDays, conf.KeyQueriesJSONNumDays, conf.GetIntDefault(stdDef, conf.KeyQueriesJSONNumDays), "number of days of queries.json to collect")
 		cmd.Flags().IntVar(&serverLogsNumDays, conf.KeyServerLogsNumDays, conf.GetIntDefault(stdDef, conf.KeyServerLogsNumDays), "number of days of server logs to collect")
 		cmd.Flags().IntVar(&trackerJSONNumDays, conf.KeyTrackerJSONNumDays, conf.GetIntDefault(stdDef, conf.KeyTrackerJSONNumDays), "number of days of tracker.json to collect")
@@ -1409,7 +1737,7 @@ func init() {
 	}
 
 	// ── Diagnosis-only flags ──
-	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd} {
+	for _, cmd := range []*cobra.Command{SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
 		cmd.Flags().StringVar(&cliAuthToken, "dremio-pat-token", "", "Dremio PAT token for API-based collection (env: DDC_PAT_TOKEN)")
 		cmd.Flags().StringVar(&dremioEndpoint, "dremio-endpoint", "", "Dremio REST API endpoint (e.g. http://localhost:9047)")
 		cmd.Flags().BoolVar(&allowInsecureSSL, "allow-insecure-ssl", true, "allow insecure SSL connections to Dremio REST API")
@@ -1419,6 +1747,7 @@ func init() {
 		cmd.Flags().BoolVar(&collectTop, "diag-top", conf.GetBoolDefault(diagDef, conf.KeyCollectTop), "collect top process snapshots")
 		cmd.Flags().BoolVar(&collectHeapDump, "diag-heap-dump", conf.GetBoolDefault(diagDef, conf.KeyCaptureHeapDump), "collect heap dump (requires disk >= -Xmx per pod)")
 		cmd.Flags().BoolVar(&collectAsyncProfiler, "diag-async-profiler", conf.GetBoolDefault(diagDef, conf.KeyCollectAsyncProfiler), "collect async-profiler recording")
+		cmd.Flags().BoolVar(&collectNMT, "diag-nmt", conf.GetBoolDefault(diagDef, conf.KeyCollectNMT), "collect the jcmd VM.native_memory summary (needs -XX:NativeMemoryTracking=summary on the Dremio JVM)")
 		cmd.Flags().BoolVar(&collectGCLogs, "collect-gc-logs", conf.GetBoolDefault(diagDef, conf.KeyCollectGCLogs), "collect GC log files")
 		cmd.Flags().BoolVar(&collectAcceleration, "collect-acceleration-log", conf.GetBoolDefault(diagDef, conf.KeyCollectAccelerationLog), "collect acceleration.log files")
 		cmd.Flags().BoolVar(&collectAccessLog, "collect-access-log", conf.GetBoolDefault(diagDef, conf.KeyCollectAccessLog), "collect access.log files")
@@ -1426,6 +1755,10 @@ func init() {
 		cmd.Flags().IntVar(&diagTimeSeconds, "diag-time-seconds", conf.GetIntDefault(diagDef, conf.KeyDiagTimeSeconds), "duration in seconds for all diagnostic tools (JFR, jstack, top, async-profiler)")
 		cmd.Flags().StringVar(&startDate, "start-date", "", "start of collection date range (date-only, e.g. 2026-03-20). Defaults to now minus --days")
 		cmd.Flags().IntVar(&daysFlag, "days", conf.GetIntDefault(diagDef, conf.KeyDremioLogsNumDays), "number of days to collect from --start-date (default: 3)")
+		cmd.Flags().BoolVar(&trimToWindow, "trim-to-window", false, "keep only the log lines between --since and --until, with the stack traces that follow them")
+		cmd.Flags().StringVar(&sinceFlag, "since", "", "start of the --trim-to-window window, e.g. 2026-03-20T13:30 (UTC unless a zone is given)")
+		cmd.Flags().StringVar(&untilFlag, "until", "", "end of the --trim-to-window window, e.g. 2026-03-20T14:30 (UTC unless a zone is given)")
+		cmd.Flags().StringVar(&logTimezoneFlag, "log-timezone", "", "zone of log timestamps without one for --trim-to-window, e.g. Europe/Berlin (default: each node's UTC offset)")
 	}
 
 	// Wire up subcommands
@@ -1437,10 +1770,13 @@ func init() {
 	LocalCmd.AddCommand(LocalDiagnosisCmd)
 	LocalK8sCmd.AddCommand(LocalK8sStandardCmd)
 	LocalK8sCmd.AddCommand(LocalK8sDiagnosisCmd)
+	DockerCmd.AddCommand(DockerStandardCmd)
+	DockerCmd.AddCommand(DockerDiagnosisCmd)
 	CollectCmd.AddCommand(SSHCmd)
 	CollectCmd.AddCommand(K8sCmd)
 	CollectCmd.AddCommand(LocalCmd)
 	CollectCmd.AddCommand(LocalK8sCmd)
+	CollectCmd.AddCommand(DockerCmd)
 
 	// init
 	cobra.EnableCommandSorting = false
@@ -1448,6 +1784,9 @@ func init() {
 	RootCmd.PersistentFlags().BoolVar(&skipVersionCheck, "skip-version-check", false, "skip checking for newer DDC versions at startup")
 	RootCmd.AddCommand(CollectCmd)
 	RootCmd.AddCommand(version.VersionCmd)
+	RootCmd.AddCommand(extract.ExtractCmd)
+	RootCmd.AddCommand(PreflightCmd)
+	RootCmd.AddCommand(watch.WatchCmd)
 	RootCmd.CompletionOptions.DisableDefaultCmd = true
 }
 
@@ -1527,12 +1866,64 @@ func validateV4Flags(mode collects.CollectionMode, cliMode bool) error {
 			return fmt.Errorf("--start-date must be date-only format (e.g. 2026-03-20), got %q", startDate)
 		}
 	}
+	// --trim-to-window needs a window, and --since / --until only apply to it
+	trimSince, trimUntil = time.Time{}, time.Time{}
+	if trimToWindow && sinceFlag == "" && untilFlag == "" {
+		return fmt.Errorf("--trim-to-window requires --since and/or --until (e.g. --since 2026-03-20T13:30)")
+	}
+	if !trimToWindow && (sinceFlag != "" || untilFlag != "" || logTimezoneFlag != "") {
+		return fmt.Errorf("--since, --until and --log-timezone only apply with --trim-to-window")
+	}
+	logTimezone = nil
+	if logTimezoneFlag != "" {
+		loc, err := time.LoadLocation(logTimezoneFlag)
+		if err != nil {
+			return fmt.Errorf("--log-timezone: %w", err)
+		}
+		logTimezone = loc
+	}
+	if sinceFlag != "" {
+		t, err := logparser.ParseWindowTime(sinceFlag)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		trimSince = t
+	}
+	if untilFlag != "" {
+		t, err := logparser.ParseWindowTime(untilFlag)
+		if err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+		trimUntil = t
+	}
+	if !trimSince.IsZero() && !trimUntil.IsZero() && trimUntil.Before(trimSince) {
+		return fmt.Errorf("--until %v is before --since %v", untilFlag, sinceFlag)
+	}
 	// PAT-dependent collections are silently disabled when no PAT is provided.
 	// The warning at the end of this function covers user notification.
 	// --nodes and --exclude-nodes are mutually exclusive
 	if nodesFlag != "" && excludeNodesFlag != "" {
 		return fmt.Errorf("--nodes and --exclude-nodes are mutually exclusive — use one or the other")
 	}
+	// a plan already fixes the nodes and files
+	if dryRun && planFile != "" {
+		return fmt.Errorf("--dry-run and --plan are mutually exclusive — write the plan first, then collect it")
+	}
+	if preflightRun && (dryRun || planFile != "") {
+		return fmt.Errorf("--preflight only checks the nodes, drop --dry-run / --plan")
+	}
+	if preflightJSON && !preflightRun {
+		return fmt.Errorf("--json only applies to the --preflight report")
+	}
+	if planFile != "" && (nodesFlag != "" || excludeNodesFlag != "") {
+		return fmt.Errorf("--plan already lists the nodes to collect, drop --nodes / --exclude-nodes")
+	}
+	// Fail fast on unreadable certificates or a malformed proxy url
+	if dremioCACert != "" || dremioClientCert != "" || dremioClientKey != "" || httpProxy != "" {
+		if _, err := restclient.NewTransport(restClientConfig()); err != nil {
+			return fmt.Errorf("invalid REST client TLS/proxy settings: %w", err)
+		}
+	}
 	// Diagnosis without PAT: warn
 	if mode == collects.DiagnosisCollection && cliAuthToken == "" {
 		simplelog.Warning("PAT token not provided — job profiles, system tables, WLM, and KV store report will not be collected")
@@ -1541,6 +1932,18 @@ func validateV4Flags(mode collects.CollectionMode, cliMode bool) error {
 	return nil
 }
 
+// restClientConfig gathers the REST TLS and proxy flags into the configuration
+// shared by the PAT pre-check and every API collection.
+func restClientConfig() restclient.ClientConfig {
+	return restclient.ClientConfig{
+		AllowInsecureSSL: allowInsecureSSL,
+		CACertFile:       dremioCACert,
+		ClientCertFile:   dremioClientCert,
+		ClientKeyFile:    dremioClientKey,
+		HTTPProxy:        httpProxy,
+	}
+}
+
 // populateDetectedPaths ensures transport info from CLI globals is available in the DetectedPaths
 // for CLI command generation. Shared by both config screen launchers.
 func populateDetectedPaths(detected *configui.DetectedPaths) *configui.DetectedPaths {
@@ -1548,6 +1951,7 @@ func populateDetectedPaths(detected *configui.DetectedPaths) *configui.DetectedP
 		detected = &configui.DetectedPaths{}
 	}
 	detected.Transport = transportCmd
+	detected.RestClient = restClientConfig()
 	if detected.Namespace == "" {
 		detected.Namespace = namespace
 	}
@@ -1569,6 +1973,8 @@ func populateDetectedPaths(detected *configui.DetectedPaths) *configui.DetectedP
 	if detected.DremioHome == "" {
 		detected.DremioHome = dremioHome
 	}
+	detected.Preset = activePreset
+	detected.PresetFile = presetFile
 	return detected
 }
 
@@ -1699,6 +2105,7 @@ func runDiagnosisConfigScreen(detected *configui.DetectedPaths) error {
 	collectTop = cfg.CollectTop
 	collectHeapDump = cfg.CollectHeapDump
 	collectAsyncProfiler = cfg.CollectAsyncProfiler
+	collectNMT = cfg.CollectNMT
 	diagTimeSeconds = cfg.DiagTimeSeconds
 	collectServerLogs = cfg.CollectServerLogs
 	collectGCLogs = cfg.CollectGCLogs
@@ -21,6 +21,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 	"sync"
@@ -49,6 +50,11 @@ type ExecuteCliErr struct {
 	Cmd string
 }
 
+// MaxOutputLineBytes is the longest single line ExecuteAndStreamOutput will pass to a handler.
+// Helpers such as rocksdb-viewer emit one JSON document per line, which can be far
+// larger than bufio.Scanner's 64KB default.
+const MaxOutputLineBytes = 64 * 1024 * 1024
+
 // OutputHandler is a function type that processes lines of output
 type OutputHandler func(line string)
 
@@ -98,6 +104,7 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 		return UnableToStartErr{Err: err, Cmd: strings.Join(args, " ")}
 	}
 	stdOutScanner := bufio.NewScanner(stdout)
+	stdOutScanner.Buffer(make([]byte, 0, 64*1024), MaxOutputLineBytes)
 
 	// Create a pipe to get the error output from the command
 	stderr, err := cmd.StderrPipe()
@@ -105,6 +112,7 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 		return UnableToStartErr{Err: err, Cmd: strings.Join(args, " ")}
 	}
 	stdErrScanner := bufio.NewScanner(stderr)
+	stdErrScanner.Buffer(make([]byte, 0, 64*1024), MaxOutputLineBytes)
 
 	if pat != "" {
 		buff := bytes.Buffer{}
@@ -120,6 +128,7 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 	}
 	var mut sync.Mutex
 	var waitGroup sync.WaitGroup
+	var scanErr error
 	waitGroup.Add(1)
 	// Asynchronously read the output from the command line by line
 	// and pass it to the outputHandler. This runs in a goroutine
@@ -130,6 +139,13 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 			outputHandler(stdOutScanner.Text())
 			mut.Unlock()
 		}
+		if err := stdOutScanner.Err(); err != nil {
+			// keep draining so the command is not blocked on a full pipe
+			_, _ = io.Copy(io.Discard, stdout)
+			mut.Lock()
+			scanErr = err
+			mut.Unlock()
+		}
 		waitGroup.Done()
 	}()
 
@@ -142,6 +158,12 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 			outputHandler(stdErrScanner.Text())
 			mut.Unlock()
 		}
+		if err := stdErrScanner.Err(); err != nil {
+			_, _ = io.Copy(io.Discard, stderr)
+			mut.Lock()
+			scanErr = err
+			mut.Unlock()
+		}
 		waitGroup.Done()
 	}()
 
@@ -152,6 +174,9 @@ func (c *cli) ExecuteAndStreamOutput(mask bool, outputHandler OutputHandler, pat
 	if err := cmd.Wait(); err != nil {
 		return UnableToStartErr{Err: err, Cmd: strings.Join(args, " ")}
 	}
+	if scanErr != nil {
+		return UnableToStartErr{Err: fmt.Errorf("reading output: %w", scanErr), Cmd: strings.Join(args, " ")}
+	}
 
 	// If there was no error, return nil
 	return nil
@@ -99,6 +99,23 @@ func TestExecuteAndStreamOutput_WithCommandProducesStderr(t *testing.T) {
 	}
 }
 
+func TestExecuteAndStreamOutput_WithLineLongerThanScannerDefault(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses head and tr")
+	}
+	var lines []string
+	handler := func(line string) {
+		lines = append(lines, line)
+	}
+	err := c.ExecuteAndStreamOutput(false, handler, "", "sh", "-c", "head -c 1048576 /dev/zero | tr '\\0' x; echo; echo done")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || len(lines[0]) != 1048576 || lines[1] != "done" {
+		t.Errorf("expected a 1MB line followed by done, got %d lines", len(lines))
+	}
+}
+
 func TestExecuteAndStreamOutput_WithInvalidCommand(t *testing.T) {
 	setupTestCLI()
 	err := c.ExecuteAndStreamOutput(false, outputHandler, "", "22JIDJMJMHHF")
@@ -28,13 +28,13 @@ import (
 
 // APICollectionArgs holds configuration for orchestrator-side REST API collections.
 type APICollectionArgs struct {
-	TmpDir           string
-	CoordinatorNode  string
-	DremioEndpoint   string
-	DremioPAT        string
-	AllowInsecureSSL bool
-	RestHTTPTimeout  int
-	Hook             shutdown.Hook
+	TmpDir          string
+	CoordinatorNode string
+	DremioEndpoint  string
+	DremioPAT       string
+	RestClient      restclient.ClientConfig
+	RestHTTPTimeout int
+	Hook            shutdown.Hook
 }
 
 // RunCollectKVStore fetches the KV store report from the Dremio REST API.
@@ -44,7 +44,9 @@ func RunCollectKVStore(args APICollectionArgs) error {
 		return nil
 	}
 
-	restclient.InitClient(args.AllowInsecureSSL, args.RestHTTPTimeout)
+	if err := restclient.InitClientWithConfig(args.RestClient, args.RestHTTPTimeout); err != nil {
+		return fmt.Errorf("unable to initialize REST client: %w", err)
+	}
 
 	outDir := filepath.Join(args.TmpDir, "kvstore", args.CoordinatorNode)
 	if err := os.MkdirAll(outDir, 0o700); err != nil {
@@ -60,14 +62,13 @@ func RunCollectKVStore(args APICollectionArgs) error {
 	simplelog.Info("Collecting KV store report...")
 	url := args.DremioEndpoint + "/apiv2/kvstore/report"
 	headers := map[string]string{"Accept": "application/octet-stream"}
-	body, err := restclient.APIRequest(hook, url, args.DremioPAT, "GET", headers)
+	outFile := filepath.Join(outDir, "kvstore-report.zip")
+	size, err := restclient.DownloadToFile(hook, url, args.DremioPAT, "GET", headers, outFile, consoleprint.UpdateArchiveProgress)
+	// Reset progress bar so it doesn't linger after the download.
+	consoleprint.UpdateArchiveProgress(0, 0)
 	if err != nil {
 		return fmt.Errorf("unable to retrieve KV store report from %v: %w", url, err)
 	}
-	outFile := filepath.Join(outDir, "kvstore-report.zip")
-	if err := os.WriteFile(outFile, body, 0o600); err != nil {
-		return fmt.Errorf("unable to write %v: %w", outFile, err)
-	}
-	simplelog.Info("Collected KV store report")
+	simplelog.Infof("Collected KV store report (%d bytes)", size)
 	return nil
 }
@@ -44,13 +44,13 @@ func TestRunCollectKVStore(t *testing.T) {
 
 	tmpDir := t.TempDir()
 	args := APICollectionArgs{
-		TmpDir:           tmpDir,
-		CoordinatorNode:  "dremio-master-0",
-		DremioEndpoint:   server.URL,
-		DremioPAT:        "test-token",
-		AllowInsecureSSL: false,
-		RestHTTPTimeout:  30,
-		Hook:             hook,
+		TmpDir:          tmpDir,
+		CoordinatorNode: "dremio-master-0",
+		DremioEndpoint:  server.URL,
+		DremioPAT:       "test-token",
+		RestClient:      restclient.ClientConfig{AllowInsecureSSL: false},
+		RestHTTPTimeout: 30,
+		Hook:            hook,
 	}
 
 	err := RunCollectKVStore(args)
@@ -123,4 +123,27 @@ func TestFindClusterIDFromOrchestrator(t *testing.T) {
 	if results[0].NodeName != nodeName {
 		t.Errorf("expected nodeName %v, got %v", nodeName, results[0].NodeName)
 	}
+
+	// rocksdb-viewer writes the gzip form
+	gzDir := filepath.Join(tmpDir, "cluster-stats", "dremio-master-1")
+	if err := os.MkdirAll(gzDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	w, err := newGzipLineWriter(filepath.Join(gzDir, "cluster-stats.json.gz"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteLine(`{"clusterID":"test-cluster-123","nodeName":"dremio-master-1"}`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	results, err = FindClusterID(tmpDir)
+	if err != nil {
+		t.Fatalf("FindClusterID failed: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results including cluster-stats.json.gz, got %d", len(results))
+	}
 }
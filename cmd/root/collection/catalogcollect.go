@@ -0,0 +1,279 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/restclient"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+const (
+	// DefaultCatalogMaxDepth bounds how far below the catalog root the walk descends.
+	DefaultCatalogMaxDepth = 5
+	// DefaultCatalogMaxItems bounds the total number of catalog entries visited.
+	DefaultCatalogMaxItems = 10000
+)
+
+// CatalogCollectArgs holds configuration for the catalog inventory collection.
+type CatalogCollectArgs struct {
+	APICollectionArgs
+	MaxDepth int
+	MaxItems int
+}
+
+// CatalogCounts is the per-type tally written to catalog-inventory.json.
+type CatalogCounts struct {
+	Sources     int `json:"sources"`
+	Spaces      int `json:"spaces"`
+	Homes       int `json:"homes"`
+	Folders     int `json:"folders"`
+	VDS         int `json:"vds"`
+	PDS         int `json:"pds"`
+	Reflections int `json:"reflections"`
+}
+
+// CatalogInventory summarises a catalog walk, including whether limits cut it short.
+type CatalogInventory struct {
+	Counts         CatalogCounts `json:"counts"`
+	ItemsVisited   int           `json:"itemsVisited"`
+	MaxDepth       int           `json:"maxDepth"`
+	MaxItems       int           `json:"maxItems"`
+	DepthTruncated bool          `json:"depthTruncated"`
+	ItemsTruncated bool          `json:"itemsTruncated"`
+	Errors         []string      `json:"errors,omitempty"`
+}
+
+// catalogEntry is the subset of a /api/v3/catalog listing entry used to drive the walk.
+type catalogEntry struct {
+	ID            string   `json:"id"`
+	Path          []string `json:"path"`
+	Type          string   `json:"type"`
+	ContainerType string   `json:"containerType"`
+	DatasetType   string   `json:"datasetType"`
+}
+
+type catalogListing struct {
+	Data []catalogEntry `json:"data"`
+}
+
+type catalogContainer struct {
+	Children []catalogEntry `json:"children"`
+}
+
+type catalogWalkItem struct {
+	entry catalogEntry
+	depth int
+}
+
+// RunCollectCatalog walks /api/v3/catalog and /api/v3/reflection and writes a
+// source, reflection and object count inventory to catalog/<coordinator>.
+// Source credentials are masked before anything is written to disk.
+func RunCollectCatalog(args CatalogCollectArgs) error {
+	if args.DremioPAT == "" {
+		simplelog.Info("Skipping catalog inventory collection: no PAT token provided")
+		return nil
+	}
+	if args.MaxDepth <= 0 {
+		args.MaxDepth = DefaultCatalogMaxDepth
+	}
+	if args.MaxItems <= 0 {
+		args.MaxItems = DefaultCatalogMaxItems
+	}
+
+	if err := restclient.InitClientWithConfig(args.RestClient, args.RestHTTPTimeout); err != nil {
+		return fmt.Errorf("unable to initialize REST client: %w", err)
+	}
+
+	outDir := filepath.Join(args.TmpDir, "catalog", args.CoordinatorNode)
+	if err := os.MkdirAll(outDir, 0o700); err != nil {
+		return fmt.Errorf("unable to create catalog output directory %v: %w", outDir, err)
+	}
+
+	hook, ok := args.Hook.(shutdown.CancelHook)
+	if !ok {
+		return errors.New("hook does not implement CancelHook")
+	}
+
+	consoleprint.UpdateResult("Collecting catalog inventory...")
+	simplelog.Info("Collecting catalog inventory...")
+	headers := map[string]string{"Accept": "application/json"}
+	get := func(path string) ([]byte, error) {
+		return restclient.APIRequest(hook, args.DremioEndpoint+path, args.DremioPAT, "GET", headers)
+	}
+
+	body, err := get("/api/v3/catalog")
+	if err != nil {
+		return fmt.Errorf("unable to list catalog from %v: %w", args.DremioEndpoint, err)
+	}
+	var root catalogListing
+	if err := json.Unmarshal(body, &root); err != nil {
+		return fmt.Errorf("unable to parse catalog listing: %w", err)
+	}
+
+	inventory := CatalogInventory{MaxDepth: args.MaxDepth, MaxItems: args.MaxItems}
+	var sources []map[string]interface{}
+	queue := make([]catalogWalkItem, 0, len(root.Data))
+	for _, e := range root.Data {
+		queue = append(queue, catalogWalkItem{entry: e, depth: 1})
+	}
+
+	for len(queue) > 0 {
+		if hook.GetContext().Err() != nil {
+			return fmt.Errorf("catalog inventory collection cancelled: %w", hook.GetContext().Err())
+		}
+		item := queue[0]
+		queue = queue[1:]
+		if inventory.ItemsVisited >= args.MaxItems {
+			inventory.ItemsTruncated = true
+			break
+		}
+		inventory.ItemsVisited++
+		countCatalogEntry(&inventory.Counts, item.entry)
+
+		if item.entry.Type != "CONTAINER" || item.entry.ContainerType == "FUNCTION" {
+			continue
+		}
+		if item.depth >= args.MaxDepth && item.entry.ContainerType != "SOURCE" {
+			inventory.DepthTruncated = true
+			continue
+		}
+		detail, err := get("/api/v3/catalog/" + url.PathEscape(item.entry.ID))
+		if err != nil {
+			msg := fmt.Sprintf("unable to read catalog entry %v: %v", item.entry.Path, err)
+			simplelog.Warning(msg)
+			inventory.Errors = append(inventory.Errors, msg)
+			continue
+		}
+		if item.entry.ContainerType == "SOURCE" {
+			source, err := maskedSource(detail)
+			if err != nil {
+				msg := fmt.Sprintf("unable to parse source %v: %v", item.entry.Path, err)
+				simplelog.Warning(msg)
+				inventory.Errors = append(inventory.Errors, msg)
+			} else {
+				sources = append(sources, source)
+			}
+			if item.depth >= args.MaxDepth {
+				inventory.DepthTruncated = true
+				continue
+			}
+		}
+		var container catalogContainer
+		if err := json.Unmarshal(detail, &container); err != nil {
+			msg := fmt.Sprintf("unable to parse children of %v: %v", item.entry.Path, err)
+			simplelog.Warning(msg)
+			inventory.Errors = append(inventory.Errors, msg)
+			continue
+		}
+		for _, child := range container.Children {
+			queue = append(queue, catalogWalkItem{entry: child, depth: item.depth + 1})
+		}
+	}
+	if inventory.ItemsTruncated {
+		simplelog.Warningf("catalog inventory stopped after %d items (limit reached)", args.MaxItems)
+	}
+
+	reflections, err := get("/api/v3/reflection")
+	if err != nil {
+		msg := fmt.Sprintf("unable to list reflections: %v", err)
+		simplelog.Warning(msg)
+		inventory.Errors = append(inventory.Errors, msg)
+	} else {
+		var listing struct {
+			Data []json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(reflections, &listing); err != nil {
+			msg := fmt.Sprintf("unable to parse reflection listing: %v", err)
+			simplelog.Warning(msg)
+			inventory.Errors = append(inventory.Errors, msg)
+		} else {
+			inventory.Counts.Reflections = len(listing.Data)
+			if err := writeCatalogJSON(filepath.Join(outDir, "reflections.json"), listing); err != nil {
+				return err
+			}
+		}
+	}
+
+	if sources == nil {
+		sources = []map[string]interface{}{}
+	}
+	if err := writeCatalogJSON(filepath.Join(outDir, "sources.json"), sources); err != nil {
+		return err
+	}
+	if err := writeCatalogJSON(filepath.Join(outDir, "catalog-inventory.json"), inventory); err != nil {
+		return err
+	}
+	simplelog.Infof("Collected catalog inventory: %d sources, %d spaces, %d folders, %d VDS, %d PDS, %d reflections",
+		inventory.Counts.Sources, inventory.Counts.Spaces, inventory.Counts.Folders, inventory.Counts.VDS, inventory.Counts.PDS, inventory.Counts.Reflections)
+	return nil
+}
+
+func countCatalogEntry(counts *CatalogCounts, e catalogEntry) {
+	switch e.Type {
+	case "CONTAINER":
+		switch e.ContainerType {
+		case "SOURCE":
+			counts.Sources++
+		case "SPACE":
+			counts.Spaces++
+		case "HOME":
+			counts.Homes++
+		case "FOLDER":
+			counts.Folders++
+		}
+	case "DATASET":
+		switch e.DatasetType {
+		case "VIRTUAL":
+			counts.VDS++
+		case "PROMOTED", "DIRECT":
+			counts.PDS++
+		}
+	}
+}
+
+// maskedSource keeps the identifying fields of a source and its masked config,
+// dropping the children listing which is already reflected in the counts.
+func maskedSource(detail []byte) (map[string]interface{}, error) {
+	var source map[string]interface{}
+	if err := json.Unmarshal(detail, &source); err != nil {
+		return nil, err
+	}
+	delete(source, "children")
+	if config, ok := source["config"].(map[string]interface{}); ok {
+		masking.MaskDremioSourceConfig(config)
+	}
+	return source, nil
+}
+
+func writeCatalogJSON(outFile string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return fmt.Errorf("unable to marshal %v: %w", outFile, err)
+	}
+	if err := os.WriteFile(outFile, data, 0o600); err != nil {
+		return fmt.Errorf("unable to write %v: %w", outFile, err)
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+)
+
+func newCatalogServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"/api/v3/catalog": `{"data":[
+			{"id":"src1","path":["s3"],"type":"CONTAINER","containerType":"SOURCE"},
+			{"id":"sp1","path":["Sales"],"type":"CONTAINER","containerType":"SPACE"},
+			{"id":"home1","path":["@admin"],"type":"CONTAINER","containerType":"HOME"}
+		]}`,
+		"/api/v3/catalog/src1": `{"entityType":"source","id":"src1","name":"s3","type":"S3",
+			"config":{"accessKey":"AKIAEXAMPLE","accessSecret":"very-secret","secure":true},
+			"children":[{"id":"pds1","path":["s3","t1"],"type":"DATASET","datasetType":"PROMOTED"}]}`,
+		"/api/v3/catalog/sp1": `{"entityType":"space","id":"sp1","children":[
+			{"id":"f1","path":["Sales","reports"],"type":"CONTAINER","containerType":"FOLDER"},
+			{"id":"v1","path":["Sales","v1"],"type":"DATASET","datasetType":"VIRTUAL"}
+		]}`,
+		"/api/v3/catalog/f1": `{"entityType":"folder","id":"f1","children":[
+			{"id":"v2","path":["Sales","reports","v2"],"type":"DATASET","datasetType":"VIRTUAL"}
+		]}`,
+		"/api/v3/catalog/home1": `{"entityType":"home","id":"home1","children":[]}`,
+		"/api/v3/reflection":    `{"data":[{"id":"r1","type":"RAW"},{"id":"r2","type":"AGGREGATION"}]}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func readInventory(t *testing.T, dir string) CatalogInventory {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, "catalog-inventory.json"))
+	if err != nil {
+		t.Fatalf("expected catalog-inventory.json to exist: %v", err)
+	}
+	var inv CatalogInventory
+	if err := json.Unmarshal(data, &inv); err != nil {
+		t.Fatalf("unable to parse inventory: %v", err)
+	}
+	return inv
+}
+
+func TestRunCollectCatalog(t *testing.T) {
+	server := newCatalogServer(t)
+	defer server.Close()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	tmpDir := t.TempDir()
+	err := RunCollectCatalog(CatalogCollectArgs{
+		APICollectionArgs: APICollectionArgs{
+			TmpDir:          tmpDir,
+			CoordinatorNode: "dremio-master-0",
+			DremioEndpoint:  server.URL,
+			DremioPAT:       "test-token",
+			RestHTTPTimeout: 30,
+			Hook:            hook,
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunCollectCatalog failed: %v", err)
+	}
+
+	outDir := filepath.Join(tmpDir, "catalog", "dremio-master-0")
+	inv := readInventory(t, outDir)
+	expected := CatalogCounts{Sources: 1, Spaces: 1, Homes: 1, Folders: 1, VDS: 2, PDS: 1, Reflections: 2}
+	if inv.Counts != expected {
+		t.Errorf("expected counts %+v, got %+v", expected, inv.Counts)
+	}
+	if inv.DepthTruncated || inv.ItemsTruncated {
+		t.Errorf("did not expect truncation: %+v", inv)
+	}
+
+	sources, err := os.ReadFile(filepath.Join(outDir, "sources.json"))
+	if err != nil {
+		t.Fatalf("expected sources.json to exist: %v", err)
+	}
+	for _, secret := range []string{"AKIAEXAMPLE", "very-secret"} {
+		if strings.Contains(string(sources), secret) {
+			t.Errorf("expected %q to be masked in sources.json: %v", secret, string(sources))
+		}
+	}
+	if !strings.Contains(string(sources), `"S3"`) {
+		t.Errorf("expected source type in sources.json: %v", string(sources))
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "reflections.json")); err != nil {
+		t.Errorf("expected reflections.json to exist: %v", err)
+	}
+}
+
+func TestRunCollectCatalog_Limits(t *testing.T) {
+	server := newCatalogServer(t)
+	defer server.Close()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	tmpDir := t.TempDir()
+	err := RunCollectCatalog(CatalogCollectArgs{
+		APICollectionArgs: APICollectionArgs{
+			TmpDir:          tmpDir,
+			CoordinatorNode: "dremio-master-0",
+			DremioEndpoint:  server.URL,
+			DremioPAT:       "test-token",
+			RestHTTPTimeout: 30,
+			Hook:            hook,
+		},
+		MaxDepth: 1,
+		MaxItems: 100,
+	})
+	if err != nil {
+		t.Fatalf("RunCollectCatalog failed: %v", err)
+	}
+	inv := readInventory(t, filepath.Join(tmpDir, "catalog", "dremio-master-0"))
+	if !inv.DepthTruncated {
+		t.Error("expected depth truncation with MaxDepth=1")
+	}
+	if inv.Counts.Folders != 0 || inv.Counts.VDS != 0 {
+		t.Errorf("expected no entries below the root, got %+v", inv.Counts)
+	}
+	if inv.Counts.Sources != 1 {
+		t.Errorf("expected the source to still be counted, got %+v", inv.Counts)
+	}
+
+	tmpDir = t.TempDir()
+	err = RunCollectCatalog(CatalogCollectArgs{
+		APICollectionArgs: APICollectionArgs{
+			TmpDir:          tmpDir,
+			CoordinatorNode: "dremio-master-0",
+			DremioEndpoint:  server.URL,
+			DremioPAT:       "test-token",
+			RestHTTPTimeout: 30,
+			Hook:            hook,
+		},
+		MaxItems: 2,
+	})
+	if err != nil {
+		t.Fatalf("RunCollectCatalog failed: %v", err)
+	}
+	inv = readInventory(t, filepath.Join(tmpDir, "catalog", "dremio-master-0"))
+	if !inv.ItemsTruncated || inv.ItemsVisited != 2 {
+		t.Errorf("expected walk to stop at 2 items, got %+v", inv)
+	}
+}
+
+func TestRunCollectCatalog_NoPAT(t *testing.T) {
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+	tmpDir := t.TempDir()
+	if err := RunCollectCatalog(CatalogCollectArgs{APICollectionArgs: APICollectionArgs{TmpDir: tmpDir, Hook: hook}}); err != nil {
+		t.Fatalf("expected no error when PAT is empty, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "catalog")); !os.IsNotExist(err) {
+		t.Errorf("expected no catalog directory without a PAT")
+	}
+}
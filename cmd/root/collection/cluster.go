@@ -16,12 +16,10 @@
 package collection
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"path/filepath"
 	"time"
 
@@ -37,8 +35,8 @@ import (
 
 var clusterRequestTimeout = 120
 
-func ClusterK8sExecute(hook shutdown.CancelHook, namespace string, c *k8sapi.Clientset, cs CopyStrategy, ddfs helpers.Filesystem) error {
-	cmds := []string{"nodes", "sc", "pvc", "pv", "service", "endpoints", "pods", "deployments", "statefulsets", "daemonset", "replicaset", "cronjob", "job", "ingress", "limitrange", "resourcequota", "hpa", "pdb", "pc", "events"}
+func ClusterK8sExecute(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem) error {
+	cmds := []string{"nodes", "sc", "pvc", "pv", "service", "endpoints", "pods", "deployments", "statefulsets", "daemonset", "replicaset", "cronjob", "job", "ingress", "limitrange", "resourcequota", "hpa", "pdb", "pc", "events", "configmaps", "secrets", "serviceaccounts", "roles", "rolebindings", "networkpolicies"}
 	path, err := cs.CreatePath("kubernetes", "", "")
 	if err != nil {
 		simplelog.Errorf("trying to construct cluster config path %v with error %v", path, err)
@@ -66,6 +64,9 @@ func ClusterK8sExecute(hook shutdown.CancelHook, namespace string, c *k8sapi.Cli
 			continue
 		}
 	}
+	collectCustomResources(hook, namespace, c, cs, ddfs)
+	collectOpenShiftResources(hook, namespace, c, cs, ddfs)
+	collectHelmReleases(hook, namespace, c, cs, ddfs)
 	return nil
 }
 
@@ -73,7 +74,7 @@ func ClusterK8sExecute(hook shutdown.CancelHook, namespace string, c *k8sapi.Cli
 // This runs unconditionally (not gated by --collect-container-logs) so that restart evidence is always captured.
 // When labelSelector is empty, all pods in the namespace are listed.
 // When labelSelector is non-empty, only pods matching the selector are listed.
-func GetPreviousLogsForRestartedPods(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, labelSelector string) error {
+func GetPreviousLogsForRestartedPods(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, labelSelector string, opts ContainerLogOptions) error {
 	path, err := cs.CreatePath("kubernetes", "container-logs", "")
 	if err != nil {
 		simplelog.Errorf("trying to construct cluster container log path %v with error %v", path, err)
@@ -98,7 +99,7 @@ func GetPreviousLogsForRestartedPods(hook shutdown.CancelHook, namespace string,
 			continue
 		}
 		consoleprint.UpdateResult(fmt.Sprintf("Collecting previous logs for restarted pods (%d/%d): %s...", i+1, len(pods.Items), podObj.Name))
-		savePreviousLogsFromPod(podObj, hook, cs, ddfs, namespace, clientSet, path)
+		savePreviousLogsFromPod(podObj, hook, cs, ddfs, namespace, clientSet, path, opts)
 	}
 	return nil
 }
@@ -117,7 +118,7 @@ func podHasRestarts(pod corev1.Pod) bool {
 	return false
 }
 
-func savePreviousLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, namespace string, c k8sapi.Interface, path string) {
+func savePreviousLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, namespace string, c k8sapi.Interface, path string, opts ContainerLogOptions) {
 	podName := podObj.Name
 	var containers []string
 	for _, c := range podObj.Spec.Containers {
@@ -127,14 +128,14 @@ func savePreviousLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs Cop
 		containers = append(containers, c.Name)
 	}
 	for _, container := range containers {
-		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, true)
+		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, true, opts)
 	}
 }
 
 // GetClusterLogs collects current container logs from pods in the namespace.
 // When labelSelector is empty, all pods in the namespace are listed.
 // When labelSelector is non-empty, only pods matching the selector are listed.
-func GetClusterLogs(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, labelSelector string) error {
+func GetClusterLogs(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, labelSelector string, opts ContainerLogOptions) error {
 	path, err := cs.CreatePath("kubernetes", "container-logs", "")
 	if err != nil {
 		simplelog.Errorf("trying to construct cluster container log path %v with error %v", path, err)
@@ -157,12 +158,12 @@ func GetClusterLogs(hook shutdown.CancelHook, namespace string, clientSet k8sapi
 	// Loop over pods
 	for i, podObj := range pods.Items {
 		consoleprint.UpdateResult(fmt.Sprintf("Collecting K8s container logs (%d/%d): %s...", i+1, len(pods.Items), podObj.Name))
-		saveLogsFromPod(podObj, hook, cs, ddfs, namespace, clientSet, path)
+		saveLogsFromPod(podObj, hook, cs, ddfs, namespace, clientSet, path, opts)
 	}
 	return nil
 }
 
-func saveLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, namespace string, c k8sapi.Interface, path string) {
+func saveLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, namespace string, c k8sapi.Interface, path string, opts ContainerLogOptions) {
 	podName := podObj.Name
 	var containers []string
 	for _, c := range podObj.Spec.Containers {
@@ -175,20 +176,27 @@ func saveLogsFromPod(podObj corev1.Pod, hook shutdown.CancelHook, cs CopyStrateg
 	// write the output of the kubectl logs command to a file
 	for _, container := range containers {
 		// save previous logs if present
-		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, true)
+		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, true, opts)
 		// save current logs
-		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, false)
+		copyContainerLog(hook, cs, ddfs, container, namespace, c, path, podName, false, opts)
 	}
 }
 
-func copyContainerLog(hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, container, namespace string, client k8sapi.Interface, path string, pod string, previous bool) {
+func copyContainerLog(hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Filesystem, container, namespace string, client k8sapi.Interface, path string, pod string, previous bool, opts ContainerLogOptions) {
 	timeoutDuration := time.Duration(clusterRequestTimeout) * time.Second
 	ctx, timeout := context.WithTimeoutCause(hook.GetContext(), timeoutDuration, fmt.Errorf("while copying container %s from pod %s in namespace %s timeout exceeded %v", container, pod, namespace, timeoutDuration))
 	defer timeout() // releases resources if slowOperation completes before timeout elapses
-	req := client.CoreV1().Pods(namespace).GetLogs(pod, &corev1.PodLogOptions{
+	logOpts := &corev1.PodLogOptions{
 		Container: container,
 		Previous:  previous,
-	})
+	}
+	if !opts.SinceTime.IsZero() {
+		logOpts.SinceTime = &metav1.Time{Time: opts.SinceTime}
+	}
+	if tail := opts.TailLines(); tail > 0 {
+		logOpts.TailLines = &tail
+	}
+	req := client.CoreV1().Pods(namespace).GetLogs(pod, logOpts)
 	r, err := req.Stream(ctx)
 	if err != nil {
 		switch ctx.Err() {
@@ -201,23 +209,11 @@ func copyContainerLog(hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Fi
 	}
 	defer func() { _ = r.Close() }()
 
-	buf := new(bytes.Buffer)
-	_, err = io.Copy(buf, r)
-	if err != nil {
-		switch ctx.Err() {
-		case context.DeadlineExceeded:
-			simplelog.Errorf("%v", context.Cause(ctx))
-		default:
-			simplelog.Warningf("unable to copy log into string for pod: %v container: %v with error: %v", pod, container, err)
-			return
-		}
-	}
-	out := buf.String()
 	var outFile string
 	if previous {
-		outFile = filepath.Join(path, pod+"-"+container+"-previous.txt")
+		outFile = filepath.Join(path, pod+"-"+container+"-previous.txt.gz")
 	} else {
-		outFile = filepath.Join(path, pod+"-"+container+".txt")
+		outFile = filepath.Join(path, pod+"-"+container+".txt.gz")
 	}
 	simplelog.Debugf("getting logs for pod: %v container: %v", pod, container)
 	p, err := cs.CreatePath("kubernetes", "container-logs", "")
@@ -225,17 +221,33 @@ func copyContainerLog(hook shutdown.CancelHook, cs CopyStrategy, ddfs helpers.Fi
 		simplelog.Errorf("trying to create container log path \n%v \nwith error \n%v", p, err)
 		return
 	}
-	// Write out the logs to a file
-	err = ddfs.WriteFile(outFile, []byte(out), DirPerms)
+	// Stream the log through gzip, keeping only the tail when a limit is set
+	f, uncompressed, truncated, err := writeContainerLog(ddfs, outFile, r, opts.LimitBytes)
 	if err != nil {
-		simplelog.Errorf("trying to write file %v, error was %v", outFile, err)
+		if f.Path == "" {
+			simplelog.Errorf("trying to write file %v, error was %v", outFile, err)
+			return
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			// keep what arrived before the timeout
+			simplelog.Errorf("%v", context.Cause(ctx))
+		default:
+			simplelog.Warningf("unable to copy log for pod: %v container: %v with error: %v", pod, container, err)
+			removeContainerLog(ddfs, outFile)
+			return
+		}
 	}
+	if truncated {
+		simplelog.Infof("container log for pod: %v container: %v cut to the last %v bytes", pod, container, opts.LimitBytes)
+	}
+	containerLogTotals.record(pod, f, uncompressed, truncated)
 }
 
 // Execute commands at the cluster level
 // Calls a raw execute function and simply writes out the byte array read from the response
 // that comes in directly from kubectl
-func clusterExecuteBytes(hook shutdown.CancelHook, namespace string, c *k8sapi.Clientset, resource string) ([]byte, error) {
+func clusterExecuteBytes(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, resource string) ([]byte, error) {
 	options := metav1.ListOptions{}
 	var b []byte
 	timeoutDuration := 60 * time.Second
@@ -642,6 +654,126 @@ func clusterExecuteBytes(hook shutdown.CancelHook, namespace string, c *k8sapi.C
 		if err != nil {
 			return []byte(""), err
 		}
+	case "configmaps":
+		list, err := c.CoreV1().ConfigMaps(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		list.Kind = "list"
+		for i, c := range list.Items {
+			c.Kind = "ConfigMap"
+			c.APIVersion = "v1"
+			// dremio.conf and friends usually live here on K8s
+			masking.MaskConfigMapData(c.Data)
+			for k := range c.BinaryData {
+				c.BinaryData[k] = nil
+			}
+			list.Items[i] = c
+		}
+		b, err = json.Marshal(list)
+		if err != nil {
+			return []byte(""), err
+		}
+	case "secrets":
+		list, err := c.CoreV1().Secrets(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		// never marshal the Secret itself, only what secretMetadataList keeps
+		b, err = json.Marshal(secretMetadataList(list.Items))
+		if err != nil {
+			return []byte(""), err
+		}
+	case "serviceaccounts":
+		list, err := c.CoreV1().ServiceAccounts(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		list.Kind = "list"
+		for i, c := range list.Items {
+			c.Kind = "ServiceAccount"
+			c.APIVersion = "v1"
+			list.Items[i] = c
+		}
+		b, err = json.Marshal(list)
+		if err != nil {
+			return []byte(""), err
+		}
+	case "roles":
+		list, err := c.RbacV1().Roles(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		list.Kind = "list"
+		for i, c := range list.Items {
+			c.Kind = "Role"
+			c.APIVersion = "rbac.authorization.k8s.io/v1"
+			list.Items[i] = c
+		}
+		b, err = json.Marshal(list)
+		if err != nil {
+			return []byte(""), err
+		}
+	case "rolebindings":
+		list, err := c.RbacV1().RoleBindings(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		list.Kind = "list"
+		for i, c := range list.Items {
+			c.Kind = "RoleBinding"
+			c.APIVersion = "rbac.authorization.k8s.io/v1"
+			list.Items[i] = c
+		}
+		b, err = json.Marshal(list)
+		if err != nil {
+			return []byte(""), err
+		}
+	case "networkpolicies":
+		list, err := c.NetworkingV1().NetworkPolicies(namespace).List(ctx, options)
+		if err != nil {
+			switch ctx.Err() {
+			case context.DeadlineExceeded:
+				return nil, context.Cause(ctx)
+			default:
+				return []byte(""), err
+			}
+		}
+		list.Kind = "list"
+		for i, c := range list.Items {
+			c.Kind = "NetworkPolicy"
+			c.APIVersion = "networking.k8s.io/v1"
+			list.Items[i] = c
+		}
+		b, err = json.Marshal(list)
+		if err != nil {
+			return []byte(""), err
+		}
 	default:
 		simplelog.Errorf("resource (%v) does not have an implementation", resource)
 	}
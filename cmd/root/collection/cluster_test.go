@@ -71,7 +71,7 @@ func TestGetClusterLogs_EmptySelector_ListsAllNamespacePods(t *testing.T) {
 	fc := fake.NewSimpleClientset(dremio, other)
 	dir := t.TempDir()
 
-	err := GetClusterLogs(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "")
+	err := GetClusterLogs(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "", ContainerLogOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -86,9 +86,9 @@ func TestGetClusterLogs_EmptySelector_ListsAllNamespacePods(t *testing.T) {
 		names = append(names, e.Name())
 	}
 	sort.Strings(names)
-	// GetClusterLogs writes both current (-main.txt) and previous (-main-previous.txt)
+	// GetClusterLogs writes both current (-main.txt.gz) and previous (-main-previous.txt.gz)
 	// files per container, so 2 pods × 2 files = 4 expected outputs.
-	want := []string{"dremio-master-0-main-previous.txt", "dremio-master-0-main.txt", "opensearch-0-main-previous.txt", "opensearch-0-main.txt"}
+	want := []string{"dremio-master-0-main-previous.txt.gz", "dremio-master-0-main.txt.gz", "opensearch-0-main-previous.txt.gz", "opensearch-0-main.txt.gz"}
 	if len(names) != len(want) {
 		t.Fatalf("expected %d log files, got %d: %v", len(want), len(names), names)
 	}
@@ -105,7 +105,7 @@ func TestGetClusterLogs_NonEmptySelector_FiltersPods(t *testing.T) {
 	fc := fake.NewSimpleClientset(dremio, other)
 	dir := t.TempDir()
 
-	err := GetClusterLogs(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "role=dremio-cluster-pod")
+	err := GetClusterLogs(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "role=dremio-cluster-pod", ContainerLogOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -116,7 +116,7 @@ func TestGetClusterLogs_NonEmptySelector_FiltersPods(t *testing.T) {
 
 	entries, _ := os.ReadDir(dir)
 	for _, e := range entries {
-		if e.Name() == "opensearch-0-main.txt" {
+		if e.Name() == "opensearch-0-main.txt.gz" {
 			t.Errorf("opensearch-0 log should not be collected when selector excludes it")
 		}
 	}
@@ -128,7 +128,7 @@ func TestGetPreviousLogsForRestartedPods_EmptySelector_ListsAllNamespacePods(t *
 	fc := fake.NewSimpleClientset(dremio, other)
 	dir := t.TempDir()
 
-	err := GetPreviousLogsForRestartedPods(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "")
+	err := GetPreviousLogsForRestartedPods(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "", ContainerLogOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -144,7 +144,7 @@ func TestGetPreviousLogsForRestartedPods_NonEmptySelector_FiltersPods(t *testing
 	fc := fake.NewSimpleClientset(dremio, other)
 	dir := t.TempDir()
 
-	err := GetPreviousLogsForRestartedPods(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "role=dremio-cluster-pod")
+	err := GetPreviousLogsForRestartedPods(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "role=dremio-cluster-pod", ContainerLogOptions{})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -155,7 +155,7 @@ func TestGetPreviousLogsForRestartedPods_NonEmptySelector_FiltersPods(t *testing
 
 	entries, _ := os.ReadDir(dir)
 	for _, e := range entries {
-		if e.Name() == "opensearch-0-main-previous.txt" {
+		if e.Name() == "opensearch-0-main-previous.txt.gz" {
 			t.Errorf("opensearch-0 previous log should not be collected when selector excludes it")
 		}
 	}
@@ -16,6 +16,7 @@
 package collection
 
 import (
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -24,8 +25,10 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/restclient"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/clusterstats"
@@ -59,6 +62,13 @@ type Collector interface {
 	// so the stream arrives compressed; the caller is responsible for decompression.
 	// Implementations must preserve binary integrity (no line splitting or encoding).
 	StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error
+	// StreamFromHostAt is StreamFromHost starting offset bytes into the remote file,
+	// used to resume an interrupted transfer. The offset counts uncompressed bytes:
+	// with useGzip the remote side skips them before compressing.
+	StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error
+	// StreamCommandFromHost runs streamCmd through "sh -c" on the remote host and
+	// streams its raw stdout to writer, e.g. a tar of many files in one exec.
+	StreamCommandFromHost(host, streamCmd string, writer io.Writer) error
 	// DiscoverFiles runs lightweight shell commands on a remote host to enumerate
 	// log files, config files, GC logs, and the Dremio PID. Individual command
 	// failures are logged as warnings — partial results are always returned.
@@ -74,7 +84,10 @@ type Args struct {
 	Disabled              []string
 	Enabled               []string
 	DisableFreeSpaceCheck bool
+	DisableBatchStreaming bool
+	Dedup                 bool
 	CollectionMode        collects.CollectionMode
+	Preset                string // --preset name, recorded in summary.json
 	CollectionThreads     int
 	CoordinatorLogDir     string
 	ExecutorLogDir        string
@@ -104,6 +117,7 @@ type Args struct {
 	CollectJFR           bool
 	CollectHeapDump      bool
 	CollectAsyncProfiler bool
+	CollectNMT           bool
 	DiagTimeSeconds      int
 
 	// Node filtering (diagnosis mode node selection or --nodes/--exclude-nodes flags)
@@ -124,15 +138,56 @@ type Args struct {
 
 	// API collections (run from orchestrator)
 	DremioEndpoint             string
-	AllowInsecureSSL           bool
+	RestClient                 restclient.ClientConfig
 	RestHTTPTimeout            int
 	CollectWLM                 bool
 	CollectKVStoreReport       bool
 	CollectProblematicProfiles bool
+	CollectCatalogInventory    bool
+	CatalogMaxDepth            int
+	CatalogMaxItems            int
 	CollectSystemTables        bool
 	SystemTables               []string
+	RocksDBTypes               []string
+	RocksDBTypeOverrides       map[string]RocksDBTypeSpec
+	HelperGuard                HelperGuardConfig
+
+	// Container logs (K8s transports): keep only the last N bytes of each log, 0 = all
+	ContainerLogLimitBytes int64
+
+	// Source of the cluster-level data read by clusterCollection, e.g. "Kubernetes API"; empty when there is none
+	ClusterData string
+
+	// Line-level log trimming (--trim-to-window): a zero TrimSince/TrimUntil leaves that side open
+	TrimToWindow bool
+	TrimSince    time.Time
+	TrimUntil    time.Time
+	trimmed      *trimRecorder
+	// Zone of log timestamps without one (--log-timezone), nil = each node's UTC offset from discovery
+	LogTimezone *time.Location
+
+	// Incremental collection: state file of the previous run (--since-last), empty = collect everything
+	SinceLast   string
+	incremental *incrementalRun
+
+	// Collection plans: DryRun writes plan.json next to OutputLoc instead of collecting (--dry-run),
+	// a non-nil Plan limits the collection to a plan written earlier (--plan)
+	DryRun bool
+	Plan   *CollectionPlan
+	// --debug-container-image of a K8s dry run, which attaches nothing but records
+	// the pods it would attach to in the plan
+	DebugContainerImage string
+
+	// ddc watch: the trigger that started this collection, recorded in summary.json
+	WatchTrigger *WatchTrigger
+
+	// Masked merged configuration (flags, DDC_* environment, --config file) archived as EffectiveConfigFile
+	EffectiveConfig []byte
 }
 
+// EffectiveConfigFile is the name of the archived effective configuration
+const EffectiveConfigFile = "ddc-config.yaml"
+
 func FilterCoordinators(coordinators []string) []string {
 	// use a map for the unique key property, so we handle duplicates in the list
 	filteredList := make(map[string]bool)
@@ -226,8 +281,8 @@ func FindClusterID(outputDir string) (clusterStatsList []clusterstats.ClusterSta
 		if err != nil {
 			return err // Handle the error according to your needs
 		}
-		if info.Name() == "cluster-stats.json" {
-			b, err := os.ReadFile(filepath.Clean(path)) // #nosec G122 -- path is from Walk over controlled output dir
+		if info.Name() == "cluster-stats.json" || info.Name() == "cluster-stats.json.gz" {
+			b, err := readMaybeGzip(path)
 			if err != nil {
 				return err
 			}
@@ -245,6 +300,24 @@ func FindClusterID(outputDir string) (clusterStatsList []clusterstats.ClusterSta
 	return
 }
 
+// readMaybeGzip reads a file, decompressing it when the name ends in .gz.
+func readMaybeGzip(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path)) // #nosec G304 -- path is from Walk over controlled output dir
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if !strings.HasSuffix(path, ".gz") {
+		return io.ReadAll(f)
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("gzip %v: %w", path, err)
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
 // logDistributedCollectionSummary logs a comprehensive summary of the distributed collection
 func logDistributedCollectionSummary(collectionMode collects.CollectionMode, coordinators, executors []string, files []helpers.CollectedFile, totalFailedFiles, totalFailedNodes, totalSkippedFiles []string, nodesConnectedTo int, duration time.Duration) {
 	simplelog.Info("=== DISTRIBUTED COLLECTION SUMMARY ===")
@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
@@ -55,6 +56,8 @@ type RemoteNodeInfo struct {
 	DremioPID     int              `json:"dremio_pid"`
 	ChecksumTool  string           `json:"checksum_tool"`
 	GzipAvailable bool             `json:"gzip_available"`
+	TarAvailable  bool             `json:"tar_available"`
+	UTCOffset     string           `json:"utc_offset"` // e.g. +0100, the zone of log timestamps without one
 	Files         []RemoteFileInfo `json:"files"`
 }
 
@@ -154,6 +157,18 @@ func RunDiscovery(executor HostExecutor, host, logDir, confDir string) (*RemoteN
 		simplelog.Infof("RunDiscovery: gzip not available on %v", host)
 	}
 
+	// 7. Probe for tar (used to stream all of a node's files in one exec).
+	info.TarAvailable = probeTar(executor, host)
+	if info.TarAvailable {
+		simplelog.Infof("RunDiscovery: tar available on %v", host)
+	} else {
+		simplelog.Infof("RunDiscovery: tar not available on %v", host)
+	}
+
+	// 8. Probe the node's UTC offset (log timestamps without a zone are in it).
+	info.UTCOffset = probeUTCOffset(executor, host)
+	simplelog.Infof("RunDiscovery: UTC offset on %v: %q", host, info.UTCOffset)
+
 	if !anySuccess {
 		return info, fmt.Errorf("DiscoverFiles: all discovery commands failed on host %v", host)
 	}
@@ -477,6 +492,27 @@ func probeGzip(executor HostExecutor, host string) bool {
 	return err == nil && strings.TrimSpace(out) != ""
 }
 
+// probeTar checks whether tar is available on the remote host using POSIX
+// "command -v tar". Without it files are streamed one command at a time.
+func probeTar(executor HostExecutor, host string) bool {
+	out, err := executor(host, "command", "-v", "tar")
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// probeUTCOffset returns the host's current UTC offset as printed by
+// "date +%z", e.g. +0100, or "" when it cannot be read.
+func probeUTCOffset(executor HostExecutor, host string) string {
+	out, err := executor(host, "date", "+%z")
+	if err != nil {
+		return ""
+	}
+	offset := strings.TrimSpace(out)
+	if _, err := time.Parse("-0700", offset); err != nil {
+		return ""
+	}
+	return offset
+}
+
 // detectRocksDBDir reads dremio.conf from confDir on the remote host and
 // extracts the RocksDB path via paths.local + /db. Returns "" if the
 // config cannot be read or parsed — this is advisory, not fatal.
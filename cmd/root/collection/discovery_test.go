@@ -64,12 +64,16 @@ func TestDiscoverFiles_FullInfo(t *testing.T) {
 		"jcmd -l":               {out: "", err: fmt.Errorf("not found")},
 		"pgrep -x java":         {out: "", err: fmt.Errorf("exit status 1")},
 		"pgrep -f dremio.*java": {out: "42\n", err: nil},
+		"date +%z":              {out: "+0530\n", err: nil},
 	}
 
 	info, err := RunDiscovery(mockExecutor(responses), "node1", "", "")
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
+	if info.UTCOffset != "+0530" {
+		t.Errorf("UTCOffset = %q, want +0530", info.UTCOffset)
+	}
 	if info.LogDir != "/var/log/dremio" {
 		t.Errorf("LogDir = %q, want /var/log/dremio", info.LogDir)
 	}
@@ -1299,3 +1303,19 @@ func TestRunDiscovery_GzipProbe(t *testing.T) {
 		})
 	}
 }
+
+func TestProbeTar(t *testing.T) {
+	found := mockExecutor(map[string]struct {
+		out string
+		err error
+	}{
+		"command -v tar": {out: "/bin/tar\n", err: nil},
+	})
+	if !probeTar(found, "node1") {
+		t.Error("expected tar to be detected")
+	}
+	// unmatched commands fail, as "command -v" does for a missing tool
+	if probeTar(mockExecutor(nil), "node1") {
+		t.Error("expected no tar when command -v fails")
+	}
+}
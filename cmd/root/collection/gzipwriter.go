@@ -0,0 +1,110 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+)
+
+// byteCounter counts the bytes written through it.
+type byteCounter struct {
+	n int64
+}
+
+func (b *byteCounter) Write(p []byte) (int, error) {
+	b.n += int64(len(p))
+	return len(p), nil
+}
+
+// gzipLineWriter gzips lines into a file while hashing and counting the compressed
+// bytes as they are written, so the collected size and sha256 are known without
+// reading the file back. Lines that would take the uncompressed total past maxBytes
+// are dropped whole, keeping the file parseable.
+type gzipLineWriter struct {
+	path     string
+	maxBytes int64 // uncompressed cap; <= 0 means no cap
+	file     helpers.File
+	buf      *bufio.Writer
+	gz       *gzip.Writer
+	sum      hash.Hash
+	onDisk   byteCounter
+	written  int64 // uncompressed bytes kept
+	lines    int
+	dropped  int
+}
+
+func newGzipLineWriter(path string, maxBytes int64) (*gzipLineWriter, error) {
+	return newGzipLineWriterFS(helpers.NewRealFileSystem(), path, maxBytes)
+}
+
+// newGzipLineWriterFS is newGzipLineWriter creating the file through ddfs.
+func newGzipLineWriterFS(ddfs helpers.Filesystem, path string, maxBytes int64) (*gzipLineWriter, error) {
+	f, err := ddfs.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create %s: %w", path, err)
+	}
+	w := &gzipLineWriter{path: path, maxBytes: maxBytes, file: f, sum: sha256.New()}
+	w.buf = bufio.NewWriterSize(io.MultiWriter(f, w.sum, &w.onDisk), 1024*1024)
+	w.gz = gzip.NewWriter(w.buf)
+	return w, nil
+}
+
+// WriteLine writes line plus a newline, or drops it once the cap is reached.
+func (w *gzipLineWriter) WriteLine(line string) error {
+	n := int64(len(line)) + 1
+	if w.maxBytes > 0 && w.written+n > w.maxBytes {
+		w.dropped++
+		return nil
+	}
+	if _, err := io.WriteString(w.gz, line); err != nil {
+		return fmt.Errorf("write %s: %w", w.path, err)
+	}
+	if _, err := io.WriteString(w.gz, "\n"); err != nil {
+		return fmt.Errorf("write %s: %w", w.path, err)
+	}
+	w.written += n
+	w.lines++
+	return nil
+}
+
+// Truncated reports whether any line was dropped because of the cap.
+func (w *gzipLineWriter) Truncated() bool {
+	return w.dropped > 0
+}
+
+// Close finishes the gzip stream and returns the file with its on-disk size and sha256.
+func (w *gzipLineWriter) Close() (helpers.CollectedFile, error) {
+	gzErr := w.gz.Close()
+	bufErr := w.buf.Flush()
+	fileErr := w.file.Close()
+	for _, err := range []error{gzErr, bufErr, fileErr} {
+		if err != nil {
+			return helpers.CollectedFile{}, fmt.Errorf("close %s: %w", w.path, err)
+		}
+	}
+	return helpers.CollectedFile{
+		Path:   w.path,
+		Size:   w.onDisk.n,
+		SHA256: hex.EncodeToString(w.sum.Sum(nil)),
+	}, nil
+}
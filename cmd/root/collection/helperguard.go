@@ -0,0 +1,311 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+const (
+	// DefaultHelperTimeout is the wall-clock limit for one helper invocation when none is configured.
+	DefaultHelperTimeout = 30 * time.Minute
+	// DefaultHelperMemoryLimitMB is the memory ceiling applied to helper invocations when none is
+	// configured. It is only applied with a cgroup scope: ulimit -v caps virtual memory, and the JVM
+	// of rocksdb-viewer reserves more than this at startup on large-heap hosts.
+	DefaultHelperMemoryLimitMB = 2048
+
+	// helperTimeoutGrace is how long the local side waits past the remote timeout before giving up.
+	helperTimeoutGrace = 30 * time.Second
+
+	memoryLimitCgroup = "cgroup-scope"
+	memoryLimitUlimit = "ulimit"
+	memoryLimitNone   = "none"
+)
+
+// HelperGuardConfig bounds how uploaded helper binaries are run on a node.
+type HelperGuardConfig struct {
+	TimeoutSeconds int // default wall-clock limit per invocation; <= 0 uses DefaultHelperTimeout
+	MemoryLimitMB  int // memory ceiling per invocation; < 0 disables, 0 uses DefaultHelperMemoryLimitMB where a cgroup scope is available
+}
+
+// Timeout returns the configured timeout or DefaultHelperTimeout.
+func (h HelperGuardConfig) Timeout() time.Duration {
+	if h.TimeoutSeconds > 0 {
+		return time.Duration(h.TimeoutSeconds) * time.Second
+	}
+	return DefaultHelperTimeout
+}
+
+func (h HelperGuardConfig) memoryLimitMB() int {
+	if h.MemoryLimitMB == 0 {
+		return DefaultHelperMemoryLimitMB
+	}
+	if h.MemoryLimitMB < 0 {
+		return 0
+	}
+	return h.MemoryLimitMB
+}
+
+// HelperInvocation records one guarded command.
+type HelperInvocation struct {
+	Label          string `json:"label"`
+	TimeoutSeconds int    `json:"timeoutSeconds"`
+	Error          string `json:"error,omitempty"`
+}
+
+// HelperGuardRecord is written to helper-guards/<host>/<helper>.json and lists the
+// guards that were actually in force, which can differ from what was requested
+// when a node lacks nice, ionice, timeout or systemd.
+type HelperGuardRecord struct {
+	Helper            string             `json:"helper"`
+	Host              string             `json:"host"`
+	Nice              bool               `json:"nice"`
+	Ionice            bool               `json:"ionice"`
+	RemoteTimeout     bool               `json:"remoteTimeout"`
+	MemoryLimitMB     int                `json:"memoryLimitMB"`
+	MemoryLimitMethod string             `json:"memoryLimitMethod"`
+	SHA256            map[string]string  `json:"sha256"`
+	SHA256Verified    bool               `json:"sha256Verified"`
+	Invocations       []HelperInvocation `json:"invocations"`
+	Notes             []string           `json:"notes,omitempty"`
+}
+
+// helperGuard wraps commands for one helper on one host according to what the host supports.
+type helperGuard struct {
+	cfg    HelperGuardConfig
+	mu     sync.Mutex
+	record HelperGuardRecord
+}
+
+// probeHelperGuard checks which guard tools the host offers. Missing tools are noted
+// in the record rather than treated as errors.
+func probeHelperGuard(c Collector, host, helper string, cfg HelperGuardConfig) *helperGuard {
+	g := &helperGuard{
+		cfg: cfg,
+		record: HelperGuardRecord{
+			Helper:            helper,
+			Host:              host,
+			MemoryLimitMethod: memoryLimitNone,
+			SHA256:            make(map[string]string),
+		},
+	}
+	probe := `for t in nice ionice timeout systemd-run sha256sum; do command -v $t >/dev/null 2>&1 && echo "tool=$t"; done; [ -d /run/systemd/system ] && echo systemd=1; echo "uid=$(id -u)"`
+	out, err := c.HostExecute(false, host, probe)
+	if err != nil {
+		g.note(fmt.Sprintf("guard probe failed, running without nice/ionice/timeout/memory limit: %v", err))
+		return g
+	}
+	tools := make(map[string]bool)
+	systemd, root := false, false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "tool="):
+			tools[strings.TrimPrefix(line, "tool=")] = true
+		case line == "systemd=1":
+			systemd = true
+		case line == "uid=0":
+			root = true
+		}
+	}
+	g.record.Nice = tools["nice"]
+	g.record.Ionice = tools["ionice"]
+	g.record.RemoteTimeout = tools["timeout"]
+	if limit := cfg.memoryLimitMB(); limit > 0 {
+		switch {
+		case tools["systemd-run"] && systemd && root:
+			g.record.MemoryLimitMB = limit
+			g.record.MemoryLimitMethod = memoryLimitCgroup
+		case cfg.MemoryLimitMB > 0:
+			// only when asked for: ulimit -v also counts the address space a JVM reserves
+			g.record.MemoryLimitMB = limit
+			g.record.MemoryLimitMethod = memoryLimitUlimit
+		default:
+			g.note(fmt.Sprintf("no systemd cgroup scope (needs systemd-run and root), default %dMB memory limit not applied; set --helper-memory-limit-mb to enforce one with ulimit -v", limit))
+		}
+	}
+	if !tools["sha256sum"] {
+		g.note("sha256sum not available, uploaded bytes could not be verified")
+	}
+	if !g.record.RemoteTimeout {
+		g.note("timeout not available, wall-clock limit enforced from the collector side only")
+	}
+	return g
+}
+
+func (g *helperGuard) note(msg string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	simplelog.Warningf("%s guard on %s: %s", g.record.Helper, g.record.Host, msg)
+	g.record.Notes = append(g.record.Notes, msg)
+}
+
+// verify compares the sha256 of each remote path with the bytes that were uploaded.
+// A mismatch is an error; an unverifiable host is recorded and allowed.
+func (g *helperGuard) verify(c Collector, host string, files map[string][]byte) error {
+	paths := make([]string, 0, len(files))
+	for p, data := range files {
+		sum := sha256.Sum256(data)
+		g.record.SHA256[p] = hex.EncodeToString(sum[:])
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	out, err := c.HostExecute(false, host, "sha256sum "+strings.Join(paths, " "))
+	if err != nil {
+		g.note(fmt.Sprintf("sha256sum failed, uploaded bytes could not be verified: %v", err))
+		return nil
+	}
+	remote := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			remote[strings.TrimPrefix(fields[1], "*")] = fields[0]
+		}
+	}
+	for _, p := range paths {
+		if remote[p] == "" {
+			g.note(fmt.Sprintf("sha256sum reported no hash for %s, uploaded bytes could not be verified", p))
+			return nil
+		}
+		if remote[p] != g.record.SHA256[p] {
+			return fmt.Errorf("sha256 mismatch for %s on %s: expected %s, got %q", p, host, g.record.SHA256[p], remote[p])
+		}
+	}
+	g.record.SHA256Verified = true
+	simplelog.Infof("%s guard on %s: sha256 verified for %d file(s)", g.record.Helper, host, len(paths))
+	return nil
+}
+
+// command wraps cmd with the memory ceiling, timeout and nice/ionice the host supports.
+func (g *helperGuard) command(cmd string, timeout time.Duration) string {
+	var prefix []string
+	if g.record.MemoryLimitMethod == memoryLimitCgroup {
+		prefix = append(prefix, fmt.Sprintf("systemd-run --quiet --scope -p MemoryMax=%dM --", g.record.MemoryLimitMB))
+	}
+	if g.record.RemoteTimeout {
+		prefix = append(prefix, fmt.Sprintf("timeout -k 10 %d", int(timeout.Seconds())))
+	}
+	if g.record.Nice {
+		prefix = append(prefix, "nice -n 19")
+	}
+	if g.record.Ionice {
+		prefix = append(prefix, "ionice -c 3")
+	}
+	wrapped := strings.Join(append(prefix, cmd), " ")
+	if g.record.MemoryLimitMethod == memoryLimitUlimit {
+		wrapped = fmt.Sprintf("sh -c 'ulimit -v %d && exec %s'", g.record.MemoryLimitMB*1024, strings.ReplaceAll(wrapped, "'", `'\''`))
+	}
+	return wrapped
+}
+
+// run executes a guarded command, with a local backstop in case the remote timeout is unavailable.
+func (g *helperGuard) run(c Collector, host, label, cmd string, timeout time.Duration) (string, error) {
+	type result struct {
+		out string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		out, err := c.HostExecute(false, host, g.command(cmd, timeout))
+		done <- result{out, err}
+	}()
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(timeout + helperTimeoutGrace):
+		r.err = fmt.Errorf("timed out after %v", timeout)
+	}
+	g.track(label, timeout, r.err)
+	return r.out, r.err
+}
+
+// stream executes a guarded command and passes each output line to handler as it
+// arrives. Lines arriving after the local backstop fires are discarded, so the
+// caller can close its writers as soon as stream returns.
+func (g *helperGuard) stream(c Collector, host, label, cmd string, timeout time.Duration, handler cli.OutputHandler) error {
+	var mu sync.Mutex
+	stopped := false
+	guarded := func(line string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			handler(line)
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- c.HostExecuteAndStream(false, host, guarded, "", g.command(cmd, timeout))
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(timeout + helperTimeoutGrace):
+		err = fmt.Errorf("timed out after %v", timeout)
+	}
+	mu.Lock()
+	stopped = true
+	mu.Unlock()
+	g.track(label, timeout, err)
+	return err
+}
+
+// track adds an invocation to the record.
+func (g *helperGuard) track(label string, timeout time.Duration, err error) {
+	inv := HelperInvocation{Label: label, TimeoutSeconds: int(timeout.Seconds())}
+	if err != nil {
+		inv.Error = err.Error()
+	}
+	g.mu.Lock()
+	g.record.Invocations = append(g.record.Invocations, inv)
+	g.mu.Unlock()
+}
+
+// write stores the record under helper-guards/<host>/<helper>.json.
+func (g *helperGuard) write(cs CopyStrategy, nodeType string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cs == nil {
+		return
+	}
+	dir, err := cs.CreatePath("helper-guards", g.record.Host, nodeType)
+	if err != nil {
+		simplelog.Warningf("unable to create helper-guards dir for %s: %v", g.record.Host, err)
+		return
+	}
+	data, err := json.MarshalIndent(g.record, "", "\t")
+	if err != nil {
+		simplelog.Warningf("unable to marshal %s guard record: %v", g.record.Helper, err)
+		return
+	}
+	outFile := filepath.Join(dir, g.record.Helper+".json")
+	if err := os.WriteFile(outFile, data, 0o600); err != nil {
+		simplelog.Warningf("unable to write %v: %v", outFile, err)
+		return
+	}
+	simplelog.Infof("%s guard on %s: nice=%v ionice=%v remoteTimeout=%v memory=%s/%dMB sha256Verified=%v",
+		g.record.Helper, g.record.Host, g.record.Nice, g.record.Ionice, g.record.RemoteTimeout, g.record.MemoryLimitMethod, g.record.MemoryLimitMB, g.record.SHA256Verified)
+}
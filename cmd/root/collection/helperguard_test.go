@@ -0,0 +1,110 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func guardCollector(probe string, sha string) *mockStreamCollector {
+	return &mockStreamCollector{
+		hostExecuteFunc: func(_ bool, _ string, args ...string) (string, error) {
+			cmd := strings.Join(args, " ")
+			switch {
+			case strings.Contains(cmd, "command -v $t"):
+				return probe, nil
+			case strings.HasPrefix(cmd, "sha256sum "):
+				return sha, nil
+			}
+			return "", fmt.Errorf("unexpected host command: %s", cmd)
+		},
+	}
+}
+
+func TestHelperGuardCommandCgroupScope(t *testing.T) {
+	mc := guardCollector("tool=nice\ntool=ionice\ntool=timeout\ntool=systemd-run\nsystemd=1\nuid=0\n", "")
+	g := probeHelperGuard(mc, "node1", "rocksdb-viewer", HelperGuardConfig{MemoryLimitMB: 512})
+	got := g.command("/tmp/viewer -type x", 90*time.Second)
+	want := "systemd-run --quiet --scope -p MemoryMax=512M -- timeout -k 10 90 nice -n 19 ionice -c 3 /tmp/viewer -type x"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestHelperGuardCommandUlimitWithoutRoot(t *testing.T) {
+	mc := guardCollector("tool=nice\ntool=timeout\ntool=systemd-run\nsystemd=1\nuid=1000\n", "")
+	g := probeHelperGuard(mc, "node1", "rocksdb-viewer", HelperGuardConfig{MemoryLimitMB: 2048})
+	got := g.command("/tmp/viewer -type x", time.Minute)
+	want := "sh -c 'ulimit -v 2097152 && exec timeout -k 10 60 nice -n 19 /tmp/viewer -type x'"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if g.record.Ionice || g.record.MemoryLimitMethod != memoryLimitUlimit {
+		t.Errorf("unexpected record: %+v", g.record)
+	}
+}
+
+func TestHelperGuardDefaultMemoryLimitNeedsCgroup(t *testing.T) {
+	mc := guardCollector("tool=timeout\ntool=systemd-run\nsystemd=1\nuid=1000\n", "")
+	g := probeHelperGuard(mc, "node1", "rocksdb-viewer", HelperGuardConfig{})
+	if got := g.command("/tmp/viewer", time.Minute); got != "timeout -k 10 60 /tmp/viewer" {
+		t.Errorf("expected no ulimit -v for the default limit, got %q", got)
+	}
+	if g.record.MemoryLimitMethod != memoryLimitNone || g.record.MemoryLimitMB != 0 || !strings.Contains(strings.Join(g.record.Notes, "\n"), "default 2048MB memory limit not applied") {
+		t.Errorf("expected the skipped default limit recorded, got %+v", g.record)
+	}
+
+	mc = guardCollector("tool=systemd-run\nsystemd=1\nuid=0\n", "")
+	g = probeHelperGuard(mc, "node1", "rocksdb-viewer", HelperGuardConfig{})
+	if g.record.MemoryLimitMethod != memoryLimitCgroup || g.record.MemoryLimitMB != DefaultHelperMemoryLimitMB {
+		t.Errorf("expected the default limit in a cgroup scope, got %+v", g.record)
+	}
+}
+
+func TestHelperGuardCommandMemoryLimitDisabled(t *testing.T) {
+	mc := guardCollector("uid=0\n", "")
+	g := probeHelperGuard(mc, "node1", "asprof", HelperGuardConfig{MemoryLimitMB: -1})
+	if got := g.command("/tmp/asprof", time.Minute); got != "/tmp/asprof" {
+		t.Errorf("expected the command unchanged, got %q", got)
+	}
+	if len(g.record.Notes) == 0 {
+		t.Error("expected missing sha256sum and timeout to be noted")
+	}
+}
+
+func TestHelperGuardVerify(t *testing.T) {
+	data := []byte("helper bytes")
+	good := fmt.Sprintf("%x  /tmp/helper\n", sha256.Sum256(data))
+	g := probeHelperGuard(guardCollector("tool=sha256sum\n", good), "node1", "rocksdb-viewer", HelperGuardConfig{})
+	if err := g.verify(guardCollector("", good), "node1", map[string][]byte{"/tmp/helper": data}); err != nil {
+		t.Fatalf("expected verification to pass: %v", err)
+	}
+	if !g.record.SHA256Verified {
+		t.Error("expected record to show verified")
+	}
+
+	bad := fmt.Sprintf("%x  /tmp/helper\n", sha256.Sum256([]byte("tampered")))
+	g = probeHelperGuard(guardCollector("tool=sha256sum\n", bad), "node1", "rocksdb-viewer", HelperGuardConfig{})
+	if err := g.verify(guardCollector("", bad), "node1", map[string][]byte{"/tmp/helper": data}); err == nil || !strings.Contains(err.Error(), "sha256 mismatch") {
+		t.Errorf("expected a sha256 mismatch, got %v", err)
+	}
+	if g.record.SHA256Verified {
+		t.Error("expected record to show unverified")
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/restclient"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// Identity sources recorded in summary.json, in the order they are tried.
+const (
+	IdentitySourceRocksDB     = "rocksdb-cluster-stats"
+	IdentitySourceServerInfo  = "rest-server-status"
+	IdentitySourceSysVersion  = "rest-sys-version"
+	IdentitySourceJarManifest = "jar-manifest"
+	IdentitySourceServerLog   = "server-log"
+)
+
+// identityJobPollInterval and identityJobMaxPolls bound the wait for the sys.version query.
+var (
+	identityJobPollInterval = time.Second
+	identityJobMaxPolls     = 30
+)
+
+var (
+	reJarVersion       = regexp.MustCompile(`dremio-common-([0-9]+\.[0-9]+\.[0-9]+[0-9A-Za-z.\-]*?)\.jar$`)
+	reLogBannerVersion = regexp.MustCompile(`(?i)dremio.{0,40}?version[:=\s]+v?([0-9]+\.[0-9]+\.[0-9]+[0-9A-Za-z.\-]*)`)
+	reLogClusterID     = regexp.MustCompile(`(?i)cluster\s*id[:=\s]+([0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12})`)
+)
+
+// clusterIdentity holds the per-node Dremio version and cluster ID along with
+// where each value came from.
+type clusterIdentity struct {
+	DremioVersion       map[string]string
+	DremioVersionSource map[string]string
+	ClusterID           map[string]string
+	ClusterIDSource     map[string]string
+}
+
+func newClusterIdentity() *clusterIdentity {
+	return &clusterIdentity{
+		DremioVersion:       make(map[string]string),
+		DremioVersionSource: make(map[string]string),
+		ClusterID:           make(map[string]string),
+		ClusterIDSource:     make(map[string]string),
+	}
+}
+
+func (ci *clusterIdentity) setVersion(node, version, source string) {
+	if version == "" || ci.DremioVersion[node] != "" {
+		return
+	}
+	ci.DremioVersion[node] = version
+	ci.DremioVersionSource[node] = source
+}
+
+func (ci *clusterIdentity) setClusterID(node, clusterID, source string) {
+	if clusterID == "" || ci.ClusterID[node] != "" {
+		return
+	}
+	ci.ClusterID[node] = clusterID
+	ci.ClusterIDSource[node] = source
+}
+
+// identityArgs carries what the fallback chain needs from the streaming collection.
+type identityArgs struct {
+	Collector    Collector
+	Hook         shutdown.Hook
+	TmpDir       string
+	Coordinators []string
+	Executors    []string
+	NodeInfo     map[string]*RemoteNodeInfo
+	CollectArgs  Args
+}
+
+// resolveClusterIdentity fills in the Dremio version and cluster ID per node using
+// rocksdb-viewer cluster-stats first, then the REST API (server_status, sys.version),
+// then the dremio-common jar on the node, and finally the server.log startup banner.
+func resolveClusterIdentity(args identityArgs) *clusterIdentity {
+	ci := newClusterIdentity()
+	primary := ""
+	if len(args.Coordinators) > 0 {
+		primary = args.Coordinators[0]
+	}
+
+	stats, err := FindClusterID(args.TmpDir)
+	if err != nil {
+		simplelog.Warningf("identity: unable to read cluster-stats.json: %v", err)
+	}
+	for _, st := range stats {
+		node := st.NodeName
+		if node == "" {
+			node = primary
+		}
+		ci.setVersion(node, st.DremioVersion, IdentitySourceRocksDB)
+		ci.setClusterID(node, st.ClusterID, IdentitySourceRocksDB)
+	}
+
+	plan := args.CollectArgs.Plan
+	if primary != "" && args.CollectArgs.DremioPAT != "" && args.CollectArgs.DremioEndpoint != "" &&
+		(ci.DremioVersion[primary] == "" || ci.ClusterID[primary] == "") && plan.calls(planCallIdentity) {
+		resolveIdentityFromREST(ci, primary, args)
+	}
+
+	nodes := append(append([]string{}, args.Coordinators...), args.Executors...)
+	for _, node := range nodes {
+		info := args.NodeInfo[node]
+		if info == nil || !plan.runs(node, planToolIdentity) {
+			continue
+		}
+		if ci.DremioVersion[node] == "" {
+			version, err := versionFromJar(args.Collector, node, info.ConfDir)
+			if err != nil {
+				simplelog.Debugf("identity: jar version lookup on %v failed: %v", node, err)
+			}
+			ci.setVersion(node, version, IdentitySourceJarManifest)
+		}
+		if ci.DremioVersion[node] == "" || ci.ClusterID[node] == "" {
+			version, clusterID, err := identityFromServerLog(args.Collector, node, info.LogDir)
+			if err != nil {
+				simplelog.Debugf("identity: server.log lookup on %v failed: %v", node, err)
+			}
+			ci.setVersion(node, version, IdentitySourceServerLog)
+			ci.setClusterID(node, clusterID, IdentitySourceServerLog)
+		}
+	}
+
+	for _, node := range nodes {
+		if ci.DremioVersion[node] == "" {
+			simplelog.Warningf("identity: unable to determine Dremio version for %v", node)
+		} else {
+			simplelog.Infof("identity: %v runs Dremio %v (source: %v)", node, ci.DremioVersion[node], ci.DremioVersionSource[node])
+		}
+	}
+	return ci
+}
+
+// resolveIdentityFromREST asks the coordinator REST API for its version and cluster ID.
+func resolveIdentityFromREST(ci *clusterIdentity, node string, args identityArgs) {
+	hook, ok := args.Hook.(shutdown.CancelHook)
+	if !ok {
+		simplelog.Warning("identity: hook does not implement CancelHook, skipping REST lookup")
+		return
+	}
+	if err := restclient.InitClientWithConfig(args.CollectArgs.RestClient, args.CollectArgs.RestHTTPTimeout); err != nil {
+		simplelog.Warningf("identity: unable to initialize REST client: %v", err)
+		return
+	}
+	endpoint := strings.TrimRight(args.CollectArgs.DremioEndpoint, "/")
+	pat := args.CollectArgs.DremioPAT
+	headers := map[string]string{"Accept": "application/json"}
+
+	body, err := restclient.APIRequest(hook, endpoint+"/apiv2/server_status", pat, "GET", headers)
+	if err != nil {
+		simplelog.Debugf("identity: /apiv2/server_status failed: %v", err)
+	} else {
+		version, clusterID := identityFromJSON(body)
+		ci.setVersion(node, version, IdentitySourceServerInfo)
+		ci.setClusterID(node, clusterID, IdentitySourceServerInfo)
+	}
+
+	if ci.DremioVersion[node] == "" {
+		version, err := querySysVersion(hook, endpoint, pat)
+		if err != nil {
+			simplelog.Debugf("identity: sys.version query failed: %v", err)
+		}
+		ci.setVersion(node, version, IdentitySourceSysVersion)
+	}
+}
+
+// identityFromJSON pulls version and cluster ID fields out of a JSON object,
+// tolerating the key spellings used across Dremio releases.
+func identityFromJSON(body []byte) (version, clusterID string) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return "", ""
+	}
+	for k, v := range fields {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.ReplaceAll(k, "_", "")) {
+		case "version", "dremioversion":
+			version = s
+		case "clusterid":
+			clusterID = s
+		}
+	}
+	return version, clusterID
+}
+
+// querySysVersion runs SELECT version FROM sys.version through the SQL API and waits for the result.
+func querySysVersion(hook shutdown.CancelHook, endpoint, pat string) (string, error) {
+	headers := map[string]string{"Content-Type": "application/json"}
+	jobID, err := restclient.PostQuery(hook, endpoint+"/api/v3/sql", pat, headers, `{"sql": "SELECT version FROM sys.version"}`)
+	if err != nil {
+		return "", err
+	}
+	if jobID == "" {
+		return "", errors.New("no job id returned for sys.version query")
+	}
+	jsonHeaders := map[string]string{"Accept": "application/json"}
+	for i := 0; ; i++ {
+		body, err := restclient.APIRequest(hook, endpoint+"/api/v3/job/"+jobID, pat, "GET", jsonHeaders)
+		if err != nil {
+			return "", err
+		}
+		var status struct {
+			JobState string `json:"jobState"`
+		}
+		if err := json.Unmarshal(body, &status); err != nil {
+			return "", fmt.Errorf("unable to parse job status: %w", err)
+		}
+		if status.JobState == "COMPLETED" {
+			break
+		}
+		if status.JobState == "FAILED" || status.JobState == "CANCELED" {
+			return "", fmt.Errorf("sys.version query ended in state %v", status.JobState)
+		}
+		if i >= identityJobMaxPolls {
+			return "", fmt.Errorf("sys.version query did not complete after %d polls", identityJobMaxPolls)
+		}
+		select {
+		case <-hook.GetContext().Done():
+			return "", hook.GetContext().Err()
+		case <-time.After(identityJobPollInterval):
+		}
+	}
+	body, err := restclient.APIRequest(hook, endpoint+"/api/v3/job/"+jobID+"/results?limit=1", pat, "GET", jsonHeaders)
+	if err != nil {
+		return "", err
+	}
+	var results struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal(body, &results); err != nil {
+		return "", fmt.Errorf("unable to parse sys.version results: %w", err)
+	}
+	if len(results.Rows) == 0 {
+		return "", errors.New("sys.version returned no rows")
+	}
+	version, _ := results.Rows[0]["version"].(string)
+	return version, nil
+}
+
+// jarDirCandidates returns where dremio-common-*.jar is expected, preferring the
+// install that owns the discovered conf dir.
+func jarDirCandidates(confDir string) []string {
+	var dirs []string
+	if confDir != "" {
+		dirs = append(dirs, path.Join(path.Dir(confDir), "jars"))
+	}
+	if len(dirs) == 0 || dirs[0] != "/opt/dremio/jars" {
+		dirs = append(dirs, "/opt/dremio/jars")
+	}
+	return dirs
+}
+
+// versionFromJar reads the Implementation-Version of dremio-common-*.jar on the node,
+// falling back to the version embedded in the jar file name when unzip is unavailable.
+func versionFromJar(c Collector, host, confDir string) (string, error) {
+	var lastErr error
+	for _, dir := range jarDirCandidates(confDir) {
+		cmd := fmt.Sprintf(`for f in %s/dremio-common-*.jar; do [ -f "$f" ] || continue; echo "$f"; unzip -p "$f" META-INF/MANIFEST.MF 2>/dev/null | grep -i '^Implementation-Version:'; break; done`, dir)
+		out, err := c.HostExecute(false, host, cmd)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if version := parseJarVersionOutput(out); version != "" {
+			return version, nil
+		}
+	}
+	return "", lastErr
+}
+
+func parseJarVersionOutput(out string) string {
+	fileVersion := ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Implementation-Version") {
+			if v := strings.TrimSpace(value); v != "" {
+				return v
+			}
+		}
+		if m := reJarVersion.FindStringSubmatch(line); m != nil && fileVersion == "" {
+			fileVersion = m[1]
+		}
+	}
+	return fileVersion
+}
+
+// identityFromServerLog searches the node's server.log for the startup banner.
+func identityFromServerLog(c Collector, host, logDir string) (version, clusterID string, err error) {
+	if logDir == "" {
+		return "", "", errors.New("no log dir discovered")
+	}
+	cmd := fmt.Sprintf(`grep -i -m 20 -E 'dremio.{0,40}version|cluster ?id' '%s/server.log'`, strings.ReplaceAll(logDir, "'", "'\\''"))
+	out, err := c.HostExecute(false, host, cmd)
+	if err != nil && out == "" {
+		return "", "", err
+	}
+	version, clusterID = parseServerLogIdentity(out)
+	return version, clusterID, nil
+}
+
+func parseServerLogIdentity(out string) (version, clusterID string) {
+	for _, line := range strings.Split(out, "\n") {
+		if version == "" {
+			if m := reLogBannerVersion.FindStringSubmatch(line); m != nil {
+				version = m[1]
+			}
+		}
+		if clusterID == "" {
+			if m := reLogClusterID.FindStringSubmatch(line); m != nil {
+				clusterID = m[1]
+			}
+		}
+	}
+	return version, clusterID
+}
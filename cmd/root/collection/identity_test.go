@@ -0,0 +1,142 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+)
+
+func TestResolveClusterIdentity_ServerStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/apiv2/server_status" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"version":"25.1.0-202409","clusterId":"0b8e7ad5-7f7a-4a3d-9c0e-4a0c2c1b7f11"}`))
+	}))
+	defer server.Close()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	ci := resolveClusterIdentity(identityArgs{
+		Collector:    &mockStreamCollector{},
+		Hook:         hook,
+		TmpDir:       t.TempDir(),
+		Coordinators: []string{"dremio-master-0"},
+		CollectArgs:  Args{DremioPAT: "pat", DremioEndpoint: server.URL, RestHTTPTimeout: 30},
+	})
+	if ci.DremioVersion["dremio-master-0"] != "25.1.0-202409" || ci.DremioVersionSource["dremio-master-0"] != IdentitySourceServerInfo {
+		t.Errorf("unexpected version result: %v %v", ci.DremioVersion, ci.DremioVersionSource)
+	}
+	if ci.ClusterID["dremio-master-0"] != "0b8e7ad5-7f7a-4a3d-9c0e-4a0c2c1b7f11" || ci.ClusterIDSource["dremio-master-0"] != IdentitySourceServerInfo {
+		t.Errorf("unexpected cluster id result: %v %v", ci.ClusterID, ci.ClusterIDSource)
+	}
+}
+
+func TestResolveClusterIdentity_SysVersion(t *testing.T) {
+	original := identityJobPollInterval
+	identityJobPollInterval = time.Millisecond
+	defer func() { identityJobPollInterval = original }()
+
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v3/sql":
+			_, _ = w.Write([]byte(`{"id":"job-1"}`))
+		case "/api/v3/job/job-1":
+			polls++
+			if polls < 2 {
+				_, _ = w.Write([]byte(`{"jobState":"RUNNING"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"jobState":"COMPLETED"}`))
+		case "/api/v3/job/job-1/results":
+			_, _ = w.Write([]byte(`{"rowCount":1,"rows":[{"version":"24.3.2-202401"}]}`))
+		default:
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	ci := resolveClusterIdentity(identityArgs{
+		Collector:    &mockStreamCollector{},
+		Hook:         hook,
+		TmpDir:       t.TempDir(),
+		Coordinators: []string{"dremio-master-0"},
+		CollectArgs:  Args{DremioPAT: "pat", DremioEndpoint: server.URL, RestHTTPTimeout: 30},
+	})
+	if ci.DremioVersion["dremio-master-0"] != "24.3.2-202401" || ci.DremioVersionSource["dremio-master-0"] != IdentitySourceSysVersion {
+		t.Errorf("unexpected version result: %v %v", ci.DremioVersion, ci.DremioVersionSource)
+	}
+}
+
+func TestResolveClusterIdentity_NodeFallbacks(t *testing.T) {
+	collector := &mockStreamCollector{
+		hostExecuteFunc: func(_ bool, host string, args ...string) (string, error) {
+			cmd := strings.Join(args, " ")
+			switch {
+			case strings.Contains(cmd, "dremio-common-") && host == "dremio-master-0":
+				return "/opt/dremio/jars/dremio-common-25.0.4-202407.jar\nImplementation-Version: 25.0.4-202407\n", nil
+			case strings.Contains(cmd, "dremio-common-"):
+				return "/opt/dremio/jars/dremio-common-25.0.3-202406.jar\n", nil
+			case strings.Contains(cmd, "server.log"):
+				return "2024-07-01 10:00:00,000 [main] INFO  c.d.dac.daemon.DremioDaemon - Dremio Daemon version: 25.0.4-202407\n" +
+					"2024-07-01 10:00:01,000 [main] INFO  c.d.s.c.ClusterIdentity - Cluster ID: 0b8e7ad5-7f7a-4a3d-9c0e-4a0c2c1b7f11\n", nil
+			}
+			return "", nil
+		},
+	}
+	hook := shutdown.NewHook()
+	defer hook.Cleanup()
+
+	ci := resolveClusterIdentity(identityArgs{
+		Collector:    collector,
+		Hook:         hook,
+		TmpDir:       t.TempDir(),
+		Coordinators: []string{"dremio-master-0"},
+		Executors:    []string{"dremio-executor-0"},
+		NodeInfo: map[string]*RemoteNodeInfo{
+			"dremio-master-0":   {ConfDir: "/opt/dremio/conf", LogDir: "/var/log/dremio"},
+			"dremio-executor-0": {ConfDir: "/opt/dremio/conf", LogDir: "/var/log/dremio"},
+		},
+	})
+	if ci.DremioVersion["dremio-master-0"] != "25.0.4-202407" || ci.DremioVersionSource["dremio-master-0"] != IdentitySourceJarManifest {
+		t.Errorf("unexpected coordinator version: %v %v", ci.DremioVersion, ci.DremioVersionSource)
+	}
+	if ci.DremioVersion["dremio-executor-0"] != "25.0.3-202406" {
+		t.Errorf("expected version from jar file name, got %v", ci.DremioVersion)
+	}
+	if ci.ClusterID["dremio-master-0"] != "0b8e7ad5-7f7a-4a3d-9c0e-4a0c2c1b7f11" || ci.ClusterIDSource["dremio-master-0"] != IdentitySourceServerLog {
+		t.Errorf("unexpected cluster id result: %v %v", ci.ClusterID, ci.ClusterIDSource)
+	}
+}
+
+func TestParseServerLogIdentity(t *testing.T) {
+	version, clusterID := parseServerLogIdentity("Dremio version: 26.0.0-202501\nnothing here\n")
+	if version != "26.0.0-202501" {
+		t.Errorf("expected 26.0.0-202501, got %q", version)
+	}
+	if clusterID != "" {
+		t.Errorf("expected no cluster id, got %q", clusterID)
+	}
+}
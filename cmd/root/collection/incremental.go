@@ -0,0 +1,313 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// statePrefixBytes is how much of the start of a file the state file hashes.
+// A file whose first bytes still hash the same has only been appended to; a
+// rotated or rewritten file has not.
+const statePrefixBytes = 64 * 1024
+
+// IncrementalState is the --since-last state file: what each host's log files
+// looked like when the archive ArchiveID was collected.
+type IncrementalState struct {
+	ArchiveID  string                          `json:"archiveId"`
+	Archive    string                          `json:"archive"`
+	CreatedUTC time.Time                       `json:"createdUTC"`
+	Hosts      map[string]map[string]FileState `json:"hosts"` // host -> remote path -> state
+}
+
+// FileState is a remote file as last collected. PrefixSHA256 is the SHA-256 of
+// its first PrefixLen bytes.
+type FileState struct {
+	Size         int64  `json:"size"`
+	ModTime      int64  `json:"modTime"`
+	PrefixLen    int64  `json:"prefixLen"`
+	PrefixSHA256 string `json:"prefixSHA256"`
+}
+
+// DeltaInfo marks an archive collected with --since-last against a previous
+// one: unchanged files are left out and Tails hold only the appended bytes.
+type DeltaInfo struct {
+	PreviousArchiveID string     `json:"previousArchiveId"`
+	PreviousArchive   string     `json:"previousArchive"`
+	UnchangedFiles    []string   `json:"unchangedFiles"`
+	Tails             []TailFile `json:"tails"`
+}
+
+// TailFile is a file of which only the bytes from Offset on were collected.
+type TailFile struct {
+	Host   string `json:"host"`
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// LoadIncrementalState reads a state file, returning nil when it does not exist
+// yet (the first run collects everything).
+func LoadIncrementalState(path string) (*IncrementalState, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading --since-last state %v: %w", path, err)
+	}
+	var st IncrementalState
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("parsing --since-last state %v: %w", path, err)
+	}
+	return &st, nil
+}
+
+// Save writes the state file through a temporary file so an interrupted write
+// never leaves a truncated state behind.
+func (st *IncrementalState) Save(path string) error {
+	b, err := json.MarshalIndent(st, "", "\t")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return fmt.Errorf("writing --since-last state %v: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing --since-last state %v: %w", path, err)
+	}
+	return nil
+}
+
+// isTrackedFile reports whether a file takes part in incremental collection.
+// Config files are always collected in full.
+func isTrackedFile(rf RemoteFileInfo) bool {
+	return rf.FileType == "log" || rf.FileType == "queries" || rf.FileType == "gc-log"
+}
+
+// isAppendOnly reports whether a file is an active log written by appending
+// (server.log, queries.json, ...) rather than a rolled, often compressed one.
+func isAppendOnly(rf RemoteFileInfo) bool {
+	base := filepath.Base(rf.Path)
+	if rf.FileType != "log" && rf.FileType != "queries" {
+		return false
+	}
+	if strings.HasSuffix(base, ".gz") || strings.HasSuffix(base, ".zip") {
+		return false
+	}
+	return extractFilenameDate(base) == ""
+}
+
+// incrementalRun compares one collection against the previous state and builds
+// the next one. It is shared by the node goroutines.
+type incrementalRun struct {
+	archiveID string
+	previous  *IncrementalState // nil on the first run
+
+	mu    sync.Mutex
+	hosts map[string]map[string]FileState
+	delta DeltaInfo
+}
+
+func newIncrementalRun(archiveID string, previous *IncrementalState) *incrementalRun {
+	run := &incrementalRun{archiveID: archiveID, previous: previous, hosts: make(map[string]map[string]FileState)}
+	if previous != nil {
+		run.delta.PreviousArchiveID = previous.ArchiveID
+		run.delta.PreviousArchive = previous.Archive
+	}
+	return run
+}
+
+// plan drops the files unchanged since the previous run and sets the offset of
+// append-only files that only grew, so just their new bytes are streamed.
+// Anything it cannot prove unchanged or appended to is collected in full.
+func (run *incrementalRun) plan(c Collector, host string, files []nodeFile) []nodeFile {
+	var prev map[string]FileState
+	if run.previous != nil {
+		prev = run.previous.Hosts[host]
+	}
+	var planned []nodeFile
+	for _, nf := range files {
+		rf := nf.remote
+		old, seen := prev[rf.Path]
+		if !seen || !isTrackedFile(rf) {
+			planned = append(planned, nf)
+			continue
+		}
+		switch {
+		case rf.Size == old.Size && rf.ModTime == old.ModTime && rf.ModTime != 0:
+			simplelog.Infof("stream exclude (unchanged since %v): %v:%v", run.delta.PreviousArchiveID, host, rf.Path)
+			run.keep(host, rf.Path, old)
+			run.markUnchanged(host, rf.Path)
+			continue
+		case rf.Size > old.Size && isAppendOnly(rf) && run.prefixMatches(c, host, rf.Path, old):
+			simplelog.Infof("stream tail: %v:%v from byte %d (%d new bytes)", host, rf.Path, old.Size, rf.Size-old.Size)
+			nf.offset = old.Size
+		}
+		planned = append(planned, nf)
+	}
+	return planned
+}
+
+// prefixMatches checks that the first bytes of the remote file still hash as
+// recorded, i.e. it was appended to and not rotated in place.
+func (run *incrementalRun) prefixMatches(c Collector, host, remotePath string, old FileState) bool {
+	if old.PrefixSHA256 == "" || old.PrefixLen <= 0 {
+		return false
+	}
+	h := sha256.New()
+	cw := &countingWriter{w: h}
+	if err := c.StreamCommandFromHost(host, fmt.Sprintf("head -c %d %v", old.PrefixLen, shellQuote(remotePath)), cw); err != nil {
+		simplelog.Warningf("stream tail: %v:%v — could not read the first %d bytes, collecting it in full: %v", host, remotePath, old.PrefixLen, err)
+		return false
+	}
+	if cw.n != old.PrefixLen || hex.EncodeToString(h.Sum(nil)) != old.PrefixSHA256 {
+		simplelog.Infof("stream tail: %v:%v was rewritten since the last run, collecting it in full", host, remotePath)
+		return false
+	}
+	return true
+}
+
+// record adds a streamed file to the next state. A tail keeps the prefix of
+// the previous run, which it was checked against; a full copy is hashed from
+// the local file.
+func (run *incrementalRun) record(host string, nf nodeFile, written int64) {
+	rf := nf.remote
+	if !isTrackedFile(rf) {
+		return
+	}
+	if nf.offset > 0 {
+		old := run.previous.Hosts[host][rf.Path]
+		run.keep(host, rf.Path, FileState{
+			Size:         nf.offset + written,
+			ModTime:      rf.ModTime,
+			PrefixLen:    old.PrefixLen,
+			PrefixSHA256: old.PrefixSHA256,
+		})
+		run.mu.Lock()
+		run.delta.Tails = append(run.delta.Tails, TailFile{Host: host, Path: rf.Path, Offset: nf.offset, Bytes: written})
+		run.mu.Unlock()
+		return
+	}
+	n, sum, err := hashPrefix(nf.destPath)
+	if err != nil {
+		simplelog.Warningf("stream state: %v — %v", nf.destPath, err)
+	}
+	run.keep(host, rf.Path, FileState{Size: written, ModTime: rf.ModTime, PrefixLen: n, PrefixSHA256: sum})
+}
+
+// keep stores a file's state for the next run.
+func (run *incrementalRun) keep(host, remotePath string, st FileState) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.hosts[host] == nil {
+		run.hosts[host] = make(map[string]FileState)
+	}
+	run.hosts[host][remotePath] = st
+}
+
+// markUnchanged lists a file left out of the archive, as host:path.
+func (run *incrementalRun) markUnchanged(host, remotePath string) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.delta.UnchangedFiles = append(run.delta.UnchangedFiles, host+":"+remotePath)
+}
+
+// Delta returns the delta marker for summary.json, nil on a first run.
+func (run *incrementalRun) Delta() *DeltaInfo {
+	if run.previous == nil {
+		return nil
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	d := run.delta
+	sort.Strings(d.UnchangedFiles)
+	sort.Slice(d.Tails, func(i, j int) bool {
+		if d.Tails[i].Host != d.Tails[j].Host {
+			return d.Tails[i].Host < d.Tails[j].Host
+		}
+		return d.Tails[i].Path < d.Tails[j].Path
+	})
+	return &d
+}
+
+// State returns the state to save for the next run. Hosts that were not
+// collected this time keep their previous state.
+func (run *incrementalRun) State(archive string) *IncrementalState {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	st := &IncrementalState{ArchiveID: run.archiveID, Archive: archive, CreatedUTC: time.Now().UTC(), Hosts: make(map[string]map[string]FileState)}
+	if run.previous != nil {
+		for host, files := range run.previous.Hosts {
+			st.Hosts[host] = files
+		}
+	}
+	for host, files := range run.hosts {
+		st.Hosts[host] = files
+	}
+	return st
+}
+
+// hashPrefix returns the length and SHA-256 of the first statePrefixBytes of a
+// local file.
+func hashPrefix(path string) (int64, string, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close() //nolint:errcheck // read-only file; close error is non-fatal
+	h := sha256.New()
+	n, err := io.Copy(h, io.LimitReader(f, statePrefixBytes))
+	if err != nil {
+		return 0, "", fmt.Errorf("hashing %v: %w", path, err)
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// countingWriter counts the bytes passed through to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// offsetCollector shifts every StreamFromHostAt by base bytes, so the resumable
+// per-file streaming fetches only what was appended after base.
+type offsetCollector struct {
+	Collector
+	base int64
+}
+
+func (oc offsetCollector) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
+	return oc.Collector.StreamFromHostAt(host, remotePath, oc.base+offset, writer, useGzip)
+}
@@ -0,0 +1,220 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// remoteFiles is a node's log directory for the incremental tests: it serves
+// files from an offset, "head -c" and tar streams of whatever it holds.
+type remoteFiles struct {
+	content map[string]string
+	modTime map[string]int64
+	fetched map[string]int64 // path -> offset of the last per-file stream
+}
+
+func (r *remoteFiles) info(tar bool) *RemoteNodeInfo {
+	info := &RemoteNodeInfo{TarAvailable: tar}
+	for p, c := range r.content {
+		ft := "log"
+		switch {
+		case strings.HasSuffix(p, ".conf"):
+			ft = "config"
+		case strings.Contains(p, "queries."):
+			ft = "queries"
+		}
+		info.Files = append(info.Files, RemoteFileInfo{Path: p, Size: int64(len(c)), FileType: ft, ModTime: r.modTime[p]})
+	}
+	sort.Slice(info.Files, func(i, j int) bool { return info.Files[i].Path < info.Files[j].Path })
+	return info
+}
+
+func (r *remoteFiles) collector(t *testing.T) *mockStreamCollector {
+	return &mockStreamCollector{
+		streamAtFunc: func(_, remotePath string, offset int64, writer io.Writer) error {
+			r.fetched[remotePath] = offset
+			_, err := io.WriteString(writer, r.content[remotePath][offset:])
+			return err
+		},
+		streamCmdFunc: func(_, streamCmd string, writer io.Writer) error {
+			var n int
+			var quoted string
+			if _, err := fmt.Sscanf(streamCmd, "head -c %d %s", &n, &quoted); err == nil {
+				c := r.content[strings.Trim(quoted, "'")]
+				if n > len(c) {
+					n = len(c)
+				}
+				_, err := io.WriteString(writer, c[:n])
+				return err
+			}
+			var order []string
+			for p := range r.content {
+				if strings.Contains(streamCmd, shellQuote(p)) {
+					order = append(order, p)
+					r.fetched[p] = 0
+				}
+			}
+			writeTarStream(t, writer, r.content, order, false, false)
+			return nil
+		},
+	}
+}
+
+func TestStreamNodeFiles_SinceLast(t *testing.T) {
+	remote := &remoteFiles{
+		content: map[string]string{
+			"/var/log/dremio/server.log":                 "line 1\nline 2\n",
+			"/var/log/dremio/server.2024-01-01.0.log.gz": "rolled",
+			"/var/log/dremio/queries.json":               `{"q":1}` + "\n",
+			"/opt/dremio/conf/dremio.conf":               "paths.local: /data\n",
+		},
+		modTime: map[string]int64{
+			"/var/log/dremio/server.log":                 100,
+			"/var/log/dremio/server.2024-01-01.0.log.gz": 50,
+			"/var/log/dremio/queries.json":               100,
+			"/opt/dremio/conf/dremio.conf":               10,
+		},
+		fetched: make(map[string]int64),
+	}
+	args := Args{CollectServerLogs: true, CollectQueriesJSON: true}
+
+	// first run: no state yet, everything is collected in one tar stream
+	first := newIncrementalRun("archive-1", nil)
+	args.incremental = first
+	collected, skipped := streamNodeFiles(remote.collector(t), "host1", remote.info(true), &mockCopyStrategy{tmpDir: t.TempDir()}, "coordinator", "diagnosis", false, args)
+	if len(collected) != 4 || len(skipped) != 0 {
+		t.Fatalf("expected 4 files collected on the first run, got %v, skipped %v", collected, skipped)
+	}
+	if first.Delta() != nil {
+		t.Error("a first run is not a delta")
+	}
+	state := first.State("/tmp/diag-1.tgz")
+	if got := state.Hosts["host1"]["/var/log/dremio/server.log"]; got.Size != 14 || got.PrefixLen != 14 || got.PrefixSHA256 == "" {
+		t.Errorf("unexpected server.log state %+v", got)
+	}
+	if _, tracked := state.Hosts["host1"]["/opt/dremio/conf/dremio.conf"]; tracked {
+		t.Error("config files are always collected and should not be tracked")
+	}
+	stateFile := filepath.Join(t.TempDir(), "ddc-state.json")
+	if err := state.Save(stateFile); err != nil {
+		t.Fatal(err)
+	}
+	previous, err := LoadIncrementalState(stateFile)
+	if err != nil || previous.ArchiveID != "archive-1" {
+		t.Fatalf("expected the saved state back, got %+v, %v", previous, err)
+	}
+
+	// second run: server.log grew, queries.json was rotated and rewritten
+	remote.content["/var/log/dremio/server.log"] += "line 3\n"
+	remote.modTime["/var/log/dremio/server.log"] = 200
+	remote.content["/var/log/dremio/queries.json"] = `{"q":2}` + "\n" + `{"q":3}` + "\n"
+	remote.modTime["/var/log/dremio/queries.json"] = 200
+	remote.fetched = make(map[string]int64)
+
+	tmpDir := t.TempDir()
+	second := newIncrementalRun("archive-2", previous)
+	args.incremental = second
+	collected, skipped = streamNodeFiles(remote.collector(t), "host1", remote.info(true), &mockCopyStrategy{tmpDir: tmpDir}, "coordinator", "diagnosis", false, args)
+	if len(collected) != 3 || len(skipped) != 0 {
+		t.Fatalf("expected 3 files collected on the second run, got %v, skipped %v", collected, skipped)
+	}
+	wantFetched := map[string]int64{
+		"/var/log/dremio/server.log":   14,
+		"/var/log/dremio/queries.json": 0,
+		"/opt/dremio/conf/dremio.conf": 0,
+	}
+	if !reflect.DeepEqual(remote.fetched, wantFetched) {
+		t.Errorf("expected fetches %v, got %v", wantFetched, remote.fetched)
+	}
+	b, err := os.ReadFile(filepath.Join(tmpDir, "logs", "host1", "server.log"))
+	if err != nil || string(b) != "line 3\n" {
+		t.Errorf("expected only the appended line, got %q, %v", b, err)
+	}
+
+	delta := second.Delta()
+	if delta == nil || delta.PreviousArchiveID != "archive-1" || delta.PreviousArchive != "/tmp/diag-1.tgz" {
+		t.Fatalf("expected a delta of archive-1, got %+v", delta)
+	}
+	if !reflect.DeepEqual(delta.UnchangedFiles, []string{"host1:/var/log/dremio/server.2024-01-01.0.log.gz"}) {
+		t.Errorf("unexpected unchanged files %v", delta.UnchangedFiles)
+	}
+	if !reflect.DeepEqual(delta.Tails, []TailFile{{Host: "host1", Path: "/var/log/dremio/server.log", Offset: 14, Bytes: 7}}) {
+		t.Errorf("unexpected tails %+v", delta.Tails)
+	}
+	next := second.State("/tmp/diag-2.tgz").Hosts["host1"]
+	if got := next["/var/log/dremio/server.log"]; got.Size != 21 || got.ModTime != 200 || got.PrefixLen != 14 {
+		t.Errorf("unexpected server.log state %+v", got)
+	}
+	if got := next["/var/log/dremio/server.2024-01-01.0.log.gz"]; got != previous.Hosts["host1"]["/var/log/dremio/server.2024-01-01.0.log.gz"] {
+		t.Errorf("expected the unchanged file to keep its state, got %+v", got)
+	}
+	if got := next["/var/log/dremio/queries.json"]; got.Size != 16 || got.PrefixLen != 16 {
+		t.Errorf("expected queries.json to be tracked from its new content, got %+v", got)
+	}
+}
+
+func TestIncrementalRunState_KeepsHostsNotCollected(t *testing.T) {
+	previous := &IncrementalState{ArchiveID: "a", Hosts: map[string]map[string]FileState{
+		"host1": {"/var/log/dremio/server.log": {Size: 1}},
+		"host2": {"/var/log/dremio/server.log": {Size: 2}},
+	}}
+	run := newIncrementalRun("b", previous)
+	run.keep("host1", "/var/log/dremio/server.log", FileState{Size: 10})
+	st := run.State("diag.tgz")
+	if st.ArchiveID != "b" || st.Hosts["host1"]["/var/log/dremio/server.log"].Size != 10 || st.Hosts["host2"]["/var/log/dremio/server.log"].Size != 2 {
+		t.Errorf("unexpected state %+v", st)
+	}
+}
+
+func TestLoadIncrementalState(t *testing.T) {
+	dir := t.TempDir()
+	if st, err := LoadIncrementalState(filepath.Join(dir, "missing.json")); st != nil || err != nil {
+		t.Errorf("expected no state and no error for a missing file, got %v, %v", st, err)
+	}
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadIncrementalState(bad); err == nil {
+		t.Error("expected an error for a corrupt state file")
+	}
+}
+
+func TestIsAppendOnly(t *testing.T) {
+	tests := []struct {
+		rf   RemoteFileInfo
+		want bool
+	}{
+		{RemoteFileInfo{Path: "/var/log/dremio/server.log", FileType: "log"}, true},
+		{RemoteFileInfo{Path: "/var/log/dremio/queries.json", FileType: "queries"}, true},
+		{RemoteFileInfo{Path: "/var/log/dremio/server.2024-01-01.0.log.gz", FileType: "log"}, false},
+		{RemoteFileInfo{Path: "/var/log/dremio/queries.2024-01-01.0.json", FileType: "queries"}, false},
+		{RemoteFileInfo{Path: "/var/log/dremio/gc.log", FileType: "gc-log"}, false},
+		{RemoteFileInfo{Path: "/opt/dremio/conf/dremio.conf", FileType: "config"}, false},
+	}
+	for _, tt := range tests {
+		if got := isAppendOnly(tt.rf); got != tt.want {
+			t.Errorf("isAppendOnly(%v) = %v, want %v", tt.rf.Path, got, tt.want)
+		}
+	}
+}
@@ -152,6 +152,31 @@ func CollectJVMFlags(c Collector, host string, pid int, outDir string) error {
 	return nil
 }
 
+// CollectNMT captures the VM.native_memory summary from a remote host. Native
+// memory tracking has to be enabled on the JVM (-XX:NativeMemoryTracking=summary),
+// otherwise jcmd reports it as disabled and that answer is written as is.
+func CollectNMT(c Collector, host string, pid int, outDir string) error {
+	simplelog.Infof("nmt: starting collection on %s (pid=%d)", host, pid)
+
+	if err := os.MkdirAll(outDir, DirPerms); err != nil {
+		return fmt.Errorf("nmt: failed to create output dir %s: %w", outDir, err)
+	}
+
+	out, err := jcmdExec(c, host, strconv.Itoa(pid), "VM.native_memory", "summary")
+	if err != nil {
+		simplelog.Warningf("nmt: VM.native_memory failed on %s: %v", host, err)
+		return fmt.Errorf("nmt: VM.native_memory failed on %s: %w", host, err)
+	}
+
+	filename := filepath.Join(outDir, "native_memory.txt")
+	if err := os.WriteFile(filename, []byte(out), 0o600); err != nil {
+		return fmt.Errorf("nmt: failed to write %s: %w", filename, err)
+	}
+
+	simplelog.Infof("nmt: completed on %s, wrote %s", host, filename)
+	return nil
+}
+
 // CollectJFR runs a Java Flight Recording on a remote host, streams the
 // resulting .jfr file back, and cleans up the remote temp file.
 // sleepFn is injected so tests can skip the real wait.
@@ -337,23 +362,13 @@ func probeRemoteFileSize(c Collector, host, remotePath string) int64 {
 	return n
 }
 
-// streamRemoteFile streams a remote file to a local path via the collector's
-// StreamFromHost method, reporting transfer progress (including percentage
-// when the remote file size can be probed) to the TUI.
+// streamRemoteFile streams a remote file to a local path via streamFile, so a
+// transfer broken part way through a large heap dump or JFR resumes from the bytes
+// already received. Progress (including percentage when the remote file size can
+// be probed) is reported to the TUI.
 func streamRemoteFile(c Collector, host, remotePath, localPath string) error {
 	expectedSize := probeRemoteFileSize(c, host, remotePath)
-
-	f, err := os.Create(localPath) // #nosec G304 -- localPath is derived from controlled output dir
-	if err != nil {
-		return fmt.Errorf("failed to create local file %s: %w", localPath, err)
-	}
-	defer f.Close()
-
-	filename := filepath.Base(localPath)
-	pw := &progressWriter{w: f, expectedSize: expectedSize, host: host, filename: filename}
-
-	if err := c.StreamFromHost(host, remotePath, pw, false); err != nil {
-		_ = os.Remove(localPath)
+	if _, _, err := streamFile(c, host, remotePath, localPath, maxRetries, expectedSize, filepath.Base(localPath), "", false); err != nil {
 		return fmt.Errorf("StreamFromHost %s: %w", remotePath, err)
 	}
 	return nil
@@ -58,12 +58,18 @@ func (m *mockJVMCollector) Name() string           { return "mock-jvm" }
 func (m *mockJVMCollector) Protocol() string       { return "mock" }
 func (m *mockJVMCollector) SetHostPid(_, _ string) {}
 func (m *mockJVMCollector) CleanupRemote() error   { return nil }
-func (m *mockJVMCollector) StreamFromHost(host string, remotePath string, writer io.Writer, _ bool) error {
+func (m *mockJVMCollector) StreamFromHost(host string, remotePath string, writer io.Writer, useGzip bool) error {
+	return m.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+func (m *mockJVMCollector) StreamFromHostAt(host string, remotePath string, _ int64, writer io.Writer, _ bool) error {
 	if m.streamFromHostFn != nil {
 		return m.streamFromHostFn(host, remotePath, writer)
 	}
 	return nil
 }
+func (m *mockJVMCollector) StreamCommandFromHost(_, _ string, _ io.Writer) error {
+	return nil
+}
 func (m *mockJVMCollector) DiscoverFiles(_, _, _ string) (*RemoteNodeInfo, error) {
 	return &RemoteNodeInfo{}, nil
 }
@@ -294,6 +300,48 @@ func TestCollectJVMFlags_BothFail(t *testing.T) {
 	}
 }
 
+// --- NMT tests ---
+
+func TestCollectNMT_WritesSummary(t *testing.T) {
+	outDir := t.TempDir()
+	mock := &mockJVMCollector{
+		hostExecuteFn: func(_ bool, _ string, args ...string) (string, error) {
+			if !strings.Contains(strings.Join(args, " "), "VM.native_memory summary") {
+				return "", fmt.Errorf("unexpected args: %v", args)
+			}
+			return "Native Memory Tracking:\nTotal: reserved=10GB, committed=8GB", nil
+		},
+	}
+
+	if err := CollectNMT(mock, "node-1", 12345, outDir); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(outDir, "native_memory.txt"))
+	if err != nil {
+		t.Fatalf("failed to read native_memory.txt: %v", err)
+	}
+	if !strings.Contains(string(content), "committed=8GB") {
+		t.Errorf("unexpected native_memory.txt content: %s", content)
+	}
+}
+
+func TestCollectNMT_Failure(t *testing.T) {
+	outDir := t.TempDir()
+	mock := &mockJVMCollector{
+		hostExecuteFn: func(_ bool, _ string, _ ...string) (string, error) {
+			return "", errors.New("jcmd not responding")
+		},
+	}
+
+	err := CollectNMT(mock, "node-1", 12345, outDir)
+	if err == nil || !strings.Contains(err.Error(), "VM.native_memory") {
+		t.Fatalf("expected a VM.native_memory error, got %v", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(outDir, "native_memory.txt")); !os.IsNotExist(statErr) {
+		t.Error("expected no native_memory.txt when jcmd fails")
+	}
+}
+
 // --- JFR tests ---
 
 func TestCollectJFR_HappyPath(t *testing.T) {
@@ -0,0 +1,250 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sapi "k8s.io/client-go/kubernetes"
+)
+
+const (
+	helmReleaseSecretType   = "helm.sh/release.v1"
+	helmReleaseSecretPrefix = "sh.helm.release.v1."
+)
+
+// helmRelease is the part of a Helm v3 release record that is kept. The rendered
+// manifest, templates and notes are left out.
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		FirstDeployed string `json:"first_deployed"`
+		LastDeployed  string `json:"last_deployed"`
+		Status        string `json:"status"`
+		Description   string `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+		Values map[string]interface{} `json:"values"`
+	} `json:"chart"`
+	Config map[string]interface{} `json:"config"`
+}
+
+// HelmRevision is one entry of kubernetes/helm/<release>/history.json.
+type HelmRevision struct {
+	Revision      int    `json:"revision"`
+	Status        string `json:"status"`
+	Chart         string `json:"chart"`
+	ChartVersion  string `json:"chartVersion"`
+	AppVersion    string `json:"appVersion,omitempty"`
+	FirstDeployed string `json:"firstDeployed,omitempty"`
+	LastDeployed  string `json:"lastDeployed,omitempty"`
+	Description   string `json:"description,omitempty"`
+}
+
+// HelmValues is written to kubernetes/helm/<release>/values-r<revision>.json. Computed
+// is the chart defaults with the user supplied values merged over them, the same as
+// `helm get values --all`.
+type HelmValues struct {
+	Release      string                 `json:"release"`
+	Revision     int                    `json:"revision"`
+	Status       string                 `json:"status"`
+	Chart        string                 `json:"chart"`
+	ChartVersion string                 `json:"chartVersion"`
+	UserSupplied map[string]interface{} `json:"userSupplied"`
+	Computed     map[string]interface{} `json:"computed"`
+}
+
+// decodeHelmRelease undoes Helm's storage encoding: the secret's release key holds
+// base64 text of (usually gzipped) JSON.
+func decodeHelmRelease(data []byte) (helmRelease, error) {
+	var rel helmRelease
+	raw, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return rel, fmt.Errorf("base64 decode: %w", err)
+	}
+	if len(raw) > 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		gz, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return rel, fmt.Errorf("gzip: %w", err)
+		}
+		defer gz.Close()
+		if raw, err = io.ReadAll(gz); err != nil {
+			return rel, fmt.Errorf("gzip: %w", err)
+		}
+	}
+	if err := json.Unmarshal(raw, &rel); err != nil {
+		return rel, fmt.Errorf("json: %w", err)
+	}
+	return rel, nil
+}
+
+// coalesceHelmValues merges src over dst the way Helm does: nested maps are merged,
+// anything else replaces, and a null in src deletes the default.
+func coalesceHelmValues(dst, src map[string]interface{}) map[string]interface{} {
+	if dst == nil {
+		dst = make(map[string]interface{})
+	}
+	for k, v := range src {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := dst[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			dst[k] = coalesceHelmValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+// helmReleaseName returns the release a Helm storage secret belongs to, from its
+// name label or failing that the secret name sh.helm.release.v1.<name>.v<N>.
+func helmReleaseName(s corev1.Secret) string {
+	if name := s.Labels["name"]; name != "" {
+		return name
+	}
+	name := strings.TrimPrefix(s.Name, helmReleaseSecretPrefix)
+	if i := strings.LastIndex(name, ".v"); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func helmValues(rel helmRelease) HelmValues {
+	user := rel.Config
+	if user == nil {
+		user = map[string]interface{}{}
+	}
+	// the computed copy is built from a fresh decode of user so masking one doesn't touch the other
+	var userCopy map[string]interface{}
+	if b, err := json.Marshal(user); err == nil {
+		_ = json.Unmarshal(b, &userCopy)
+	}
+	computed := coalesceHelmValues(rel.Chart.Values, userCopy)
+	masking.MaskHelmValues(user)
+	masking.MaskHelmValues(computed)
+	return HelmValues{
+		Release:      rel.Name,
+		Revision:     rel.Version,
+		Status:       rel.Info.Status,
+		Chart:        rel.Chart.Metadata.Name,
+		ChartVersion: rel.Chart.Metadata.Version,
+		UserSupplied: user,
+		Computed:     computed,
+	}
+}
+
+// collectHelmReleases finds Helm v3 release secrets in the namespace and writes, per
+// release, the revision history and the masked values of the latest and previous
+// revisions to kubernetes/helm/<release>/. Nothing is written when Helm isn't used.
+func collectHelmReleases(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem) {
+	timeoutDuration := 60 * time.Second
+	ctx, timeout := context.WithTimeoutCause(hook.GetContext(), timeoutDuration, fmt.Errorf("while listing helm releases in namespace %s timeout exceeded %v", namespace, timeoutDuration))
+	defer timeout()
+	consoleprint.UpdateResult("Collecting Helm release values...")
+	secrets, err := c.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: "owner=helm"})
+	if err != nil {
+		simplelog.Warningf("unable to list helm release secrets in namespace %v: %v", namespace, err)
+		return
+	}
+	releases := make(map[string][]helmRelease)
+	for _, s := range secrets.Items {
+		if s.Type != helmReleaseSecretType || !strings.HasPrefix(s.Name, helmReleaseSecretPrefix) {
+			continue
+		}
+		rel, err := decodeHelmRelease(s.Data["release"])
+		if err != nil {
+			simplelog.Warningf("unable to decode helm release secret %v: %v", s.Name, err)
+			continue
+		}
+		if rel.Name == "" {
+			rel.Name = helmReleaseName(s)
+		}
+		if rel.Version == 0 {
+			rel.Version, _ = strconv.Atoi(s.Labels["version"])
+		}
+		releases[rel.Name] = append(releases[rel.Name], rel)
+	}
+	if len(releases) == 0 {
+		simplelog.Infof("no helm releases found in namespace %v", namespace)
+		return
+	}
+	for name, revisions := range releases {
+		sort.Slice(revisions, func(i, j int) bool { return revisions[i].Version > revisions[j].Version })
+		path, err := cs.CreatePath("kubernetes", filepath.Join("helm", name), "")
+		if err != nil {
+			simplelog.Errorf("trying to construct helm path %v with error %v", path, err)
+			continue
+		}
+		history := make([]HelmRevision, 0, len(revisions))
+		for _, rel := range revisions {
+			history = append(history, HelmRevision{
+				Revision:      rel.Version,
+				Status:        rel.Info.Status,
+				Chart:         rel.Chart.Metadata.Name,
+				ChartVersion:  rel.Chart.Metadata.Version,
+				AppVersion:    rel.Chart.Metadata.AppVersion,
+				FirstDeployed: rel.Info.FirstDeployed,
+				LastDeployed:  rel.Info.LastDeployed,
+				Description:   rel.Info.Description,
+			})
+		}
+		files := map[string]interface{}{"history.json": history}
+		// latest and previous revision only, older ones are in the history
+		for _, rel := range revisions[:min(2, len(revisions))] {
+			files[fmt.Sprintf("values-r%d.json", rel.Version)] = helmValues(rel)
+		}
+		for file, v := range files {
+			data, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				simplelog.Errorf("unable to marshal helm %v for release %v: %v", file, name, err)
+				continue
+			}
+			filename := filepath.Join(path, file)
+			if err := ddfs.WriteFile(filename, data, DirPerms); err != nil {
+				simplelog.Errorf("trying to write file %v, error was %v", filename, err)
+			}
+		}
+		simplelog.Infof("collected helm release %v (%d revisions, chart %v-%v)", name, len(revisions), revisions[0].Chart.Metadata.Name, revisions[0].Chart.Metadata.Version)
+	}
+}
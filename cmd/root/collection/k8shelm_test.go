@@ -0,0 +1,179 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// helmSecret encodes a release the way Helm's secret storage driver does.
+func helmSecret(t *testing.T, release string, revision int, status string, config map[string]interface{}) *corev1.Secret {
+	t.Helper()
+	rel := map[string]interface{}{
+		"name":    release,
+		"version": revision,
+		"info":    map[string]interface{}{"status": status, "last_deployed": "2024-05-01T10:00:00Z"},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{"name": "dremio_v2", "version": fmt.Sprintf("2.%d.0", revision), "appVersion": "25.0.0"},
+			"values": map[string]interface{}{
+				"coordinator": map[string]interface{}{"count": 0, "memory": 8192},
+				"executor":    map[string]interface{}{"count": 3},
+				"zookeeper":   map[string]interface{}{"count": 3},
+			},
+		},
+		"config":   config,
+		"manifest": "apiVersion: v1\nkind: Secret\n",
+	}
+	raw, err := json.Marshal(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", release, revision),
+			Namespace: "test-ns",
+			Labels:    map[string]string{"owner": "helm", "name": release, "version": fmt.Sprint(revision), "status": status},
+		},
+		Type: helmReleaseSecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))},
+	}
+}
+
+func TestCollectHelmReleases_LatestAndPreviousMaskedWithHistory(t *testing.T) {
+	dir := t.TempDir()
+	fc := fake.NewSimpleClientset(
+		helmSecret(t, "dremio", 1, "superseded", map[string]interface{}{"executor": map[string]interface{}{"count": 2}}),
+		helmSecret(t, "dremio", 2, "superseded", map[string]interface{}{"executor": map[string]interface{}{"count": 4}}),
+		helmSecret(t, "dremio", 3, "deployed", map[string]interface{}{
+			"executor":    map[string]interface{}{"count": 5},
+			"zookeeper":   nil,
+			"distStorage": map[string]interface{}{"aws": map[string]interface{}{"accessKey": "AKIAEXAMPLE", "bucketName": "dist"}},
+		}),
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "test-ns"}, Data: map[string][]byte{"release": []byte("x")}},
+	)
+	cs := &helmPathCS{dir: dir}
+
+	collectHelmReleases(&stubHook{ctx: context.Background()}, "test-ns", fc, cs, helpers.NewRealFileSystem())
+
+	releaseDir := filepath.Join(dir, "helm", "dremio")
+	entries, err := os.ReadDir(releaseDir)
+	if err != nil {
+		t.Fatalf("expected helm output under %v: %v", releaseDir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "history.json,values-r2.json,values-r3.json" {
+		t.Fatalf("expected history plus latest and previous values, got %v", names)
+	}
+
+	var history []HelmRevision
+	readJSON(t, filepath.Join(releaseDir, "history.json"), &history)
+	if len(history) != 3 || history[0].Revision != 3 || history[0].Status != "deployed" || history[0].ChartVersion != "2.3.0" {
+		t.Errorf("unexpected history %+v", history)
+	}
+
+	raw, err := os.ReadFile(filepath.Join(releaseDir, "values-r3.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), "AKIAEXAMPLE") || strings.Contains(string(raw), "kind: Secret") {
+		t.Errorf("values-r3.json leaks the access key or the manifest: %s", raw)
+	}
+	var values HelmValues
+	readJSON(t, filepath.Join(releaseDir, "values-r3.json"), &values)
+	if values.Chart != "dremio_v2" || values.Revision != 3 {
+		t.Errorf("unexpected chart or revision %+v", values)
+	}
+	executor := values.Computed["executor"].(map[string]interface{})
+	coordinator := values.Computed["coordinator"].(map[string]interface{})
+	if executor["count"] != float64(5) || coordinator["memory"] != float64(8192) {
+		t.Errorf("expected user values merged over chart defaults, got %v", values.Computed)
+	}
+	if _, ok := values.Computed["zookeeper"]; ok {
+		t.Errorf("expected a null user value to remove the chart default, got %v", values.Computed["zookeeper"])
+	}
+	if _, ok := values.UserSupplied["coordinator"]; ok {
+		t.Errorf("user supplied values should not include chart defaults: %v", values.UserSupplied)
+	}
+}
+
+func TestCollectHelmReleases_NoReleasesWritesNothing(t *testing.T) {
+	dir := t.TempDir()
+	fc := fake.NewSimpleClientset()
+	collectHelmReleases(&stubHook{ctx: context.Background()}, "test-ns", fc, &helmPathCS{dir: dir}, helpers.NewRealFileSystem())
+	if _, err := os.Stat(filepath.Join(dir, "helm")); !os.IsNotExist(err) {
+		t.Errorf("expected no helm directory, got %v", err)
+	}
+}
+
+func TestDecodeHelmRelease_Uncompressed(t *testing.T) {
+	data := []byte(base64.StdEncoding.EncodeToString([]byte(`{"name":"dremio","version":7}`)))
+	rel, err := decodeHelmRelease(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rel.Name != "dremio" || rel.Version != 7 {
+		t.Errorf("unexpected release %+v", rel)
+	}
+	if _, err := decodeHelmRelease([]byte("not base64!")); err == nil {
+		t.Error("expected an error for invalid data")
+	}
+}
+
+// helmPathCS creates the requested source directory under dir, unlike stubCS which
+// puts everything in one place.
+type helmPathCS struct{ dir string }
+
+func (s *helmPathCS) CreatePath(_, source, _ string) (string, error) {
+	p := filepath.Join(s.dir, source)
+	return p, os.MkdirAll(p, 0o750)
+}
+func (s *helmPathCS) ArchiveDiag(_, _ string) error { return nil }
+func (s *helmPathCS) GetTmpDir() string             { return s.dir }
+
+func readJSON(t *testing.T, path string, v interface{}) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("unable to parse %v: %v", path, err)
+	}
+}
@@ -0,0 +1,251 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// ContainerLogOptions bounds what is pulled for each container log, current or previous.
+type ContainerLogOptions struct {
+	// SinceTime skips log lines older than the collection window; zero means the whole log.
+	SinceTime time.Time
+	// LimitBytes keeps only the last LimitBytes of each log; <= 0 means no limit.
+	LimitBytes int64
+}
+
+// ContainerLogOptionsFor derives the container log window from the same settings used
+// for server.log: --start-date in diagnosis mode, otherwise now minus the day limit.
+func ContainerLogOptionsFor(args Args) ContainerLogOptions {
+	opts := ContainerLogOptions{LimitBytes: args.ContainerLogLimitBytes}
+	dayLimit := logDayLimit("server.log", args)
+	if dayLimit <= 0 {
+		return opts
+	}
+	if args.CollectionMode == collects.DiagnosisCollection {
+		if sd, err := time.Parse("2006-01-02", args.StartDate); err == nil {
+			opts.SinceTime = sd
+			return opts
+		}
+	}
+	opts.SinceTime = time.Now().AddDate(0, 0, -dayLimit)
+	return opts
+}
+
+// containerLogMinLineBytes is the shortest average line assumed when asking the
+// server for the tail of a log, so that the lines requested hold at least
+// LimitBytes for any realistic log.
+const containerLogMinLineBytes = 64
+
+// TailLines returns the number of lines to ask the API server or container runtime
+// for so the log is cut before it is transferred, 0 without a limit. The server
+// can only cut by lines (its LimitBytes keeps the head, not the tail), so the
+// exact byte limit is still applied while writing.
+func (o ContainerLogOptions) TailLines() int64 {
+	if o.LimitBytes <= 0 {
+		return 0
+	}
+	return o.LimitBytes/containerLogMinLineBytes + 1
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max     int64
+	buf     []byte
+	dropped int64
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	// compact once the buffer is twice the limit, so copying stays amortized
+	if over := int64(len(t.buf)) - t.max; over > t.max {
+		t.dropped += over
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// Bytes returns the kept tail, starting at a line boundary when anything was dropped.
+func (t *tailBuffer) Bytes() []byte {
+	b := t.buf
+	if over := int64(len(b)) - t.max; over > 0 {
+		t.dropped += over
+		b = b[over:]
+		t.buf = b
+	}
+	if t.dropped > 0 {
+		if i := bytes.IndexByte(b, '\n'); i >= 0 {
+			t.dropped += int64(i + 1)
+			b = b[i+1:]
+			t.buf = b
+		}
+	}
+	return b
+}
+
+// writeContainerLog gzips a log stream to path, keeping only the last limitBytes when
+// set. It returns the file with its on-disk size and sha256, the uncompressed bytes
+// written and whether the head of the log was cut. On a read error the partial file
+// is kept and returned with the error, so a log cut off by a timeout is still useful.
+func writeContainerLog(ddfs helpers.Filesystem, path string, r io.Reader, limitBytes int64) (helpers.CollectedFile, int64, bool, error) {
+	src := r
+	var tail *tailBuffer
+	var readErr error
+	if limitBytes > 0 {
+		tail = &tailBuffer{max: limitBytes}
+		_, readErr = io.Copy(tail, r)
+		src = bytes.NewReader(tail.Bytes())
+	}
+	w, err := newGzipLineWriterFS(ddfs, path, 0)
+	if err != nil {
+		return helpers.CollectedFile{}, 0, false, err
+	}
+	br := bufio.NewReaderSize(src, 64*1024)
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if werr := w.WriteLine(strings.TrimSuffix(line, "\n")); werr != nil {
+				_, _ = w.Close()
+				return helpers.CollectedFile{}, 0, false, werr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+	}
+	f, err := w.Close()
+	if err != nil {
+		return helpers.CollectedFile{}, 0, false, err
+	}
+	truncated := tail != nil && tail.dropped > 0
+	if readErr != nil {
+		return f, w.written, truncated, fmt.Errorf("reading log: %w", readErr)
+	}
+	return f, w.written, truncated, nil
+}
+
+// WriteContainerLog gzips a container log stream to path as K8s container logs are
+// written, and counts it under owner (the pod or container) in summary.json. A log
+// that fails part way is removed; it is for transports that cannot resume a log.
+func WriteContainerLog(ddfs helpers.Filesystem, owner, path string, r io.Reader, limitBytes int64) error {
+	f, uncompressed, truncated, err := writeContainerLog(ddfs, path, r, limitBytes)
+	if err != nil {
+		if f.Path != "" {
+			removeContainerLog(ddfs, path)
+		}
+		return err
+	}
+	if truncated {
+		simplelog.Infof("container log for %v cut to the last %v bytes", owner, limitBytes)
+	}
+	containerLogTotals.record(owner, f, uncompressed, truncated)
+	return nil
+}
+
+// ContainerLogSummary is the per-pod container log total recorded in summary.json.
+type ContainerLogSummary struct {
+	Pod               string   `json:"pod"`
+	Files             int      `json:"files"`
+	Bytes             int64    `json:"bytes"`
+	UncompressedBytes int64    `json:"uncompressedBytes"`
+	Truncated         []string `json:"truncated,omitempty"` // files cut to --container-log-limit-bytes
+}
+
+// containerLogRecorder keeps the container logs written, keyed by file so a log
+// fetched twice (previous logs are pulled for restarts and again with all logs) is
+// counted once.
+type containerLogRecorder struct {
+	mu    sync.Mutex
+	files map[string]containerLogFile
+}
+
+type containerLogFile struct {
+	pod          string
+	file         helpers.CollectedFile
+	uncompressed int64
+	truncated    bool
+}
+
+var containerLogTotals = &containerLogRecorder{}
+
+func (r *containerLogRecorder) record(pod string, f helpers.CollectedFile, uncompressed int64, truncated bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.files == nil {
+		r.files = make(map[string]containerLogFile)
+	}
+	r.files[f.Path] = containerLogFile{pod: pod, file: f, uncompressed: uncompressed, truncated: truncated}
+}
+
+func (r *containerLogRecorder) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.files = nil
+}
+
+// ContainerLogSummaries returns the per-pod container log totals, sorted by pod.
+func ContainerLogSummaries() []ContainerLogSummary {
+	r := containerLogTotals
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.files) == 0 {
+		return nil
+	}
+	pods := make(map[string]*ContainerLogSummary)
+	for _, f := range r.files {
+		s, ok := pods[f.pod]
+		if !ok {
+			s = &ContainerLogSummary{Pod: f.pod}
+			pods[f.pod] = s
+		}
+		s.Files++
+		s.Bytes += f.file.Size
+		s.UncompressedBytes += f.uncompressed
+		if f.truncated {
+			s.Truncated = append(s.Truncated, filepath.Base(f.file.Path))
+		}
+	}
+	out := make([]ContainerLogSummary, 0, len(pods))
+	for _, s := range pods {
+		sort.Strings(s.Truncated)
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pod < out[j].Pod })
+	return out
+}
+
+// removeContainerLog drops a log file that could not be fetched at all.
+func removeContainerLog(ddfs helpers.Filesystem, path string) {
+	if err := ddfs.Remove(path); err != nil && !os.IsNotExist(err) {
+		simplelog.Warningf("unable to remove incomplete container log %v: %v", path, err)
+	}
+}
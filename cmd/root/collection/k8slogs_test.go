@@ -0,0 +1,156 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("%v is not gzip: %v", path, err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestContainerLogOptionsFor(t *testing.T) {
+	opts := ContainerLogOptionsFor(Args{CollectionMode: collects.DiagnosisCollection, DiagLogDays: 3, StartDate: "2024-05-01", ContainerLogLimitBytes: 1024})
+	if !opts.SinceTime.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || opts.LimitBytes != 1024 {
+		t.Errorf("expected the start date and limit, got %+v", opts)
+	}
+	if opts.TailLines() != 1024/containerLogMinLineBytes+1 {
+		t.Errorf("expected the server-side cut sized from the limit, got %d lines", opts.TailLines())
+	}
+
+	before := time.Now().AddDate(0, 0, -7)
+	opts = ContainerLogOptionsFor(Args{CollectionMode: collects.StandardCollection, ServerLogsNumDays: 7})
+	if opts.SinceTime.Before(before) || opts.SinceTime.After(time.Now().AddDate(0, 0, -7)) {
+		t.Errorf("expected now minus 7 days, got %v", opts.SinceTime)
+	}
+
+	opts = ContainerLogOptionsFor(Args{CollectionMode: collects.StandardCollection})
+	if !opts.SinceTime.IsZero() || opts.TailLines() != 0 {
+		t.Errorf("expected no window or line cut without limits, got %+v", opts)
+	}
+}
+
+func TestWriteContainerLog_TailLimitStartsAtLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pod-main.txt.gz")
+	var in strings.Builder
+	for i := 0; i < 1000; i++ {
+		in.WriteString("line number ")
+		in.WriteString(strings.Repeat("x", i%7))
+		in.WriteString("\n")
+	}
+	in.WriteString("last line\n")
+
+	f, uncompressed, truncated, err := writeContainerLog(helpers.NewRealFileSystem(), path, strings.NewReader(in.String()), 100)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !truncated {
+		t.Error("expected the log to be reported as truncated")
+	}
+	got := readGzip(t, path)
+	if int64(len(got)) > 100 || int64(len(got)) != uncompressed {
+		t.Errorf("expected at most 100 uncompressed bytes matching %d, got %d", uncompressed, len(got))
+	}
+	if !strings.HasPrefix(got, "line number ") || !strings.HasSuffix(got, "last line\n") {
+		t.Errorf("expected whole lines ending with the last one, got %q", got)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Size != st.Size() || f.SHA256 == "" {
+		t.Errorf("expected size %d and a sha256, got %+v", st.Size(), f)
+	}
+
+	_, _, truncated, err = writeContainerLog(helpers.NewRealFileSystem(), path, strings.NewReader("short\n"), 100)
+	if err != nil || truncated {
+		t.Errorf("expected a short log to be kept whole, got truncated=%v err=%v", truncated, err)
+	}
+}
+
+func TestGetClusterLogs_SinceTimeAndSummary(t *testing.T) {
+	containerLogTotals.reset()
+	defer containerLogTotals.reset()
+	fc := fake.NewSimpleClientset(makePod("dremio-master-0", map[string]string{"role": "dremio-cluster-pod"}, 1))
+	dir := t.TempDir()
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	hook := &stubHook{ctx: context.Background()}
+	opts := ContainerLogOptions{SinceTime: since, LimitBytes: 1 << 20}
+	if err := GetPreviousLogsForRestartedPods(hook, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := GetClusterLogs(hook, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "", opts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var logRequests int
+	for _, a := range fc.Actions() {
+		if a.GetSubresource() != "log" {
+			continue
+		}
+		logRequests++
+		o := a.(k8stesting.GenericAction).GetValue().(*corev1.PodLogOptions)
+		if o.SinceTime == nil || !o.SinceTime.Time.Equal(since) {
+			t.Errorf("expected sinceTime %v on every log request, got %v", since, o.SinceTime)
+		}
+		if o.TailLines == nil || *o.TailLines != opts.TailLines() || o.LimitBytes != nil {
+			t.Errorf("expected the log cut to %d lines on the server, got %v", opts.TailLines(), o.TailLines)
+		}
+	}
+	if logRequests != 3 {
+		t.Errorf("expected 3 log requests, got %d", logRequests)
+	}
+	if got := readGzip(t, filepath.Join(dir, "dremio-master-0-main.txt.gz")); got != "fake logs\n" {
+		t.Errorf("unexpected log content %q", got)
+	}
+
+	// the previous log was fetched twice but is only counted once
+	summaries := ContainerLogSummaries()
+	if len(summaries) != 1 || summaries[0].Pod != "dremio-master-0" || summaries[0].Files != 2 || summaries[0].UncompressedBytes != 2*int64(len("fake logs\n")) {
+		t.Fatalf("unexpected summaries %+v", summaries)
+	}
+	if summaries[0].Bytes <= 0 {
+		t.Errorf("expected on-disk bytes, got %+v", summaries[0])
+	}
+}
@@ -0,0 +1,232 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"k8s.io/apimachinery/pkg/api/resource"
+	k8sapi "k8s.io/client-go/kubernetes"
+)
+
+const metricsGroupVersion = "metrics.k8s.io/v1beta1"
+
+// DefaultMetricsInterval is how often pod and node usage is sampled during the diagnostic window.
+const DefaultMetricsInterval = 10 * time.Second
+
+// fetchMetrics reads one metrics.k8s.io path as raw JSON. It is a variable so tests can
+// stand in for metrics-server, which the fake clientset does not serve.
+var fetchMetrics = func(ctx context.Context, c k8sapi.Interface, path string) ([]byte, error) {
+	rc := c.Discovery().RESTClient()
+	if rc == nil {
+		return nil, errors.New("no REST client available for metrics.k8s.io")
+	}
+	return rc.Get().AbsPath(path).DoRaw(ctx)
+}
+
+// metricsUsage is the usage block of a metrics.k8s.io object.
+type metricsUsage struct {
+	CPU    string `json:"cpu"`
+	Memory string `json:"memory"`
+}
+
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Timestamp  string `json:"timestamp"`
+		Window     string `json:"window"`
+		Containers []struct {
+			Name  string       `json:"name"`
+			Usage metricsUsage `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+type nodeMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+		Timestamp string       `json:"timestamp"`
+		Window    string       `json:"window"`
+		Usage     metricsUsage `json:"usage"`
+	} `json:"items"`
+}
+
+// UsageSample is one CPU/memory reading, converted to millicores and bytes.
+type UsageSample struct {
+	Name          string `json:"name"`
+	Container     string `json:"container,omitempty"`
+	Timestamp     string `json:"timestamp"`
+	Window        string `json:"window"`
+	CPUMillicores int64  `json:"cpuMillicores"`
+	MemoryBytes   int64  `json:"memoryBytes"`
+}
+
+// UsageSnapshot holds every reading taken at one point in the collection.
+type UsageSnapshot struct {
+	SampledAt time.Time     `json:"sampledAt"`
+	Samples   []UsageSample `json:"samples"`
+}
+
+// UsageSeries is written to kubernetes/metrics/pods.json and nodes.json.
+type UsageSeries struct {
+	Source          string          `json:"source"`
+	Namespace       string          `json:"namespace,omitempty"`
+	IntervalSeconds int             `json:"intervalSeconds"`
+	Snapshots       []UsageSnapshot `json:"snapshots"`
+	Errors          []string        `json:"errors,omitempty"`
+}
+
+func newUsageSample(name, container, timestamp, window string, usage metricsUsage) UsageSample {
+	s := UsageSample{Name: name, Container: container, Timestamp: timestamp, Window: window}
+	if q, err := resource.ParseQuantity(usage.CPU); err == nil {
+		s.CPUMillicores = q.MilliValue()
+	}
+	if q, err := resource.ParseQuantity(usage.Memory); err == nil {
+		s.MemoryBytes = q.Value()
+	}
+	return s
+}
+
+// metricsAPIAvailable reports whether metrics-server (or another metrics.k8s.io provider) is registered.
+func metricsAPIAvailable(c k8sapi.Interface) bool {
+	list, err := c.Discovery().ServerResourcesForGroupVersion(metricsGroupVersion)
+	if err != nil || list == nil {
+		simplelog.Debugf("metrics.k8s.io discovery: %v", err)
+		return false
+	}
+	return len(list.APIResources) > 0
+}
+
+func samplePodMetrics(ctx context.Context, c k8sapi.Interface, namespace string) ([]UsageSample, error) {
+	out, err := fetchMetrics(ctx, c, fmt.Sprintf("/apis/%s/namespaces/%s/pods", metricsGroupVersion, namespace))
+	if err != nil {
+		return nil, err
+	}
+	var list podMetricsList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("unable to parse pod metrics: %w", err)
+	}
+	var samples []UsageSample
+	for _, pod := range list.Items {
+		for _, container := range pod.Containers {
+			samples = append(samples, newUsageSample(pod.Metadata.Name, container.Name, pod.Timestamp, pod.Window, container.Usage))
+		}
+	}
+	return samples, nil
+}
+
+func sampleNodeMetrics(ctx context.Context, c k8sapi.Interface) ([]UsageSample, error) {
+	out, err := fetchMetrics(ctx, c, fmt.Sprintf("/apis/%s/nodes", metricsGroupVersion))
+	if err != nil {
+		return nil, err
+	}
+	var list nodeMetricsList
+	if err := json.Unmarshal(out, &list); err != nil {
+		return nil, fmt.Errorf("unable to parse node metrics: %w", err)
+	}
+	var samples []UsageSample
+	for _, node := range list.Items {
+		samples = append(samples, newUsageSample(node.Metadata.Name, "", node.Timestamp, node.Window, node.Usage))
+	}
+	return samples, nil
+}
+
+// CollectK8sMetrics samples pod/container and node usage from metrics.k8s.io every interval
+// until duration has passed, or once when duration is zero (standard mode). When the metrics
+// API is not installed it logs and returns without writing anything. Node metrics need
+// cluster-scoped access; if they are refused the pod series is still written.
+func CollectK8sMetrics(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, duration, interval time.Duration) error {
+	if !metricsAPIAvailable(c) {
+		simplelog.Infof("%s is not available in this cluster (is metrics-server installed?) — skipping resource usage snapshots", metricsGroupVersion)
+		return nil
+	}
+	if interval <= 0 {
+		interval = DefaultMetricsInterval
+	}
+	pods := UsageSeries{Source: metricsGroupVersion, Namespace: namespace, IntervalSeconds: int(interval.Seconds())}
+	nodes := UsageSeries{Source: metricsGroupVersion, IntervalSeconds: int(interval.Seconds())}
+	nodesRefused := false
+
+	sample := func() {
+		ctx, cancel := context.WithTimeout(hook.GetContext(), 30*time.Second)
+		defer cancel()
+		now := time.Now().UTC()
+		if samples, err := samplePodMetrics(ctx, c, namespace); err != nil {
+			simplelog.Warningf("unable to sample pod metrics in namespace %v: %v", namespace, err)
+			pods.Errors = append(pods.Errors, fmt.Sprintf("%s: %v", now.Format(time.RFC3339), err))
+		} else {
+			pods.Snapshots = append(pods.Snapshots, UsageSnapshot{SampledAt: now, Samples: samples})
+		}
+		if nodesRefused {
+			return
+		}
+		if samples, err := sampleNodeMetrics(ctx, c); err != nil {
+			// typically a namespaced Role without nodes access; don't retry every interval
+			simplelog.Warningf("unable to sample node metrics, continuing with pod metrics only: %v", err)
+			nodes.Errors = append(nodes.Errors, fmt.Sprintf("%s: %v", now.Format(time.RFC3339), err))
+			nodesRefused = true
+		} else {
+			nodes.Snapshots = append(nodes.Snapshots, UsageSnapshot{SampledAt: now, Samples: samples})
+		}
+	}
+
+	deadline := time.Now().Add(duration)
+	sample()
+	if duration > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+	loop:
+		for time.Now().Before(deadline) {
+			consoleprint.UpdateResult(fmt.Sprintf("Sampling K8s resource usage (%d samples)...", len(pods.Snapshots)))
+			select {
+			case <-hook.GetContext().Done():
+				break loop
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}
+
+	path, err := cs.CreatePath("kubernetes", "metrics", "")
+	if err != nil {
+		return fmt.Errorf("trying to construct metrics path %v: %w", path, err)
+	}
+	for name, series := range map[string]UsageSeries{"pods.json": pods, "nodes.json": nodes} {
+		data, err := json.MarshalIndent(series, "", "  ")
+		if err != nil {
+			simplelog.Errorf("unable to marshal %v: %v", name, err)
+			continue
+		}
+		filename := filepath.Join(path, name)
+		if err := ddfs.WriteFile(filename, data, DirPerms); err != nil {
+			simplelog.Errorf("trying to write file %v, error was %v", filename, err)
+		}
+	}
+	simplelog.Infof("collected %d pod and %d node usage snapshots from %s", len(pods.Snapshots), len(nodes.Snapshots), metricsGroupVersion)
+	return nil
+}
@@ -0,0 +1,103 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sapi "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func metricsClientset() *fake.Clientset {
+	fc := fake.NewSimpleClientset()
+	fc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: metricsGroupVersion, APIResources: []metav1.APIResource{
+			{Name: "pods", Namespaced: true, Verbs: []string{"get", "list"}},
+			{Name: "nodes", Namespaced: false, Verbs: []string{"get", "list"}},
+		}},
+	}
+	return fc
+}
+
+func TestCollectK8sMetricsSamplesOverWindow(t *testing.T) {
+	original := fetchMetrics
+	defer func() { fetchMetrics = original }()
+	nodeCalls := 0
+	fetchMetrics = func(_ context.Context, _ k8sapi.Interface, path string) ([]byte, error) {
+		if strings.HasSuffix(path, "/nodes") {
+			nodeCalls++
+			return nil, errors.New("nodes.metrics.k8s.io is forbidden")
+		}
+		return []byte(`{"items":[{"metadata":{"name":"dremio-master-0"},"timestamp":"2026-10-18T10:00:00Z","window":"15s",
+			"containers":[{"name":"dremio-master-coordinator","usage":{"cpu":"1500m","memory":"4Gi"}}]}]}`), nil
+	}
+
+	dir := t.TempDir()
+	err := CollectK8sMetrics(&stubHook{ctx: context.Background()}, "test-ns", metricsClientset(), &stubCS{dir: dir}, helpers.NewRealFileSystem(), 60*time.Millisecond, 20*time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "pods.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pods UsageSeries
+	if err := json.Unmarshal(data, &pods); err != nil {
+		t.Fatal(err)
+	}
+	if len(pods.Snapshots) < 2 {
+		t.Fatalf("expected several snapshots over the window, got %d", len(pods.Snapshots))
+	}
+	got := pods.Snapshots[0].Samples[0]
+	if got.Name != "dremio-master-0" || got.Container != "dremio-master-coordinator" || got.CPUMillicores != 1500 || got.MemoryBytes != 4*1024*1024*1024 {
+		t.Errorf("unexpected sample: %+v", got)
+	}
+	if nodeCalls != 1 {
+		t.Errorf("expected node metrics to be tried once after being refused, got %d calls", nodeCalls)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "nodes.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "forbidden") {
+		t.Errorf("expected the node metrics error recorded: %s", data)
+	}
+}
+
+func TestCollectK8sMetricsWithoutMetricsAPI(t *testing.T) {
+	original := fetchMetrics
+	defer func() { fetchMetrics = original }()
+	fetchMetrics = func(_ context.Context, _ k8sapi.Interface, path string) ([]byte, error) {
+		t.Errorf("metrics should not be fetched when the API is missing: %s", path)
+		return nil, nil
+	}
+	dir := t.TempDir()
+	if err := CollectK8sMetrics(&stubHook{ctx: context.Background()}, "test-ns", fake.NewSimpleClientset(), &stubCS{dir: dir}, helpers.NewRealFileSystem(), 0, 0); err != nil {
+		t.Fatalf("expected a missing metrics API to be skipped, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected no files, got %d", len(entries))
+	}
+}
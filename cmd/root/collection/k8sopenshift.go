@@ -0,0 +1,154 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sapi "k8s.io/client-go/kubernetes"
+)
+
+// openShiftResource is an OpenShift API resource collected by name when the cluster
+// serves its group.
+type openShiftResource struct {
+	GroupVersion schema.GroupVersion
+	Resource     string
+	// Scope is "namespaced" for a list in the namespace, "cluster" for a cluster wide
+	// list and "project" for the single project matching the namespace.
+	Scope string
+}
+
+// openShiftResources are the OpenShift kinds that explain how a Dremio deployment is
+// exposed, built and admitted.
+var openShiftResources = []openShiftResource{
+	{GroupVersion: schema.GroupVersion{Group: "route.openshift.io", Version: "v1"}, Resource: "routes", Scope: "namespaced"},
+	{GroupVersion: schema.GroupVersion{Group: "apps.openshift.io", Version: "v1"}, Resource: "deploymentconfigs", Scope: "namespaced"},
+	{GroupVersion: schema.GroupVersion{Group: "image.openshift.io", Version: "v1"}, Resource: "imagestreams", Scope: "namespaced"},
+	{GroupVersion: schema.GroupVersion{Group: "security.openshift.io", Version: "v1"}, Resource: "securitycontextconstraints", Scope: "cluster"},
+	{GroupVersion: schema.GroupVersion{Group: "project.openshift.io", Version: "v1"}, Resource: "projects", Scope: "project"},
+}
+
+// isOpenShiftAPIGroup reports whether a group is part of the OpenShift platform.
+func isOpenShiftAPIGroup(group string) bool {
+	return strings.HasSuffix(group, ".openshift.io")
+}
+
+// getOpenShiftResource fetches raw JSON from an absolute API path. It is a variable so
+// tests can stand in for the API server, which the fake clientset does not serve.
+var getOpenShiftResource = func(ctx context.Context, c k8sapi.Interface, absPath ...string) ([]byte, error) {
+	rc := c.Discovery().RESTClient()
+	if rc == nil {
+		return nil, errors.New("no REST client available for OpenShift resources")
+	}
+	return rc.Get().AbsPath(absPath...).DoRaw(ctx)
+}
+
+// detectOpenShift reports whether the cluster is OpenShift, from the API groups it
+// serves, and returns the served group versions.
+func detectOpenShift(c k8sapi.Interface) (bool, map[string]bool, error) {
+	groups, err := c.Discovery().ServerGroups()
+	if err != nil {
+		return false, nil, err
+	}
+	served := make(map[string]bool)
+	openShift := false
+	for _, g := range groups.Groups {
+		if !isOpenShiftAPIGroup(g.Name) {
+			continue
+		}
+		openShift = true
+		for _, v := range g.Versions {
+			served[v.GroupVersion] = true
+		}
+	}
+	return openShift, served, nil
+}
+
+// collectOpenShiftResources writes routes, deployment configs, image streams, the
+// security context constraints and the namespace's project to kubernetes/openshift/
+// when the cluster is OpenShift. Nothing is written on other clusters. SCCs are cluster
+// scoped, so a namespaced Role will usually be refused them; that is logged and skipped.
+func collectOpenShiftResources(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem) {
+	openShift, served, err := detectOpenShift(c)
+	if err != nil {
+		simplelog.Warningf("unable to read API groups for OpenShift detection: %v", err)
+		return
+	}
+	if !openShift {
+		simplelog.Debug("no OpenShift API groups served, skipping OpenShift resources")
+		return
+	}
+	simplelog.Info("OpenShift detected, collecting OpenShift resources")
+	path, err := cs.CreatePath("kubernetes", "openshift", "")
+	if err != nil {
+		simplelog.Errorf("trying to construct openshift path %v with error %v", path, err)
+		return
+	}
+	for i, r := range openShiftResources {
+		if !served[r.GroupVersion.String()] {
+			simplelog.Debugf("%v is not served, skipping %v", r.GroupVersion, r.Resource)
+			continue
+		}
+		consoleprint.UpdateResult(fmt.Sprintf("Collecting OpenShift resources (%d/%d): %s...", i+1, len(openShiftResources), r.Resource))
+		out, err := openShiftResourceBytes(hook, namespace, c, r)
+		if err != nil {
+			simplelog.Warningf("unable to get %s.%s: %v", r.Resource, r.GroupVersion.Group, err)
+			continue
+		}
+		text := string(out)
+		filename := filepath.Join(path, r.Resource+".json")
+		if r.Scope == "project" {
+			// a single object, holding the uid and supplemental group ranges SCCs assign from
+			filename = filepath.Join(path, "project.json")
+		} else if text, err = masking.RemoveSecretsFromK8sJSON(out); err != nil {
+			simplelog.Errorf("unable to mask secrets for %s.%s in namespace %v: %v", r.Resource, r.GroupVersion.Group, namespace, err)
+			continue
+		}
+		if err := ddfs.WriteFile(filename, []byte(text), DirPerms); err != nil {
+			simplelog.Errorf("trying to write file %v, error was %v", filename, err)
+		}
+	}
+}
+
+func openShiftResourceBytes(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, r openShiftResource) ([]byte, error) {
+	timeoutDuration := 60 * time.Second
+	ctx, timeout := context.WithTimeoutCause(hook.GetContext(), timeoutDuration, fmt.Errorf("while getting %v in namespace %s timeout exceeded %v", r.Resource, namespace, timeoutDuration))
+	defer timeout()
+	absPath := []string{"/apis", r.GroupVersion.Group, r.GroupVersion.Version}
+	switch r.Scope {
+	case "namespaced":
+		absPath = append(absPath, "namespaces", namespace, r.Resource)
+	case "project":
+		absPath = append(absPath, r.Resource, namespace)
+	default:
+		absPath = append(absPath, r.Resource)
+	}
+	out, err := getOpenShiftResource(ctx, c, absPath...)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, context.Cause(ctx)
+	}
+	return out, err
+}
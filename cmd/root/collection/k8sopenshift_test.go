@@ -0,0 +1,150 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sapi "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func openShiftClientset() *fake.Clientset {
+	fc := fake.NewSimpleClientset()
+	fc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Verbs: []string{"list"}}}},
+		{GroupVersion: "route.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "routes", Namespaced: true, Verbs: []string{"list"}}}},
+		{GroupVersion: "apps.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "deploymentconfigs", Namespaced: true, Verbs: []string{"list"}}}},
+		{GroupVersion: "security.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "securitycontextconstraints", Verbs: []string{"list"}}}},
+		{GroupVersion: "project.openshift.io/v1", APIResources: []metav1.APIResource{{Name: "projects", Verbs: []string{"get", "list"}}}},
+	}
+	return fc
+}
+
+func TestCollectOpenShiftResources(t *testing.T) {
+	fc := openShiftClientset()
+	original := getOpenShiftResource
+	defer func() { getOpenShiftResource = original }()
+	var requested []string
+	getOpenShiftResource = func(_ context.Context, _ k8sapi.Interface, absPath ...string) ([]byte, error) {
+		p := strings.Join(absPath, "/")
+		requested = append(requested, p)
+		switch {
+		case strings.HasSuffix(p, "/securitycontextconstraints"):
+			return nil, errors.New(`securitycontextconstraints.security.openshift.io is forbidden`)
+		case strings.HasSuffix(p, "/projects/test-ns"):
+			return []byte(`{"kind":"Project","metadata":{"name":"test-ns","annotations":{"openshift.io/sa.scc.uid-range":"1000650000/10000"}}}`), nil
+		}
+		return []byte(`{"kind":"List","items":[{"kind":"Route","metadata":{"name":"dremio-ui"}}]}`), nil
+	}
+
+	dir := t.TempDir()
+	collectOpenShiftResources(&stubHook{ctx: context.Background()}, "test-ns", fc, &helmPathCS{dir: dir}, helpers.NewRealFileSystem())
+
+	sort.Strings(requested)
+	want := []string{
+		"/apis/apps.openshift.io/v1/namespaces/test-ns/deploymentconfigs",
+		"/apis/project.openshift.io/v1/projects/test-ns",
+		"/apis/route.openshift.io/v1/namespaces/test-ns/routes",
+		"/apis/security.openshift.io/v1/securitycontextconstraints",
+	}
+	if strings.Join(requested, ",") != strings.Join(want, ",") {
+		t.Errorf("expected only served OpenShift resources to be requested, got %v", requested)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "openshift"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "deploymentconfigs.json,project.json,routes.json" {
+		t.Errorf("unexpected openshift files %v", names)
+	}
+	project, err := os.ReadFile(filepath.Join(dir, "openshift", "project.json"))
+	if err != nil || !strings.Contains(string(project), "sa.scc.uid-range") {
+		t.Errorf("expected the project with its SCC ranges, got %s (%v)", project, err)
+	}
+}
+
+func TestCollectOpenShiftResources_NotOpenShift(t *testing.T) {
+	fc := fake.NewSimpleClientset()
+	fc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Verbs: []string{"list"}}}},
+	}
+	original := getOpenShiftResource
+	defer func() { getOpenShiftResource = original }()
+	getOpenShiftResource = func(_ context.Context, _ k8sapi.Interface, absPath ...string) ([]byte, error) {
+		t.Errorf("unexpected request %v", absPath)
+		return nil, nil
+	}
+	dir := t.TempDir()
+	collectOpenShiftResources(&stubHook{ctx: context.Background()}, "test-ns", fc, &helmPathCS{dir: dir}, helpers.NewRealFileSystem())
+	if _, err := os.Stat(filepath.Join(dir, "openshift")); !os.IsNotExist(err) {
+		t.Errorf("expected no openshift directory, got %v", err)
+	}
+}
+
+func TestWritePodRestartReport_SCCAdmissionFailure(t *testing.T) {
+	consoleprint.Clear()
+	defer consoleprint.Clear()
+	note := `create Pod dremio-executor-0 in StatefulSet dremio-executor failed error: pods "dremio-executor-0" is forbidden: unable to validate against any security context constraint: [provider "restricted-v2": Forbidden: not usable by user or serviceaccount]`
+	refusal := &eventsv1.Event{
+		ObjectMeta:      metav1.ObjectMeta{Name: "ev1", Namespace: "test-ns"},
+		EventTime:       metav1.NewMicroTime(time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)),
+		Regarding:       corev1.ObjectReference{Kind: "StatefulSet", Name: "dremio-executor"},
+		Reason:          "FailedCreate",
+		Type:            "Warning",
+		Note:            note,
+		DeprecatedCount: 12,
+	}
+	unrelated := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "ev2", Namespace: "test-ns"},
+		Regarding:  corev1.ObjectReference{Kind: "StatefulSet", Name: "zk"},
+		Reason:     "SuccessfulCreate",
+		Note:       "create Pod zk-0 in StatefulSet zk successful",
+	}
+	fc := fake.NewSimpleClientset(makePod("dremio-master-0", nil, 0), refusal, unrelated)
+	dir := t.TempDir()
+
+	if err := WritePodRestartReport(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := readRestartReport(t, dir)
+	if len(report.AdmissionFailures) != 1 {
+		t.Fatalf("expected one admission failure, got %+v", report.AdmissionFailures)
+	}
+	f := report.AdmissionFailures[0]
+	if f.Kind != "StatefulSet" || f.Name != "dremio-executor" || f.Count != 12 || f.Reason != "FailedCreate" {
+		t.Errorf("unexpected admission failure %+v", f)
+	}
+	warnings := consoleprint.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "SecurityContextConstraints") || !strings.Contains(warnings[0], "StatefulSet/dremio-executor") {
+		t.Errorf("expected an SCC warning, got %v", warnings)
+	}
+}
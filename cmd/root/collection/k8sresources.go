@@ -0,0 +1,218 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	k8sapi "k8s.io/client-go/kubernetes"
+)
+
+// secretMetadata is all that is kept of a Secret: what it is called, its type and
+// which keys it holds. Values are never read into it.
+type secretMetadata struct {
+	Kind       string            `json:"kind"`
+	APIVersion string            `json:"apiVersion"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Type       corev1.SecretType `json:"type"`
+	Keys       []string          `json:"keys"`
+}
+
+type secretMetadataItems struct {
+	Kind  string           `json:"kind"`
+	Items []secretMetadata `json:"items"`
+}
+
+func secretMetadataList(secrets []corev1.Secret) secretMetadataItems {
+	list := secretMetadataItems{Kind: "list", Items: []secretMetadata{}}
+	for _, s := range secrets {
+		keys := make([]string, 0, len(s.Data)+len(s.StringData))
+		for k := range s.Data {
+			keys = append(keys, k)
+		}
+		for k := range s.StringData {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		meta := metav1.ObjectMeta{
+			Name:              s.Name,
+			Namespace:         s.Namespace,
+			Labels:            s.Labels,
+			CreationTimestamp: s.CreationTimestamp,
+			OwnerReferences:   s.OwnerReferences,
+		}
+		for k, v := range s.Annotations {
+			// last-applied-configuration carries the full Secret, values included
+			if k == "kubectl.kubernetes.io/last-applied-configuration" {
+				continue
+			}
+			if meta.Annotations == nil {
+				meta.Annotations = make(map[string]string)
+			}
+			meta.Annotations[k] = v
+		}
+		list.Items = append(list.Items, secretMetadata{
+			Kind:       "Secret",
+			APIVersion: "v1",
+			Metadata:   meta,
+			Type:       s.Type,
+			Keys:       keys,
+		})
+	}
+	return list
+}
+
+// customResource is a namespaced, listable resource served by a non built-in API group.
+type customResource struct {
+	GroupVersion schema.GroupVersion
+	Resource     string
+}
+
+func (r customResource) fileName() string {
+	return r.Resource + "." + r.GroupVersion.Group + ".json"
+}
+
+// listCustomResource fetches one custom resource list as raw JSON. It is a variable so
+// tests can stand in for the API server, which the fake clientset does not serve.
+var listCustomResource = func(ctx context.Context, c k8sapi.Interface, gv schema.GroupVersion, resource, namespace string) ([]byte, error) {
+	rc := c.Discovery().RESTClient()
+	if rc == nil {
+		return nil, errors.New("no REST client available for custom resources")
+	}
+	return rc.Get().AbsPath("/apis", gv.Group, gv.Version, "namespaces", namespace, resource).DoRaw(ctx)
+}
+
+// isBuiltinAPIGroup reports whether a group ships with Kubernetes itself (core, apps,
+// batch, *.k8s.io ...) or with OpenShift, rather than being added by a CRD. The
+// OpenShift kinds worth having are collected by collectOpenShiftResources.
+func isBuiltinAPIGroup(group string) bool {
+	return group == "" || !strings.Contains(group, ".") || strings.HasSuffix(group, ".k8s.io") || isOpenShiftAPIGroup(group)
+}
+
+// discoverCustomResources uses the discovery API to find namespaced custom resources,
+// at the server's preferred version for each group. Groups that fail discovery are
+// logged and skipped.
+func discoverCustomResources(c k8sapi.Interface) ([]customResource, error) {
+	groups, lists, err := c.Discovery().ServerGroupsAndResources()
+	if err != nil {
+		if !discovery.IsGroupDiscoveryFailedError(err) {
+			return nil, err
+		}
+		simplelog.Warningf("some API groups could not be discovered, their custom resources will be missing: %v", err)
+	}
+	preferred := make(map[string]string, len(groups))
+	for _, g := range groups {
+		if g != nil {
+			preferred[g.Name] = g.PreferredVersion.GroupVersion
+		}
+	}
+	var resources []customResource
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || isBuiltinAPIGroup(gv.Group) || preferred[gv.Group] != list.GroupVersion {
+			continue
+		}
+		for _, r := range list.APIResources {
+			// subresources such as foo/status are not listable on their own
+			if !r.Namespaced || strings.Contains(r.Name, "/") || !slices.Contains(r.Verbs, "list") {
+				continue
+			}
+			resources = append(resources, customResource{GroupVersion: gv, Resource: r.Name})
+		}
+	}
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].fileName() < resources[j].fileName()
+	})
+	return resources, nil
+}
+
+// collectCustomResources writes every namespaced custom resource that has objects in
+// the namespace to kubernetes/custom-resources/<resource>.<group>.json, which picks up
+// Dremio operator and CNPG-style resources without naming them up front.
+func collectCustomResources(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem) {
+	resources, err := discoverCustomResources(c)
+	if err != nil {
+		simplelog.Errorf("unable to discover custom resources: %v", err)
+		return
+	}
+	if len(resources) == 0 {
+		simplelog.Info("no namespaced custom resources discovered")
+		return
+	}
+	path, err := cs.CreatePath("kubernetes", "custom-resources", "")
+	if err != nil {
+		simplelog.Errorf("trying to construct custom resources path %v with error %v", path, err)
+		return
+	}
+	for i, r := range resources {
+		consoleprint.UpdateResult(fmt.Sprintf("Collecting K8s custom resources (%d/%d): %s...", i+1, len(resources), r.Resource))
+		out, err := customResourceBytes(hook, namespace, c, r)
+		if err != nil {
+			simplelog.Warningf("unable to list %s.%s in namespace %v: %v", r.Resource, r.GroupVersion.Group, namespace, err)
+			continue
+		}
+		var list struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(out, &list); err != nil {
+			simplelog.Warningf("unable to parse %s.%s: %v", r.Resource, r.GroupVersion.Group, err)
+			continue
+		}
+		if len(list.Items) == 0 {
+			simplelog.Debugf("no %s.%s in namespace %v", r.Resource, r.GroupVersion.Group, namespace)
+			continue
+		}
+		text, err := masking.RemoveSecretsFromK8sJSON(out)
+		if err != nil {
+			simplelog.Errorf("unable to mask secrets for %s.%s in namespace %v: %v", r.Resource, r.GroupVersion.Group, namespace, err)
+			continue
+		}
+		filename := filepath.Join(path, r.fileName())
+		if err := ddfs.WriteFile(filename, []byte(text), DirPerms); err != nil {
+			simplelog.Errorf("trying to write file %v, error was %v", filename, err)
+		}
+	}
+}
+
+func customResourceBytes(hook shutdown.CancelHook, namespace string, c k8sapi.Interface, r customResource) ([]byte, error) {
+	timeoutDuration := 60 * time.Second
+	ctx, timeout := context.WithTimeoutCause(hook.GetContext(), timeoutDuration, fmt.Errorf("while getting custom resource %v in namespace %s timeout exceeded %v", r.Resource, namespace, timeoutDuration))
+	defer timeout()
+	out, err := listCustomResource(ctx, c, r.GroupVersion, r.Resource, namespace)
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		return nil, context.Cause(ctx)
+	}
+	return out, err
+}
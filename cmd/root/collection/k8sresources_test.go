@@ -0,0 +1,113 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sapi "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestClusterK8sExecuteMasksConfigMapsSecretsAndFindsCustomResources(t *testing.T) {
+	fc := fake.NewSimpleClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "dremio-config", Namespace: "test-ns"},
+			Data: map[string]string{
+				"dremio.conf": "services.coordinator.web.ssl.keyStorePassword: \"hunter2\"\npaths.local: \"/opt/dremio/data\"\n",
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "dremio-tls", Namespace: "test-ns"},
+			Type:       corev1.SecretTypeTLS,
+			Data:       map[string][]byte{"tls.key": []byte("PRIVATE-KEY-BYTES"), "tls.crt": []byte("CERT-BYTES")},
+		},
+	)
+	fc.Resources = []*metav1.APIResourceList{
+		{GroupVersion: "apps/v1", APIResources: []metav1.APIResource{{Name: "deployments", Namespaced: true, Verbs: []string{"list"}}}},
+		{GroupVersion: "postgresql.cnpg.io/v1", APIResources: []metav1.APIResource{
+			{Name: "clusters", Namespaced: true, Verbs: []string{"get", "list"}},
+			{Name: "clusters/status", Namespaced: true, Verbs: []string{"get"}},
+		}},
+		{GroupVersion: "operator.dremio.com/v1", APIResources: []metav1.APIResource{{Name: "dremioclusterconfigs", Namespaced: false, Verbs: []string{"list"}}}},
+	}
+	original := listCustomResource
+	defer func() { listCustomResource = original }()
+	var listed []string
+	listCustomResource = func(_ context.Context, _ k8sapi.Interface, gv schema.GroupVersion, resource, namespace string) ([]byte, error) {
+		listed = append(listed, gv.String()+"/"+resource+"@"+namespace)
+		return []byte(`{"kind":"ClusterList","items":[{"kind":"Cluster","apiVersion":"postgresql.cnpg.io/v1","metadata":{"name":"pg"}}]}`), nil
+	}
+
+	dir := t.TempDir()
+	if err := ClusterK8sExecute(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	configMaps, err := os.ReadFile(filepath.Join(dir, "configmaps.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(configMaps), "hunter2") || !strings.Contains(string(configMaps), "/opt/dremio/data") {
+		t.Errorf("expected dremio.conf masked in configmaps.json: %s", configMaps)
+	}
+	secrets, err := os.ReadFile(filepath.Join(dir, "secrets.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"dremio-tls", "kubernetes.io/tls", "tls.key", "tls.crt"} {
+		if !strings.Contains(string(secrets), want) {
+			t.Errorf("expected %q in secrets.json: %s", want, secrets)
+		}
+	}
+	if strings.Contains(string(secrets), "PRIVATE-KEY-BYTES") || strings.Contains(string(secrets), "UFJJVkFURS1LRVktQllURVM") {
+		t.Errorf("secret values leaked into secrets.json: %s", secrets)
+	}
+	for _, f := range []string{"serviceaccounts.json", "roles.json", "rolebindings.json", "networkpolicies.json"} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			t.Errorf("expected %s: %v", f, err)
+		}
+	}
+
+	if len(listed) != 1 || listed[0] != "postgresql.cnpg.io/v1/clusters@test-ns" {
+		t.Errorf("expected only the namespaced cnpg clusters to be listed, got %v", listed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clusters.postgresql.cnpg.io.json")); err != nil {
+		t.Errorf("expected custom resource file: %v", err)
+	}
+}
+
+func TestIsBuiltinAPIGroup(t *testing.T) {
+	for group, want := range map[string]bool{
+		"":                          true,
+		"apps":                      true,
+		"rbac.authorization.k8s.io": true,
+		"postgresql.cnpg.io":        false,
+		"cluster.x-k8s.io":          false,
+		"route.openshift.io":        true,
+	} {
+		if got := isBuiltinAPIGroup(group); got != want {
+			t.Errorf("%q: expected %v, got %v", group, want, got)
+		}
+	}
+}
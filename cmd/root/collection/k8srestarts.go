@@ -0,0 +1,418 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sapi "k8s.io/client-go/kubernetes"
+)
+
+// TerminationInfo is the last (or current) terminated state of a container.
+type TerminationInfo struct {
+	Reason     string    `json:"reason"`
+	Message    string    `json:"message,omitempty"`
+	ExitCode   int32     `json:"exitCode"`
+	Signal     int32     `json:"signal,omitempty"`
+	StartedAt  time.Time `json:"startedAt,omitempty"`
+	FinishedAt time.Time `json:"finishedAt,omitempty"`
+}
+
+// ContainerRestart describes one container of a restarted or evicted pod.
+type ContainerRestart struct {
+	Name                string           `json:"name"`
+	Init                bool             `json:"init,omitempty"`
+	RestartCount        int32            `json:"restartCount"`
+	LastTermination     *TerminationInfo `json:"lastTermination,omitempty"`
+	PreviousLogCaptured bool             `json:"previousLogCaptured"`
+	PreviousLogFile     string           `json:"previousLogFile,omitempty"`
+}
+
+// NodeConditionInfo is one condition of the node a pod ran on.
+type NodeConditionInfo struct {
+	Type               corev1.NodeConditionType `json:"type"`
+	Status             corev1.ConditionStatus   `json:"status"`
+	Reason             string                   `json:"reason,omitempty"`
+	Message            string                   `json:"message,omitempty"`
+	LastTransitionTime time.Time                `json:"lastTransitionTime,omitempty"`
+}
+
+// RestartEvent is an event whose regarding object is the pod.
+type RestartEvent struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`
+	Reason string    `json:"reason"`
+	Note   string    `json:"note,omitempty"`
+	Count  int32     `json:"count,omitempty"`
+	// SCC is set when the event is an OpenShift SecurityContextConstraints refusal.
+	SCC bool `json:"scc,omitempty"`
+}
+
+// AdmissionFailure is an event recording that a controller could not create a pod
+// because no OpenShift SecurityContextConstraints admitted it. Such pods never exist,
+// so they cannot show up as restarts.
+type AdmissionFailure struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Name   string    `json:"name"`
+	Reason string    `json:"reason"`
+	Note   string    `json:"note"`
+	Count  int32     `json:"count,omitempty"`
+}
+
+// PodRestart is the restart history of a single pod.
+type PodRestart struct {
+	Name           string              `json:"name"`
+	Node           string              `json:"node,omitempty"`
+	Phase          corev1.PodPhase     `json:"phase"`
+	Reason         string              `json:"reason,omitempty"`
+	Message        string              `json:"message,omitempty"`
+	TotalRestarts  int32               `json:"totalRestarts"`
+	Containers     []ContainerRestart  `json:"containers"`
+	NodeConditions []NodeConditionInfo `json:"nodeConditions,omitempty"`
+	Events         []RestartEvent      `json:"events,omitempty"`
+}
+
+// PodRestartReport is written to kubernetes/pod-restarts.json.
+type PodRestartReport struct {
+	GeneratedAt   time.Time    `json:"generatedAt"`
+	Namespace     string       `json:"namespace"`
+	LabelSelector string       `json:"labelSelector,omitempty"`
+	Pods          []PodRestart `json:"pods"`
+	// AdmissionFailures are the workloads whose pods were refused by SCC admission.
+	AdmissionFailures []AdmissionFailure `json:"admissionFailures,omitempty"`
+	Errors            []string           `json:"errors,omitempty"`
+}
+
+// reportedNodeConditions are the node conditions that explain restarts and evictions.
+var reportedNodeConditions = []corev1.NodeConditionType{
+	corev1.NodeReady,
+	corev1.NodeMemoryPressure,
+	corev1.NodeDiskPressure,
+	corev1.NodePIDPressure,
+}
+
+func terminationInfo(t *corev1.ContainerStateTerminated) *TerminationInfo {
+	if t == nil {
+		return nil
+	}
+	return &TerminationInfo{
+		Reason:     t.Reason,
+		Message:    t.Message,
+		ExitCode:   t.ExitCode,
+		Signal:     t.Signal,
+		StartedAt:  t.StartedAt.UTC(),
+		FinishedAt: t.FinishedAt.UTC(),
+	}
+}
+
+func isEvicted(pod corev1.Pod) bool {
+	return pod.Status.Reason == "Evicted"
+}
+
+// containerRestarts lists the containers that restarted or are sitting terminated,
+// preferring the last termination state and falling back to the current one for
+// containers that will not be restarted (restartPolicy Never or an evicted pod).
+func containerRestarts(pod corev1.Pod) ([]ContainerRestart, int32) {
+	var out []ContainerRestart
+	var total int32
+	add := func(statuses []corev1.ContainerStatus, init bool) {
+		for _, s := range statuses {
+			term := terminationInfo(s.LastTerminationState.Terminated)
+			if term == nil {
+				term = terminationInfo(s.State.Terminated)
+				// a completed init container is not interesting
+				if term != nil && term.ExitCode == 0 && term.Reason != "OOMKilled" {
+					term = nil
+				}
+			}
+			if s.RestartCount == 0 && term == nil {
+				continue
+			}
+			total += s.RestartCount
+			out = append(out, ContainerRestart{
+				Name:            s.Name,
+				Init:            init,
+				RestartCount:    s.RestartCount,
+				LastTermination: term,
+			})
+		}
+	}
+	add(pod.Status.InitContainerStatuses, true)
+	add(pod.Status.ContainerStatuses, false)
+	return out, total
+}
+
+func nodeConditions(node *corev1.Node) []NodeConditionInfo {
+	var out []NodeConditionInfo
+	for _, want := range reportedNodeConditions {
+		for _, cond := range node.Status.Conditions {
+			if cond.Type != want {
+				continue
+			}
+			out = append(out, NodeConditionInfo{
+				Type:               cond.Type,
+				Status:             cond.Status,
+				Reason:             cond.Reason,
+				Message:            cond.Message,
+				LastTransitionTime: cond.LastTransitionTime.UTC(),
+			})
+		}
+	}
+	return out
+}
+
+func eventTime(e eventsv1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.UTC()
+	case !e.EventTime.IsZero():
+		return e.EventTime.UTC()
+	case !e.DeprecatedLastTimestamp.IsZero():
+		return e.DeprecatedLastTimestamp.UTC()
+	default:
+		return e.CreationTimestamp.UTC()
+	}
+}
+
+func eventCount(e eventsv1.Event) int32 {
+	if e.Series != nil {
+		return e.Series.Count
+	}
+	return e.DeprecatedCount
+}
+
+// isSCCRefusal reports whether an event note is OpenShift's SCC admission refusal,
+// e.g. `pods "x" is forbidden: unable to validate against any security context constraint`.
+func isSCCRefusal(note string) bool {
+	return strings.Contains(strings.ToLower(note), "security context constraint")
+}
+
+// podEvents groups the namespace events by the pod they are about, oldest first.
+func podEvents(events []eventsv1.Event) map[string][]RestartEvent {
+	out := make(map[string][]RestartEvent)
+	for _, e := range events {
+		if e.Regarding.Kind != "Pod" || e.Regarding.Name == "" {
+			continue
+		}
+		out[e.Regarding.Name] = append(out[e.Regarding.Name], RestartEvent{
+			Time:   eventTime(e),
+			Type:   e.Type,
+			Reason: e.Reason,
+			Note:   e.Note,
+			Count:  eventCount(e),
+			SCC:    isSCCRefusal(e.Note),
+		})
+	}
+	for _, list := range out {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	}
+	return out
+}
+
+// admissionFailures lists the events where a controller's pod was refused by SCC
+// admission, newest first.
+func admissionFailures(events []eventsv1.Event) []AdmissionFailure {
+	var out []AdmissionFailure
+	for _, e := range events {
+		if e.Regarding.Kind == "Pod" || !isSCCRefusal(e.Note) {
+			continue
+		}
+		out = append(out, AdmissionFailure{
+			Time:   eventTime(e),
+			Kind:   e.Regarding.Kind,
+			Name:   e.Regarding.Name,
+			Reason: e.Reason,
+			Note:   e.Note,
+			Count:  eventCount(e),
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	return out
+}
+
+// admissionWarning summarizes SCC refusals in one line, or returns "" when there are none.
+func admissionWarning(report PodRestartReport) string {
+	workloads := make(map[string]bool)
+	for _, f := range report.AdmissionFailures {
+		workloads[f.Kind+"/"+f.Name] = true
+	}
+	if len(workloads) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(workloads))
+	for w := range workloads {
+		names = append(names, w)
+	}
+	sort.Strings(names)
+	return fmt.Sprintf("%d workload(s) in namespace %s could not create pods, refused by SecurityContextConstraints (%s) - see kubernetes/pod-restarts.json", len(names), report.Namespace, strings.Join(names, ", "))
+}
+
+// restartWarning summarizes the report in one line for the TUI and summary.json,
+// or returns "" when no pod restarted or was evicted.
+func restartWarning(report PodRestartReport) string {
+	if len(report.Pods) == 0 {
+		return ""
+	}
+	reasons := make(map[string]int)
+	for _, p := range report.Pods {
+		if p.Reason != "" {
+			reasons[p.Reason]++
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, c := range p.Containers {
+			if c.LastTermination != nil && c.LastTermination.Reason != "" && !seen[c.LastTermination.Reason] {
+				seen[c.LastTermination.Reason] = true
+				reasons[c.LastTermination.Reason]++
+			}
+		}
+	}
+	keys := make([]string, 0, len(reasons))
+	for k := range reasons {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%d %s", reasons[k], k))
+	}
+	msg := fmt.Sprintf("%d pod(s) in namespace %s restarted or were evicted", len(report.Pods), report.Namespace)
+	if len(parts) > 0 {
+		msg += " (" + strings.Join(parts, ", ") + ")"
+	}
+	return msg + " - see kubernetes/pod-restarts.json"
+}
+
+// WritePodRestartReport writes kubernetes/pod-restarts.json describing why pods restarted:
+// restart counts, last termination reason, exit code and signal, the node and its pressure
+// conditions, the pod's events and whether GetPreviousLogsForRestartedPods captured a
+// previous log, so it should run after that. Events where OpenShift SCC admission refused
+// a controller's pods are listed too. When any pod restarted or was evicted, or pods were
+// refused, a warning is added to the TUI and summary.json. Missing access to nodes or
+// events is recorded in the report rather than failing it.
+func WritePodRestartReport(hook shutdown.CancelHook, namespace string, clientSet k8sapi.Interface, cs CopyStrategy, ddfs helpers.Filesystem, labelSelector string) error {
+	ctx, cancel := context.WithTimeoutCause(hook.GetContext(), 60*time.Second, fmt.Errorf("timeout while building pod restart report for namespace %s", namespace))
+	defer cancel()
+	listOpts := metav1.ListOptions{}
+	if labelSelector != "" {
+		listOpts.LabelSelector = labelSelector
+	}
+	pods, err := clientSet.CoreV1().Pods(namespace).List(ctx, listOpts)
+	if err != nil {
+		return err
+	}
+	logPath, err := cs.CreatePath("kubernetes", "container-logs", "")
+	if err != nil {
+		return fmt.Errorf("trying to construct cluster container log path %v: %w", logPath, err)
+	}
+
+	report := PodRestartReport{
+		GeneratedAt:   time.Now().UTC(),
+		Namespace:     namespace,
+		LabelSelector: labelSelector,
+		Pods:          []PodRestart{},
+	}
+	// events explain restarts and also carry SCC refusals for pods that were never created
+	var events map[string][]RestartEvent
+	eventList, err := clientSet.EventsV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		simplelog.Warningf("unable to list events for pod restart report: %v", err)
+		report.Errors = append(report.Errors, fmt.Sprintf("events: %v", err))
+	} else {
+		events = podEvents(eventList.Items)
+		report.AdmissionFailures = admissionFailures(eventList.Items)
+	}
+	nodes := make(map[string][]NodeConditionInfo)
+	nodesRefused := false
+	for _, pod := range pods.Items {
+		containers, total := containerRestarts(pod)
+		if len(containers) == 0 && !isEvicted(pod) {
+			continue
+		}
+		consoleprint.UpdateResult(fmt.Sprintf("Building pod restart report: %s...", pod.Name))
+		for i, c := range containers {
+			name := pod.Name + "-" + c.Name + "-previous.txt.gz"
+			if _, err := ddfs.Stat(filepath.Join(logPath, name)); err == nil {
+				containers[i].PreviousLogCaptured = true
+				containers[i].PreviousLogFile = filepath.Join("kubernetes", "container-logs", name)
+			}
+		}
+		entry := PodRestart{
+			Name:          pod.Name,
+			Node:          pod.Spec.NodeName,
+			Phase:         pod.Status.Phase,
+			Reason:        pod.Status.Reason,
+			Message:       pod.Status.Message,
+			TotalRestarts: total,
+			Containers:    containers,
+		}
+		if entry.Containers == nil {
+			entry.Containers = []ContainerRestart{}
+		}
+
+		if entry.Node != "" && !nodesRefused {
+			if _, ok := nodes[entry.Node]; !ok {
+				node, err := clientSet.CoreV1().Nodes().Get(ctx, entry.Node, metav1.GetOptions{})
+				if err != nil {
+					// nodes are cluster scoped, a namespaced Role cannot read them; don't ask again per pod
+					simplelog.Warningf("unable to read node %v for pod restart report: %v", entry.Node, err)
+					report.Errors = append(report.Errors, fmt.Sprintf("node %s: %v", entry.Node, err))
+					nodesRefused = true
+				} else {
+					nodes[entry.Node] = nodeConditions(node)
+				}
+			}
+			entry.NodeConditions = nodes[entry.Node]
+		}
+
+		entry.Events = events[pod.Name]
+		report.Pods = append(report.Pods, entry)
+	}
+	sort.Slice(report.Pods, func(i, j int) bool { return report.Pods[i].Name < report.Pods[j].Name })
+
+	path, err := cs.CreatePath("kubernetes", "", "")
+	if err != nil {
+		return fmt.Errorf("trying to construct kubernetes path %v: %w", path, err)
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal pod restart report: %w", err)
+	}
+	filename := filepath.Join(path, "pod-restarts.json")
+	if err := ddfs.WriteFile(filename, data, DirPerms); err != nil {
+		return fmt.Errorf("trying to write file %v: %w", filename, err)
+	}
+	for _, msg := range []string{restartWarning(report), admissionWarning(report)} {
+		if msg != "" {
+			simplelog.Warning(msg)
+			consoleprint.AddWarning(msg)
+		}
+	}
+	return nil
+}
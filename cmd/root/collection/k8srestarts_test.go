@@ -0,0 +1,185 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	corev1 "k8s.io/api/core/v1"
+	eventsv1 "k8s.io/api/events/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func oomKilledPod() *corev1.Pod {
+	finished := metav1.NewTime(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	pod := makePod("dremio-executor-0", map[string]string{"role": "dremio-cluster-pod"}, 3)
+	pod.Spec.NodeName = "node-a"
+	pod.Status.Phase = corev1.PodRunning
+	pod.Status.ContainerStatuses[0].LastTerminationState.Terminated = &corev1.ContainerStateTerminated{
+		Reason:     "OOMKilled",
+		ExitCode:   137,
+		Signal:     9,
+		StartedAt:  metav1.NewTime(finished.Add(-time.Hour)),
+		FinishedAt: finished,
+	}
+	return pod
+}
+
+func pressuredNode() *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-a"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeMemoryPressure, Status: corev1.ConditionTrue, Reason: "KubeletHasInsufficientMemory"},
+			{Type: corev1.NodeDiskPressure, Status: corev1.ConditionFalse},
+			{Type: corev1.NodeNetworkUnavailable, Status: corev1.ConditionFalse},
+		}},
+	}
+}
+
+func readRestartReport(t *testing.T, dir string) PodRestartReport {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(dir, "pod-restarts.json"))
+	if err != nil {
+		t.Fatalf("pod-restarts.json not written: %v", err)
+	}
+	var report PodRestartReport
+	if err := json.Unmarshal(b, &report); err != nil {
+		t.Fatalf("unable to parse pod-restarts.json: %v", err)
+	}
+	return report
+}
+
+func TestWritePodRestartReport_OOMKilledWithNodeEventsAndPreviousLog(t *testing.T) {
+	consoleprint.Clear()
+	defer consoleprint.Clear()
+	dir := t.TempDir()
+	event := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "ev1", Namespace: "test-ns"},
+		EventTime:  metav1.NewMicroTime(time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC)),
+		Regarding:  corev1.ObjectReference{Kind: "Pod", Name: "dremio-executor-0"},
+		Reason:     "BackOff",
+		Type:       "Warning",
+		Note:       "Back-off restarting failed container",
+	}
+	other := &eventsv1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: "ev2", Namespace: "test-ns"},
+		Regarding:  corev1.ObjectReference{Kind: "Pod", Name: "zk-0"},
+		Reason:     "Pulled",
+	}
+	fc := fake.NewSimpleClientset(oomKilledPod(), makePod("dremio-master-0", nil, 0), pressuredNode(), event, other)
+	if err := os.WriteFile(filepath.Join(dir, "dremio-executor-0-main-previous.txt.gz"), []byte("log"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WritePodRestartReport(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := readRestartReport(t, dir)
+	if len(report.Pods) != 1 {
+		t.Fatalf("expected only the restarted pod, got %+v", report.Pods)
+	}
+	p := report.Pods[0]
+	if p.Name != "dremio-executor-0" || p.Node != "node-a" || p.TotalRestarts != 3 {
+		t.Errorf("unexpected pod entry %+v", p)
+	}
+	c := p.Containers[0]
+	if c.LastTermination == nil || c.LastTermination.Reason != "OOMKilled" || c.LastTermination.ExitCode != 137 || c.LastTermination.Signal != 9 {
+		t.Errorf("unexpected termination %+v", c.LastTermination)
+	}
+	if !c.PreviousLogCaptured || c.PreviousLogFile != filepath.Join("kubernetes", "container-logs", "dremio-executor-0-main-previous.txt.gz") {
+		t.Errorf("expected previous log to be reported as captured, got %+v", c)
+	}
+	if len(p.NodeConditions) != 2 || p.NodeConditions[0].Type != corev1.NodeMemoryPressure || p.NodeConditions[0].Status != corev1.ConditionTrue {
+		t.Errorf("expected MemoryPressure and DiskPressure conditions only, got %+v", p.NodeConditions)
+	}
+	if len(p.Events) != 1 || p.Events[0].Reason != "BackOff" {
+		t.Errorf("expected only the pod's BackOff event, got %+v", p.Events)
+	}
+
+	warnings := consoleprint.Warnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "1 OOMKilled") || !strings.Contains(warnings[0], "pod-restarts.json") {
+		t.Errorf("expected an OOMKilled warning, got %v", warnings)
+	}
+}
+
+func TestWritePodRestartReport_EvictedPodAndNodesForbidden(t *testing.T) {
+	consoleprint.Clear()
+	defer consoleprint.Clear()
+	dir := t.TempDir()
+	evicted := makePod("dremio-executor-1", nil, 0)
+	evicted.Spec.NodeName = "node-b"
+	evicted.Status.Phase = corev1.PodFailed
+	evicted.Status.Reason = "Evicted"
+	evicted.Status.Message = "The node was low on resource: memory."
+	fc := fake.NewSimpleClientset(evicted)
+	fc.PrependReactor("get", "nodes", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("nodes is forbidden")
+	})
+
+	if err := WritePodRestartReport(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	report := readRestartReport(t, dir)
+	if len(report.Pods) != 1 || report.Pods[0].Reason != "Evicted" {
+		t.Fatalf("expected the evicted pod, got %+v", report.Pods)
+	}
+	if len(report.Pods[0].Containers) != 0 {
+		t.Errorf("expected no container restarts, got %+v", report.Pods[0].Containers)
+	}
+	if len(report.Errors) != 1 || !strings.Contains(report.Errors[0], "forbidden") {
+		t.Errorf("expected the node error to be recorded, got %v", report.Errors)
+	}
+	if warnings := consoleprint.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "1 Evicted") {
+		t.Errorf("expected an Evicted warning, got %v", warnings)
+	}
+}
+
+func TestWritePodRestartReport_NoRestartsNoWarning(t *testing.T) {
+	consoleprint.Clear()
+	defer consoleprint.Clear()
+	dir := t.TempDir()
+	fc := fake.NewSimpleClientset(makePod("dremio-master-0", nil, 0))
+
+	if err := WritePodRestartReport(&stubHook{ctx: context.Background()}, "test-ns", fc, &stubCS{dir: dir}, helpers.NewRealFileSystem(), "role=dremio-cluster-pod"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := readRestartReport(t, dir)
+	if len(report.Pods) != 0 {
+		t.Errorf("expected no pods, got %+v", report.Pods)
+	}
+	if report.LabelSelector != "role=dremio-cluster-pod" {
+		t.Errorf("expected label selector to be recorded, got %q", report.LabelSelector)
+	}
+	if got := listActionRestrictions(t, fc); got != "role=dremio-cluster-pod" {
+		t.Errorf("expected pods to be listed with the label selector, got %q", got)
+	}
+	if warnings := consoleprint.Warnings(); len(warnings) != 0 {
+		t.Errorf("expected no warnings, got %v", warnings)
+	}
+}
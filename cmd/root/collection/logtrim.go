@@ -0,0 +1,258 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// TrimWindowSummary records --trim-to-window in summary.json: the window and,
+// per log, how many bytes were collected and how many were kept.
+type TrimWindowSummary struct {
+	Since string        `json:"since,omitempty"`
+	Until string        `json:"until,omitempty"`
+	Files []TrimmedFile `json:"files"`
+}
+
+// TrimmedFile is one log cut to the window. Bytes are uncompressed.
+type TrimmedFile struct {
+	Host          string `json:"host"`
+	Path          string `json:"path"`
+	OriginalBytes int64  `json:"originalBytes"`
+	KeptBytes     int64  `json:"keptBytes"`
+}
+
+// trimRecorder collects the TrimmedFile entries of all node goroutines.
+type trimRecorder struct {
+	mu    sync.Mutex
+	files []TrimmedFile
+}
+
+func (tr *trimRecorder) add(f TrimmedFile) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.files = append(tr.files, f)
+}
+
+// summary returns the summary.json entry for the window.
+func (tr *trimRecorder) summary(win logparser.Window) *TrimWindowSummary {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	s := &TrimWindowSummary{Files: append([]TrimmedFile{}, tr.files...)}
+	if !win.Since.IsZero() {
+		s.Since = win.Since.Format(time.RFC3339)
+	}
+	if !win.Until.IsZero() {
+		s.Until = win.Until.Format(time.RFC3339)
+	}
+	sort.Slice(s.Files, func(i, j int) bool {
+		if s.Files[i].Host != s.Files[j].Host {
+			return s.Files[i].Host < s.Files[j].Host
+		}
+		return s.Files[i].Path < s.Files[j].Path
+	})
+	return s
+}
+
+// logWindow returns the --since / --until window of the collection.
+func logWindow(args Args) logparser.Window {
+	return logparser.Window{Since: args.TrimSince, Until: args.TrimUntil}
+}
+
+// nodeWindow is logWindow with timestamps without a zone read in the node's:
+// --log-timezone when given, else the UTC offset found by discovery, else UTC.
+func nodeWindow(args Args, info *RemoteNodeInfo) logparser.Window {
+	win := logWindow(args)
+	win.Location = args.LogTimezone
+	if win.Location == nil && info != nil && info.UTCOffset != "" {
+		if t, err := time.Parse("-0700", info.UTCOffset); err == nil {
+			_, offset := t.Zone()
+			win.Location = time.FixedZone(info.UTCOffset, offset)
+		}
+	}
+	return win
+}
+
+// overlapsWindow reports whether a log file can hold lines inside win: a file
+// last written before the window starts cannot, nor can a rolled file dated a
+// day outside it. The date in the name is in win.Location, the node's zone.
+func overlapsWindow(baseName string, modTime int64, win logparser.Window) bool {
+	if !win.Since.IsZero() && modTime != 0 && time.Unix(modTime, 0).Before(win.Since) {
+		return false
+	}
+	fileDate := extractFilenameDate(baseName)
+	if fileDate == "" {
+		return true
+	}
+	loc := win.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	day, err := time.ParseInLocation("2006-01-02", fileDate, loc)
+	if err != nil {
+		return true
+	}
+	if !win.Since.IsZero() && day.AddDate(0, 0, 1).Before(win.Since) {
+		return false
+	}
+	if !win.Until.IsZero() && day.After(win.Until) {
+		return false
+	}
+	return true
+}
+
+// isTrimmedLog reports whether a selected file is cut to the window while it is
+// streamed. Only server-side logs are; queries.json and GC logs stay whole.
+func isTrimmedLog(nf nodeFile, args Args) bool {
+	return args.TrimToWindow && nf.remote.FileType == "log"
+}
+
+// streamTrimmedLogs streams the logs of a node keeping only the lines inside the
+// window, so the lines outside it are never written locally. Files that lose bytes
+// are recorded for summary.json. A tail of --since-last is read from its offset.
+func streamTrimmedLogs(c Collector, host string, info *RemoteNodeInfo, files []nodeFile, args Args) ([]helpers.CollectedFile, []string) {
+	win := nodeWindow(args, info)
+	var collected []helpers.CollectedFile
+	var skipped []string
+	for _, nf := range files {
+		rf := nf.remote
+		simplelog.Infof("stream start (trimmed to the window): %v:%v → %v", host, rf.Path, nf.destPath)
+		cf, stats, err := streamTrimmedLog(nf.collector(c), host, rf.Path, nf.destPath, nf.size(), win, info.GzipAvailable)
+		if err != nil {
+			simplelog.Warningf("stream skip: %v:%v — %v", host, rf.Path, err)
+			skipped = append(skipped, rf.Path)
+			continue
+		}
+		read := stats.BytesKept + stats.BytesDropped
+		simplelog.Infof("stream complete: %v:%v kept %d of %d bytes", host, rf.Path, stats.BytesKept, read)
+		collected = append(collected, cf)
+		if stats.BytesDropped > 0 && args.trimmed != nil {
+			args.trimmed.add(TrimmedFile{Host: host, Path: rf.Path, OriginalBytes: read, KeptBytes: stats.BytesKept})
+		}
+		// --since-last state covers the remote file, which a trimmed copy no longer
+		// matches: a tail records what was read, a whole file is left to be collected
+		// in full next time
+		if inc := args.incremental; inc != nil && (nf.offset > 0 || stats.BytesDropped == 0) {
+			inc.record(host, nf, read)
+		}
+	}
+	return collected, skipped
+}
+
+// streamTrimmedLog streams one log through logparser.TrimLines into destPath. A .gz
+// log is decompressed and compressed again on the way. The bytes kept cannot be
+// mapped back to a remote offset, so a retry after a transient error starts over.
+func streamTrimmedLog(c Collector, host, remotePath, destPath string, expectedSize int64, win logparser.Window, useGzip bool) (helpers.CollectedFile, logparser.TrimStats, error) {
+	var lastErr error
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if attempt > 1 {
+			simplelog.Infof("stream retry %d/%d for %v:%v", attempt, maxRetries, host, remotePath)
+		}
+		cf, stats, err := streamTrimmedLogOnce(c, host, remotePath, destPath, expectedSize, win, useGzip)
+		if err == nil {
+			return cf, stats, nil
+		}
+		lastErr = err
+		if !isTransientError(err) {
+			break
+		}
+		simplelog.Warningf("transient error streaming %v:%v (attempt %d/%d): %v", host, remotePath, attempt, maxRetries, err)
+	}
+	_ = os.Remove(destPath)
+	return helpers.CollectedFile{}, logparser.TrimStats{}, lastErr
+}
+
+func streamTrimmedLogOnce(c Collector, host, remotePath, destPath string, expectedSize int64, win logparser.Window, useGzip bool) (helpers.CollectedFile, logparser.TrimStats, error) {
+	var stats logparser.TrimStats
+	if err := os.MkdirAll(filepath.Dir(destPath), DirPerms); err != nil {
+		return helpers.CollectedFile{}, stats, fmt.Errorf("failed to create destination dir for %v: %w", destPath, err)
+	}
+	out, err := os.OpenFile(filepath.Clean(destPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return helpers.CollectedFile{}, stats, fmt.Errorf("failed to create file %v: %w", destPath, err)
+	}
+
+	pr, pipew := io.Pipe()
+	go func() {
+		pipew.CloseWithError(c.StreamFromHostAt(host, remotePath, 0, pipew, useGzip))
+	}()
+	// stops the stream when trimming fails before reading it all
+	defer pr.Close() //nolint:errcheck
+
+	fail := func(err error) (helpers.CollectedFile, logparser.TrimStats, error) {
+		_ = out.Close()
+		return helpers.CollectedFile{}, stats, err
+	}
+	var r io.Reader = bufio.NewReaderSize(pr, streamReadBufSize)
+	if useGzip {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fail(fmt.Errorf("gzip reader init failed for %v:%v: %w", host, remotePath, err))
+		}
+		defer gz.Close() //nolint:errcheck
+		r = gz
+	}
+	compressed := strings.HasSuffix(remotePath, ".gz")
+	if compressed {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fail(fmt.Errorf("gzip reader init failed for %v:%v: %w", host, remotePath, err))
+		}
+		defer gz.Close() //nolint:errcheck
+		r = gz
+	}
+
+	// size and hash what reaches the disk, which for a .gz log is compressed again
+	h := sha256.New()
+	disk := &countingWriter{w: io.MultiWriter(out, h)}
+	bf := bufio.NewWriterSize(disk, streamWriteBufSize)
+	var w io.Writer = &progressWriter{w: bf, expectedSize: expectedSize, host: host, filename: filepath.Base(remotePath)}
+	var gzw *gzip.Writer
+	if compressed {
+		gzw = gzip.NewWriter(w)
+		w = gzw
+	}
+	stats, err = logparser.TrimLines(r, w, win) // #nosec G110 -- source is trusted dremio cluster output
+	if err != nil {
+		return fail(err)
+	}
+	if gzw != nil {
+		if err := gzw.Close(); err != nil {
+			return fail(err)
+		}
+	}
+	if err := bf.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := out.Close(); err != nil {
+		return helpers.CollectedFile{}, stats, err
+	}
+	return helpers.CollectedFile{Path: destPath, Size: disk.n, SHA256: hex.EncodeToString(h.Sum(nil))}, stats, nil
+}
@@ -0,0 +1,191 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
+)
+
+func TestOverlapsWindow(t *testing.T) {
+	win := logparser.Window{Since: time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC), Until: time.Date(2026, 3, 20, 14, 30, 0, 0, time.UTC)}
+	tests := []struct {
+		name    string
+		modTime time.Time
+		want    bool
+	}{
+		{"server.log", time.Date(2026, 3, 20, 15, 0, 0, 0, time.UTC), true},
+		{"server.log", time.Date(2026, 3, 20, 13, 0, 0, 0, time.UTC), false}, // last written before the window
+		{"server.2026-03-20.0.log.gz", time.Date(2026, 3, 21, 0, 0, 0, 0, time.UTC), true},
+		{"server.2026-03-19.0.log.gz", time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC), false},
+		{"server.2026-03-21.0.log.gz", time.Date(2026, 3, 22, 0, 0, 0, 0, time.UTC), false},
+		{"server.log", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		var mod int64
+		if !tt.modTime.IsZero() {
+			mod = tt.modTime.Unix()
+		}
+		if got := overlapsWindow(tt.name, mod, win); got != tt.want {
+			t.Errorf("overlapsWindow(%v, %v) = %v, want %v", tt.name, tt.modTime, got, tt.want)
+		}
+	}
+	// the 19th on a node eight hours behind UTC lasts until 08:00 UTC on the 20th
+	early := logparser.Window{Since: time.Date(2026, 3, 20, 5, 0, 0, 0, time.UTC)}
+	if overlapsWindow("server.2026-03-19.0.log.gz", 0, early) {
+		t.Error("expected the 19th to end before the window in UTC")
+	}
+	early.Location = nodeWindow(Args{}, &RemoteNodeInfo{UTCOffset: "-0800"}).Location
+	if !overlapsWindow("server.2026-03-19.0.log.gz", 0, early) {
+		t.Error("expected the 19th in the node's zone to overlap the window")
+	}
+}
+
+func TestStreamNodeFiles_TrimToWindow(t *testing.T) {
+	tmpDir := t.TempDir()
+	logContent := strings.Join([]string{
+		"2026-03-20 13:00:00,000 [main] INFO before",
+		"2026-03-20 14:05:00,000 [main] ERROR the incident",
+		"\tat com.dremio.Foo.bar(Foo.java:1)",
+		"2026-03-20 15:00:00,000 [main] INFO after",
+		"",
+	}, "\n")
+	var gzContent bytes.Buffer
+	gw := gzip.NewWriter(&gzContent)
+	if _, err := io.WriteString(gw, logContent); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	remote := map[string]string{
+		"/var/log/dremio/server.log":                 logContent,
+		"/var/log/dremio/server.2026-03-20.0.log.gz": gzContent.String(),
+		"/var/log/dremio/server.2026-03-18.0.log.gz": gzContent.String(),
+		"/opt/dremio/conf/dremio.conf":               "2026-03-20 13:00:00,000 not a log line\n",
+	}
+	mc := &mockStreamCollector{
+		streamFunc: func(_, remotePath string, writer io.Writer) error {
+			_, err := io.WriteString(writer, remote[remotePath])
+			return err
+		},
+	}
+	modTime := time.Date(2026, 3, 20, 23, 59, 0, 0, time.UTC).Unix()
+	info := &RemoteNodeInfo{Files: []RemoteFileInfo{
+		{Path: "/var/log/dremio/server.log", Size: int64(len(logContent)), FileType: "log", ModTime: modTime},
+		{Path: "/var/log/dremio/server.2026-03-20.0.log.gz", Size: int64(gzContent.Len()), FileType: "log", ModTime: modTime},
+		{Path: "/var/log/dremio/server.2026-03-18.0.log.gz", Size: int64(gzContent.Len()), FileType: "log", ModTime: modTime},
+		{Path: "/opt/dremio/conf/dremio.conf", Size: 40, FileType: "config", ModTime: modTime},
+	}}
+	args := Args{
+		CollectServerLogs: true,
+		TrimToWindow:      true,
+		TrimSince:         time.Date(2026, 3, 20, 14, 0, 0, 0, time.UTC),
+		TrimUntil:         time.Date(2026, 3, 20, 14, 30, 0, 0, time.UTC),
+		trimmed:           &trimRecorder{},
+	}
+
+	collected, skipped := streamNodeFiles(mc, "host1", info, &mockCopyStrategy{tmpDir: tmpDir}, "coordinator", "diagnosis", false, args)
+	if len(collected) != 3 || len(skipped) != 0 {
+		t.Fatalf("expected the 2026-03-18 log left out and 3 files collected, got %v, skipped %v", collected, skipped)
+	}
+	want := "2026-03-20 14:05:00,000 [main] ERROR the incident\n\tat com.dremio.Foo.bar(Foo.java:1)\n"
+	b, err := os.ReadFile(filepath.Join(tmpDir, "logs", "host1", "server.log"))
+	if err != nil || string(b) != want {
+		t.Errorf("expected server.log trimmed to the window, got %q, %v", b, err)
+	}
+	f, err := os.Open(filepath.Join(tmpDir, "logs", "host1", "server.2026-03-20.0.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, err := io.ReadAll(gr); err != nil || string(b) != want {
+		t.Errorf("expected the rolled log trimmed and still gzipped, got %q, %v", b, err)
+	}
+	if b, err := os.ReadFile(filepath.Join(tmpDir, "configuration", "host1", "dremio.conf")); err != nil || !strings.Contains(string(b), "not a log line") {
+		t.Errorf("expected config files left alone, got %q, %v", b, err)
+	}
+	for _, cf := range collected {
+		if strings.HasSuffix(cf.Path, "server.log") && cf.Size != int64(len(want)) {
+			t.Errorf("expected the trimmed size to be reported, got %d", cf.Size)
+		}
+	}
+
+	for _, cf := range collected {
+		if strings.HasSuffix(cf.Path, "server.log") && cf.SHA256 != fmt.Sprintf("%x", sha256.Sum256([]byte(want))) {
+			t.Errorf("expected the hash of the trimmed file, got %v", cf.SHA256)
+		}
+	}
+
+	summary := args.trimmed.summary(logWindow(args))
+	if summary.Since != "2026-03-20T14:00:00Z" || summary.Until != "2026-03-20T14:30:00Z" || len(summary.Files) != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if got := summary.Files[1]; got.Path != "/var/log/dremio/server.log" || got.OriginalBytes != int64(len(logContent)) || got.KeptBytes != int64(len(want)) {
+		t.Errorf("unexpected trimmed file %+v", got)
+	}
+}
+
+func TestStreamNodeFiles_TrimToWindowInNodeZone(t *testing.T) {
+	tmpDir := t.TempDir()
+	logContent := "2026-03-20 12:59:00,000 [main] INFO before\n2026-03-20 13:05:00,000 [main] ERROR the incident\n2026-03-20 14:05:00,000 [main] INFO after\n"
+	mc := &mockStreamCollector{
+		streamFunc: func(_, _ string, writer io.Writer) error {
+			_, err := io.WriteString(writer, logContent)
+			return err
+		},
+	}
+	info := &RemoteNodeInfo{UTCOffset: "-0100", Files: []RemoteFileInfo{
+		{Path: "/var/log/dremio/server.log", Size: int64(len(logContent)), FileType: "log"},
+	}}
+	args := Args{
+		CollectServerLogs: true,
+		TrimToWindow:      true,
+		TrimSince:         time.Date(2026, 3, 20, 14, 0, 0, 0, time.UTC),
+		TrimUntil:         time.Date(2026, 3, 20, 14, 30, 0, 0, time.UTC),
+	}
+
+	if _, skipped := streamNodeFiles(mc, "host1", info, &mockCopyStrategy{tmpDir: tmpDir}, "coordinator", "diagnosis", false, args); len(skipped) != 0 {
+		t.Fatalf("unexpected skipped files %v", skipped)
+	}
+	// 14:00-14:30 UTC is 13:00-13:30 on the node
+	b, err := os.ReadFile(filepath.Join(tmpDir, "logs", "host1", "server.log"))
+	if err != nil || string(b) != "2026-03-20 13:05:00,000 [main] ERROR the incident\n" {
+		t.Errorf("expected the window read in the node's zone, got %q, %v", b, err)
+	}
+
+	// --log-timezone overrides the node's offset
+	args.LogTimezone = time.UTC
+	if _, skipped := streamNodeFiles(mc, "host1", info, &mockCopyStrategy{tmpDir: tmpDir}, "coordinator", "diagnosis", false, args); len(skipped) != 0 {
+		t.Fatalf("unexpected skipped files %v", skipped)
+	}
+	if b, err = os.ReadFile(filepath.Join(tmpDir, "logs", "host1", "server.log")); err != nil || string(b) != "2026-03-20 14:05:00,000 [main] INFO after\n" {
+		t.Errorf("expected the window read in UTC, got %q, %v", b, err)
+	}
+}
@@ -0,0 +1,471 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/versions"
+)
+
+// PlanFileName is the file a --dry-run writes next to the --output-file.
+const PlanFileName = "plan.json"
+
+// Tools a plan runs on a node, in the order the collection runs them.
+const (
+	planToolOSInfo           = "os-info"
+	planToolDiskUsage        = "disk-usage"
+	planToolRocksDBDiskUsage = "rocksdb-disk-usage"
+	planToolJVMFlags         = "jvm-flags"
+	planToolNMT              = "native-memory"
+	planToolJStack           = "jstack"
+	planToolTop              = "top"
+	planToolJFR              = "jfr"
+	planToolAsyncProfiler    = "async-profiler"
+	planToolHeapDump         = "heap-dump"
+	planToolRocksDBViewer    = "rocksdb-viewer"
+	planToolIdentity         = "dremio-identity"
+)
+
+// jvmPlanTools are the tools run by runJVMCollection.
+var jvmPlanTools = []string{planToolJStack, planToolTop, planToolJFR, planToolAsyncProfiler, planToolHeapDump}
+
+// Calls a plan makes from the machine running ddc rather than on a node.
+const (
+	planCallKVStore     = "kv-store-report"
+	planCallCatalog     = "catalog-inventory"
+	planCallProfiles    = "problematic-profiles"
+	planCallClusterData = "cluster-data"
+	planCallIdentity    = "dremio-identity"
+)
+
+// CollectionPlan is everything a collection would read, as found by --dry-run:
+// the files on each node that pass the same filters as a collection, the tools
+// run on each node and the REST calls made. A collection started with --plan
+// reads exactly this and nothing else.
+type CollectionPlan struct {
+	PlanID          string                  `json:"planId"`
+	CreatedUTC      time.Time               `json:"createdUTC"`
+	DDCVersion      string                  `json:"ddcVersion"`
+	Transport       string                  `json:"transport"`
+	CollectionMode  collects.CollectionMode `json:"collectionMode"`
+	DiagTimeSeconds int                     `json:"diagTimeSeconds,omitempty"`
+	TotalFiles      int                     `json:"totalFiles"`
+	TotalBytes      int64                   `json:"totalBytes"`
+	Nodes           []PlanNode              `json:"nodes"`
+	Calls           []PlanCall              `json:"calls"`
+	FailedNodes     []string                `json:"failedNodes,omitempty"`
+
+	files map[string]map[string]int64 // host -> planned path -> planned size, built by restrict
+}
+
+// PlanNode is what the plan reads from one node. Bytes is the size of its files.
+type PlanNode struct {
+	Host         string     `json:"host"`
+	NodeType     string     `json:"nodeType"`
+	Bytes        int64      `json:"bytes"`
+	Files        []PlanFile `json:"files"`
+	Tools        []string   `json:"tools"`
+	RocksDBTypes []string   `json:"rocksdbTypes,omitempty"`
+	// DebugContainer is the ephemeral container a collection with
+	// --debug-container-image attaches to the pod, which a dry run does not.
+	DebugContainer *PlanDebugContainer `json:"debugContainer,omitempty"`
+}
+
+// PlanDebugContainer is a debug container a collection would attach to a pod:
+// its image and the tools the Dremio container lacks, or NoShell when it cannot
+// run sh and every command would run in the debug container.
+type PlanDebugContainer struct {
+	Image        string   `json:"image"`
+	MissingTools []string `json:"missingTools,omitempty"`
+	NoShell      bool     `json:"noShell,omitempty"`
+}
+
+// debugContainerPlanner is implemented by collectors that attach debug
+// containers. PlanDebugContainer probes the tools of host the way the
+// collection does before attaching, without attaching anything.
+type debugContainerPlanner interface {
+	PlanDebugContainer(host string) (missing []string, noShell, attach bool)
+}
+
+// PlanFile is a discovered file with its size and modification time (Unix
+// seconds) when the plan was made.
+type PlanFile struct {
+	Path     string `json:"path"`
+	FileType string `json:"fileType"`
+	Size     int64  `json:"size"`
+	ModTime  int64  `json:"modTime"`
+}
+
+// PlanCall is a call made from the machine running ddc; Requests describes
+// what is requested.
+type PlanCall struct {
+	Name     string `json:"name"`
+	Requests string `json:"requests"`
+}
+
+// LoadCollectionPlan reads a plan written by --dry-run.
+func LoadCollectionPlan(path string) (*CollectionPlan, error) {
+	b, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("reading --plan %v: %w", path, err)
+	}
+	var p CollectionPlan
+	if err := json.Unmarshal(b, &p); err != nil {
+		return nil, fmt.Errorf("parsing --plan %v: %w", path, err)
+	}
+	return &p, nil
+}
+
+// Save writes the plan as indented JSON.
+func (p *CollectionPlan) Save(path string) error {
+	b, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, b, 0o600); err != nil {
+		return fmt.Errorf("writing plan %v: %w", path, err)
+	}
+	return nil
+}
+
+// buildPlan describes what ExecuteStreamingCollect would collect from the
+// discovered nodes. Only read-only commands are run: the RocksDB catalog
+// check is the same one the viewer collection starts with.
+func buildPlan(c Collector, args Args, planID string, coordinators, executors []string, nodeInfoByHost map[string]*RemoteNodeInfo, failedNodes []string) *CollectionPlan {
+	p := &CollectionPlan{
+		PlanID:         planID,
+		CreatedUTC:     time.Now().UTC(),
+		DDCVersion:     versions.GetCLIVersion(),
+		Transport:      c.Name(),
+		CollectionMode: args.CollectionMode,
+		Nodes:          []PlanNode{},
+		Calls:          []PlanCall{},
+		FailedNodes:    failedNodes,
+	}
+	addNode := func(host, nodeType string) {
+		info := nodeInfoByHost[host]
+		if info == nil {
+			return
+		}
+		n := PlanNode{Host: host, NodeType: nodeType, Files: []PlanFile{}}
+		for _, rf := range info.Files {
+			if !includeNodeFile(host, info, rf, args.CollectionMode, args.CollectGCLogs, args) {
+				continue
+			}
+			n.Files = append(n.Files, PlanFile{Path: rf.Path, FileType: rf.FileType, Size: rf.Size, ModTime: rf.ModTime})
+			n.Bytes += rf.Size
+		}
+		n.Tools, n.RocksDBTypes = planNodeTools(c, host, nodeType, info, args)
+		if dp, ok := c.(debugContainerPlanner); ok && args.DebugContainerImage != "" {
+			if missing, noShell, attach := dp.PlanDebugContainer(host); attach {
+				n.DebugContainer = &PlanDebugContainer{Image: args.DebugContainerImage, MissingTools: missing, NoShell: noShell}
+			}
+		}
+		p.TotalFiles += len(n.Files)
+		p.TotalBytes += n.Bytes
+		p.Nodes = append(p.Nodes, n)
+	}
+	for _, host := range coordinators {
+		addNode(host, "coordinator")
+	}
+	for _, host := range executors {
+		addNode(host, "executor")
+	}
+
+	for _, n := range p.Nodes {
+		if slices.ContainsFunc(jvmPlanTools, func(tool string) bool { return slices.Contains(n.Tools, tool) }) {
+			p.DiagTimeSeconds = args.DiagTimeSeconds
+			break
+		}
+	}
+	if args.DremioPAT != "" && len(coordinators) > 0 {
+		if args.DremioEndpoint != "" {
+			p.Calls = append(p.Calls, PlanCall{Name: planCallIdentity, Requests: "GET " + args.DremioEndpoint + "/apiv2/server_status and, when it has no version, a sys.version query"})
+		}
+		if args.CollectKVStoreReport {
+			p.Calls = append(p.Calls, PlanCall{Name: planCallKVStore, Requests: "GET " + args.DremioEndpoint + "/apiv2/kvstore/report"})
+		}
+		if args.CollectCatalogInventory {
+			p.Calls = append(p.Calls, PlanCall{Name: planCallCatalog, Requests: fmt.Sprintf("GET %v/api/v3/catalog and the entries below it (depth %d, at most %d items), GET %v/api/v3/reflection",
+				args.DremioEndpoint, args.CatalogMaxDepth, args.CatalogMaxItems, args.DremioEndpoint)})
+		}
+		if args.CollectProblematicProfiles {
+			p.Calls = append(p.Calls, PlanCall{Name: planCallProfiles, Requests: fmt.Sprintf("GET %v/apiv2/support/<job id>/download for up to %d failed or slow jobs found in the collected logs",
+				args.DremioEndpoint, maxProblematicProfiles)})
+		}
+	}
+	if args.ClusterData != "" {
+		p.Calls = append(p.Calls, PlanCall{Name: planCallClusterData, Requests: "read-only cluster resources, events and, when enabled, container logs through the " + args.ClusterData})
+	}
+	return p
+}
+
+// planNodeTools returns the tools the collection runs on a node and, when the
+// RocksDB viewer runs, the types it extracts. It mirrors the node-info, JVM and
+// RocksDB phases of ExecuteStreamingCollect.
+func planNodeTools(c Collector, host, nodeType string, info *RemoteNodeInfo, args Args) ([]string, []string) {
+	// the identity lookup reads the dremio-common jar manifest and server.log
+	tools := []string{planToolOSInfo, planToolDiskUsage, planToolIdentity}
+	if nodeType == "coordinator" {
+		tools = append(tools, planToolRocksDBDiskUsage)
+	}
+	if info.DremioPID > 0 {
+		tools = append(tools, planToolJVMFlags)
+		if args.CollectionMode == collects.DiagnosisCollection {
+			for _, t := range []struct {
+				name    string
+				enabled bool
+			}{
+				{planToolNMT, args.CollectNMT},
+				{planToolJStack, args.CollectJStack},
+				{planToolTop, args.CollectTop},
+				{planToolJFR, args.CollectJFR},
+				{planToolAsyncProfiler, args.CollectAsyncProfiler},
+				{planToolHeapDump, args.CollectHeapDump},
+			} {
+				if t.enabled {
+					tools = append(tools, t.name)
+				}
+			}
+		}
+	}
+	if nodeType != "coordinator" {
+		return tools, nil
+	}
+	rocksDBDir := info.RocksDBDir
+	if rocksDBDir == "" {
+		rocksDBDir = args.DremioRocksDBDir
+	}
+	if rocksDBDir == "" || !hasRocksDBCatalog(c, host, rocksDBDir) {
+		return tools, nil
+	}
+	types, queriesPerf := selectedRocksDBTypes(RocksCollectArgs{
+		CollectSystemTables: args.CollectSystemTables,
+		SystemTables:        args.SystemTables,
+		CollectWLM:          args.CollectWLM,
+		CollectQueriesPerf:  args.CollectQueriesPerf,
+		Types:               args.RocksDBTypes,
+		TypeOverrides:       args.RocksDBTypeOverrides,
+	})
+	if queriesPerf {
+		types = append(types, RocksDBTypeQueriesPerf)
+	}
+	return append(tools, planToolRocksDBViewer), types
+}
+
+// writeDryRunPlan builds the plan of a --dry-run and writes it next to the
+// --output-file instead of collecting anything.
+func writeDryRunPlan(c Collector, args Args, planID string, coordinators, executors []string, nodeInfoByHost map[string]*RemoteNodeInfo, failedNodes []string) error {
+	p := buildPlan(c, args, planID, coordinators, executors, nodeInfoByHost, failedNodes)
+	for _, n := range p.Nodes {
+		consoleprint.UpdateNodeState(consoleprint.NodeState{
+			Node:          n.Host,
+			Status:        consoleprint.Completed,
+			StatusUX:      fmt.Sprintf("Planned: %d files (%s), %d tools", len(n.Files), humanizeBytes(n.Bytes), len(n.Tools)),
+			EndProcess:    true,
+			IsCoordinator: n.NodeType == "coordinator",
+		})
+	}
+	path, err := filepath.Abs(filepath.Join(filepath.Dir(args.OutputLoc), PlanFileName))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), DirPerms); err != nil {
+		return fmt.Errorf("writing plan %v: %w", path, err)
+	}
+	if err := p.Save(path); err != nil {
+		return err
+	}
+	if len(failedNodes) > 0 {
+		simplelog.Warningf("dry run: discovery failed on %d node(s), left out of the plan: %v", len(failedNodes), failedNodes)
+	}
+	simplelog.Infof("dry run: plan %v with %d file(s) (%d bytes) on %d node(s) and %d call(s) written to %v", p.PlanID, p.TotalFiles, p.TotalBytes, len(p.Nodes), len(p.Calls), path)
+	consoleprint.UpdateResult(fmt.Sprintf("Dry run: %d file(s) (%s) on %d node(s), plan written to %v", p.TotalFiles, humanizeBytes(p.TotalBytes), len(p.Nodes), path))
+	return nil
+}
+
+// restrict limits a collection to the plan: its nodes, tools and calls. The
+// files are limited per node by includesFile.
+func (p *CollectionPlan) restrict(args *Args) error {
+	if p.CollectionMode != args.CollectionMode {
+		return fmt.Errorf("--plan %v was made for a %v collection, run it with the %v mode", p.PlanID, p.CollectionMode, p.CollectionMode)
+	}
+	if len(p.Nodes) == 0 {
+		return fmt.Errorf("--plan %v has no nodes to collect", p.PlanID)
+	}
+	p.files = make(map[string]map[string]int64, len(p.Nodes))
+	args.IncludeNodes, args.ExcludeNodes = nil, nil
+	for _, n := range p.Nodes {
+		args.IncludeNodes = append(args.IncludeNodes, n.Host)
+		paths := make(map[string]int64, len(n.Files))
+		for _, f := range n.Files {
+			paths[f.Path] = f.Size
+		}
+		p.files[n.Host] = paths
+	}
+	args.CollectJStack = p.anyNodeRuns(planToolJStack)
+	args.CollectTop = p.anyNodeRuns(planToolTop)
+	args.CollectJFR = p.anyNodeRuns(planToolJFR)
+	args.CollectAsyncProfiler = p.anyNodeRuns(planToolAsyncProfiler)
+	args.CollectHeapDump = p.anyNodeRuns(planToolHeapDump)
+	args.CollectNMT = p.anyNodeRuns(planToolNMT)
+	if p.DiagTimeSeconds > 0 {
+		args.DiagTimeSeconds = p.DiagTimeSeconds
+	}
+	args.CollectKVStoreReport = p.calls(planCallKVStore)
+	args.CollectCatalogInventory = p.calls(planCallCatalog)
+	args.CollectProblematicProfiles = p.calls(planCallProfiles)
+	if (args.CollectKVStoreReport || args.CollectCatalogInventory || args.CollectProblematicProfiles) && args.DremioPAT == "" {
+		simplelog.Warningf("--plan %v lists REST calls but no PAT was given, they are skipped", p.PlanID)
+	}
+	return nil
+}
+
+// node returns the plan of host, nil when the plan does not include it.
+func (p *CollectionPlan) node(host string) *PlanNode {
+	for i := range p.Nodes {
+		if p.Nodes[i].Host == host {
+			return &p.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// runs reports whether tool runs on host. Without a plan every tool runs.
+func (p *CollectionPlan) runs(host, tool string) bool {
+	if p == nil {
+		return true
+	}
+	n := p.node(host)
+	return n != nil && slices.Contains(n.Tools, tool)
+}
+
+// anyNodeRuns reports whether tool runs on any node of the plan.
+func (p *CollectionPlan) anyNodeRuns(tool string) bool {
+	for _, n := range p.Nodes {
+		if slices.Contains(n.Tools, tool) {
+			return true
+		}
+	}
+	return false
+}
+
+// calls reports whether the plan makes the named call. Without a plan every
+// call is made.
+func (p *CollectionPlan) calls(name string) bool {
+	if p == nil {
+		return true
+	}
+	return slices.ContainsFunc(p.Calls, func(pc PlanCall) bool { return pc.Name == name })
+}
+
+// jvmTargets keeps the hosts of pidByHost the plan runs JVM tools on. Without
+// a plan it returns pidByHost.
+func (p *CollectionPlan) jvmTargets(pidByHost map[string]int) map[string]int {
+	if p == nil {
+		return pidByHost
+	}
+	targets := make(map[string]int)
+	for host, pid := range pidByHost {
+		for _, tool := range jvmPlanTools {
+			if p.runs(host, tool) {
+				targets[host] = pid
+				break
+			}
+		}
+	}
+	return targets
+}
+
+// includesFile reports whether a file discovered on host is in the plan. A
+// planned file that is now empty is left out like any other empty file.
+func (p *CollectionPlan) includesFile(host string, rf RemoteFileInfo) bool {
+	if _, ok := p.files[host][rf.Path]; !ok {
+		simplelog.Infof("stream exclude (not in plan): %v:%v", host, rf.Path)
+		return false
+	}
+	if rf.Size == 0 {
+		simplelog.Infof("stream exclude (0 bytes): %v:%v", host, rf.Path)
+		return false
+	}
+	return true
+}
+
+// sizeLimit returns the size a planned file had when the plan was made if it
+// has grown since, so the collection reads no more than was approved, and 0
+// when the whole file is read.
+func (p *CollectionPlan) sizeLimit(host string, rf RemoteFileInfo) int64 {
+	planned, ok := p.files[host][rf.Path]
+	if !ok || rf.Size <= planned {
+		return 0
+	}
+	simplelog.Warningf("stream cap: %v:%v grew from %d to %d bytes since plan %v, collecting the first %d", host, rf.Path, planned, rf.Size, p.PlanID, planned)
+	return planned
+}
+
+// cappedCollector reads at most limit bytes of a file, so a --plan collection
+// stops at the size the plan approved.
+type cappedCollector struct {
+	Collector
+	limit int64
+}
+
+func (cc cappedCollector) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
+	compress := ""
+	if useGzip {
+		compress = "gzip -c"
+	}
+	return cc.StreamCommandFromHost(host, cappedReadCommand(remotePath, offset, cc.limit, compress), writer)
+}
+
+// cappedReadCommand is RemoteReadCommand stopping at byte limit of the file.
+func cappedReadCommand(remotePath string, offset, limit int64, compress string) string {
+	read := fmt.Sprintf("%s | head -c %d", RemoteReadCommand(remotePath, offset, ""), max(limit-offset, 0))
+	if compress != "" {
+		return read + " | " + compress
+	}
+	return read
+}
+
+// missingFiles returns the planned files of host that were not discovered
+// again, e.g. because they were rotated away since the plan was made.
+func (p *CollectionPlan) missingFiles(host string, info *RemoteNodeInfo) []string {
+	n := p.node(host)
+	if n == nil {
+		return nil
+	}
+	found := make(map[string]bool, len(info.Files))
+	for _, rf := range info.Files {
+		found[rf.Path] = true
+	}
+	var missing []string
+	for _, f := range n.Files {
+		if !found[f.Path] {
+			simplelog.Warningf("stream skip: %v:%v is in the plan but no longer exists", host, f.Path)
+			missing = append(missing, f.Path)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,232 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+)
+
+func TestExecuteStreamingCollect_DryRun(t *testing.T) {
+	tmpDir := t.TempDir()
+	cs := &mockCopyStrategy{tmpDir: tmpDir}
+	recent := time.Now().Add(-time.Hour).Unix()
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		executors:    []string{"exec1"},
+		discoverFunc: func(host string) (*RemoteNodeInfo, error) {
+			info := &RemoteNodeInfo{Files: []RemoteFileInfo{
+				{Path: "/var/log/dremio/server.log", Size: 100, FileType: "log", ModTime: recent},
+				{Path: "/var/log/dremio/server.2020-01-01.0.log.gz", Size: 50, FileType: "log", ModTime: 1},
+				{Path: "/var/log/dremio/gc.log", Size: 10, FileType: "gc-log", ModTime: recent},
+				{Path: "/opt/dremio/conf/dremio.conf", Size: 5, FileType: "config", ModTime: recent},
+			}}
+			if host == "coord1" {
+				info.DremioPID = 42
+				info.RocksDBDir = "/opt/dremio/data/db"
+			}
+			return info, nil
+		},
+		hostExecuteFunc: func(_ bool, _ string, args ...string) (string, error) {
+			if cmd := strings.Join(args, " "); cmd == "test -f /opt/dremio/data/db/catalog/CURRENT && echo exists" {
+				return "exists\n", nil
+			}
+			t.Errorf("dry run executed %v", args)
+			return "", errors.New("unexpected command")
+		},
+		streamFunc: func(host, remotePath string, _ io.Writer) error {
+			t.Errorf("dry run streamed %v:%v", host, remotePath)
+			return nil
+		},
+	}
+	args := Args{
+		OutputLoc:         filepath.Join(tmpDir, "diag.tgz"),
+		CollectionMode:    collects.DiagnosisCollection,
+		DiagLogDays:       3,
+		DiagTimeSeconds:   60,
+		CollectJStack:     true,
+		CollectHeapDump:   true,
+		CollectGCLogs:     true,
+		CollectWLM:        true,
+		CollectServerLogs: true,
+		DryRun:            true,
+		ClusterData:       "Kubernetes API",
+	}
+	if err := ExecuteStreamingCollect(mc, cs, args, shutdown.NewHook(), func() { t.Error("dry run collected cluster data") }); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := LoadCollectionPlan(filepath.Join(tmpDir, PlanFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Nodes) != 2 || plan.Nodes[0].Host != "coord1" || plan.TotalFiles != 6 || plan.TotalBytes != 230 {
+		t.Fatalf("unexpected plan %+v", plan)
+	}
+	var paths []string
+	for _, f := range plan.Nodes[0].Files {
+		paths = append(paths, f.Path)
+	}
+	if want := []string{"/var/log/dremio/server.log", "/var/log/dremio/gc.log", "/opt/dremio/conf/dremio.conf"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("expected the files a collection would stream, got %v", paths)
+	}
+	wantTools := []string{planToolOSInfo, planToolDiskUsage, planToolIdentity, planToolRocksDBDiskUsage, planToolJVMFlags, planToolJStack, planToolHeapDump, planToolRocksDBViewer}
+	if !reflect.DeepEqual(plan.Nodes[0].Tools, wantTools) {
+		t.Errorf("unexpected coordinator tools %v", plan.Nodes[0].Tools)
+	}
+	if want := append([]string{"cluster_stats"}, wlmTypes...); !reflect.DeepEqual(plan.Nodes[0].RocksDBTypes, want) {
+		t.Errorf("unexpected RocksDB types %v", plan.Nodes[0].RocksDBTypes)
+	}
+	if want := []string{planToolOSInfo, planToolDiskUsage, planToolIdentity}; !reflect.DeepEqual(plan.Nodes[1].Tools, want) {
+		t.Errorf("expected no JVM tools without a PID, got %v", plan.Nodes[1].Tools)
+	}
+	if len(plan.Calls) != 1 || plan.Calls[0].Name != planCallClusterData || plan.DiagTimeSeconds != 60 {
+		t.Errorf("expected only the cluster data call without a PAT, got %+v", plan.Calls)
+	}
+}
+
+func TestExecuteStreamingCollect_Plan(t *testing.T) {
+	tmpDir := t.TempDir()
+	cs := &mockCopyStrategy{tmpDir: tmpDir}
+	var mu sync.Mutex
+	var streamed, discovered, commands []string
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		executors:    []string{"exec1"},
+		discoverFunc: func(host string) (*RemoteNodeInfo, error) {
+			mu.Lock()
+			discovered = append(discovered, host)
+			mu.Unlock()
+			return &RemoteNodeInfo{DremioPID: 42, Files: []RemoteFileInfo{
+				{Path: "/var/log/dremio/server.log", Size: 100, FileType: "log"},
+				{Path: "/var/log/dremio/metadata_refresh.log", Size: 20, FileType: "log"},
+				{Path: "/opt/dremio/conf/dremio.conf", Size: 5, FileType: "config"},
+			}}, nil
+		},
+		hostExecuteFunc: func(_ bool, _ string, args ...string) (string, error) {
+			t.Errorf("a plan without tools executed %v", args)
+			return "", errors.New("unexpected command")
+		},
+		streamFunc: func(host, remotePath string, w io.Writer) error {
+			mu.Lock()
+			streamed = append(streamed, host+":"+remotePath)
+			mu.Unlock()
+			_, err := w.Write([]byte("data"))
+			return err
+		},
+		streamCmdFunc: func(_, streamCmd string, w io.Writer) error {
+			mu.Lock()
+			commands = append(commands, streamCmd)
+			mu.Unlock()
+			_, err := w.Write([]byte("data"))
+			return err
+		},
+	}
+	plan := &CollectionPlan{
+		PlanID:         "plan-1",
+		CollectionMode: collects.StandardCollection,
+		Nodes: []PlanNode{{Host: "coord1", NodeType: "coordinator", Tools: []string{}, Files: []PlanFile{
+			{Path: "/var/log/dremio/server.log", FileType: "log", Size: 90},
+			{Path: "/var/log/dremio/server.2026-01-01.0.log.gz", FileType: "log", Size: 40},
+			{Path: "/opt/dremio/conf/dremio.conf", FileType: "config", Size: 5},
+		}}},
+	}
+	// the command line enables more than the plan: the plan wins
+	args := Args{
+		OutputLoc:             filepath.Join(tmpDir, "diag.tgz"),
+		CollectionMode:        collects.StandardCollection,
+		CollectServerLogs:     true,
+		CollectMetaRefreshLog: true,
+		ExcludeNodes:          []string{"coord1"},
+		Plan:                  plan,
+	}
+	if err := ExecuteStreamingCollect(mc, cs, args, shutdown.NewHook(), func() { t.Error("cluster data is not in the plan") }); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(streamed)
+	if want := []string{"coord1:/opt/dremio/conf/dremio.conf"}; !reflect.DeepEqual(streamed, want) {
+		t.Errorf("expected only the planned files, got %v", streamed)
+	}
+	// server.log grew from the planned 90 bytes to 100: only the planned bytes are read
+	if want := []string{"cat '/var/log/dremio/server.log' | head -c 90"}; !reflect.DeepEqual(commands, want) {
+		t.Errorf("expected the grown file capped at its planned size, got %v", commands)
+	}
+	if !reflect.DeepEqual(discovered, []string{"coord1"}) {
+		t.Errorf("expected only the planned nodes, got %v", discovered)
+	}
+
+	args.CollectionMode = collects.DiagnosisCollection
+	if err := ExecuteStreamingCollect(mc, cs, args, shutdown.NewHook(), func() {}); err == nil || !strings.Contains(err.Error(), "standard collection") {
+		t.Errorf("expected a mode mismatch error, got %v", err)
+	}
+}
+
+// debugPlanningCollector is a collector that attaches debug containers.
+type debugPlanningCollector struct {
+	*mockStreamCollector
+	missing map[string][]string
+}
+
+func (d debugPlanningCollector) PlanDebugContainer(host string) ([]string, bool, bool) {
+	return d.missing[host], false, len(d.missing[host]) > 0
+}
+
+func TestBuildPlan_DebugContainer(t *testing.T) {
+	mc := debugPlanningCollector{
+		mockStreamCollector: &mockStreamCollector{},
+		missing:             map[string][]string{"exec1": {"jcmd", "gzip"}},
+	}
+	infos := map[string]*RemoteNodeInfo{"exec1": {}, "exec2": {}}
+	args := Args{CollectionMode: collects.StandardCollection, DebugContainerImage: "busybox:1.36"}
+	p := buildPlan(mc, args, "plan-1", nil, []string{"exec1", "exec2"}, infos, nil)
+	if got := p.Nodes[0].DebugContainer; got == nil || got.Image != "busybox:1.36" || !reflect.DeepEqual(got.MissingTools, []string{"jcmd", "gzip"}) {
+		t.Errorf("expected the debug container exec1 would get, got %+v", got)
+	}
+	if got := p.Nodes[1].DebugContainer; got != nil {
+		t.Errorf("expected no debug container on a pod with every tool, got %+v", got)
+	}
+
+	args.DebugContainerImage = ""
+	if p := buildPlan(mc, args, "plan-2", nil, []string{"exec1"}, infos, nil); p.Nodes[0].DebugContainer != nil {
+		t.Errorf("expected no debug container without --debug-container-image, got %+v", p.Nodes[0].DebugContainer)
+	}
+}
+
+func TestCappedReadCommand(t *testing.T) {
+	tests := []struct {
+		offset, limit int64
+		compress      string
+		want          string
+	}{
+		{0, 90, "", "cat '/var/log/dremio/server.log' | head -c 90"},
+		{10, 90, "gzip -c", "tail -c +11 '/var/log/dremio/server.log' | head -c 80 | gzip -c"},
+		{100, 90, "", "tail -c +101 '/var/log/dremio/server.log' | head -c 0"},
+	}
+	for _, tt := range tests {
+		if got := cappedReadCommand("/var/log/dremio/server.log", tt.offset, tt.limit, tt.compress); got != tt.want {
+			t.Errorf("cappedReadCommand(%d, %d, %q) = %q, want %q", tt.offset, tt.limit, tt.compress, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,407 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// PreflightStatus is the outcome of a single preflight check.
+type PreflightStatus string
+
+const (
+	// PreflightPass means the collection step the check covers will work.
+	PreflightPass PreflightStatus = "pass"
+	// PreflightWarn means the collection runs but that step is degraded or skipped.
+	PreflightWarn PreflightStatus = "warn"
+	// PreflightFail means the node (or cluster) cannot be collected as is.
+	PreflightFail PreflightStatus = "fail"
+)
+
+// Names of the transport specific preflight checks.
+const (
+	PreflightCheckSudo = "sudo"
+	PreflightCheckRBAC = "rbac"
+)
+
+// Names of the node checks RunPreflight runs itself.
+const (
+	checkConnectivity   = "connectivity"
+	checkDiscovery      = "discovery"
+	checkChecksum       = "checksum"
+	checkGzip           = "gzip"
+	checkTar            = "tar"
+	checkPID            = "pid"
+	checkJcmd           = "jcmd"
+	checkHeapDumpSpace  = "heap-dump-space"
+	checkRocksDBCatalog = "rocksdb-catalog"
+)
+
+// preflightColumns is the column order of the report table.
+var preflightColumns = []string{checkConnectivity, PreflightCheckSudo, checkDiscovery, checkChecksum, checkGzip, checkTar, checkPID, checkJcmd, checkHeapDumpSpace, checkRocksDBCatalog}
+
+// PreflightCheck is one cell of the preflight matrix.
+type PreflightCheck struct {
+	Name   string          `json:"name"`
+	Status PreflightStatus `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+}
+
+// PreflightCategory is what a collection would pull of one log category on a
+// node: the files that pass the collection filters and their size on disk.
+type PreflightCategory struct {
+	Category string `json:"category"`
+	Files    int    `json:"files"`
+	Bytes    int64  `json:"bytes"`
+}
+
+// PreflightNode is the preflight result of a single node.
+type PreflightNode struct {
+	Host       string              `json:"host"`
+	NodeType   string              `json:"nodeType"`
+	Checks     []PreflightCheck    `json:"checks"`
+	Categories []PreflightCategory `json:"categories,omitempty"`
+}
+
+// PreflightReport is the result of ddc preflight.
+type PreflightReport struct {
+	Transport string           `json:"transport"`
+	Cluster   []PreflightCheck `json:"cluster,omitempty"`
+	Nodes     []PreflightNode  `json:"nodes"`
+}
+
+// NodeCheck runs transport specific preflight checks on a node (e.g. sudo over
+// SSH). Like the built-in checks it must not change anything on the node.
+type NodeCheck func(host string) []PreflightCheck
+
+// Failed reports whether any check of the report failed.
+func (r *PreflightReport) Failed() bool {
+	for _, c := range r.Cluster {
+		if c.Status == PreflightFail {
+			return true
+		}
+	}
+	for _, n := range r.Nodes {
+		for _, c := range n.Checks {
+			if c.Status == PreflightFail {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RunPreflight runs the discovery-only readiness checks of a collection on
+// every node of c: connectivity, discovery and its tool probes, the Dremio
+// PID, jcmd attachability, room for a heap dump and the RocksDB catalog. It
+// only runs read-only commands — nothing is uploaded, started or written on a
+// node — and sizes each log category with the same filters the collection
+// uses. nodeCheck, when set, adds transport specific checks.
+func RunPreflight(c Collector, args Args, nodeCheck NodeCheck) (*PreflightReport, error) {
+	coordinators, err := c.GetCoordinators()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coordinators: %w", err)
+	}
+	executorsRaw, err := c.GetExecutors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executors: %w", err)
+	}
+	coordinators = FilterCoordinators(coordinators)
+	executors := FilterExecutors(executorsRaw, coordinators)
+	coordinators = FilterByNodeSelection(coordinators, args.IncludeNodes, args.ExcludeNodes)
+	executors = FilterByNodeSelection(executors, args.IncludeNodes, args.ExcludeNodes)
+	if len(coordinators)+len(executors) == 0 {
+		return nil, fmt.Errorf("no hosts found, nothing to check: %v", c.HelpText())
+	}
+
+	threads := args.CollectionThreads
+	if threads <= 0 {
+		threads = 5
+	}
+	report := &PreflightReport{Transport: c.Name()}
+	report.Nodes = make([]PreflightNode, len(coordinators)+len(executors))
+	sem := make(chan struct{}, threads)
+	var wg sync.WaitGroup
+	for i, host := range append(append([]string{}, coordinators...), executors...) {
+		nodeType := "executor"
+		if i < len(coordinators) {
+			nodeType = "coordinator"
+		}
+		wg.Add(1)
+		go func(i int, host, nodeType string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			report.Nodes[i] = preflightNode(c, host, nodeType, args, nodeCheck)
+		}(i, host, nodeType)
+	}
+	wg.Wait()
+	return report, nil
+}
+
+// preflightNode runs the checks of a single node. Checks that depend on an
+// earlier one (jcmd on the PID, heap dump space on jcmd) are skipped with a
+// warning when it did not pass.
+func preflightNode(c Collector, host, nodeType string, args Args, nodeCheck NodeCheck) PreflightNode {
+	node := PreflightNode{Host: host, NodeType: nodeType}
+	add := func(name string, status PreflightStatus, detail string) {
+		node.Checks = append(node.Checks, PreflightCheck{Name: name, Status: status, Detail: detail})
+	}
+	simplelog.Infof("preflight: checking %v (%v)", host, nodeType)
+
+	if out, err := c.HostExecute(false, host, "echo", "ok"); err != nil || !strings.Contains(out, "ok") {
+		add(checkConnectivity, PreflightFail, fmt.Sprintf("unable to run commands: %v", err))
+		return node
+	}
+	add(checkConnectivity, PreflightPass, "")
+	if nodeCheck != nil {
+		node.Checks = append(node.Checks, nodeCheck(host)...)
+	}
+
+	logDir := args.CoordinatorLogDir
+	if nodeType == "executor" {
+		logDir = args.ExecutorLogDir
+	}
+	info, err := c.DiscoverFiles(host, logDir, args.DremioConfDir)
+	if err != nil {
+		add(checkDiscovery, PreflightFail, err.Error())
+		return node
+	}
+	switch {
+	case info.LogDir == "":
+		add(checkDiscovery, PreflightWarn, "no log directory found, pass --coordinator-log-dir / --executor-log-dir")
+	case info.ConfDir == "":
+		add(checkDiscovery, PreflightWarn, fmt.Sprintf("logs in %v, no configuration directory found, pass --dremio-conf-dir", info.LogDir))
+	default:
+		add(checkDiscovery, PreflightPass, fmt.Sprintf("logs in %v, configuration in %v", info.LogDir, info.ConfDir))
+	}
+
+	switch info.ChecksumTool {
+	case "sha256sum":
+		add(checkChecksum, PreflightPass, info.ChecksumTool)
+	case "":
+		add(checkChecksum, PreflightWarn, "no sha256sum or md5sum, transfers are not verified")
+	default:
+		add(checkChecksum, PreflightWarn, info.ChecksumTool+" only, no SHA-256 in summary.json")
+	}
+	if info.GzipAvailable {
+		add(checkGzip, PreflightPass, "")
+	} else {
+		add(checkGzip, PreflightWarn, "files are streamed uncompressed")
+	}
+	if info.TarAvailable {
+		add(checkTar, PreflightPass, "")
+	} else {
+		add(checkTar, PreflightWarn, "files are streamed one command per file")
+	}
+
+	if info.DremioPID > 0 {
+		add(checkPID, PreflightPass, strconv.Itoa(info.DremioPID))
+		preflightJVM(c, host, info.DremioPID, add)
+	} else {
+		add(checkPID, PreflightWarn, "no Dremio process found, JVM diagnostics are skipped")
+		add(checkJcmd, PreflightWarn, "skipped, no Dremio process")
+		add(checkHeapDumpSpace, PreflightWarn, "skipped, no Dremio process")
+	}
+
+	if nodeType == "coordinator" {
+		rocksDBDir := info.RocksDBDir
+		if rocksDBDir == "" {
+			rocksDBDir = args.DremioRocksDBDir
+		}
+		if rocksDBDir == "" {
+			add(checkRocksDBCatalog, PreflightWarn, "no RocksDB directory found, pass --dremio-rocksdb-dir")
+		} else {
+			if !hasRocksDBCatalog(c, host, rocksDBDir) {
+				add(checkRocksDBCatalog, PreflightWarn, fmt.Sprintf("no catalog at %v/catalog/CURRENT (not the master coordinator)", rocksDBDir))
+			} else {
+				add(checkRocksDBCatalog, PreflightPass, rocksDBDir)
+			}
+		}
+	}
+
+	node.Categories = preflightCategories(host, info, args)
+	return node
+}
+
+// preflightJVM checks that jcmd can attach to pid and that /tmp can hold a
+// heap dump of -Xmx, the same test the heap dump step runs.
+func preflightJVM(c Collector, host string, pid int, add func(name string, status PreflightStatus, detail string)) {
+	vmFlagsOut, err := jcmdExec(c, host, strconv.Itoa(pid), "VM.flags")
+	if err != nil {
+		add(checkJcmd, PreflightWarn, fmt.Sprintf("jcmd cannot attach, JVM diagnostics are skipped: %v", err))
+		add(checkHeapDumpSpace, PreflightWarn, "skipped, jcmd cannot attach")
+		return
+	}
+	add(checkJcmd, PreflightPass, "")
+	maxHeap, err := ParseXmxBytes(vmFlagsOut)
+	if err != nil {
+		add(checkHeapDumpSpace, PreflightWarn, err.Error())
+		return
+	}
+	avail, err := CheckRemoteDiskSpace(c, host, "/tmp")
+	switch {
+	case err != nil:
+		add(checkHeapDumpSpace, PreflightWarn, err.Error())
+	case avail < maxHeap:
+		add(checkHeapDumpSpace, PreflightWarn, fmt.Sprintf("%v free in /tmp < -Xmx %v, a heap dump is skipped", humanizeBytes(int64(avail)), humanizeBytes(int64(maxHeap)))) // #nosec G115 -- disk and heap sizes fit in int64
+	default:
+		add(checkHeapDumpSpace, PreflightPass, fmt.Sprintf("%v free in /tmp, -Xmx %v", humanizeBytes(int64(avail)), humanizeBytes(int64(maxHeap)))) // #nosec G115 -- disk and heap sizes fit in int64
+	}
+}
+
+// preflightCategories sums the files of info a collection would stream by
+// log category. Sizes are the bytes on the node, before compression.
+func preflightCategories(host string, info *RemoteNodeInfo, args Args) []PreflightCategory {
+	byName := make(map[string]*PreflightCategory)
+	for _, rf := range info.Files {
+		if !includeNodeFile(host, info, rf, args.CollectionMode, args.CollectGCLogs, args) {
+			continue
+		}
+		name := preflightCategory(rf)
+		cat, ok := byName[name]
+		if !ok {
+			cat = &PreflightCategory{Category: name}
+			byName[name] = cat
+		}
+		cat.Files++
+		cat.Bytes += rf.Size
+	}
+	categories := make([]PreflightCategory, 0, len(byName))
+	for _, cat := range byName {
+		categories = append(categories, *cat)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Category < categories[j].Category })
+	return categories
+}
+
+// preflightCategory names the log category of a discovered file: the log name
+// for server-side logs (server, tracker, vacuum, ...), else its file type.
+func preflightCategory(rf RemoteFileInfo) string {
+	switch rf.FileType {
+	case "gc-log":
+		return "gc"
+	case "log":
+		base := filepath.Base(rf.Path)
+		if strings.HasPrefix(base, "hs_err") {
+			return "hs_err"
+		}
+		name, _, _ := strings.Cut(base, ".")
+		return name
+	default:
+		return rf.FileType
+	}
+}
+
+// WriteTable prints the report as a pass/warn/fail matrix with one row per
+// node, followed by the details of every check that did not pass and the
+// files and bytes each log category would pull.
+func (r *PreflightReport) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(r.Cluster) > 0 {
+		fmt.Fprintf(tw, "CLUSTER\tSTATUS\tDETAIL\n")
+		for _, c := range r.Cluster {
+			fmt.Fprintf(tw, "%v\t%v\t%v\n", c.Name, c.Status, c.Detail)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	columns := r.columns()
+	fmt.Fprintf(tw, "NODE\tTYPE\t%v\n", strings.ToUpper(strings.Join(columns, "\t")))
+	for _, n := range r.Nodes {
+		status := make(map[string]PreflightStatus, len(n.Checks))
+		for _, c := range n.Checks {
+			status[c.Name] = c.Status
+		}
+		cells := make([]string, len(columns))
+		for i, col := range columns {
+			cells[i] = "-"
+			if s, ok := status[col]; ok {
+				cells[i] = string(s)
+			}
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\n", n.Host, n.NodeType, strings.Join(cells, "\t"))
+	}
+
+	var details []string
+	for _, n := range r.Nodes {
+		for _, c := range n.Checks {
+			if c.Status != PreflightPass {
+				details = append(details, fmt.Sprintf("%v\t%v\t%v\t%v", n.Host, c.Name, c.Status, c.Detail))
+			}
+		}
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(tw, "\nNODE\tCHECK\tSTATUS\tDETAIL\n")
+		for _, d := range details {
+			fmt.Fprintln(tw, d)
+		}
+	}
+
+	var sizes []string
+	for _, n := range r.Nodes {
+		if n.Categories == nil {
+			// not discovered
+			continue
+		}
+		var files int
+		var bytes int64
+		for _, cat := range n.Categories {
+			sizes = append(sizes, fmt.Sprintf("%v\t%v\t%d\t%d (%v)", n.Host, cat.Category, cat.Files, cat.Bytes, humanizeBytes(cat.Bytes)))
+			files += cat.Files
+			bytes += cat.Bytes
+		}
+		sizes = append(sizes, fmt.Sprintf("%v\ttotal\t%d\t%d (%v)", n.Host, files, bytes, humanizeBytes(bytes)))
+	}
+	if len(sizes) > 0 {
+		fmt.Fprintf(tw, "\nNODE\tCATEGORY\tFILES\tBYTES\n")
+		for _, line := range sizes {
+			fmt.Fprintln(tw, line)
+		}
+	}
+	return tw.Flush()
+}
+
+// columns returns the checks present in the report, in table order.
+func (r *PreflightReport) columns() []string {
+	present := make(map[string]bool)
+	for _, n := range r.Nodes {
+		for _, c := range n.Checks {
+			present[c.Name] = true
+		}
+	}
+	var columns []string
+	for _, col := range preflightColumns {
+		if present[col] {
+			columns = append(columns, col)
+			delete(present, col)
+		}
+	}
+	var extra []string
+	for col := range present {
+		extra = append(extra, col)
+	}
+	sort.Strings(extra)
+	return append(columns, extra...)
+}
@@ -0,0 +1,198 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+)
+
+func preflightStatuses(report *PreflightReport, host string) map[string]PreflightStatus {
+	m := make(map[string]PreflightStatus)
+	for _, n := range report.Nodes {
+		if n.Host != host {
+			continue
+		}
+		for _, c := range n.Checks {
+			m[c.Name] = c.Status
+		}
+	}
+	return m
+}
+
+func TestRunPreflight(t *testing.T) {
+	recent := time.Now().Add(-time.Hour).Unix()
+	var mu sync.Mutex
+	var executed []string
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		executors:    []string{"exec1", "exec2"},
+		discoverFunc: func(host string) (*RemoteNodeInfo, error) {
+			if host == "exec2" {
+				return nil, errors.New("all discovery commands failed")
+			}
+			info := &RemoteNodeInfo{LogDir: "/var/log/dremio", ConfDir: "/opt/dremio/conf", ChecksumTool: "sha256sum", GzipAvailable: true, TarAvailable: true, Files: []RemoteFileInfo{
+				{Path: "/var/log/dremio/server.log", Size: 100, FileType: "log", ModTime: recent},
+				{Path: "/var/log/dremio/server.2020-01-01.0.log.gz", Size: 50, FileType: "log", ModTime: 1},
+				{Path: "/var/log/dremio/tracker.json", Size: 30, FileType: "log", ModTime: recent},
+				{Path: "/var/log/dremio/queries.json", Size: 20, FileType: "queries", ModTime: recent},
+				{Path: "/var/log/dremio/gc.log", Size: 10, FileType: "gc-log", ModTime: recent},
+				{Path: "/opt/dremio/conf/dremio.conf", Size: 5, FileType: "config", ModTime: recent},
+			}}
+			if host == "coord1" {
+				info.DremioPID = 42
+				info.RocksDBDir = "/opt/dremio/data/db"
+			} else {
+				info.ChecksumTool = "md5sum"
+				info.TarAvailable = false
+			}
+			return info, nil
+		},
+		hostExecuteFunc: func(_ bool, host string, args ...string) (string, error) {
+			cmd := strings.Join(args, " ")
+			mu.Lock()
+			executed = append(executed, cmd)
+			mu.Unlock()
+			switch {
+			case cmd == "echo ok":
+				return "ok\n", nil
+			case strings.Contains(cmd, "jcmd 42 VM.flags"):
+				return "-XX:MaxHeapSize=8589934592 -XX:+UseG1GC", nil
+			case strings.HasPrefix(cmd, "df -P /tmp"):
+				return "Filesystem 1024-blocks Used Available Capacity Mounted on\n/dev/sda1 20971520 1048576 4194304 5% /\n", nil
+			case cmd == "test -f /opt/dremio/data/db/catalog/CURRENT && echo exists":
+				return "exists\n", nil
+			}
+			return "", errors.New("unexpected command " + cmd)
+		},
+	}
+	args := Args{CollectionMode: collects.DiagnosisCollection, DiagLogDays: 3, CollectServerLogs: true, CollectTrackerJSON: true, CollectQueriesJSON: true, CollectGCLogs: true}
+	sudo := func(string) []PreflightCheck {
+		return []PreflightCheck{{Name: PreflightCheckSudo, Status: PreflightPass}}
+	}
+
+	report, err := RunPreflight(mc, args, sudo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Nodes) != 3 || report.Nodes[0].Host != "coord1" || report.Nodes[0].NodeType != "coordinator" {
+		t.Fatalf("expected the coordinator first and both executors, got %+v", report.Nodes)
+	}
+
+	coord := preflightStatuses(report, "coord1")
+	wantCoord := map[string]PreflightStatus{
+		checkConnectivity: PreflightPass, PreflightCheckSudo: PreflightPass, checkDiscovery: PreflightPass,
+		checkChecksum: PreflightPass, checkGzip: PreflightPass, checkTar: PreflightPass, checkPID: PreflightPass,
+		checkJcmd: PreflightPass, checkHeapDumpSpace: PreflightWarn, checkRocksDBCatalog: PreflightPass,
+	}
+	if !reflect.DeepEqual(coord, wantCoord) {
+		t.Errorf("unexpected coordinator checks %v", coord)
+	}
+	wantCategories := []PreflightCategory{
+		{Category: "config", Files: 1, Bytes: 5},
+		{Category: "gc", Files: 1, Bytes: 10},
+		{Category: "queries", Files: 1, Bytes: 20},
+		{Category: "server", Files: 1, Bytes: 100},
+		{Category: "tracker", Files: 1, Bytes: 30},
+	}
+	if !reflect.DeepEqual(report.Nodes[0].Categories, wantCategories) {
+		t.Errorf("expected the same files a collection would stream, got %+v", report.Nodes[0].Categories)
+	}
+
+	exec1 := preflightStatuses(report, "exec1")
+	if exec1[checkChecksum] != PreflightWarn || exec1[checkTar] != PreflightWarn || exec1[checkPID] != PreflightWarn || exec1[checkJcmd] != PreflightWarn {
+		t.Errorf("unexpected executor checks %v", exec1)
+	}
+	if _, ok := exec1[checkRocksDBCatalog]; ok {
+		t.Error("the RocksDB catalog is only checked on coordinators")
+	}
+	if exec2 := preflightStatuses(report, "exec2"); exec2[checkDiscovery] != PreflightFail {
+		t.Errorf("expected discovery to fail on exec2, got %v", exec2)
+	}
+	if !report.Failed() {
+		t.Error("expected the report to fail")
+	}
+
+	for _, cmd := range executed {
+		for _, verb := range []string{"rm ", "mkdir", "cp ", "JFR.start", "GC.heap_dump", "Thread.print"} {
+			if strings.Contains(cmd, verb) {
+				t.Errorf("preflight ran %q, which changes the node", cmd)
+			}
+		}
+	}
+
+	var table bytes.Buffer
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatal(err)
+	}
+	out := table.String()
+	for _, want := range []string{"HEAP-DUMP-SPACE", "coord1", "4.0GB free in /tmp < -Xmx 8.0GB", "server", "100 (100B)", "total"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in the table:\n%v", want, out)
+		}
+	}
+	if err := json.NewEncoder(io.Discard).Encode(report); err != nil {
+		t.Errorf("report is not JSON serialisable: %v", err)
+	}
+}
+
+func TestRunPreflight_Unreachable(t *testing.T) {
+	discovered := false
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		hostExecuteFunc: func(_ bool, _ string, _ ...string) (string, error) {
+			return "", errors.New("connection refused")
+		},
+		discoverFunc: func(string) (*RemoteNodeInfo, error) {
+			discovered = true
+			return &RemoteNodeInfo{}, nil
+		},
+	}
+	report, err := RunPreflight(mc, Args{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := report.Nodes[0].Checks; len(got) != 1 || got[0].Name != checkConnectivity || got[0].Status != PreflightFail {
+		t.Errorf("expected only a failed connectivity check, got %+v", got)
+	}
+	if discovered || !report.Failed() {
+		t.Error("an unreachable node should fail without discovery")
+	}
+}
+
+func TestPreflightCategory(t *testing.T) {
+	tests := map[RemoteFileInfo]string{
+		{Path: "/var/log/dremio/server.2024-01-01.0.log.gz", FileType: "log"}: "server",
+		{Path: "/var/log/dremio/metadata_refresh.log", FileType: "log"}:       "metadata_refresh",
+		{Path: "/var/log/dremio/hs_err_pid123.log", FileType: "log"}:          "hs_err",
+		{Path: "/var/log/dremio/gc.log.0.current", FileType: "gc-log"}:        "gc",
+		{Path: "/var/log/dremio/queries.json", FileType: "queries"}:           "queries",
+		{Path: "/opt/dremio/conf/dremio.conf", FileType: "config"}:            "config",
+	}
+	for rf, want := range tests {
+		if got := preflightCategory(rf); got != want {
+			t.Errorf("preflightCategory(%v) = %v, want %v", rf.Path, got, want)
+		}
+	}
+}
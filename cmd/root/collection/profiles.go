@@ -32,14 +32,14 @@ const maxProblematicProfiles = 500
 
 // LogProfileArgs holds the parameters for log-based profile collection.
 type LogProfileArgs struct {
-	TmpDir           string
-	DremioEndpoint   string
-	DremioPAT        string
-	NodeName         string
-	CollectionMode   collects.CollectionMode
-	AllowInsecureSSL bool
-	RestHTTPTimeout  int
-	Hook             shutdown.Hook
+	TmpDir          string
+	DremioEndpoint  string
+	DremioPAT       string
+	NodeName        string
+	CollectionMode  collects.CollectionMode
+	RestClient      restclient.ClientConfig
+	RestHTTPTimeout int
+	Hook            shutdown.Hook
 }
 
 // RunLogBasedProfileCollection scans extracted server.log files for job IDs
@@ -137,7 +137,9 @@ func RunLogBasedProfileCollection(args LogProfileArgs) error {
 	consoleprint.UpdateResult(fmt.Sprintf("Found %d problematic job IDs, downloading profiles...", len(jobIDs)))
 
 	// Ensure the REST client is initialized.
-	restclient.InitClient(args.AllowInsecureSSL, args.RestHTTPTimeout)
+	if err := restclient.InitClientWithConfig(args.RestClient, args.RestHTTPTimeout); err != nil {
+		return fmt.Errorf("initializing REST client: %w", err)
+	}
 
 	// Ensure the job profiles output directory exists (per-node subdirectory).
 	jobProfilesDir := filepath.Join(args.TmpDir, "job-profiles", args.NodeName)
@@ -160,6 +162,7 @@ func RunLogBasedProfileCollection(args LogProfileArgs) error {
 		}
 		downloaded++
 	}
+	consoleprint.UpdateArchiveProgress(0, 0)
 
 	simplelog.Infof("log-based profile collection: %d job IDs found, %d profiles downloaded, %d failures", len(jobIDs), downloaded, failures)
 	simplelog.Info("=== LOG-BASED PROFILE COLLECTION END ===")
@@ -196,13 +199,9 @@ func downloadJobProfile(hook shutdown.Hook, endpoint, pat, outDir, jobID string)
 	apipath := "/apiv2/support/" + jobID + "/download"
 	url := endpoint + apipath
 	headers := map[string]string{"Accept": "application/octet-stream"}
-	body, err := restclient.APIRequest(hook, url, pat, "POST", headers)
-	if err != nil {
-		return err
-	}
 	filename := filepath.Join(outDir, jobID+".zip")
-	if err := os.WriteFile(filename, body, 0o600); err != nil {
-		return fmt.Errorf("unable to write profile %s: %w", jobID, err)
+	if _, err := restclient.DownloadToFile(hook, url, pat, "POST", headers, filename, consoleprint.UpdateArchiveProgress); err != nil {
+		return fmt.Errorf("unable to download profile %s: %w", jobID, err)
 	}
 	return nil
 }
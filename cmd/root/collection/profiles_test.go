@@ -70,14 +70,14 @@ func TestRunLogBasedProfileCollection_DiagnosisMode(t *testing.T) {
 	defer hook.Cleanup()
 
 	err := RunLogBasedProfileCollection(LogProfileArgs{
-		TmpDir:           tmpDir,
-		DremioEndpoint:   ts.URL,
-		DremioPAT:        "test-pat-token",
-		NodeName:         "coordinator",
-		CollectionMode:   collects.DiagnosisCollection,
-		AllowInsecureSSL: false,
-		RestHTTPTimeout:  30,
-		Hook:             hook,
+		TmpDir:          tmpDir,
+		DremioEndpoint:  ts.URL,
+		DremioPAT:       "test-pat-token",
+		NodeName:        "coordinator",
+		CollectionMode:  collects.DiagnosisCollection,
+		RestClient:      restclient.ClientConfig{AllowInsecureSSL: false},
+		RestHTTPTimeout: 30,
+		Hook:            hook,
 	})
 	if err != nil {
 		t.Fatalf("RunLogBasedProfileCollection returned error: %v", err)
@@ -116,14 +116,14 @@ func TestRunLogBasedProfileCollection_StandardMode(t *testing.T) {
 	defer hook.Cleanup()
 
 	err := RunLogBasedProfileCollection(LogProfileArgs{
-		TmpDir:           t.TempDir(), // empty dir is fine since we shouldn't touch it
-		DremioEndpoint:   "http://not-a-real-server:9047",
-		DremioPAT:        "test-pat-token",
-		NodeName:         "coordinator",
-		CollectionMode:   collects.StandardCollection,
-		AllowInsecureSSL: false,
-		RestHTTPTimeout:  30,
-		Hook:             hook,
+		TmpDir:          t.TempDir(), // empty dir is fine since we shouldn't touch it
+		DremioEndpoint:  "http://not-a-real-server:9047",
+		DremioPAT:       "test-pat-token",
+		NodeName:        "coordinator",
+		CollectionMode:  collects.StandardCollection,
+		RestClient:      restclient.ClientConfig{AllowInsecureSSL: false},
+		RestHTTPTimeout: 30,
+		Hook:            hook,
 	})
 	if err != nil {
 		t.Fatalf("expected nil error for standard mode, got: %v", err)
@@ -150,14 +150,14 @@ func TestRunLogBasedProfileCollection_NoMatches(t *testing.T) {
 	defer hook.Cleanup()
 
 	err := RunLogBasedProfileCollection(LogProfileArgs{
-		TmpDir:           tmpDir,
-		DremioEndpoint:   "http://not-a-real-server:9047",
-		DremioPAT:        "test-pat-token",
-		NodeName:         "coordinator",
-		CollectionMode:   collects.DiagnosisCollection,
-		AllowInsecureSSL: false,
-		RestHTTPTimeout:  30,
-		Hook:             hook,
+		TmpDir:          tmpDir,
+		DremioEndpoint:  "http://not-a-real-server:9047",
+		DremioPAT:       "test-pat-token",
+		NodeName:        "coordinator",
+		CollectionMode:  collects.DiagnosisCollection,
+		RestClient:      restclient.ClientConfig{AllowInsecureSSL: false},
+		RestHTTPTimeout: 30,
+		Hook:            hook,
 	})
 	if err != nil {
 		t.Fatalf("expected nil error for no-match case, got: %v", err)
@@ -170,14 +170,14 @@ func TestRunLogBasedProfileCollection_NoPAT(t *testing.T) {
 	defer hook.Cleanup()
 
 	err := RunLogBasedProfileCollection(LogProfileArgs{
-		TmpDir:           t.TempDir(),
-		DremioEndpoint:   "http://not-a-real-server:9047",
-		DremioPAT:        "",
-		NodeName:         "coordinator",
-		CollectionMode:   collects.DiagnosisCollection,
-		AllowInsecureSSL: false,
-		RestHTTPTimeout:  30,
-		Hook:             hook,
+		TmpDir:          t.TempDir(),
+		DremioEndpoint:  "http://not-a-real-server:9047",
+		DremioPAT:       "",
+		NodeName:        "coordinator",
+		CollectionMode:  collects.DiagnosisCollection,
+		RestClient:      restclient.ClientConfig{AllowInsecureSSL: false},
+		RestHTTPTimeout: 30,
+		Hook:            hook,
 	})
 	if err != nil {
 		t.Fatalf("expected nil error when no PAT provided, got: %v", err)
@@ -15,7 +15,6 @@
 package collection
 
 import (
-	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -25,7 +24,6 @@ import (
 	"time"
 
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/rockscollect"
-	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
@@ -33,67 +31,83 @@ import (
 
 const rocksdbViewerRemotePath = "/tmp/dremio-rocksdb-viewer"
 
-// dateSplitWriter routes JSON lines to per-day files based on the start_time field.
-// Records must arrive sorted by date (rocksdb-viewer guarantees this).
+// dateSplitWriter routes JSON lines to per-day gzip files based on the start_time field.
+// Records must arrive sorted by date (rocksdb-viewer guarantees this). maxBytes caps the
+// uncompressed total across all days; once reached, further lines are dropped.
 type dateSplitWriter struct {
-	dir     string
-	prefix  string
-	curDate string
-	dates   []string // ordered list of dates seen
-	file    *os.File
-	buf     *bufio.Writer
+	dir       string
+	prefix    string
+	maxBytes  int64 // <= 0 means no cap
+	written   int64
+	dropped   int
+	curDate   string
+	dates     []string // ordered list of dates seen
+	cur       *gzipLineWriter
+	collected []helpers.CollectedFile
 }
 
-func newDateSplitWriter(dir, prefix string) *dateSplitWriter {
-	return &dateSplitWriter{dir: dir, prefix: prefix}
+func newDateSplitWriter(dir, prefix string, maxBytes int64) *dateSplitWriter {
+	return &dateSplitWriter{dir: dir, prefix: prefix, maxBytes: maxBytes}
 }
 
 // WriteLine writes a JSON line to the file for its date. Extracts the date from "start_time":"...".
 func (dw *dateSplitWriter) WriteLine(line string) error {
+	n := int64(len(line)) + 1
+	if dw.maxBytes > 0 && dw.written+n > dw.maxBytes {
+		dw.dropped++
+		return nil
+	}
 	date := extractDate(line)
-	if date != dw.curDate {
+	if dw.cur == nil || date != dw.curDate {
 		if err := dw.switchFile(date); err != nil {
 			return err
 		}
 	}
-	_, err := dw.buf.WriteString(line)
-	if err != nil {
+	if err := dw.cur.WriteLine(line); err != nil {
 		return fmt.Errorf("write queries-perf line: %w", err)
 	}
-	_, err = dw.buf.WriteString("\n")
-	return err
+	dw.written += n
+	return nil
 }
 
 func (dw *dateSplitWriter) switchFile(date string) error {
-	if dw.buf != nil {
-		_ = dw.buf.Flush()
-	}
-	if dw.file != nil {
-		_ = dw.file.Close()
+	if err := dw.closeCurrent(); err != nil {
+		return err
 	}
 	dw.curDate = date
 	dw.dates = append(dw.dates, date)
-	fname := fmt.Sprintf("%s.%s.json", dw.prefix, date)
-	path := filepath.Join(dw.dir, fname)
-	f, err := os.Create(path) // #nosec G304 -- path is derived from controlled internal dir + date
+	path := filepath.Join(dw.dir, fmt.Sprintf("%s.%s.json.gz", dw.prefix, date))
+	w, err := newGzipLineWriter(path, 0)
 	if err != nil {
 		return fmt.Errorf("create date file %s: %w", path, err)
 	}
-	dw.file = f
-	dw.buf = bufio.NewWriterSize(f, 8*1024*1024)
+	dw.cur = w
 	return nil
 }
 
-func (dw *dateSplitWriter) Close() error {
-	if dw.buf != nil {
-		_ = dw.buf.Flush()
+func (dw *dateSplitWriter) closeCurrent() error {
+	if dw.cur == nil {
+		return nil
 	}
-	if dw.file != nil {
-		return dw.file.Close()
+	cf, err := dw.cur.Close()
+	dw.cur = nil
+	if err != nil {
+		return err
 	}
+	dw.collected = append(dw.collected, cf)
 	return nil
 }
 
+// Close finishes the current day's file. It is safe to call more than once.
+func (dw *dateSplitWriter) Close() error {
+	return dw.closeCurrent()
+}
+
+// Collected returns the closed per-day files with their on-disk sizes and sha256.
+func (dw *dateSplitWriter) Collected() []helpers.CollectedFile {
+	return dw.collected
+}
+
 // extractDate pulls the date (YYYY-MM-DD) from the "query_start_epoch_ms" JSON field.
 // The field value is a Unix epoch in milliseconds (numeric, no quotes).
 // Falls back to "unknown" if the field is missing or unparseable.
@@ -127,13 +141,22 @@ type RocksCollectArgs struct {
 	SystemTables        []string
 	CollectWLM          bool
 	CollectQueriesPerf  bool
-	QueriesPerfDays     int    // standard mode: from --queries-perf-num-days
-	Days                int    // diagnosis mode: from --days
-	StartDate           string // diagnosis mode (date-only, e.g. 2026-04-07)
+	Types               []string                   // --rocksdb-types; replaces the selection above when set
+	TypeOverrides       map[string]RocksDBTypeSpec // --rocksdb-types-config entries keyed by viewer type
+	Guard               HelperGuardConfig          // nice/ionice, timeout and memory ceiling for the viewer
+	QueriesPerfDays     int                        // standard mode: from --queries-perf-num-days
+	Days                int                        // diagnosis mode: from --days
+	StartDate           string                     // diagnosis mode (date-only, e.g. 2026-04-07)
 }
 
 var wlmTypes = []string{"wlm_queues", "wlm_rules", "wlm_engines", "wlm_cluster_usage"}
 
+// hasRocksDBCatalog reports whether the RocksDB catalog exists under rocksDBDir on host.
+func hasRocksDBCatalog(c Collector, host, rocksDBDir string) bool {
+	out, err := c.HostExecute(false, host, "test", "-f", rocksDBDir+"/catalog/CURRENT", "&&", "echo", "exists")
+	return err == nil && strings.Contains(out, "exists")
+}
+
 // RunRocksDBCollection runs the rocksdb-viewer on the coordinator node and returns
 // the list of files collected (for inclusion in per-node file counts and byte totals).
 func RunRocksDBCollection(args RocksCollectArgs) ([]helpers.CollectedFile, error) {
@@ -145,9 +168,8 @@ func RunRocksDBCollection(args RocksCollectArgs) ([]helpers.CollectedFile, error
 	// The RocksDB catalog (KV store) lives only on the master coordinator.
 	// Scale-out coordinators connect to it remotely and have no local catalog,
 	// so rocksdb-viewer cannot read it there — skip them silently.
-	catalogCurrent := dbPath + "/CURRENT"
-	if out, err := c.HostExecute(false, host, "test", "-f", catalogCurrent, "&&", "echo", "exists"); err != nil || !strings.Contains(out, "exists") {
-		simplelog.Infof("rocksdb: no catalog at %s on %s — skipping RocksDB-viewer collection (not a master coordinator)", catalogCurrent, host)
+	if !hasRocksDBCatalog(c, host, args.RocksDBDir) {
+		simplelog.Infof("rocksdb: no catalog at %s/CURRENT on %s — skipping RocksDB-viewer collection (not a master coordinator)", dbPath, host)
 		return nil, nil
 	}
 
@@ -186,11 +208,6 @@ func RunRocksDBCollection(args RocksCollectArgs) ([]helpers.CollectedFile, error
 		return nil, fmt.Errorf("chmod rocksdb-viewer on %s: %w", host, err)
 	}
 
-	consoleprint.UpdateNodeState(consoleprint.NodeState{
-		Node:     host,
-		StatusUX: "rocksdb-viewer ready",
-	})
-
 	defer func() {
 		consoleprint.UpdateNodeState(consoleprint.NodeState{
 			Node:     host,
@@ -201,57 +218,49 @@ func RunRocksDBCollection(args RocksCollectArgs) ([]helpers.CollectedFile, error
 		}
 	}()
 
-	// Collect cluster_stats
+	// Never run the viewer against the live catalog unless the bytes on the node are ours.
+	guard := probeHelperGuard(c, host, "rocksdb-viewer", args.Guard)
+	defer guard.write(args.CopyStrategy, args.NodeType)
+	if err := guard.verify(c, host, map[string][]byte{rocksdbViewerRemotePath: bin}); err != nil {
+		guard.note(err.Error())
+		return nil, err
+	}
+
 	consoleprint.UpdateNodeState(consoleprint.NodeState{
 		Node:     host,
-		StatusUX: "Collecting cluster stats from RocksDB",
+		StatusUX: "rocksdb-viewer ready",
 	})
-	if cf, err := collectRocksType(c, args.CopyStrategy, host, args.NodeType, dbPath, "cluster_stats", "cluster-stats", "cluster-stats.json"); err != nil {
-		simplelog.Errorf("rocksdb cluster_stats on %s: %v", host, err)
-	} else if cf != nil {
-		collected = append(collected, *cf)
-	}
-
-	// Collect system tables
-	if args.CollectSystemTables {
-		for _, table := range args.SystemTables {
-			viewerType := "sys." + table // rocksdb-viewer expects sys.version, sys.options, etc.
-			consoleprint.UpdateNodeState(consoleprint.NodeState{
-				Node:     host,
-				StatusUX: fmt.Sprintf("Collecting system table: %s", viewerType),
-			})
-			fname := fmt.Sprintf("sys.%s.json", table)
-			if cf, err := collectRocksType(c, args.CopyStrategy, host, args.NodeType, dbPath, viewerType, "system-tables", fname); err != nil {
-				simplelog.Errorf("rocksdb %s on %s: %v", viewerType, host, err)
-			} else if cf != nil {
-				collected = append(collected, *cf)
-			}
-		}
-	}
 
-	// Collect WLM
-	if args.CollectWLM {
-		for _, wt := range wlmTypes {
-			consoleprint.UpdateNodeState(consoleprint.NodeState{
-				Node:     host,
-				StatusUX: fmt.Sprintf("Collecting WLM: %s", wt),
-			})
-			fname := strings.TrimPrefix(wt, "wlm_") + ".json"
-			if cf, err := collectRocksType(c, args.CopyStrategy, host, args.NodeType, dbPath, wt, "wlm", fname); err != nil {
-				simplelog.Errorf("rocksdb %s on %s: %v", wt, host, err)
-			} else if cf != nil {
-				collected = append(collected, *cf)
-			}
+	types, queriesPerf := selectedRocksDBTypes(args)
+	supported, capabilityKnown := queryRocksDBCapabilities(c, host, guard)
+	for _, name := range types {
+		if capabilityKnown && !supportsRocksDBType(supported, name) {
+			simplelog.Warningf("rocksdb-viewer on %s does not support -type %s — skipping", host, name)
+			continue
+		}
+		spec := resolveRocksDBType(name, args.TypeOverrides)
+		consoleprint.UpdateNodeState(consoleprint.NodeState{
+			Node:     host,
+			StatusUX: fmt.Sprintf("Collecting %s from RocksDB", name),
+		})
+		if cf, err := collectRocksType(c, args.CopyStrategy, host, args.NodeType, dbPath, spec, guard); err != nil {
+			simplelog.Errorf("rocksdb %s on %s: %v", name, host, err)
+		} else if cf != nil {
+			collected = append(collected, *cf)
 		}
 	}
 
 	// Collect queries-perf
-	if args.CollectQueriesPerf {
+	if queriesPerf {
+		if capabilityKnown && !supportsRocksDBType(supported, RocksDBTypeQueriesPerf) {
+			simplelog.Warningf("rocksdb-viewer on %s does not support -type %s — skipping", host, RocksDBTypeQueriesPerf)
+			return collected, nil
+		}
 		consoleprint.UpdateNodeState(consoleprint.NodeState{
 			Node:     host,
 			StatusUX: "Collecting queries-perf from RocksDB",
 		})
-		if files, err := collectQueriesPerf(c, args.CopyStrategy, host, args.NodeType, dbPath, args); err != nil {
+		if files, err := collectQueriesPerf(c, args.CopyStrategy, host, args.NodeType, dbPath, args, guard); err != nil {
 			simplelog.Errorf("rocksdb queries_perf on %s: %v", host, err)
 		} else {
 			collected = append(collected, files...)
@@ -261,27 +270,93 @@ func RunRocksDBCollection(args RocksCollectArgs) ([]helpers.CollectedFile, error
 	return collected, nil
 }
 
-func collectRocksType(c Collector, cs CopyStrategy, host, nodeType, dbPath, dataType, strategyType, filename string) (*helpers.CollectedFile, error) {
-	cmdStr := fmt.Sprintf("%s -db %s -type %s", rocksdbViewerRemotePath, dbPath, dataType)
-	out, err := c.HostExecute(false, host, cmdStr)
+// queryRocksDBCapabilities asks the viewer which -type values it supports. When the
+// viewer predates -list-types the second return is false and no filtering is applied.
+func queryRocksDBCapabilities(c Collector, host string, guard *helperGuard) (map[string]bool, bool) {
+	out, err := guard.run(c, host, "-list-types", rocksdbViewerRemotePath+" -list-types", time.Minute)
 	if err != nil {
-		return nil, fmt.Errorf("execute rocksdb-viewer -type %s: %w", dataType, err)
+		simplelog.Warningf("rocksdb-viewer -list-types failed on %s: %v (assuming all requested types are supported)", host, err)
+		return nil, false
+	}
+	supported := parseRocksDBCapabilities(out)
+	if len(supported) == 0 {
+		simplelog.Warningf("rocksdb-viewer -list-types returned no types on %s (assuming all requested types are supported)", host)
+		return nil, false
+	}
+	simplelog.Debugf("rocksdb-viewer on %s supports %d types", host, len(supported))
+	return supported, true
+}
+
+// supportsRocksDBType matches a type exactly or against a "prefix*" capability such as "sys.*".
+func supportsRocksDBType(supported map[string]bool, name string) bool {
+	if supported[name] {
+		return true
+	}
+	for capability := range supported {
+		if prefix, ok := strings.CutSuffix(capability, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// collectRocksType streams one viewer -type into <dir>/<host>/<file>.gz. Nothing is
+// written when the viewer produces no output.
+func collectRocksType(c Collector, cs CopyStrategy, host, nodeType, dbPath string, spec RocksDBTypeSpec, guard *helperGuard) (*helpers.CollectedFile, error) {
+	timeout := spec.Timeout(guard.cfg.Timeout())
+	cmdStr := fmt.Sprintf("%s -db %s -type %s", rocksdbViewerRemotePath, dbPath, spec.Type)
+	var w *gzipLineWriter
+	var writeErr error
+	handler := func(line string) {
+		if writeErr != nil {
+			return
+		}
+		if w == nil {
+			if strings.TrimSpace(line) == "" {
+				return
+			}
+			destDir, err := cs.CreatePath(spec.Dir, host, nodeType)
+			if err != nil {
+				writeErr = fmt.Errorf("create path for %s: %w", spec.Dir, err)
+				return
+			}
+			if w, err = newGzipLineWriter(filepath.Join(destDir, spec.File+".gz"), spec.SizeCap()); err != nil {
+				writeErr = err
+				return
+			}
+		}
+		writeErr = w.WriteLine(line)
 	}
-	if strings.TrimSpace(out) == "" {
-		simplelog.Infof("rocksdb-viewer -type %s returned empty output on %s", dataType, host)
+	err := guard.stream(c, host, spec.Type, cmdStr, timeout, handler)
+	if w == nil {
+		if err != nil {
+			return nil, fmt.Errorf("execute rocksdb-viewer -type %s (timeout %v): %w", spec.Type, timeout, err)
+		}
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		simplelog.Infof("rocksdb-viewer -type %s returned empty output on %s", spec.Type, host)
 		return nil, nil
 	}
-	destDir, err := cs.CreatePath(strategyType, host, nodeType)
-	if err != nil {
-		return nil, fmt.Errorf("create path for %s: %w", strategyType, err)
+	cf, closeErr := w.Close()
+	if err != nil || writeErr != nil || closeErr != nil {
+		// a partial stream is not a usable extract
+		if rmErr := os.Remove(w.path); rmErr != nil {
+			simplelog.Warningf("unable to remove partial %s: %v", w.path, rmErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("execute rocksdb-viewer -type %s (timeout %v): %w", spec.Type, timeout, err)
+		}
+		if writeErr != nil {
+			return nil, writeErr
+		}
+		return nil, closeErr
 	}
-	destPath := filepath.Join(destDir, filename)
-	if err := os.WriteFile(destPath, []byte(out), 0600); err != nil {
-		return nil, fmt.Errorf("write %s: %w", destPath, err)
+	if w.Truncated() {
+		simplelog.Warningf("rocksdb-viewer -type %s output on %s reached the %d byte cap, kept %d lines (%d bytes) and dropped %d", spec.Type, host, spec.SizeCap(), w.lines, w.written, w.dropped)
 	}
-	size := int64(len(out))
-	simplelog.Infof("rocksdb-viewer: collected %s -> %s (%d bytes)", dataType, destPath, size)
-	return &helpers.CollectedFile{Path: destPath, Size: size}, nil
+	simplelog.Infof("rocksdb-viewer: collected %s -> %s (%d bytes, %d compressed, sha256 %s)", spec.Type, cf.Path, w.written, cf.Size, cf.SHA256)
+	return &cf, nil
 }
 
 // buildQueriesPerfFilterArgs returns the day/date filter portion of the rocksdb-viewer command.
@@ -302,7 +377,7 @@ func buildQueriesPerfFilterArgs(args RocksCollectArgs) string {
 	return ""
 }
 
-func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath string, args RocksCollectArgs) ([]helpers.CollectedFile, error) {
+func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath string, args RocksCollectArgs, guard *helperGuard) ([]helpers.CollectedFile, error) {
 	filterArgs := buildQueriesPerfFilterArgs(args)
 	simplelog.Infof("rocksdb-viewer queries_perf filter: QueriesPerfDays=%d Days=%d StartDate=%q filterArgs=%q", args.QueriesPerfDays, args.Days, args.StartDate, filterArgs)
 
@@ -312,7 +387,7 @@ func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath str
 		StatusUX: "Getting number of queries for queries-perf",
 	})
 	countCmd := fmt.Sprintf("%s -db %s -type queries_perf -count%s", rocksdbViewerRemotePath, dbPath, filterArgs)
-	countOut, err := c.HostExecute(false, host, countCmd)
+	countOut, err := guard.run(c, host, "queries_perf -count", countCmd, guard.cfg.Timeout())
 	if err != nil {
 		simplelog.Warningf("rocksdb-viewer queries_perf -count failed on %s: %v (continuing without progress)", host, err)
 	}
@@ -335,7 +410,12 @@ func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath str
 		return nil, fmt.Errorf("create path for queries-perf: %w", err)
 	}
 
-	dw := newDateSplitWriter(destDir, "queries-perf")
+	spec := resolveRocksDBType(RocksDBTypeQueriesPerf, args.TypeOverrides)
+	maxBytes := DefaultQueriesPerfMaxBytes
+	if spec.MaxBytes > 0 {
+		maxBytes = spec.MaxBytes
+	}
+	dw := newDateSplitWriter(destDir, "queries-perf", maxBytes)
 	defer dw.Close()
 
 	var mu sync.Mutex
@@ -387,12 +467,20 @@ func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath str
 	}
 
 	dataCmd := fmt.Sprintf("%s -db %s -type queries_perf%s", rocksdbViewerRemotePath, dbPath, filterArgs)
-	if err := c.HostExecuteAndStream(false, host, cli.OutputHandler(handler), "", dataCmd); err != nil {
+	timeout := spec.Timeout(guard.cfg.Timeout())
+	err = guard.stream(c, host, RocksDBTypeQueriesPerf, dataCmd, timeout, handler)
+	if err != nil {
 		return nil, fmt.Errorf("execute rocksdb-viewer queries_perf: %w", err)
 	}
 	if writeErr != nil {
 		return nil, writeErr
 	}
+	if err := dw.Close(); err != nil {
+		return nil, fmt.Errorf("close queries-perf: %w", err)
+	}
+	if dw.dropped > 0 {
+		simplelog.Warningf("rocksdb-viewer queries_perf on %s reached the %d byte cap, dropped the last %d records", host, maxBytes, dw.dropped)
+	}
 
 	// Final status update
 	if totalRecords > 0 {
@@ -407,15 +495,7 @@ func collectQueriesPerf(c Collector, cs CopyStrategy, host, nodeType, dbPath str
 		})
 	}
 
-	// Collect file info for all date files
-	var collected []helpers.CollectedFile
-	for _, date := range dw.dates {
-		path := filepath.Join(destDir, fmt.Sprintf("queries-perf.%s.json", date))
-		if fi, err := os.Stat(path); err == nil {
-			collected = append(collected, helpers.CollectedFile{Path: path, Size: fi.Size()})
-		}
-	}
-
+	collected := dw.Collected()
 	simplelog.Infof("rocksdb-viewer: collected queries_perf -> %s (%d files, %d records)", destDir, len(dw.dates), lineCount)
 	return collected, nil
 }
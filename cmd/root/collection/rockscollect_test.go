@@ -15,7 +15,10 @@
 package collection
 
 import (
+	"compress/gzip"
+	"crypto/sha256"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -24,7 +27,7 @@ import (
 
 func TestDateSplitWriter_SingleDay(t *testing.T) {
 	dir := t.TempDir()
-	dw := newDateSplitWriter(dir, "queries-perf")
+	dw := newDateSplitWriter(dir, "queries-perf", 0)
 	defer dw.Close()
 
 	// 1776352188426 ms = 2026-04-16 UTC
@@ -35,9 +38,9 @@ func TestDateSplitWriter_SingleDay(t *testing.T) {
 	}
 	dw.Close()
 
-	data, err := os.ReadFile(filepath.Join(dir, "queries-perf.2026-04-16.json"))
+	data, err := readGzipFile(filepath.Join(dir, "queries-perf.2026-04-16.json.gz"))
 	if err != nil {
-		t.Fatalf("expected queries-perf.2026-04-14.json: %v", err)
+		t.Fatalf("expected queries-perf.2026-04-16.json.gz: %v", err)
 	}
 	lines := countLines(data)
 	if lines != 5 {
@@ -50,7 +53,7 @@ func TestDateSplitWriter_SingleDay(t *testing.T) {
 
 func TestDateSplitWriter_MultipleDays(t *testing.T) {
 	dir := t.TempDir()
-	dw := newDateSplitWriter(dir, "queries-perf")
+	dw := newDateSplitWriter(dir, "queries-perf", 0)
 	defer dw.Close()
 
 	// Epoch ms values for specific dates (UTC):
@@ -73,7 +76,7 @@ func TestDateSplitWriter_MultipleDays(t *testing.T) {
 	// Check each file has the right number of lines
 	want := map[string]int{"2026-04-12": 2, "2026-04-13": 3, "2026-04-14": 1}
 	for date, wantLines := range want {
-		data, err := os.ReadFile(filepath.Join(dir, "queries-perf."+date+".json"))
+		data, err := readGzipFile(filepath.Join(dir, "queries-perf."+date+".json.gz"))
 		if err != nil {
 			t.Fatalf("expected file for %s: %v", date, err)
 		}
@@ -86,15 +89,67 @@ func TestDateSplitWriter_MultipleDays(t *testing.T) {
 
 func TestDateSplitWriter_EmptyInput(t *testing.T) {
 	dir := t.TempDir()
-	dw := newDateSplitWriter(dir, "queries-perf")
+	dw := newDateSplitWriter(dir, "queries-perf", 0)
 	dw.Close()
 
-	files, _ := filepath.Glob(filepath.Join(dir, "queries-perf.*.json"))
+	files, _ := filepath.Glob(filepath.Join(dir, "queries-perf.*"))
 	if len(files) != 0 {
 		t.Errorf("expected 0 files for empty input, got %d", len(files))
 	}
 }
 
+func TestDateSplitWriter_CapAndChecksums(t *testing.T) {
+	dir := t.TempDir()
+	line := `{"query_id":"abc","query_start_epoch_ms":1776002400000}`
+	// room for three lines in total, spread over two days
+	dw := newDateSplitWriter(dir, "queries-perf", int64(3*(len(line)+1)))
+	for _, ep := range []int64{1776002400000, 1776002400000, 1776088800000, 1776088800000, 1776088800000} {
+		if err := dw.WriteLine(fmt.Sprintf(`{"query_id":"abc","query_start_epoch_ms":%d}`, ep)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+	if err := dw.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+	if dw.dropped != 2 {
+		t.Errorf("expected 2 dropped lines, got %d", dw.dropped)
+	}
+	files := dw.Collected()
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+	for _, cf := range files {
+		raw, err := os.ReadFile(cf.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cf.Size != int64(len(raw)) || cf.SHA256 != fmt.Sprintf("%x", sha256.Sum256(raw)) {
+			t.Errorf("%s: size/sha256 %d/%s do not match the file on disk", cf.Path, cf.Size, cf.SHA256)
+		}
+	}
+	data, err := readGzipFile(filepath.Join(dir, "queries-perf.2026-04-13.json.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countLines(data) != 1 {
+		t.Errorf("expected 1 line kept for the second day, got %d", countLines(data))
+	}
+}
+
+func readGzipFile(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
 func TestExtractDate(t *testing.T) {
 	tests := []struct {
 		name string
@@ -260,10 +315,10 @@ func TestWLMFileLayout(t *testing.T) {
 	}
 
 	wantContent := map[string]string{
-		"queues.json":        `{"queues":[]}`,
-		"rules.json":         `{"rules":[]}`,
-		"engines.json":       `{"engines":[]}`,
-		"cluster_usage.json": `{"cluster_usage":[]}`,
+		"queues.json.gz":        `{"queues":[]}` + "\n",
+		"rules.json.gz":         `{"rules":[]}` + "\n",
+		"engines.json.gz":       `{"engines":[]}` + "\n",
+		"cluster_usage.json.gz": `{"cluster_usage":[]}` + "\n",
 	}
 
 	var wlmDir string
@@ -278,7 +333,7 @@ func TestWLMFileLayout(t *testing.T) {
 		if wlmDir == "" {
 			wlmDir = filepath.Dir(cf.Path)
 		}
-		data, err := os.ReadFile(cf.Path)
+		data, err := readGzipFile(cf.Path)
 		if err != nil {
 			t.Errorf("read %s: %v", cf.Path, err)
 			continue
@@ -295,7 +350,7 @@ func TestWLMFileLayout(t *testing.T) {
 	if wlmDir == "" {
 		t.Fatal("no WLM files were returned, cannot perform negative-glob check")
 	}
-	leaks, err := filepath.Glob(filepath.Join(wlmDir, "wlm_*.json"))
+	leaks, err := filepath.Glob(filepath.Join(wlmDir, "wlm_*"))
 	if err != nil {
 		t.Fatalf("glob failed: %v", err)
 	}
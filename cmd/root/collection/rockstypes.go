@@ -0,0 +1,209 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// RocksDBTypeQueriesPerf is streamed and split per day rather than written as a single file.
+	RocksDBTypeQueriesPerf = "queries_perf"
+	// DefaultRocksDBTypeMaxBytes caps the output kept for a single rocksdb-viewer -type run.
+	DefaultRocksDBTypeMaxBytes int64 = 256 * 1024 * 1024
+	// DefaultQueriesPerfMaxBytes caps queries_perf, which spans many days and is larger than any single type.
+	DefaultQueriesPerfMaxBytes int64 = 4 * 1024 * 1024 * 1024
+)
+
+var reRocksDBTypeName = regexp.MustCompile(`^[A-Za-z0-9_.\-]+$`)
+
+// RocksDBTypeSpec maps a rocksdb-viewer -type to the directory and file it is
+// written to in the archive, with its own timeout and size cap.
+type RocksDBTypeSpec struct {
+	Type           string `json:"type"`
+	Dir            string `json:"dir"`
+	File           string `json:"file"`
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+	MaxBytes       int64  `json:"maxBytes,omitempty"`
+}
+
+// Timeout returns the configured timeout or the helper guard default.
+func (r RocksDBTypeSpec) Timeout(fallback time.Duration) time.Duration {
+	if r.TimeoutSeconds > 0 {
+		return time.Duration(r.TimeoutSeconds) * time.Second
+	}
+	return fallback
+}
+
+// SizeCap returns the configured size cap or DefaultRocksDBTypeMaxBytes.
+func (r RocksDBTypeSpec) SizeCap() int64 {
+	if r.MaxBytes > 0 {
+		return r.MaxBytes
+	}
+	return DefaultRocksDBTypeMaxBytes
+}
+
+// rocksDBTypesFile is the on-disk layout of --rocksdb-types-config.
+type rocksDBTypesFile struct {
+	RocksDBTypes []RocksDBTypeSpec `json:"rocksdbTypes"`
+}
+
+// LoadRocksDBTypesConfig reads the rocksdbTypes section of a YAML or JSON file and
+// returns the entries keyed by viewer type. Entries override the built-in mapping.
+func LoadRocksDBTypesConfig(path string) (map[string]RocksDBTypeSpec, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read rocksdb types config %v: %w", path, err)
+	}
+	var f rocksDBTypesFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse rocksdb types config %v: %w", path, err)
+	}
+	specs := make(map[string]RocksDBTypeSpec, len(f.RocksDBTypes))
+	for i, spec := range f.RocksDBTypes {
+		if err := ValidateRocksDBTypeName(spec.Type); err != nil {
+			return nil, fmt.Errorf("rocksdbTypes[%d]: %w", i, err)
+		}
+		if spec.Dir != "" && (filepath.IsAbs(spec.Dir) || strings.Contains(spec.Dir, "..") || strings.ContainsAny(spec.Dir, `/\`)) {
+			return nil, fmt.Errorf("rocksdbTypes[%d]: dir %q must be a single directory name", i, spec.Dir)
+		}
+		if spec.File != "" && filepath.Base(spec.File) != spec.File {
+			return nil, fmt.Errorf("rocksdbTypes[%d]: file %q must be a plain file name", i, spec.File)
+		}
+		if spec.TimeoutSeconds < 0 || spec.MaxBytes < 0 {
+			return nil, fmt.Errorf("rocksdbTypes[%d]: timeoutSeconds and maxBytes must not be negative", i)
+		}
+		specs[spec.Type] = spec
+	}
+	return specs, nil
+}
+
+// ValidateRocksDBTypeName rejects names that cannot be passed safely to rocksdb-viewer -type.
+func ValidateRocksDBTypeName(name string) error {
+	if !reRocksDBTypeName.MatchString(name) {
+		return fmt.Errorf("invalid rocksdb-viewer type %q", name)
+	}
+	return nil
+}
+
+// ParseRocksDBTypes splits the comma-separated --rocksdb-types value and validates each entry.
+func ParseRocksDBTypes(value string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if err := ValidateRocksDBTypeName(t); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// resolveRocksDBType returns the spec for a viewer type: a configured override when
+// present, otherwise the built-in layout (cluster-stats, system-tables, wlm), and
+// rocksdb/<type>.json for anything else. Missing override fields fall back to the built-in values.
+func resolveRocksDBType(name string, overrides map[string]RocksDBTypeSpec) RocksDBTypeSpec {
+	spec := RocksDBTypeSpec{Type: name, Dir: "rocksdb", File: name + ".json"}
+	switch {
+	case name == "cluster_stats":
+		spec.Dir, spec.File = "cluster-stats", "cluster-stats.json"
+	case strings.HasPrefix(name, "sys."):
+		spec.Dir = "system-tables"
+	case strings.HasPrefix(name, "wlm_"):
+		spec.Dir, spec.File = "wlm", strings.TrimPrefix(name, "wlm_")+".json"
+	}
+	if o, ok := overrides[name]; ok {
+		if o.Dir != "" {
+			spec.Dir = o.Dir
+		}
+		if o.File != "" {
+			spec.File = o.File
+		}
+		spec.TimeoutSeconds = o.TimeoutSeconds
+		spec.MaxBytes = o.MaxBytes
+	}
+	return spec
+}
+
+// isBuiltinRocksDBType reports whether a viewer type is one DDC selects itself
+// (cluster_stats, queries_perf, sys.* and the WLM types). A --rocksdb-types-config
+// entry for one of them only changes its layout, timeout or size cap.
+func isBuiltinRocksDBType(name string) bool {
+	return name == "cluster_stats" || name == RocksDBTypeQueriesPerf || strings.HasPrefix(name, "sys.") || slices.Contains(wlmTypes, name)
+}
+
+// selectedRocksDBTypes returns the single-file viewer types to extract and whether
+// queries_perf should be streamed. An explicit Types list replaces the selection
+// derived from the system table and WLM toggles. Types defined only in
+// TypeOverrides are extracted as well, so the config file can add new types.
+func selectedRocksDBTypes(args RocksCollectArgs) ([]string, bool) {
+	var types []string
+	queriesPerf := args.CollectQueriesPerf
+	if len(args.Types) > 0 {
+		queriesPerf = false
+		for _, t := range args.Types {
+			if t == RocksDBTypeQueriesPerf {
+				queriesPerf = true
+				continue
+			}
+			types = append(types, t)
+		}
+	} else {
+		types = append(types, "cluster_stats")
+		if args.CollectSystemTables {
+			for _, table := range args.SystemTables {
+				types = append(types, "sys."+table) // rocksdb-viewer expects sys.version, sys.options, etc.
+			}
+		}
+		if args.CollectWLM {
+			types = append(types, wlmTypes...)
+		}
+	}
+	var added []string
+	for name := range args.TypeOverrides {
+		if !isBuiltinRocksDBType(name) && !slices.Contains(types, name) {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	return append(types, added...), queriesPerf
+}
+
+// parseRocksDBCapabilities parses the output of rocksdb-viewer -list-types, which
+// lists one type per line (commas and extra whitespace are tolerated). A trailing
+// "*" marks a family such as "sys.*".
+func parseRocksDBCapabilities(out string) map[string]bool {
+	supported := make(map[string]bool)
+	for _, field := range strings.FieldsFunc(out, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	}) {
+		if reRocksDBTypeName.MatchString(strings.TrimSuffix(field, "*")) {
+			supported[field] = true
+		}
+	}
+	return supported
+}
@@ -0,0 +1,187 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/rockscollect"
+)
+
+func TestLoadRocksDBTypesConfig(t *testing.T) {
+	cfg := filepath.Join(t.TempDir(), "rocksdb-types.yaml")
+	content := `rocksdbTypes:
+  - type: sys.jobs_recent
+    timeoutSeconds: 600
+    maxBytes: 1048576
+  - type: reflection_entries
+    dir: reflections
+    file: entries.json
+`
+	if err := os.WriteFile(cfg, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	specs, err := LoadRocksDBTypesConfig(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jobs := resolveRocksDBType("sys.jobs_recent", specs)
+	if jobs.Dir != "system-tables" || jobs.File != "sys.jobs_recent.json" || jobs.SizeCap() != 1048576 || jobs.Timeout(time.Minute).Seconds() != 600 {
+		t.Errorf("unexpected spec for sys.jobs_recent: %+v", jobs)
+	}
+	entries := resolveRocksDBType("reflection_entries", specs)
+	if entries.Dir != "reflections" || entries.File != "entries.json" || entries.Timeout(time.Minute) != time.Minute {
+		t.Errorf("unexpected spec for reflection_entries: %+v", entries)
+	}
+}
+
+func TestLoadRocksDBTypesConfigRejectsUnsafeEntries(t *testing.T) {
+	for _, content := range []string{
+		"rocksdbTypes:\n  - type: \"x; rm -rf /\"\n",
+		"rocksdbTypes:\n  - type: ok\n    dir: ../escape\n",
+		"rocksdbTypes:\n  - type: ok\n    file: sub/file.json\n",
+	} {
+		cfg := filepath.Join(t.TempDir(), "rocksdb-types.yaml")
+		if err := os.WriteFile(cfg, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRocksDBTypesConfig(cfg); err == nil {
+			t.Errorf("expected an error for %q", content)
+		}
+	}
+}
+
+func TestResolveRocksDBTypeBuiltins(t *testing.T) {
+	tests := map[string][2]string{
+		"cluster_stats": {"cluster-stats", "cluster-stats.json"},
+		"sys.options":   {"system-tables", "sys.options.json"},
+		"wlm_rules":     {"wlm", "rules.json"},
+		"something_new": {"rocksdb", "something_new.json"},
+	}
+	for name, want := range tests {
+		spec := resolveRocksDBType(name, nil)
+		if spec.Dir != want[0] || spec.File != want[1] {
+			t.Errorf("%s: expected %v/%v, got %v/%v", name, want[0], want[1], spec.Dir, spec.File)
+		}
+	}
+}
+
+func TestSelectedRocksDBTypes(t *testing.T) {
+	types, qp := selectedRocksDBTypes(RocksCollectArgs{CollectSystemTables: true, SystemTables: []string{"version"}, CollectWLM: true})
+	want := append([]string{"cluster_stats", "sys.version"}, wlmTypes...)
+	if !reflect.DeepEqual(types, want) || qp {
+		t.Errorf("unexpected default selection: %v %v", types, qp)
+	}
+	types, qp = selectedRocksDBTypes(RocksCollectArgs{CollectWLM: true, Types: []string{"sys.options", "queries_perf"}})
+	if !reflect.DeepEqual(types, []string{"sys.options"}) || !qp {
+		t.Errorf("expected explicit types to replace the selection, got %v %v", types, qp)
+	}
+	overrides := map[string]RocksDBTypeSpec{"reflection_deps": {Type: "reflection_deps"}, "sys.options": {Type: "sys.options", MaxBytes: 1}, "queries_perf": {Type: "queries_perf", MaxBytes: 1}}
+	types, qp = selectedRocksDBTypes(RocksCollectArgs{TypeOverrides: overrides})
+	if !reflect.DeepEqual(types, []string{"cluster_stats", "reflection_deps"}) || qp {
+		t.Errorf("expected only the new config type added, got %v %v", types, qp)
+	}
+	types, _ = selectedRocksDBTypes(RocksCollectArgs{Types: []string{"sys.options"}, TypeOverrides: overrides})
+	if !reflect.DeepEqual(types, []string{"sys.options", "reflection_deps"}) {
+		t.Errorf("expected the config type added to explicit types, got %v", types)
+	}
+}
+
+func TestRunRocksDBCollectionHonoursCapabilitiesAndCaps(t *testing.T) {
+	viewerBytes, err := rockscollect.GetRocksDBViewerBinary("x86_64")
+	if err != nil {
+		t.Skipf("embedded rocksdb-viewer unavailable: %v", err)
+	}
+	tmpDir := t.TempDir()
+	var calls []string
+	mc := &mockStreamCollector{
+		hostExecuteFunc: func(_ bool, _ string, args ...string) (string, error) {
+			cmd := strings.Join(args, " ")
+			calls = append(calls, cmd)
+			switch {
+			case strings.HasPrefix(cmd, "test -f"):
+				return "exists", nil
+			case strings.Contains(cmd, "uname -m"):
+				return "x86_64\n", nil
+			case strings.Contains(cmd, "-list-types"):
+				return "cluster_stats\nsys.*\nwlm_rules\n", nil
+			case strings.Contains(cmd, "command -v $t"):
+				return "tool=nice\ntool=timeout\ntool=sha256sum\nuid=1000\n", nil
+			case strings.HasPrefix(cmd, "sha256sum "):
+				return fmt.Sprintf("%x  %s\n", sha256.Sum256(viewerBytes), rocksdbViewerRemotePath), nil
+			case strings.Contains(cmd, "-type sys.options"):
+				return strings.Repeat(`{"name":"opt"}`+"\n", 10), nil
+			case strings.Contains(cmd, "-type cluster_stats"):
+				return `{"cluster":"stub"}`, nil
+			case strings.Contains(cmd, "chmod +x"), strings.Contains(cmd, "rm -f"):
+				return "", nil
+			}
+			return "", fmt.Errorf("unexpected host command: %s", cmd)
+		},
+		copyToHostFunc: func(_, _, _ string) (string, error) { return "", nil },
+	}
+	files, err := RunRocksDBCollection(RocksCollectArgs{
+		Collector:    mc,
+		CopyStrategy: &mockCopyStrategy{tmpDir: tmpDir},
+		Host:         "dremio-master-0",
+		NodeType:     "coordinator",
+		RocksDBDir:   "/opt/dremio/data/db",
+		Types:        []string{"cluster_stats", "sys.options", "unsupported_type"},
+		Guard:        HelperGuardConfig{MemoryLimitMB: 1024},
+		TypeOverrides: map[string]RocksDBTypeSpec{
+			"sys.options": {Type: "sys.options", MaxBytes: 40, TimeoutSeconds: 42},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RunRocksDBCollection failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("expected 2 files, got %v", files)
+	}
+	for _, c := range calls {
+		if strings.Contains(c, "unsupported_type") {
+			t.Errorf("unsupported type should not be executed: %q", c)
+		}
+		if strings.Contains(c, "-type sys.options") && !strings.Contains(c, "timeout -k 10 42 nice -n 19 ") {
+			t.Errorf("expected per-type timeout on %q", c)
+		}
+	}
+	for _, cf := range files {
+		if !strings.HasSuffix(cf.Path, ".json.gz") || cf.SHA256 == "" {
+			t.Errorf("expected a hashed gzip file, got %+v", cf)
+		}
+	}
+	data, err := readGzipFile(filepath.Join(tmpDir, "system-tables", "dremio-master-0", "sys.options.json.gz"))
+	if err != nil {
+		t.Fatalf("expected sys.options.json.gz: %v", err)
+	}
+	if len(data) > 40 || !strings.HasSuffix(string(data), "\n") {
+		t.Errorf("expected output capped to whole lines within 40 bytes, got %d bytes: %q", len(data), string(data))
+	}
+	record, err := os.ReadFile(filepath.Join(tmpDir, "helper-guards", "dremio-master-0", "rocksdb-viewer.json"))
+	if err != nil {
+		t.Fatalf("expected helper guard record: %v", err)
+	}
+	if !strings.Contains(string(record), `"sha256Verified": true`) || !strings.Contains(string(record), `"memoryLimitMethod": "ulimit"`) {
+		t.Errorf("unexpected guard record: %s", record)
+	}
+}
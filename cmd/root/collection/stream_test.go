@@ -46,6 +46,12 @@ func (m *mockCollectorForStream) CleanupRemote() error   { return nil }
 func (m *mockCollectorForStream) StreamFromHost(host, remotePath string, writer io.Writer, _ bool) error {
 	return m.streamFn(host, remotePath, writer)
 }
+func (m *mockCollectorForStream) StreamFromHostAt(host, remotePath string, _ int64, writer io.Writer, _ bool) error {
+	return m.streamFn(host, remotePath, writer)
+}
+func (m *mockCollectorForStream) StreamCommandFromHost(_, _ string, _ io.Writer) error {
+	return nil
+}
 
 // TestStreamFromHost_BinaryIntegrity verifies that binary data including \n, \0,
 // and 0xFF bytes pass through StreamFromHost unchanged (mock simulating K8s transport).
@@ -140,3 +140,27 @@ func (p *StderrParser) LastSeen() time.Time {
 	defer p.mu.Unlock()
 	return p.lastSeen
 }
+
+// RemoteReadCommand returns the sh command that writes remotePath to stdout starting
+// offset bytes in, piped through compress (e.g. "gzip -c") when that is set. The path
+// is single-quoted to prevent shell injection.
+func RemoteReadCommand(remotePath string, offset int64, compress string) string {
+	quoted := shellQuote(remotePath)
+	if offset <= 0 {
+		if compress != "" {
+			return compress + " " + quoted
+		}
+		return "cat " + quoted
+	}
+	// tail -c +N starts at byte N counting from 1; GNU, busybox and BSD tail all take it
+	read := fmt.Sprintf("tail -c +%d %s", offset+1, quoted)
+	if compress != "" {
+		return read + " | " + compress
+	}
+	return read
+}
+
+// shellQuote single-quotes s for sh, escaping embedded single quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "'\\''") + "'"
+}
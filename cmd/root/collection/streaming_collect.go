@@ -25,6 +25,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -41,6 +42,7 @@ import (
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/versions"
+	"github.com/google/uuid"
 )
 
 // maxRetries is the number of retry attempts for transient streaming errors.
@@ -90,14 +92,7 @@ func hashFileInBackground(path, checksumTool string) <-chan hashResult {
 		}
 		defer f.Close() //nolint:errcheck
 
-		var h hash.Hash
-		switch checksumTool {
-		case "md5sum":
-			h = md5.New() // #nosec G401 -- MD5 used as checksum fallback, not for security
-		default: // sha256sum or any unrecognised tool — default to sha256
-			h = sha256.New()
-		}
-
+		h := newChecksumHash(checksumTool)
 		if _, err := io.Copy(h, f); err != nil {
 			ch <- hashResult{err: fmt.Errorf("hash: read %v: %w", path, err)}
 			return
@@ -107,6 +102,17 @@ func hashFileInBackground(path, checksumTool string) <-chan hashResult {
 	return ch
 }
 
+// newChecksumHash returns the local hash matching the remote checksumTool
+// ("sha256sum" or "md5sum").
+func newChecksumHash(checksumTool string) hash.Hash {
+	switch checksumTool {
+	case "md5sum":
+		return md5.New() // #nosec G401 -- MD5 used as checksum fallback, not for security
+	default: // sha256sum or any unrecognised tool — default to sha256
+		return sha256.New()
+	}
+}
+
 // getAsprofBinaryFn is a package-level function used by runJVMCollection to
 // resolve the asprof binary for a given architecture. It defaults to the real
 // jvmcollect.GetAsprofBinary but can be overridden in tests.
@@ -173,27 +179,35 @@ func fileTypeToStrategyType(ft string) string {
 }
 
 // streamFile streams a single remote file from host to a local destination path.
-// It retries up to maxRetries times on transient errors and returns the bytes
-// written along with a channel that will receive the hash result. On permanent
-// error it returns immediately without retrying. Progress is reported to the
-// TUI via progressWriter.
+// It retries up to maxRetries times on transient errors, resuming each retry from
+// the bytes already on disk rather than from the start, and returns the bytes
+// written along with a channel that will receive the hash of the whole file. On
+// permanent error it returns immediately without retrying. Progress is reported to
+// the TUI via progressWriter.
 func streamFile(c Collector, host, remotePath, destPath string, retries int, expectedSize int64, filename, checksumTool string, useGzip bool) (int64, <-chan hashResult, error) {
 	var lastErr error
+	var offset int64
 	for attempt := 1; attempt <= retries; attempt++ {
 		if attempt > 1 {
-			simplelog.Infof("stream retry %d/%d for %v:%v", attempt, retries, host, remotePath)
+			if offset > 0 {
+				simplelog.Infof("stream retry %d/%d for %v:%v resuming at byte %d", attempt, retries, host, remotePath, offset)
+			} else {
+				simplelog.Infof("stream retry %d/%d for %v:%v", attempt, retries, host, remotePath)
+			}
 		}
 
-		n, hashCh, err := streamFileOnce(c, host, remotePath, destPath, expectedSize, filename, checksumTool, useGzip)
+		n, hashCh, err := streamFileOnce(c, host, remotePath, destPath, offset, expectedSize, filename, checksumTool, useGzip)
 		if err == nil {
 			return n, hashCh, nil
 		}
 		lastErr = err
+		offset = n
 
 		if !isTransientError(err) {
+			_ = os.Remove(destPath)
 			return 0, nil, fmt.Errorf("permanent error streaming %v:%v: %w", host, remotePath, err)
 		}
-		simplelog.Warningf("transient error streaming %v:%v (attempt %d/%d): %v", host, remotePath, attempt, retries, err)
+		simplelog.Warningf("transient error streaming %v:%v (attempt %d/%d, %d bytes kept): %v", host, remotePath, attempt, retries, offset, err)
 		// Exponential backoff: 500ms, 1s, 2s (capped)
 		shift := attempt - 1
 		if shift < 0 {
@@ -205,42 +219,66 @@ func streamFile(c Collector, host, remotePath, destPath string, retries int, exp
 		}
 		time.Sleep(backoff)
 	}
+	_ = os.Remove(destPath)
 	return 0, nil, fmt.Errorf("exhausted %d retries streaming %v:%v: %w", retries, host, remotePath, lastErr)
 }
 
-// streamFileOnce opens a local file, streams the remote content via
-// StreamFromHost through a buffered writer (no inline hashing), then kicks
-// off a background goroutine to re-read the written file and compute a single
-// hash. Returns bytes written plus a channel carrying the hash result.
-func streamFileOnce(c Collector, host, remotePath, destPath string, expectedSize int64, filename, checksumTool string, useGzip bool) (int64, <-chan hashResult, error) {
+// streamFileOnce opens the local file, truncates it to offset and streams the
+// remote content from that offset via StreamFromHostAt through a buffered writer
+// (no inline hashing). On success it kicks off a background goroutine to re-read
+// the whole file and compute a single hash, so the hash covers every attempt that
+// contributed to it. It returns the bytes now in the file; on error the partial
+// file is kept so the caller can resume from that count.
+func streamFileOnce(c Collector, host, remotePath, destPath string, offset, expectedSize int64, filename, checksumTool string, useGzip bool) (int64, <-chan hashResult, error) {
 	if err := os.MkdirAll(filepath.Dir(destPath), DirPerms); err != nil {
-		return 0, nil, fmt.Errorf("failed to create destination dir for %v: %w", destPath, err)
+		return offset, nil, fmt.Errorf("failed to create destination dir for %v: %w", destPath, err)
 	}
 
-	f, err := os.Create(filepath.Clean(destPath))
+	f, err := os.OpenFile(filepath.Clean(destPath), os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		return 0, nil, fmt.Errorf("failed to create file %v: %w", destPath, err)
+		return offset, nil, fmt.Errorf("failed to create file %v: %w", destPath, err)
+	}
+	// Drop anything past offset (or everything, on a fresh transfer) and append from there.
+	if err := f.Truncate(offset); err != nil {
+		_ = f.Close()
+		return 0, nil, fmt.Errorf("failed to truncate %v to %d: %w", destPath, offset, err)
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		_ = f.Close()
+		return 0, nil, fmt.Errorf("failed to seek %v to %d: %w", destPath, offset, err)
 	}
 
 	// Buffered writer batches chunks into large disk writes.
 	bf := bufio.NewWriterSize(f, streamWriteBufSize)
 
 	// progressWriter wraps bf to count bytes and report TUI progress.
-	pw := &progressWriter{w: bf, expectedSize: expectedSize, host: host, filename: filename}
+	pw := &progressWriter{w: bf, n: offset, expectedSize: expectedSize, host: host, filename: filename}
+
+	// keep returns the bytes safely on disk after a failed attempt.
+	keep := func() int64 {
+		if err := bf.Flush(); err != nil {
+			_ = f.Close()
+			return offset
+		}
+		if err := f.Close(); err != nil {
+			return offset
+		}
+		return pw.n
+	}
 
 	// Copy buffer larger than io.Copy's 32 KB default reduces syscall
 	// overhead and improves throughput for large file transfers.
 	copyBuf := make([]byte, copyBufSize)
 
 	if useGzip {
-		// Gzip decompression pipeline: goroutine runs StreamFromHost writing
+		// Gzip decompression pipeline: goroutine runs StreamFromHostAt writing
 		// compressed bytes to pipe writer; main thread reads through gzip.NewReader
 		// into the progressWriter (which counts decompressed bytes).
 		pr, pipew := io.Pipe()
 
 		var streamErr error
 		go func() {
-			streamErr = c.StreamFromHost(host, remotePath, pipew, true)
+			streamErr = c.StreamFromHostAt(host, remotePath, offset, pipew, true)
 			pipew.CloseWithError(streamErr) // signals EOF or error to reader side
 		}()
 
@@ -251,46 +289,37 @@ func streamFileOnce(c Collector, host, remotePath, destPath string, expectedSize
 		gz, gzErr := gzip.NewReader(bufPR)
 		if gzErr != nil {
 			_ = pr.Close()
-			_ = f.Close()
-			_ = os.Remove(destPath)
-			return 0, nil, fmt.Errorf("gzip reader init failed for %v:%v: %w", host, remotePath, gzErr)
+			// gzErr carries the stream error when the stream broke before the gzip header
+			return keep(), nil, fmt.Errorf("gzip reader init failed for %v:%v: %w", host, remotePath, gzErr)
 		}
 
+		// Bytes decompressed before a broken stream are a valid prefix of the file.
 		_, copyErr := io.CopyBuffer(pw, gz, copyBuf) // #nosec G110 -- source is trusted dremio cluster output
 		_ = gz.Close()
 		_ = pr.Close()
 
 		// Check both the stream error and the copy error.
 		if streamErr != nil {
-			_ = bf.Flush()
-			_ = f.Close()
-			_ = os.Remove(destPath)
-			return 0, nil, streamErr
+			return keep(), nil, streamErr
 		}
 		if copyErr != nil {
-			_ = bf.Flush()
-			_ = f.Close()
-			_ = os.Remove(destPath)
-			return 0, nil, fmt.Errorf("gzip decompress copy failed for %v:%v: %w", host, remotePath, copyErr)
+			return keep(), nil, fmt.Errorf("gzip decompress copy failed for %v:%v: %w", host, remotePath, copyErr)
 		}
 	} else {
-		// Direct path: StreamFromHost writes raw bytes to progressWriter.
-		streamErr := c.StreamFromHost(host, remotePath, pw, false)
+		// Direct path: StreamFromHostAt writes raw bytes to progressWriter.
+		streamErr := c.StreamFromHostAt(host, remotePath, offset, pw, false)
 		if streamErr != nil {
-			_ = bf.Flush()
-			_ = f.Close()
-			_ = os.Remove(destPath)
-			return 0, nil, streamErr
+			return keep(), nil, streamErr
 		}
 	}
 
 	if flushErr := bf.Flush(); flushErr != nil {
 		_ = f.Close()
-		return 0, nil, fmt.Errorf("flush failed for %v: %w", destPath, flushErr)
+		return offset, nil, fmt.Errorf("flush failed for %v: %w", destPath, flushErr)
 	}
 	closeErr := f.Close()
 	if closeErr != nil {
-		return 0, nil, fmt.Errorf("close failed for %v: %w", destPath, closeErr)
+		return offset, nil, fmt.Errorf("close failed for %v: %w", destPath, closeErr)
 	}
 
 	// Kick off background hash of the written file.
@@ -389,10 +418,15 @@ func verifyChecksum(c Collector, host, remotePath, localHash, checksumTool strin
 		return nil
 	}
 
-	remoteHash := parseChecksumOutput(out)
+	compareChecksum(host, remotePath, localHash, parseChecksumOutput(out), checksumTool)
+	return nil
+}
+
+// compareChecksum logs whether the local and remote digests of a file agree.
+func compareChecksum(host, remotePath, localHash, remoteHash, checksumTool string) {
 	if remoteHash == "" {
 		simplelog.Warningf("checksum verification skipped for %v:%v — empty output from %v", host, remotePath, checksumTool)
-		return nil
+		return
 	}
 
 	if remoteHash == localHash {
@@ -400,7 +434,6 @@ func verifyChecksum(c Collector, host, remotePath, localHash, checksumTool strin
 	} else {
 		simplelog.Warningf("checksum mismatch for %v:%v — local %v=%v remote %v=%v", host, remotePath, checksumTool, localHash, checksumTool, remoteHash)
 	}
-	return nil
 }
 
 // maskLocalConfigFile reads a config file from disk, applies secret masking,
@@ -578,63 +611,112 @@ func isWithinDateRange(baseName string, modTime int64, dayLimit int, startDate s
 	return time.Unix(modTime, 0).After(cutoff)
 }
 
-// streamNodeFiles iterates over discovered files for a single node, streams
-// each one to the appropriate CopyStrategy destination, and returns the list
-// of collected files plus any file paths that were skipped (due to errors).
-// Files excluded by mode or policy are silently ignored and not counted as skipped.
-func streamNodeFiles(c Collector, host string, info *RemoteNodeInfo, cs CopyStrategy, nodeType string, collectionMode collects.CollectionMode, collectGCLogs bool, collectionArgs Args) ([]helpers.CollectedFile, []string) {
-	var collected []helpers.CollectedFile
-	var skipped []string
+// nodeFile is a discovered file that passed the collection filters, paired
+// with the local path it is written to. A non-zero offset (--since-last) means
+// only the bytes appended after it are collected, a non-zero limit (--plan) that
+// none past it are.
+type nodeFile struct {
+	remote   RemoteFileInfo
+	destPath string
+	offset   int64
+	limit    int64
+}
 
-	for _, rf := range info.Files {
-		// Skip 0-byte files — nothing to collect.
-		if rf.Size == 0 {
-			simplelog.Infof("stream exclude (0 bytes): %v:%v", host, rf.Path)
-			continue
-		}
+// collector returns c reading the part of the file that is collected.
+func (nf nodeFile) collector(c Collector) Collector {
+	if nf.limit > 0 {
+		c = cappedCollector{Collector: c, limit: nf.limit}
+	}
+	if nf.offset > 0 {
+		c = offsetCollector{Collector: c, base: nf.offset}
+	}
+	return c
+}
 
-		base := filepath.Base(rf.Path)
+// size is the number of bytes of the file that are collected.
+func (nf nodeFile) size() int64 {
+	end := nf.remote.Size
+	if nf.limit > 0 {
+		end = nf.limit
+	}
+	return end - nf.offset
+}
 
-		// Always exclude admin_backup files regardless of mode.
-		if isAlwaysExcluded(base) {
-			simplelog.Infof("stream exclude (blocked): %v:%v", host, rf.Path)
-			continue
-		}
+// includeNodeFile applies the mode, policy and date filters to a file
+// discovered on host and reports whether it is collected. Exclusions are logged.
+func includeNodeFile(host string, info *RemoteNodeInfo, rf RemoteFileInfo, collectionMode collects.CollectionMode, collectGCLogs bool, collectionArgs Args) bool {
+	// Skip 0-byte files — nothing to collect.
+	if rf.Size == 0 {
+		simplelog.Infof("stream exclude (0 bytes): %v:%v", host, rf.Path)
+		return false
+	}
 
-		// Silently exclude GC logs when not enabled.
-		if rf.FileType == "gc-log" && !collectGCLogs {
-			simplelog.Infof("stream exclude gc-log (disabled): %v:%v", host, rf.Path)
-			continue
-		}
+	base := filepath.Base(rf.Path)
 
-		// Exclude log types that are disabled by the user.
-		if rf.FileType == "log" && !isLogTypeEnabled(base, collectionArgs) {
-			simplelog.Infof("stream exclude (log type disabled): %v:%v", host, rf.Path)
-			continue
-		}
+	// Always exclude admin_backup files regardless of mode.
+	if isAlwaysExcluded(base) {
+		simplelog.Infof("stream exclude (blocked): %v:%v", host, rf.Path)
+		return false
+	}
 
-		// Exclude queries.json when disabled.
-		if rf.FileType == "queries" && !collectionArgs.CollectQueriesJSON {
-			simplelog.Infof("stream exclude (queries disabled): %v:%v", host, rf.Path)
-			continue
+	// Silently exclude GC logs when not enabled.
+	if rf.FileType == "gc-log" && !collectGCLogs {
+		simplelog.Infof("stream exclude gc-log (disabled): %v:%v", host, rf.Path)
+		return false
+	}
+
+	// Exclude log types that are disabled by the user.
+	if rf.FileType == "log" && !isLogTypeEnabled(base, collectionArgs) {
+		simplelog.Infof("stream exclude (log type disabled): %v:%v", host, rf.Path)
+		return false
+	}
+
+	// Exclude queries.json when disabled.
+	if rf.FileType == "queries" && !collectionArgs.CollectQueriesJSON {
+		simplelog.Infof("stream exclude (queries disabled): %v:%v", host, rf.Path)
+		return false
+	}
+
+	// In standard mode, only collect allowlisted log files.
+	// Config files are always collected regardless of mode.
+	if collectionMode == collects.StandardCollection && rf.FileType == "log" {
+		if !isLogAllowedInStandardMode(base) {
+			simplelog.Infof("stream exclude (not in standard allowlist): %v:%v", host, rf.Path)
+			return false
 		}
+	}
 
-		// In standard mode, only collect allowlisted log files.
-		// Config files are always collected regardless of mode.
-		if collectionMode == collects.StandardCollection && rf.FileType == "log" {
-			if !isLogAllowedInStandardMode(base) {
-				simplelog.Infof("stream exclude (not in standard allowlist): %v:%v", host, rf.Path)
-				continue
-			}
+	// Apply date-range filtering for log and queries files.
+	if rf.FileType == "log" || rf.FileType == "queries" {
+		dayLimit := logDayLimit(base, collectionArgs)
+		if !isWithinDateRange(base, rf.ModTime, dayLimit, collectionArgs.StartDate) {
+			simplelog.Infof("stream exclude (outside date range, days=%d start=%q): %v:%v", dayLimit, collectionArgs.StartDate, host, rf.Path)
+			return false
 		}
+	}
+	if collectionArgs.TrimToWindow && (rf.FileType == "log" || rf.FileType == "queries") && !overlapsWindow(base, rf.ModTime, nodeWindow(collectionArgs, info)) {
+		simplelog.Infof("stream exclude (outside --since/--until): %v:%v", host, rf.Path)
+		return false
+	}
+	return true
+}
 
-		// Apply date-range filtering for log and queries files.
-		if rf.FileType == "log" || rf.FileType == "queries" {
-			dayLimit := logDayLimit(base, collectionArgs)
-			if !isWithinDateRange(base, rf.ModTime, dayLimit, collectionArgs.StartDate) {
-				simplelog.Infof("stream exclude (outside date range, days=%d start=%q): %v:%v", dayLimit, collectionArgs.StartDate, host, rf.Path)
+// selectNodeFiles keeps the files discovered on a node that pass
+// includeNodeFile, or with a --plan the planned ones, and resolves each
+// remaining file's destination. Files whose destination cannot be created,
+// and planned files that no longer exist, are returned as skipped.
+func selectNodeFiles(host string, info *RemoteNodeInfo, cs CopyStrategy, nodeType string, collectionMode collects.CollectionMode, collectGCLogs bool, collectionArgs Args) ([]nodeFile, []string) {
+	var selected []nodeFile
+	var skipped []string
+
+	plan := collectionArgs.Plan
+	for _, rf := range info.Files {
+		if plan != nil {
+			if !plan.includesFile(host, rf) {
 				continue
 			}
+		} else if !includeNodeFile(host, info, rf, collectionMode, collectGCLogs, collectionArgs) {
+			continue
 		}
 		strategyType := fileTypeToStrategyType(rf.FileType)
 		destDir, err := cs.CreatePath(strategyType, host, nodeType)
@@ -644,10 +726,98 @@ func streamNodeFiles(c Collector, host string, info *RemoteNodeInfo, cs CopyStra
 			continue
 		}
 
-		destPath := filepath.Join(destDir, filepath.Base(rf.Path))
+		nf := nodeFile{remote: rf, destPath: filepath.Join(destDir, filepath.Base(rf.Path))}
+		if plan != nil {
+			nf.limit = plan.sizeLimit(host, rf)
+		}
+		selected = append(selected, nf)
+	}
+	if plan != nil {
+		skipped = append(skipped, plan.missingFiles(host, info)...)
+	}
+	return selected, skipped
+}
+
+// streamedSHA256 returns localHash when it is the SHA-256 of the file as written:
+// md5sum digests are not, and config files change when they are masked.
+func streamedSHA256(rf RemoteFileInfo, localHash, checksumTool string) string {
+	if checksumTool != "sha256sum" || rf.FileType == "config" {
+		return ""
+	}
+	return localHash
+}
+
+// maskIfConfig applies secret masking to a config file after streaming
+// (advisory per K011).
+func maskIfConfig(rf RemoteFileInfo, destPath string) {
+	if rf.FileType != "config" {
+		return
+	}
+	if maskErr := maskLocalConfigFile(destPath); maskErr != nil {
+		simplelog.Warningf("stream mask: failed to mask config %v — %v", destPath, maskErr)
+	}
+}
+
+// streamNodeFiles streams the selected files of a single node to their
+// CopyStrategy destinations and returns the list of collected files plus any
+// file paths that were skipped (due to errors). Files excluded by mode or
+// policy are silently ignored and not counted as skipped. When the node has
+// tar the files travel in one tar stream (see streamNodeFilesBatch); anything
+// the batch did not deliver is streamed one file at a time.
+func streamNodeFiles(c Collector, host string, info *RemoteNodeInfo, cs CopyStrategy, nodeType string, collectionMode collects.CollectionMode, collectGCLogs bool, collectionArgs Args) ([]helpers.CollectedFile, []string) {
+	files, skipped := selectNodeFiles(host, info, cs, nodeType, collectionMode, collectGCLogs, collectionArgs)
+	inc := collectionArgs.incremental
+	if inc != nil {
+		files = inc.plan(c, host, files)
+	}
+
+	// Tails, files capped at their planned size and logs trimmed to a window are
+	// streamed on their own; the batch only carries whole files.
+	var whole, tails, trimmed []nodeFile
+	for _, nf := range files {
+		switch {
+		case isTrimmedLog(nf, collectionArgs):
+			trimmed = append(trimmed, nf)
+		case nf.offset > 0 || nf.limit > 0:
+			tails = append(tails, nf)
+		default:
+			whole = append(whole, nf)
+		}
+	}
+	files = whole
+
+	var collected []helpers.CollectedFile
+	if info.TarAvailable && !collectionArgs.DisableBatchStreaming && len(files) > 1 {
+		var batched []helpers.CollectedFile
+		batched, files = streamNodeFilesBatch(c, host, info, files)
+		collected = append(collected, batched...)
+		if inc != nil {
+			recordBatched(inc, host, whole, batched)
+		}
+	}
+	files = append(files, tails...)
 
+	for _, nf := range files {
+		rf, destPath := nf.remote, nf.destPath
 		simplelog.Infof("stream start: %v:%v → %v", host, rf.Path, destPath)
 
+		if nf.offset > 0 || nf.limit > 0 {
+			n, hashCh, err := streamFile(nf.collector(c), host, rf.Path, destPath, maxRetries, nf.size(), filepath.Base(rf.Path), "", info.GzipAvailable)
+			if err != nil {
+				simplelog.Warningf("stream skip: %v:%v — %v", host, rf.Path, err)
+				skipped = append(skipped, rf.Path)
+				continue
+			}
+			<-hashCh
+			// No checksum: the remote one would cover the whole file, not the part read.
+			simplelog.Infof("stream complete: %v:%v (%d bytes from byte %d)", host, rf.Path, n, nf.offset)
+			if inc != nil {
+				inc.record(host, nf, n)
+			}
+			collected = append(collected, helpers.CollectedFile{Path: destPath, Size: n})
+			continue
+		}
+
 		n, hashCh, err := streamFile(c, host, rf.Path, destPath, maxRetries, rf.Size, filepath.Base(rf.Path), info.ChecksumTool, info.GzipAvailable)
 		if err != nil {
 			simplelog.Warningf("stream skip: %v:%v — %v", host, rf.Path, err)
@@ -669,28 +839,49 @@ func streamNodeFiles(c Collector, host string, info *RemoteNodeInfo, cs CopyStra
 		// Advisory checksum verification — always returns nil.
 		_ = verifyChecksum(c, host, rf.Path, localHash, info.ChecksumTool)
 
-		// Apply secret masking to config files after streaming (advisory per K011).
-		if rf.FileType == "config" {
-			if maskErr := maskLocalConfigFile(destPath); maskErr != nil {
-				simplelog.Warningf("stream mask: failed to mask config %v — %v", destPath, maskErr)
-			}
-		}
+		maskIfConfig(rf, destPath)
 
 		simplelog.Infof("stream complete: %v:%v (%d bytes)", host, rf.Path, n)
+		if inc != nil {
+			inc.record(host, nf, n)
+		}
 		collected = append(collected, helpers.CollectedFile{
-			Path: destPath,
-			Size: n,
+			Path:   destPath,
+			Size:   n,
+			SHA256: streamedSHA256(rf, localHash, info.ChecksumTool),
 		})
 	}
 
+	if len(trimmed) > 0 {
+		trimmedFiles, trimmedSkipped := streamTrimmedLogs(c, host, info, trimmed, collectionArgs)
+		collected = append(collected, trimmedFiles...)
+		skipped = append(skipped, trimmedSkipped...)
+	}
 	return collected, skipped
 }
 
+// recordBatched adds the files a tar batch delivered to the --since-last state.
+func recordBatched(inc *incrementalRun, host string, files []nodeFile, collected []helpers.CollectedFile) {
+	byDest := make(map[string]nodeFile, len(files))
+	for _, nf := range files {
+		byDest[nf.destPath] = nf
+	}
+	for _, cf := range collected {
+		if nf, ok := byDest[cf.Path]; ok {
+			inc.record(host, nf, cf.Size)
+		}
+	}
+}
+
 // ExecuteStreamingCollect implements streaming collection: discover files on
 // each remote node, stream them individually via cat, and archive the result.
 // No binary deployment to remote nodes is required.
 func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, hook shutdown.Hook, clusterCollection func()) error {
 	start := time.Now().UTC()
+	archiveID := uuid.New().String()
+	// ddc watch runs several collections in one process; summary.json only reports this one
+	consoleprint.ResetWarnings()
+	containerLogTotals.reset()
 	outputLoc := collectionArgs.OutputLoc
 	collectionMode := collectionArgs.CollectionMode
 	collectionThreads := collectionArgs.CollectionThreads
@@ -702,6 +893,29 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		}
 	}
 
+	if collectionArgs.SinceLast != "" {
+		previous, err := LoadIncrementalState(collectionArgs.SinceLast)
+		if err != nil {
+			return err
+		}
+		if previous == nil {
+			simplelog.Infof("--since-last: no state in %v yet, collecting everything", collectionArgs.SinceLast)
+		} else {
+			simplelog.Infof("--since-last: collecting changes since archive %v (%v)", previous.ArchiveID, previous.Archive)
+		}
+		collectionArgs.incremental = newIncrementalRun(archiveID, previous)
+	}
+	if collectionArgs.TrimToWindow {
+		collectionArgs.trimmed = &trimRecorder{}
+	}
+	plan := collectionArgs.Plan
+	if plan != nil {
+		if err := plan.restrict(&collectionArgs); err != nil {
+			return err
+		}
+		simplelog.Infof("--plan: collecting plan %v: %d file(s) (%d bytes) on %d node(s)", plan.PlanID, plan.TotalFiles, plan.TotalBytes, len(plan.Nodes))
+	}
+
 	// Discover cluster topology.
 	coordinators, err := c.GetCoordinators()
 	if err != nil {
@@ -718,6 +932,14 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 	coordinators = FilterByNodeSelection(coordinators, collectionArgs.IncludeNodes, collectionArgs.ExcludeNodes)
 	executors = FilterByNodeSelection(executors, collectionArgs.IncludeNodes, collectionArgs.ExcludeNodes)
 
+	if plan != nil {
+		for _, n := range plan.Nodes {
+			if !slices.Contains(coordinators, n.Host) && !slices.Contains(executors, n.Host) {
+				simplelog.Warningf("--plan: %v is no longer part of the cluster, skipping", n.Host)
+			}
+		}
+	}
+
 	totalNodes := len(coordinators) + len(executors)
 	if totalNodes == 0 {
 		return fmt.Errorf("no hosts found, nothing to collect: %v", c.HelpText())
@@ -862,12 +1084,16 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 	}
 	wg.Wait()
 
+	if collectionArgs.DryRun {
+		return writeDryRunPlan(c, collectionArgs, archiveID, coordinators, executors, nodeInfoByHost, totalFailedNodes)
+	}
+
 	// ========================================================================
 	// Phase 2+3: JVM collection (diagnosis mode only)
 	// ========================================================================
 	var jvmFilesByHost map[string][]helpers.CollectedFile
 	if collectionArgs.CollectionMode == collects.DiagnosisCollection {
-		jvmFilesByHost = runJVMCollection(c, s, collectionArgs, pidByHost, nodeTypeByHost, collectionThreads)
+		jvmFilesByHost = runJVMCollection(c, s, collectionArgs, plan.jvmTargets(pidByHost), nodeTypeByHost, collectionThreads)
 	}
 
 	// ========================================================================
@@ -905,25 +1131,29 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 				nodeInfoToolErrors = append(nodeInfoToolErrors, fmt.Sprintf("node-info path: %v", niErr))
 				return
 			}
-			consoleprint.UpdateNodeState(consoleprint.NodeState{
-				Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting OS info",
-				IsCoordinator: nodeType == "coordinator",
-			})
-			if err := CollectOSInfo(c, host, nodeInfoDir); err != nil {
-				simplelog.Warningf("node-info-collect: os-info failed on %s: %v", host, err)
-				niToolsFailed++
-				nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
+			if plan.runs(host, planToolOSInfo) {
+				consoleprint.UpdateNodeState(consoleprint.NodeState{
+					Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting OS info",
+					IsCoordinator: nodeType == "coordinator",
+				})
+				if err := CollectOSInfo(c, host, nodeInfoDir); err != nil {
+					simplelog.Warningf("node-info-collect: os-info failed on %s: %v", host, err)
+					niToolsFailed++
+					nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
+				}
 			}
-			consoleprint.UpdateNodeState(consoleprint.NodeState{
-				Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting disk usage",
-				IsCoordinator: nodeType == "coordinator",
-			})
-			if err := CollectDiskUsage(c, host, nodeInfoDir); err != nil {
-				simplelog.Warningf("node-info-collect: disk-usage failed on %s: %v", host, err)
-				niToolsFailed++
-				nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
+			if plan.runs(host, planToolDiskUsage) {
+				consoleprint.UpdateNodeState(consoleprint.NodeState{
+					Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting disk usage",
+					IsCoordinator: nodeType == "coordinator",
+				})
+				if err := CollectDiskUsage(c, host, nodeInfoDir); err != nil {
+					simplelog.Warningf("node-info-collect: disk-usage failed on %s: %v", host, err)
+					niToolsFailed++
+					nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
+				}
 			}
-			if nodeType == "coordinator" {
+			if nodeType == "coordinator" && plan.runs(host, planToolRocksDBDiskUsage) {
 				consoleprint.UpdateNodeState(consoleprint.NodeState{
 					Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting RocksDB disk allocation",
 					IsCoordinator: true,
@@ -939,7 +1169,7 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 					nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
 				}
 			}
-			if info.DremioPID > 0 {
+			if info.DremioPID > 0 && plan.runs(host, planToolJVMFlags) {
 				consoleprint.UpdateNodeState(consoleprint.NodeState{
 					Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting JVM settings",
 					IsCoordinator: nodeType == "coordinator",
@@ -950,6 +1180,17 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 					nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
 				}
 			}
+			if info.DremioPID > 0 && collectionArgs.CollectNMT && plan.runs(host, planToolNMT) {
+				consoleprint.UpdateNodeState(consoleprint.NodeState{
+					Node: host, Status: consoleprint.Collecting, StatusUX: "Collecting native memory summary",
+					IsCoordinator: nodeType == "coordinator",
+				})
+				if err := CollectNMT(c, host, info.DremioPID, nodeInfoDir); err != nil {
+					simplelog.Warningf("node-info-collect: nmt failed on %s: %v", host, err)
+					niToolsFailed++
+					nodeInfoToolErrors = append(nodeInfoToolErrors, err.Error())
+				}
+			}
 		}()
 		nodeInfoTimeout := 2 * time.Minute
 		select {
@@ -973,7 +1214,7 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		// Mirrors the resolution used by the rocksdb-disk-allocation block above so
 		// queries-perf / cluster-stats / WLM / system-tables aren't silently skipped
 		// when the user omits --dremio-rocksdb-dir.
-		if nodeType == "coordinator" {
+		if nodeType == "coordinator" && plan.runs(host, planToolRocksDBViewer) {
 			rocksDBDir := info.RocksDBDir
 			if rocksDBDir == "" {
 				rocksDBDir = collectionArgs.DremioRocksDBDir
@@ -989,10 +1230,16 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 					SystemTables:        collectionArgs.SystemTables,
 					CollectWLM:          collectionArgs.CollectWLM,
 					CollectQueriesPerf:  collectionArgs.CollectQueriesPerf,
+					Types:               collectionArgs.RocksDBTypes,
+					TypeOverrides:       collectionArgs.RocksDBTypeOverrides,
+					Guard:               collectionArgs.HelperGuard,
 					QueriesPerfDays:     collectionArgs.QueriesPerfNumDays,
 					Days:                collectionArgs.DiagLogDays,
 					StartDate:           collectionArgs.StartDate,
 				}
+				if plan != nil {
+					rocksArgs.Types = plan.node(host).RocksDBTypes
+				}
 				if rocksFiles, err := RunRocksDBCollection(rocksArgs); err != nil {
 					simplelog.Errorf("RocksDB collection failed on %s: %v", host, err)
 				} else {
@@ -1066,6 +1313,9 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		orchestratorWg.Add(1)
 		go func() {
 			defer orchestratorWg.Done()
+			if !plan.calls(planCallClusterData) {
+				return
+			}
 			consoleprint.UpdateResult("Collecting Kubernetes cluster data...")
 			clusterCollection()
 			consoleprint.UpdateResult("Kubernetes cluster data collected")
@@ -1075,22 +1325,31 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		go func() {
 			defer orchestratorWg.Done()
 
-			// PAT-dependent REST collections (diagnosis mode only): KV store
+			// PAT-dependent REST collections: KV store (diagnosis), catalog inventory (standard)
 			if collectionArgs.DremioPAT != "" && len(coordinators) > 0 {
 				apiArgs := APICollectionArgs{
-					TmpDir:           s.GetTmpDir(),
-					CoordinatorNode:  coordinators[0],
-					DremioEndpoint:   collectionArgs.DremioEndpoint,
-					DremioPAT:        collectionArgs.DremioPAT,
-					AllowInsecureSSL: collectionArgs.AllowInsecureSSL,
-					RestHTTPTimeout:  collectionArgs.RestHTTPTimeout,
-					Hook:             hook,
+					TmpDir:          s.GetTmpDir(),
+					CoordinatorNode: coordinators[0],
+					DremioEndpoint:  collectionArgs.DremioEndpoint,
+					DremioPAT:       collectionArgs.DremioPAT,
+					RestClient:      collectionArgs.RestClient,
+					RestHTTPTimeout: collectionArgs.RestHTTPTimeout,
+					Hook:            hook,
 				}
 				if collectionArgs.CollectKVStoreReport {
 					if err := RunCollectKVStore(apiArgs); err != nil {
 						simplelog.Errorf("KV store collection failed: %v", err)
 					}
 				}
+				if collectionArgs.CollectCatalogInventory {
+					if err := RunCollectCatalog(CatalogCollectArgs{
+						APICollectionArgs: apiArgs,
+						MaxDepth:          collectionArgs.CatalogMaxDepth,
+						MaxItems:          collectionArgs.CatalogMaxItems,
+					}); err != nil {
+						simplelog.Errorf("Catalog inventory collection failed: %v", err)
+					}
+				}
 			}
 		}()
 
@@ -1109,14 +1368,14 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 	// server.log files are fully written to tmpDir.
 	if collectionArgs.CollectProblematicProfiles && collectionArgs.DremioPAT != "" && len(coordinators) > 0 {
 		if err := RunLogBasedProfileCollection(LogProfileArgs{
-			TmpDir:           s.GetTmpDir(),
-			DremioEndpoint:   collectionArgs.DremioEndpoint,
-			DremioPAT:        collectionArgs.DremioPAT,
-			NodeName:         coordinators[0],
-			CollectionMode:   collectionArgs.CollectionMode,
-			AllowInsecureSSL: collectionArgs.AllowInsecureSSL,
-			RestHTTPTimeout:  collectionArgs.RestHTTPTimeout,
-			Hook:             hook,
+			TmpDir:          s.GetTmpDir(),
+			DremioEndpoint:  collectionArgs.DremioEndpoint,
+			DremioPAT:       collectionArgs.DremioPAT,
+			NodeName:        coordinators[0],
+			CollectionMode:  collectionArgs.CollectionMode,
+			RestClient:      collectionArgs.RestClient,
+			RestHTTPTimeout: collectionArgs.RestHTTPTimeout,
+			Hook:            hook,
 		}); err != nil {
 			simplelog.Errorf("Log-based profile collection failed: %v", err)
 		}
@@ -1126,6 +1385,7 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 	// Build summary.
 	end := time.Now().UTC()
 	var summaryInfo SummaryInfo
+	summaryInfo.ArchiveID = archiveID
 	summaryInfo.StartTimeUTC = start
 	summaryInfo.EndTimeUTC = end
 	summaryInfo.TotalRuntimeSeconds = end.Unix() - start.Unix()
@@ -1140,14 +1400,44 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 	summaryInfo.TotalBytesCollected = totalBytes
 	summaryInfo.Coordinators = coordinators
 	summaryInfo.Executors = executors
+	identity := resolveClusterIdentity(identityArgs{
+		Collector:    c,
+		Hook:         hook,
+		TmpDir:       s.GetTmpDir(),
+		Coordinators: coordinators,
+		Executors:    executors,
+		NodeInfo:     nodeInfoByHost,
+		CollectArgs:  collectionArgs,
+	})
+	summaryInfo.DremioVersion = identity.DremioVersion
+	summaryInfo.DremioVersionSource = identity.DremioVersionSource
+	summaryInfo.ClusterID = identity.ClusterID
+	summaryInfo.ClusterIDSource = identity.ClusterIDSource
 	summaryInfo.DDCVersion = versions.GetCLIVersion()
 	summaryInfo.CollectionsEnabled = collectionArgs.Enabled
 	summaryInfo.CollectionsDisabled = collectionArgs.Disabled
+	summaryInfo.Warnings = consoleprint.Warnings()
+	summaryInfo.ContainerLogs = ContainerLogSummaries()
+	if collectionArgs.incremental != nil {
+		summaryInfo.Delta = collectionArgs.incremental.Delta()
+	}
+	if plan != nil {
+		summaryInfo.PlanID = plan.PlanID
+	}
+	summaryInfo.Preset = collectionArgs.Preset
+	summaryInfo.WatchTrigger = collectionArgs.WatchTrigger
+	if collectionArgs.trimmed != nil {
+		summaryInfo.TrimWindow = collectionArgs.trimmed.summary(logWindow(collectionArgs))
+	}
 
 	if len(collectedFiles) == 0 {
 		return fmt.Errorf("streaming collection completed but no files were collected from %d node(s); failed nodes: %v", totalNodes, totalFailedNodes)
 	}
 
+	if d, ok := s.(deduplicator); ok {
+		summaryInfo.Dedup = d.Deduplicate(collectedFiles)
+	}
+
 	outString, err := summaryInfo.String()
 	if err != nil {
 		return err
@@ -1163,6 +1453,12 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		simplelog.Warningf("streaming collection completed with %d failed node(s): %v", len(totalFailedNodes), totalFailedNodes)
 	}
 
+	if len(collectionArgs.EffectiveConfig) > 0 {
+		configFile := filepath.Join(s.GetTmpDir(), EffectiveConfigFile)
+		if err := os.WriteFile(configFile, collectionArgs.EffectiveConfig, 0o600); err != nil {
+			simplelog.Warningf("unable to write the effective configuration to %v: %v", configFile, err)
+		}
+	}
 	consoleprint.UpdateResult("Creating final archive...")
 	if err := s.ArchiveDiag(outString, outputLoc); err != nil {
 		return err
@@ -1172,9 +1468,21 @@ func ExecuteStreamingCollect(c Collector, s CopyStrategy, collectionArgs Args, h
 		return err
 	}
 	consoleprint.UpdateTarballDir(fullPath)
+	if collectionArgs.incremental != nil {
+		// the archive is complete; a failed save only makes the next run a full one
+		if err := collectionArgs.incremental.State(fullPath).Save(collectionArgs.SinceLast); err != nil {
+			simplelog.Errorf("--since-last: %v", err)
+		}
+	}
 	return nil
 }
 
+// deduplicator is implemented by copy strategies that store identical files once.
+// The digests computed while streaming are passed so files are not hashed again.
+type deduplicator interface {
+	Deduplicate(files []helpers.CollectedFile) *helpers.DedupIndex
+}
+
 // createFlatPath returns the category directory and host prefix for flat output.
 // Unlike CreatePath, it does not create a per-host subdirectory — files are
 // written directly into the category directory with the host prefix in the filename.
@@ -1264,6 +1572,7 @@ func runJVMCollection(c Collector, s CopyStrategy, args Args, pidByHost map[stri
 	// This runs before the synchronized start so upload time doesn't eat into profiling time.
 	asprofByHost := make(map[string]bool)      // tracks which nodes have asprof ready
 	asprofDirByHost := make(map[string]string) // remote directory where asprof is installed
+	asprofGuardByHost := make(map[string]*helperGuard)
 	if args.CollectAsyncProfiler {
 		consoleprint.UpdateResult("Distributing async-profiler to nodes...")
 		var distWg sync.WaitGroup
@@ -1383,9 +1692,25 @@ func runJVMCollection(c Collector, s CopyStrategy, args Args, pidByHost map[stri
 					}
 				}
 
+				guard := probeHelperGuard(c, host, "asprof", args.HelperGuard)
+				if err := guard.verify(c, host, map[string][]byte{
+					remoteBase + "/bin/asprof":              asprofFiles.Binary,
+					remoteBase + "/lib/libasyncProfiler.so": asprofFiles.LibSO,
+				}); err != nil {
+					guard.note(err.Error())
+					guard.write(s, nodeType)
+					simplelog.Errorf("jvm-collect: %v", err)
+					_, _ = c.HostExecute(false, host, "rm -rf "+remoteBase)
+					consoleprint.UpdateNodeState(consoleprint.NodeState{
+						Node: host, ToolErrors: []string{fmt.Sprintf("async-profiler: %v", err)},
+					})
+					return
+				}
+
 				distMu.Lock()
 				asprofByHost[host] = true
 				asprofDirByHost[host] = remoteBase // e.g. /tmp/ddc-asprof or /opt/dremio/data/ddc-asprof
+				asprofGuardByHost[host] = guard
 				distMu.Unlock()
 
 				consoleprint.UpdateNodeState(consoleprint.NodeState{
@@ -1543,9 +1868,16 @@ func runJVMCollection(c Collector, s CopyStrategy, args Args, pidByHost map[stri
 					}
 					pidStr := strconv.Itoa(pid)
 
-					// Run async-profiler producing JFR output.
+					// Run async-profiler producing JFR output. The guard timeout never cuts
+					// the requested profiling duration short.
+					guard := asprofGuardByHost[host]
+					defer guard.write(s, nodeType)
+					timeout := guard.cfg.Timeout()
+					if minTimeout := time.Duration(durSeconds+60) * time.Second; timeout < minTimeout {
+						timeout = minTimeout
+					}
 					execCmd := fmt.Sprintf("%s -e itimer,nativemem --nativemem 500m -d %d -f %s -o jfr %s", remoteBin, durSeconds, remoteJFR, pidStr)
-					if _, execErr := c.HostExecute(false, host, execCmd); execErr != nil {
+					if _, execErr := guard.run(c, host, "profile", execCmd, timeout); execErr != nil {
 						cleanCmd := fmt.Sprintf("rm -rf %s", remoteBase)
 						_, _ = c.HostExecute(false, host, cleanCmd)
 						return fmt.Errorf("execution failed: %w", execErr)
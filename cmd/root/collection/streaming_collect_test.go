@@ -40,12 +40,15 @@ import (
 // --- mock collector for streaming tests ---
 
 // mockStreamCollector satisfies the Collector interface with configurable
-// behaviour for GetCoordinators, GetExecutors, DiscoverFiles, and StreamFromHost.
+// behaviour for GetCoordinators, GetExecutors, DiscoverFiles, StreamFromHost and
+// StreamCommandFromHost.
 type mockStreamCollector struct {
 	coordinators    []string
 	executors       []string
 	discoverFunc    func(host string) (*RemoteNodeInfo, error)
 	streamFunc      func(host, remotePath string, writer io.Writer) error
+	streamAtFunc    func(host, remotePath string, offset int64, writer io.Writer) error
+	streamCmdFunc   func(host, streamCmd string, writer io.Writer) error
 	hostExecuteFunc func(mask bool, host string, args ...string) (string, error)
 	copyToHostFunc  func(host, local, remote string) (string, error)
 	hostPids        map[string]string
@@ -66,8 +69,18 @@ func (m *mockStreamCollector) HostExecute(mask bool, host string, args ...string
 	}
 	return "", nil
 }
-func (m *mockStreamCollector) HostExecuteAndStream(_ bool, _ string, _ cli.OutputHandler, _ string, _ ...string) error {
-	return nil
+func (m *mockStreamCollector) HostExecuteAndStream(mask bool, host string, output cli.OutputHandler, _ string, args ...string) error {
+	if m.hostExecuteFunc == nil {
+		return nil
+	}
+	// replay the HostExecute stub line by line, as the transports do
+	out, err := m.hostExecuteFunc(mask, host, args...)
+	if out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			output(line)
+		}
+	}
+	return err
 }
 func (m *mockStreamCollector) HelpText() string { return "mock" }
 func (m *mockStreamCollector) Name() string     { return "mock" }
@@ -82,12 +95,24 @@ func (m *mockStreamCollector) CleanupRemote() error {
 	m.cleanupCalled.Store(true)
 	return nil
 }
-func (m *mockStreamCollector) StreamFromHost(host, remotePath string, writer io.Writer, _ bool) error {
+func (m *mockStreamCollector) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return m.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+func (m *mockStreamCollector) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, _ bool) error {
+	if m.streamAtFunc != nil {
+		return m.streamAtFunc(host, remotePath, offset, writer)
+	}
 	if m.streamFunc != nil {
 		return m.streamFunc(host, remotePath, writer)
 	}
 	return nil
 }
+func (m *mockStreamCollector) StreamCommandFromHost(host, streamCmd string, writer io.Writer) error {
+	if m.streamCmdFunc != nil {
+		return m.streamCmdFunc(host, streamCmd, writer)
+	}
+	return nil
+}
 func (m *mockStreamCollector) DiscoverFiles(host, _, _ string) (*RemoteNodeInfo, error) {
 	if m.discoverFunc != nil {
 		return m.discoverFunc(host)
@@ -146,6 +171,7 @@ func TestStreamingCollect_EndToEnd(t *testing.T) {
 		CollectionThreads: 2,
 		CollectServerLogs: true,
 		CollectGCLogs:     false, // standard mode never collects GC logs
+		EffectiveConfig:   []byte("collect-server-logs:\n  source: default\n  value: \"true\"\n"),
 	}
 	hook := shutdown.NewHook()
 	defer hook.Cleanup()
@@ -184,6 +210,10 @@ func TestStreamingCollect_EndToEnd(t *testing.T) {
 			t.Errorf("gc log file %v should NOT exist in standard mode", p)
 		}
 	}
+
+	if data, err := os.ReadFile(filepath.Join(tmpDir, EffectiveConfigFile)); err != nil || !bytes.Equal(data, args.EffectiveConfig) {
+		t.Errorf("expected the effective configuration in the archive, got %q: %v", data, err)
+	}
 }
 
 func TestStreamingCollect_RetryOnTransientError(t *testing.T) {
@@ -403,7 +433,7 @@ func TestStreamingCollect_RocksDBViewer_UsesAutodetectedDir(t *testing.T) {
 	if !unameCalled.Load() {
 		t.Error("expected rocksdb-viewer collection to run (uname -m should have been called), but it was skipped")
 	}
-	clusterStats := filepath.Join(tmpDir, "cluster-stats", "coord1", "cluster-stats.json")
+	clusterStats := filepath.Join(tmpDir, "cluster-stats", "coord1", "cluster-stats.json.gz")
 	if _, err := os.Stat(clusterStats); os.IsNotExist(err) {
 		t.Errorf("expected %v to exist (rocksdb-viewer ran), but it does not", clusterStats)
 	}
@@ -961,7 +991,7 @@ func TestStreamFileOnce_SingleHash(t *testing.T) {
 
 	t.Run("sha256sum", func(t *testing.T) {
 		destPath := filepath.Join(tmpDir, "sha256file.txt")
-		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "testfile.txt", "sha256sum", false)
+		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "testfile.txt", "sha256sum", false)
 		if err != nil {
 			t.Fatalf("streamFileOnce returned error: %v", err)
 		}
@@ -988,7 +1018,7 @@ func TestStreamFileOnce_SingleHash(t *testing.T) {
 
 	t.Run("md5sum", func(t *testing.T) {
 		destPath := filepath.Join(tmpDir, "md5file.txt")
-		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "testfile.txt", "md5sum", false)
+		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "testfile.txt", "md5sum", false)
 		if err != nil {
 			t.Fatalf("streamFileOnce returned error: %v", err)
 		}
@@ -1006,7 +1036,7 @@ func TestStreamFileOnce_SingleHash(t *testing.T) {
 
 	t.Run("empty_tool_returns_empty_hex", func(t *testing.T) {
 		destPath := filepath.Join(tmpDir, "nohashfile.txt")
-		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "testfile.txt", "", false)
+		n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "testfile.txt", "", false)
 		if err != nil {
 			t.Fatalf("streamFileOnce returned error: %v", err)
 		}
@@ -1050,7 +1080,7 @@ func TestStreamFileOnce_Buffered(t *testing.T) {
 		},
 	}
 
-	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(fullContent)), "buffered.txt", "sha256sum", false)
+	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(fullContent)), "buffered.txt", "sha256sum", false)
 	if err != nil {
 		t.Fatalf("streamFileOnce returned error: %v", err)
 	}
@@ -1902,7 +1932,7 @@ func TestStreamFileOnce_GzipDecompression(t *testing.T) {
 	}
 
 	destPath := filepath.Join(tmpDir, "gzip_out.txt")
-	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "testfile.txt", "sha256sum", true)
+	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "testfile.txt", "sha256sum", true)
 	if err != nil {
 		t.Fatalf("streamFileOnce with gzip returned error: %v", err)
 	}
@@ -1946,7 +1976,7 @@ func TestStreamFileOnce_GzipFallback(t *testing.T) {
 	}
 
 	destPath := filepath.Join(tmpDir, "fallback_out.txt")
-	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "testfile.txt", "sha256sum", false)
+	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "testfile.txt", "sha256sum", false)
 	if err != nil {
 		t.Fatalf("streamFileOnce with useGzip=false returned error: %v", err)
 	}
@@ -1983,7 +2013,7 @@ func TestStreamFileOnce_GzipStreamError(t *testing.T) {
 	}
 
 	destPath := filepath.Join(tmpDir, "err_out.txt")
-	_, _, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 100, "testfile.txt", "sha256sum", true)
+	_, _, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, 100, "testfile.txt", "sha256sum", true)
 	if err == nil {
 		t.Fatal("expected error from gzip stream, got nil")
 	}
@@ -1991,9 +2021,9 @@ func TestStreamFileOnce_GzipStreamError(t *testing.T) {
 		t.Errorf("expected connection reset error, got: %v", err)
 	}
 
-	// Partial file should be cleaned up.
-	if _, statErr := os.Stat(destPath); !os.IsNotExist(statErr) {
-		t.Error("expected partial file to be removed after gzip stream error")
+	// The partial file is kept so streamFile can resume it.
+	if _, statErr := os.Stat(destPath); statErr != nil {
+		t.Errorf("expected partial file to be kept after gzip stream error: %v", statErr)
 	}
 }
 
@@ -2009,7 +2039,7 @@ func TestStreamFileOnce_GzipInvalidData(t *testing.T) {
 	}
 
 	destPath := filepath.Join(tmpDir, "bad_gzip.txt")
-	_, _, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 100, "testfile.txt", "", true)
+	_, _, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, 100, "testfile.txt", "", true)
 	if err == nil {
 		t.Fatal("expected error for invalid gzip data, got nil")
 	}
@@ -2033,7 +2063,7 @@ func TestStreamFileOnce_8MBBuffer(t *testing.T) {
 		},
 	}
 
-	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, int64(len(content)), "large.bin", "sha256sum", false)
+	n, hashCh, err := streamFileOnce(mc, "host1", "/remote/file", destPath, 0, int64(len(content)), "large.bin", "sha256sum", false)
 	if err != nil {
 		t.Fatalf("streamFileOnce returned error: %v", err)
 	}
@@ -2080,3 +2110,111 @@ func TestIsLogAllowedInStandardMode_MetadataRefresh(t *testing.T) {
 		t.Error("dated metadata_refresh rotation should be allowlisted in standard mode")
 	}
 }
+
+// brokenStream serves content from offset, failing with a connection reset after
+// breakAt bytes on the first call only.
+func brokenStream(content []byte, breakAt int, offsets *[]int64) func(_, _ string, offset int64, w io.Writer) error {
+	return func(_, _ string, offset int64, w io.Writer) error {
+		*offsets = append(*offsets, offset)
+		if len(*offsets) == 1 {
+			if _, err := w.Write(content[offset:breakAt]); err != nil {
+				return err
+			}
+			return fmt.Errorf("connection reset by peer")
+		}
+		_, err := w.Write(content[offset:])
+		return err
+	}
+}
+
+func TestStreamFile_ResumesFromOffset(t *testing.T) {
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024) // 1 MiB
+	want := sha256.Sum256(content)
+
+	var offsets []int64
+	mc := &mockStreamCollector{streamAtFunc: brokenStream(content, 700*1024, &offsets)}
+	destPath := filepath.Join(t.TempDir(), "heap.hprof")
+	n, hashCh, err := streamFile(mc, "host1", "/remote/heap.hprof", destPath, maxRetries, int64(len(content)), "heap.hprof", "sha256sum", false)
+	if err != nil {
+		t.Fatalf("streamFile returned error: %v", err)
+	}
+	if len(offsets) != 2 || offsets[0] != 0 || offsets[1] != 700*1024 {
+		t.Fatalf("expected a retry resuming at byte %d, got offsets %v", 700*1024, offsets)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("bytes written = %d, want %d", n, len(content))
+	}
+	hr := <-hashCh
+	if hr.err != nil || hr.hex != hex.EncodeToString(want[:]) {
+		t.Errorf("expected the hash of the whole file, got %q (%v)", hr.hex, hr.err)
+	}
+}
+
+func TestStreamFile_ResumesGzipFromUncompressedOffset(t *testing.T) {
+	content := bytes.Repeat([]byte("gc log line with some text\n"), 20000)
+	var offsets []int64
+	mc := &mockStreamCollector{
+		streamAtFunc: func(_, _ string, offset int64, w io.Writer) error {
+			offsets = append(offsets, offset)
+			if len(offsets) == 1 {
+				// a whole gzip member for the first part, then the connection drops
+				if _, err := w.Write(gzipCompress(t, content[:123457])); err != nil {
+					return err
+				}
+				return fmt.Errorf("connection reset by peer")
+			}
+			_, err := w.Write(gzipCompress(t, content[offset:]))
+			return err
+		},
+	}
+	destPath := filepath.Join(t.TempDir(), "gc.log")
+	n, hashCh, err := streamFile(mc, "host1", "/remote/gc.log", destPath, maxRetries, int64(len(content)), "gc.log", "", true)
+	if err != nil {
+		t.Fatalf("streamFile returned error: %v", err)
+	}
+	<-hashCh
+	if len(offsets) != 2 || offsets[1] != 123457 {
+		t.Fatalf("expected the retry to resume at the decompressed byte count, got %v", offsets)
+	}
+	data, err := os.ReadFile(destPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(len(content)) || !bytes.Equal(data, content) {
+		t.Errorf("resumed file differs: %d bytes, want %d", len(data), len(content))
+	}
+}
+
+func TestStreamFile_RemovesPartialFileWhenRetriesExhausted(t *testing.T) {
+	mc := &mockStreamCollector{
+		streamAtFunc: func(_, _ string, offset int64, w io.Writer) error {
+			_, _ = w.Write([]byte("x"))
+			return fmt.Errorf("connection reset by peer")
+		},
+	}
+	destPath := filepath.Join(t.TempDir(), "server.log")
+	if _, _, err := streamFile(mc, "host1", "/remote/server.log", destPath, 2, 10, "server.log", "", false); err == nil {
+		t.Fatal("expected an error after exhausting retries")
+	}
+	if _, err := os.Stat(destPath); !os.IsNotExist(err) {
+		t.Errorf("expected the partial file to be removed, got %v", err)
+	}
+}
+
+func TestRemoteReadCommand(t *testing.T) {
+	cases := []struct {
+		offset   int64
+		compress string
+		want     string
+	}{
+		{0, "", "cat '/var/log/it'\\''s.log'"},
+		{0, "gzip -c", "gzip -c '/var/log/it'\\''s.log'"},
+		{100, "", "tail -c +101 '/var/log/it'\\''s.log'"},
+		{100, "gzip -1 -c", "tail -c +101 '/var/log/it'\\''s.log' | gzip -1 -c"},
+	}
+	for _, c := range cases {
+		if got := RemoteReadCommand("/var/log/it's.log", c.offset, c.compress); got != c.want {
+			t.Errorf("RemoteReadCommand(%d, %q) = %q, want %q", c.offset, c.compress, got, c.want)
+		}
+	}
+}
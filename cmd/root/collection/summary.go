@@ -24,6 +24,7 @@ import (
 )
 
 type SummaryInfo struct {
+	ArchiveID           string                  `json:"archiveId,omitempty"`
 	ClusterInfo         ClusterInfo             `json:"clusterInfo"`
 	CollectedFiles      []helpers.CollectedFile `json:"collectedFiles"`
 	FailedFiles         []string                `json:"failedFiles"`
@@ -36,9 +37,19 @@ type SummaryInfo struct {
 	Coordinators        []string                `json:"coordinators"`
 	DremioVersion       map[string]string       `json:"dremioVersion"`
 	ClusterID           map[string]string       `json:"clusterID"`
+	DremioVersionSource map[string]string       `json:"dremioVersionSource"`
+	ClusterIDSource     map[string]string       `json:"clusterIDSource"`
 	DDCVersion          string                  `json:"ddcVersion"`
 	CollectionsEnabled  []string                `json:"collectionsEnabled"`
 	CollectionsDisabled []string                `json:"collectionsDisabled"`
+	Warnings            []string                `json:"warnings,omitempty"`
+	ContainerLogs       []ContainerLogSummary   `json:"containerLogs,omitempty"`
+	Delta               *DeltaInfo              `json:"delta,omitempty"`
+	TrimWindow          *TrimWindowSummary      `json:"trimWindow,omitempty"`
+	PlanID              string                  `json:"planId,omitempty"`
+	Preset              string                  `json:"preset,omitempty"`
+	WatchTrigger        *WatchTrigger           `json:"watchTrigger,omitempty"`
+	Dedup               *helpers.DedupIndex     `json:"dedup,omitempty"`
 }
 
 type ClusterInfo struct {
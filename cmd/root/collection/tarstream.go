@@ -0,0 +1,315 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// maxTarCommandBytes caps the quoted file list of one remote tar command so it
+// stays well under ARG_MAX and the exec request limits of every transport. A
+// node with more files than fit gets several tar streams.
+const maxTarCommandBytes = 64 * 1024
+
+// maxChecksumTrailerBytes bounds the checksum lines read after the archive.
+const maxChecksumTrailerBytes = 16 * 1024 * 1024
+
+// RemoteTarCommand returns the sh command that writes paths to stdout as one tar
+// archive, piped through compress (e.g. "gzip -c") when that is set. -h stores
+// the content of symlinked files, as discovery follows links. With checksumTool
+// its output for the same paths follows the end of the archive, so a single exec
+// carries both the files and their remote digests. The exit status is the last
+// pipeline stage's: tar's "file changed as we read it" on a live log does not
+// fail the stream, and entries that never arrived are detected locally instead.
+func RemoteTarCommand(paths []string, checksumTool, compress string) string {
+	quoted := make([]string, len(paths))
+	for i, p := range paths {
+		quoted[i] = shellQuote(p)
+	}
+	list := strings.Join(quoted, " ")
+	cmd := "tar -chf - " + list
+	if checksumTool != "" {
+		cmd = "{ " + cmd + "; " + checksumTool + " " + list + "; }"
+	}
+	if compress == "" {
+		compress = "cat"
+	}
+	return cmd + " | " + compress
+}
+
+// tarBatches splits files into groups whose quoted path list fits in
+// maxTarCommandBytes.
+func tarBatches(files []nodeFile) [][]nodeFile {
+	var batches [][]nodeFile
+	var current []nodeFile
+	size := 0
+	for _, nf := range files {
+		n := len(shellQuote(nf.remote.Path)) + 1
+		if len(current) > 0 && size+n > maxTarCommandBytes {
+			batches = append(batches, current)
+			current, size = nil, 0
+		}
+		current = append(current, nf)
+		size += n
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// tarEntryIndex maps the names in a tar stream back to the files requested.
+// tar drops the leading "/" from member names, and a command run in a K8s debug
+// container reads the files under the target's root, so a name also matches a
+// requested path it ends with.
+type tarEntryIndex struct {
+	exact map[string]int
+}
+
+func newTarEntryIndex(files []nodeFile) *tarEntryIndex {
+	idx := &tarEntryIndex{exact: make(map[string]int, len(files))}
+	for i, nf := range files {
+		idx.exact[strings.TrimPrefix(filepath.Clean(nf.remote.Path), "/")] = i
+	}
+	return idx
+}
+
+func (idx *tarEntryIndex) lookup(name string) (int, bool) {
+	name = strings.TrimPrefix(filepath.Clean("/"+name), "/")
+	if i, ok := idx.exact[name]; ok {
+		return i, true
+	}
+	for key, i := range idx.exact {
+		if strings.HasSuffix(name, "/"+key) {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// parseChecksumLines reads "<hash>  <path>" lines (GNU and busybox, "*" marks
+// binary mode) into hashes keyed by the index of the matching requested file.
+func parseChecksumLines(output string, idx *tarEntryIndex) map[int]string {
+	hashes := make(map[int]string)
+	for _, line := range strings.Split(output, "\n") {
+		digest, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		name = strings.TrimPrefix(strings.TrimLeft(name, " "), "*")
+		if i, found := idx.lookup(name); found {
+			hashes[i] = digest
+		}
+	}
+	return hashes
+}
+
+// streamNodeFilesBatch streams files from host as tar archives, one remote
+// command per batch instead of one (plus a checksum command) per file. Each
+// entry is written to its destination and hashed in the same pass, then checked
+// against the digest the remote side appended to the stream. It returns the
+// files collected and the files the batch did not deliver, which the caller
+// streams individually.
+func streamNodeFilesBatch(c Collector, host string, info *RemoteNodeInfo, files []nodeFile) ([]helpers.CollectedFile, []nodeFile) {
+	var collected []helpers.CollectedFile
+	var remaining []nodeFile
+	for _, batch := range tarBatches(files) {
+		got, missed, err := streamTarBatch(c, host, info, batch)
+		if err != nil {
+			simplelog.Warningf("stream batch: %v — %v, streaming the %d remaining files one at a time", host, err, len(missed))
+		}
+		collected = append(collected, got...)
+		remaining = append(remaining, missed...)
+	}
+	return collected, remaining
+}
+
+// streamTarBatch runs one remote tar command for batch and demultiplexes the
+// archive into the destination paths. Entries completed before an error are
+// kept; the rest are returned as missed.
+func streamTarBatch(c Collector, host string, info *RemoteNodeInfo, batch []nodeFile) ([]helpers.CollectedFile, []nodeFile, error) {
+	paths := make([]string, len(batch))
+	for i, nf := range batch {
+		paths[i] = nf.remote.Path
+	}
+	compress := ""
+	if info.GzipAvailable {
+		compress = "gzip -c"
+	}
+	streamCmd := RemoteTarCommand(paths, info.ChecksumTool, compress)
+	simplelog.Infof("stream batch start: %v — %d files in one tar stream", host, len(batch))
+
+	pr, pipew := io.Pipe()
+	streamErrCh := make(chan error, 1)
+	go func() {
+		err := c.StreamCommandFromHost(host, streamCmd, pipew)
+		pipew.CloseWithError(err) // signals EOF or error to reader side
+		streamErrCh <- err
+	}()
+
+	idx := newTarEntryIndex(batch)
+	written := make(map[int]int64)
+	localHashes := make(map[int]string)
+
+	// Everything below reads the decompressed stream: the archive, then the checksum lines.
+	var stream io.Reader = bufio.NewReaderSize(pr, streamReadBufSize)
+	if info.GzipAvailable {
+		gz, err := gzip.NewReader(stream)
+		if err != nil {
+			_ = pr.Close()
+			return nil, batch, fmt.Errorf("gzip reader init failed: %w", err)
+		}
+		defer gz.Close() //nolint:errcheck
+		stream = bufio.NewReaderSize(gz, streamReadBufSize)
+	}
+
+	bf := bufio.NewWriterSize(nil, streamWriteBufSize)
+	copyBuf := make([]byte, copyBufSize)
+	tr := tar.NewReader(stream)
+	var readErr error
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = fmt.Errorf("reading tar stream: %w", err)
+			break
+		}
+		i, ok := idx.lookup(hdr.Name)
+		var src io.Reader = tr
+		switch {
+		case !ok:
+			simplelog.Infof("stream batch: %v — ignoring unrequested tar entry %v", host, hdr.Name)
+			continue
+		case hdr.Typeflag == tar.TypeLink:
+			// -h turns a symlink to a file already in the archive into a hard link to it
+			j, linked := idx.lookup(hdr.Linkname)
+			if _, done := written[j]; !linked || !done {
+				simplelog.Infof("stream batch: %v — tar entry %v links to %v which was not received", host, hdr.Name, hdr.Linkname)
+				continue
+			}
+			f, err := os.Open(filepath.Clean(batch[j].destPath))
+			if err != nil {
+				simplelog.Warningf("stream batch: %v — %v", host, err)
+				continue
+			}
+			src = f
+		case hdr.Typeflag != tar.TypeReg:
+			simplelog.Infof("stream batch: %v — ignoring tar entry %v (type %c)", host, hdr.Name, hdr.Typeflag)
+			continue
+		}
+		nf := batch[i]
+		var h hash.Hash
+		if info.ChecksumTool != "" {
+			h = newChecksumHash(info.ChecksumTool)
+		}
+		n, err := writeTarEntry(src, nf.destPath, hdr.Size, h, bf, copyBuf, host)
+		if f, isFile := src.(*os.File); isFile {
+			_ = f.Close()
+		}
+		if err != nil {
+			_ = os.Remove(nf.destPath)
+			readErr = fmt.Errorf("writing %v: %w", nf.destPath, err)
+			break
+		}
+		written[i] = n
+		if h != nil {
+			localHashes[i] = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+
+	var remoteHashes map[int]string
+	if readErr == nil && info.ChecksumTool != "" {
+		trailer, err := io.ReadAll(io.LimitReader(stream, maxChecksumTrailerBytes))
+		if err != nil {
+			simplelog.Warningf("stream batch: %v — failed reading checksums after the archive: %v", host, err)
+		}
+		// the archive is padded with NULs to a full tar record before the checksum lines
+		remoteHashes = parseChecksumLines(string(bytes.TrimLeft(trailer, "\x00")), idx)
+	}
+	_ = pr.Close()
+	if readErr == nil {
+		if err := <-streamErrCh; err != nil {
+			readErr = err
+		}
+	}
+
+	var collected []helpers.CollectedFile
+	var notDelivered []nodeFile
+	for i, nf := range batch {
+		n, ok := written[i]
+		if !ok {
+			notDelivered = append(notDelivered, nf)
+			continue
+		}
+		if info.ChecksumTool == "" {
+			simplelog.Warningf("checksum verification skipped for %v:%v — no checksum tool available", host, nf.remote.Path)
+		} else {
+			compareChecksum(host, nf.remote.Path, localHashes[i], remoteHashes[i], info.ChecksumTool)
+		}
+		maskIfConfig(nf.remote, nf.destPath)
+		simplelog.Infof("stream complete: %v:%v (%d bytes, tar)", host, nf.remote.Path, n)
+		collected = append(collected, helpers.CollectedFile{Path: nf.destPath, Size: n, SHA256: streamedSHA256(nf.remote, localHashes[i], info.ChecksumTool)})
+	}
+	if readErr == nil && len(notDelivered) > 0 {
+		readErr = fmt.Errorf("%d of %d files missing from the tar stream", len(notDelivered), len(batch))
+	}
+	return collected, notDelivered, readErr
+}
+
+// writeTarEntry copies the current tar entry to destPath through bf, feeding h
+// (when set) in the same pass, and reports progress to the TUI.
+func writeTarEntry(r io.Reader, destPath string, size int64, h hash.Hash, bf *bufio.Writer, copyBuf []byte, host string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), DirPerms); err != nil {
+		return 0, fmt.Errorf("failed to create destination dir for %v: %w", destPath, err)
+	}
+	f, err := os.OpenFile(filepath.Clean(destPath), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create file %v: %w", destPath, err)
+	}
+	bf.Reset(f)
+	var w io.Writer = bf
+	if h != nil {
+		w = io.MultiWriter(bf, h)
+	}
+	pw := &progressWriter{w: w, expectedSize: size, host: host, filename: filepath.Base(destPath)}
+	if _, err := io.CopyBuffer(pw, r, copyBuf); err != nil { // #nosec G110 -- source is trusted dremio cluster output
+		_ = f.Close()
+		return pw.n, err
+	}
+	if err := bf.Flush(); err != nil {
+		_ = f.Close()
+		return pw.n, fmt.Errorf("flush failed for %v: %w", destPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return pw.n, fmt.Errorf("close failed for %v: %w", destPath, err)
+	}
+	return pw.n, nil
+}
@@ -0,0 +1,283 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// nopWriteCloser stands in for gzip when the node streams without compression.
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// writeTarStream writes files as the remote "{ tar -chf - ...; sha256sum ...; } | gzip -c"
+// would: member names without the leading "/", then the checksum lines.
+func writeTarStream(t *testing.T, w io.Writer, files map[string]string, order []string, withChecksums, compressed bool) {
+	t.Helper()
+	var gz io.WriteCloser = nopWriteCloser{w}
+	if compressed {
+		gz = gzip.NewWriter(w)
+	}
+	tw := tar.NewWriter(gz)
+	for _, p := range order {
+		content := files[p]
+		if err := tw.WriteHeader(&tar.Header{Name: strings.TrimPrefix(p, "/"), Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if withChecksums {
+		// pad to a full 10 KiB record as GNU tar does
+		if _, err := gz.Write(make([]byte, 8192)); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range order {
+			sum := sha256.Sum256([]byte(files[p]))
+			fmt.Fprintf(gz, "%s  %s\n", hex.EncodeToString(sum[:]), p)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteTarCommand(t *testing.T) {
+	got := RemoteTarCommand([]string{"/var/log/dremio/server.log", "/opt/dremio/conf/it's.conf"}, "sha256sum", "gzip -c")
+	want := `{ tar -chf - '/var/log/dremio/server.log' '/opt/dremio/conf/it'\''s.conf'; sha256sum '/var/log/dremio/server.log' '/opt/dremio/conf/it'\''s.conf'; } | gzip -c`
+	if got != want {
+		t.Errorf("RemoteTarCommand() =\n%v\nwant\n%v", got, want)
+	}
+	got = RemoteTarCommand([]string{"/var/log/dremio/server.log"}, "", "")
+	if got != "tar -chf - '/var/log/dremio/server.log' | cat" {
+		t.Errorf("unexpected command without checksum or compression: %v", got)
+	}
+}
+
+func TestTarBatches(t *testing.T) {
+	var files []nodeFile
+	long := "/var/log/dremio/" + strings.Repeat("a", 1000)
+	for i := 0; i < 200; i++ {
+		files = append(files, nodeFile{remote: RemoteFileInfo{Path: fmt.Sprintf("%s.%d", long, i)}})
+	}
+	batches := tarBatches(files)
+	if len(batches) < 2 {
+		t.Fatalf("expected the file list to be split, got %d batch", len(batches))
+	}
+	total := 0
+	for _, b := range batches {
+		total += len(b)
+	}
+	if total != len(files) {
+		t.Errorf("expected all %d files across the batches, got %d", len(files), total)
+	}
+}
+
+func TestTarEntryIndexLookup(t *testing.T) {
+	idx := newTarEntryIndex([]nodeFile{
+		{remote: RemoteFileInfo{Path: "/var/log/dremio/server.log"}},
+		{remote: RemoteFileInfo{Path: "/opt/dremio/conf/dremio.conf"}},
+	})
+	for name, want := range map[string]int{
+		"var/log/dremio/server.log":                0,
+		"/opt/dremio/conf/dremio.conf":             1,
+		"proc/1/root/var/log/dremio/server.log":    0,
+		"./var/log/dremio/../dremio/server.log":    0,
+		"/proc/1/root/opt/dremio/conf/dremio.conf": 1,
+	} {
+		if got, ok := idx.lookup(name); !ok || got != want {
+			t.Errorf("lookup(%q) = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+	if _, ok := idx.lookup("var/log/dremio/server.log.1"); ok {
+		t.Error("expected no match for a file that was not requested")
+	}
+}
+
+func TestStreamNodeFiles_Batch(t *testing.T) {
+	tmpDir := t.TempDir()
+	cs := &mockCopyStrategy{tmpDir: tmpDir}
+	files := map[string]string{
+		"/var/log/dremio/server.log":   "log line\n",
+		"/opt/dremio/conf/dremio.conf": `services.javax.net.ssl.keyStorePassword: "super-secret"`,
+	}
+	order := []string{"/var/log/dremio/server.log", "/opt/dremio/conf/dremio.conf"}
+
+	var perFile, execs atomic.Int32
+	var gotCmd string
+	mc := &mockStreamCollector{
+		streamCmdFunc: func(_, streamCmd string, writer io.Writer) error {
+			gotCmd = streamCmd
+			writeTarStream(t, writer, files, order, true, true)
+			return nil
+		},
+		streamFunc: func(_, _ string, _ io.Writer) error {
+			perFile.Add(1)
+			return nil
+		},
+		hostExecuteFunc: func(_ bool, _ string, _ ...string) (string, error) {
+			execs.Add(1)
+			return "", nil
+		},
+	}
+	info := &RemoteNodeInfo{
+		ChecksumTool:  "sha256sum",
+		GzipAvailable: true,
+		TarAvailable:  true,
+		Files: []RemoteFileInfo{
+			{Path: "/var/log/dremio/server.log", Size: 9, FileType: "log"},
+			{Path: "/opt/dremio/conf/dremio.conf", Size: 55, FileType: "config"},
+		},
+	}
+
+	collected, skipped := streamNodeFiles(mc, "host1", info, cs, "coordinator", "diagnosis", true, Args{CollectServerLogs: true})
+
+	if len(skipped) != 0 || len(collected) != 2 {
+		t.Fatalf("expected 2 collected and none skipped, got %v and %v", collected, skipped)
+	}
+	if perFile.Load() != 0 || execs.Load() != 0 {
+		t.Errorf("expected one tar stream and no per-file streams or checksum commands, got %d streams and %d commands", perFile.Load(), execs.Load())
+	}
+	if !strings.HasPrefix(gotCmd, "{ tar -chf - ") || !strings.HasSuffix(gotCmd, "| gzip -c") {
+		t.Errorf("unexpected remote command %v", gotCmd)
+	}
+	logData, err := os.ReadFile(filepath.Join(tmpDir, "logs", "host1", "server.log"))
+	if err != nil || string(logData) != "log line\n" {
+		t.Errorf("expected the log demultiplexed from the tar stream, got %q, %v", logData, err)
+	}
+	confData, err := os.ReadFile(filepath.Join(tmpDir, "configuration", "host1", "dremio.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(confData), "super-secret") {
+		t.Error("config file secret was not masked after the tar stream")
+	}
+}
+
+func TestStreamNodeFiles_BatchFallsBackPerFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	cs := &mockCopyStrategy{tmpDir: tmpDir}
+	files := map[string]string{"/var/log/dremio/server.log": "log line\n"}
+
+	var streamed []string
+	mc := &mockStreamCollector{
+		streamCmdFunc: func(_, _ string, writer io.Writer) error {
+			// the tracker file vanished between discovery and collection
+			writeTarStream(t, writer, files, []string{"/var/log/dremio/server.log"}, false, false)
+			return nil
+		},
+		streamFunc: func(_, remotePath string, writer io.Writer) error {
+			streamed = append(streamed, remotePath)
+			_, err := writer.Write([]byte("tracker\n"))
+			return err
+		},
+	}
+	info := &RemoteNodeInfo{
+		TarAvailable: true,
+		Files: []RemoteFileInfo{
+			{Path: "/var/log/dremio/server.log", Size: 9, FileType: "log"},
+			{Path: "/var/log/dremio/tracker.json", Size: 8, FileType: "log"},
+		},
+	}
+
+	collected, skipped := streamNodeFiles(mc, "host1", info, cs, "coordinator", "diagnosis", true, Args{CollectServerLogs: true, CollectTrackerJSON: true})
+	if len(skipped) != 0 || len(collected) != 2 {
+		t.Fatalf("expected 2 collected and none skipped, got %v and %v", collected, skipped)
+	}
+	if len(streamed) != 1 || streamed[0] != "/var/log/dremio/tracker.json" {
+		t.Errorf("expected only the file missing from the tar stream to be streamed on its own, got %v", streamed)
+	}
+
+	t.Run("stream failure", func(t *testing.T) {
+		streamed = nil
+		mc.streamCmdFunc = func(_, _ string, writer io.Writer) error {
+			_, _ = writer.Write([]byte("not a tar header"))
+			return fmt.Errorf("connection reset by peer")
+		}
+		collected, skipped := streamNodeFiles(mc, "host2", info, cs, "coordinator", "diagnosis", true, Args{CollectServerLogs: true, CollectTrackerJSON: true})
+		if len(skipped) != 0 || len(collected) != 2 || len(streamed) != 2 {
+			t.Errorf("expected both files streamed one at a time, got collected=%v skipped=%v streamed=%v", collected, skipped, streamed)
+		}
+	})
+
+	t.Run("no tar", func(t *testing.T) {
+		streamed = nil
+		var tarCalls atomic.Int32
+		mc.streamCmdFunc = func(_, _ string, _ io.Writer) error {
+			tarCalls.Add(1)
+			return nil
+		}
+		noTar := *info
+		noTar.TarAvailable = false
+		streamNodeFiles(mc, "host3", &noTar, cs, "coordinator", "diagnosis", true, Args{CollectServerLogs: true, CollectTrackerJSON: true})
+		batchOff := *info
+		streamNodeFiles(mc, "host4", &batchOff, cs, "coordinator", "diagnosis", true, Args{CollectServerLogs: true, CollectTrackerJSON: true, DisableBatchStreaming: true})
+		if tarCalls.Load() != 0 || len(streamed) != 4 {
+			t.Errorf("expected per-file streaming only, got %d tar streams and %v", tarCalls.Load(), streamed)
+		}
+	})
+}
+
+func TestParseChecksumLines(t *testing.T) {
+	idx := newTarEntryIndex([]nodeFile{
+		{remote: RemoteFileInfo{Path: "/var/log/dremio/server.log"}},
+		{remote: RemoteFileInfo{Path: "/var/log/dremio/gc.log"}},
+	})
+	got := parseChecksumLines("abc123  /var/log/dremio/server.log\ndef456 */var/log/dremio/gc.log\nsha256sum: /x: No such file\n", idx)
+	if got[0] != "abc123" || got[1] != "def456" || len(got) != 2 {
+		t.Errorf("unexpected checksums %v", got)
+	}
+}
+
+func TestStreamTarBatch_HardLinkEntry(t *testing.T) {
+	dir := t.TempDir()
+	mc := &mockStreamCollector{
+		streamCmdFunc: func(_, _ string, writer io.Writer) error {
+			tw := tar.NewWriter(writer)
+			_ = tw.WriteHeader(&tar.Header{Name: "var/log/dremio/server.log", Size: 5, Typeflag: tar.TypeReg})
+			_, _ = tw.Write([]byte("hello"))
+			// tar -h stores a symlink to a file it already archived as a hard link
+			_ = tw.WriteHeader(&tar.Header{Name: "var/log/dremio/current.log", Linkname: "var/log/dremio/server.log", Typeflag: tar.TypeLink})
+			return tw.Close()
+		},
+	}
+	batch := []nodeFile{
+		{remote: RemoteFileInfo{Path: "/var/log/dremio/server.log"}, destPath: filepath.Join(dir, "server.log")},
+		{remote: RemoteFileInfo{Path: "/var/log/dremio/current.log"}, destPath: filepath.Join(dir, "current.log")},
+	}
+	collected, missed, err := streamTarBatch(mc, "host1", &RemoteNodeInfo{}, batch)
+	if err != nil || len(missed) != 0 || len(collected) != 2 {
+		t.Fatalf("expected both files, got collected=%v missed=%v err=%v", collected, missed, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "current.log"))
+	if err != nil || string(data) != "hello" {
+		t.Errorf("expected the linked file's content, got %q, %v", data, err)
+	}
+}
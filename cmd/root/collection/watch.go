@@ -0,0 +1,452 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+// Kinds of watch triggers.
+const (
+	WatchTriggerLogPattern = "log-pattern"
+	WatchTriggerHeap       = "heap"
+	WatchTriggerGCPause    = "gc-pause"
+)
+
+// Decisions RunWatch takes on a trigger.
+const (
+	watchDecisionCollect  = "collect"
+	watchDecisionCooldown = "cooldown"
+)
+
+// watchMaxReadBytes caps what a single poll reads of a log: after a burst
+// larger than this only its end is checked.
+const watchMaxReadBytes int64 = 4 * 1024 * 1024
+
+// WatchArgs configures RunWatch.
+type WatchArgs struct {
+	Interval       time.Duration
+	Cooldown       time.Duration       // per node, counted from the end of its last collection
+	LogPatterns    []logparser.Pattern // server.log lines that trigger, empty disables the check
+	HeapPercent    int                 // used heap of jcmd GC.heap_info as a percentage of -Xmx that triggers, 0 disables
+	GCPause        time.Duration       // GC pause that triggers, 0 disables
+	MaxCollections int                 // stop watching after this many collections, 0 = until interrupted
+
+	CoordinatorLogDir string
+	ExecutorLogDir    string
+	DremioConfDir     string
+	IncludeNodes      []string
+	ExcludeNodes      []string
+
+	// Out receives one line per trigger decision, next to the ddc.log entry
+	Out io.Writer
+}
+
+// WatchTrigger is a problem ddc watch saw on a node.
+type WatchTrigger struct {
+	Time     time.Time `json:"time"`
+	Host     string    `json:"host"`
+	NodeType string    `json:"nodeType"`
+	Kind     string    `json:"kind"`
+	Detail   string    `json:"detail"`
+}
+
+// WatchCollect runs the scoped collection of a trigger.
+type WatchCollect func(t WatchTrigger) error
+
+// watchNode is what the watch keeps between polls for one node.
+type watchNode struct {
+	host      string
+	nodeType  string
+	pid       int
+	maxHeapKB int64 // -Xmx from jcmd VM.flags, 0 when unknown
+	serverLog string
+	serverPos int64
+	gcLog     string
+	gcPos     int64
+	stale     bool // rediscover before the next checks, e.g. after the JVM went away
+	cooldown  time.Time
+}
+
+type watcher struct {
+	c           Collector
+	args        WatchArgs
+	collect     WatchCollect
+	now         func() time.Time
+	nodes       []*watchNode
+	collections int
+}
+
+// RunWatch polls every node of c for the configured triggers until ctx is done
+// or MaxCollections collections ran. A trigger starts collect unless its node is
+// still in its cooldown; every decision is logged. Only reads run on the nodes
+// between collections: tails of server.log and the GC log and jcmd GC.heap_info,
+// plus jcmd VM.flags once per discovered JVM.
+func RunWatch(ctx context.Context, c Collector, args WatchArgs, collect WatchCollect) error {
+	w := &watcher{c: c, args: args, collect: collect, now: time.Now}
+	if err := w.discover(); err != nil {
+		return err
+	}
+	simplelog.Infof("watch: polling %d node(s) every %v with a cooldown of %v", len(w.nodes), args.Interval, args.Cooldown)
+	for {
+		if w.poll(ctx) {
+			simplelog.Infof("watch: %d collection(s) done, stopping", w.collections)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			simplelog.Infof("watch: stopped after %d collection(s)", w.collections)
+			return nil
+		case <-time.After(args.Interval):
+		}
+	}
+}
+
+// discover lists the nodes to watch and starts every log at its current end,
+// so only lines written after ddc watch started can trigger.
+func (w *watcher) discover() error {
+	coordinators, err := w.c.GetCoordinators()
+	if err != nil {
+		return fmt.Errorf("failed to get coordinators: %w", err)
+	}
+	executorsRaw, err := w.c.GetExecutors()
+	if err != nil {
+		return fmt.Errorf("failed to get executors: %w", err)
+	}
+	coordinators = FilterCoordinators(coordinators)
+	executors := FilterExecutors(executorsRaw, coordinators)
+	coordinators = FilterByNodeSelection(coordinators, w.args.IncludeNodes, w.args.ExcludeNodes)
+	executors = FilterByNodeSelection(executors, w.args.IncludeNodes, w.args.ExcludeNodes)
+	if len(coordinators)+len(executors) == 0 {
+		return fmt.Errorf("no hosts found, nothing to watch: %v", w.c.HelpText())
+	}
+	for i, host := range append(append([]string{}, coordinators...), executors...) {
+		n := &watchNode{host: host, nodeType: "executor"}
+		if i < len(coordinators) {
+			n.nodeType = "coordinator"
+		}
+		w.refresh(n)
+		w.nodes = append(w.nodes, n)
+	}
+	return nil
+}
+
+// refresh (re)discovers the PID and logs of a node. A log that is still the
+// same file keeps its position.
+func (w *watcher) refresh(n *watchNode) {
+	n.stale = false
+	logDir := w.args.CoordinatorLogDir
+	if n.nodeType == "executor" {
+		logDir = w.args.ExecutorLogDir
+	}
+	info, err := w.c.DiscoverFiles(n.host, logDir, w.args.DremioConfDir)
+	if err != nil {
+		simplelog.Warningf("watch: discovery failed on %v, retrying on the next poll: %v", n.host, err)
+		n.stale = true
+		return
+	}
+	n.pid = info.DremioPID
+	n.maxHeapKB = 0
+	if w.args.HeapPercent > 0 && n.pid > 0 {
+		n.maxHeapKB = w.maxHeap(n)
+	}
+	serverLog, gcLog := "", ""
+	if info.LogDir != "" {
+		serverLog = path.Join(info.LogDir, "server.log")
+	}
+	var gcModTime int64
+	for _, f := range info.Files {
+		if f.FileType == "gc-log" && f.ModTime >= gcModTime {
+			gcLog, gcModTime = f.Path, f.ModTime
+		}
+	}
+	if serverLog != n.serverLog {
+		n.serverLog, n.serverPos = serverLog, w.end(n.host, serverLog)
+	}
+	if gcLog != n.gcLog {
+		n.gcLog, n.gcPos = gcLog, w.end(n.host, gcLog)
+	}
+	simplelog.Infof("watch: %v (%v): pid %v, server.log %q, gc log %q", n.host, n.nodeType, n.pid, n.serverLog, n.gcLog)
+}
+
+// maxHeap returns the -Xmx of the Dremio JVM of a node in KB, 0 when jcmd VM.flags
+// does not report it. The heap trigger compares to it rather than to the committed
+// heap of GC.heap_info, which G1 keeps close to the used heap until the next young GC.
+func (w *watcher) maxHeap(n *watchNode) int64 {
+	out, err := jcmdExec(w.c, n.host, strconv.Itoa(n.pid), "VM.flags")
+	if err != nil {
+		simplelog.Warningf("watch: VM.flags failed on %v (pid %v), the heap trigger uses the committed heap: %v", n.host, n.pid, err)
+		return 0
+	}
+	maxBytes, err := ParseXmxBytes(out)
+	if err != nil {
+		simplelog.Warningf("watch: %v on %v (pid %v), the heap trigger uses the committed heap", err, n.host, n.pid)
+		return 0
+	}
+	return int64(maxBytes / 1024)
+}
+
+// end returns the current size of a remote file, 0 when it cannot be read.
+func (w *watcher) end(host, remotePath string) int64 {
+	if remotePath == "" {
+		return 0
+	}
+	size, err := remoteFileSize(w.c, host, remotePath)
+	if err != nil {
+		simplelog.Warningf("watch: unable to size %v:%v: %v", host, remotePath, err)
+		return 0
+	}
+	return size
+}
+
+// poll checks every node once and decides on its triggers. It reports whether
+// MaxCollections is reached, and stops early once ctx is done so no collection
+// starts after an interrupt.
+func (w *watcher) poll(ctx context.Context) bool {
+	for _, n := range w.nodes {
+		if ctx.Err() != nil {
+			return false
+		}
+		if n.stale {
+			w.refresh(n)
+			if n.stale {
+				continue
+			}
+		}
+		for _, t := range w.check(n) {
+			if ctx.Err() != nil {
+				return false
+			}
+			w.decide(n, t)
+			if w.args.MaxCollections > 0 && w.collections >= w.args.MaxCollections {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// check runs the enabled checks on a node and returns the triggers that fired.
+func (w *watcher) check(n *watchNode) []WatchTrigger {
+	var triggers []WatchTrigger
+	fire := func(kind, detail string) {
+		triggers = append(triggers, WatchTrigger{Time: w.now().UTC(), Host: n.host, NodeType: n.nodeType, Kind: kind, Detail: detail})
+	}
+
+	if len(w.args.LogPatterns) > 0 && n.serverLog != "" {
+		lines, err := readNewLines(w.c, n.host, n.serverLog, &n.serverPos)
+		if err != nil {
+			simplelog.Warningf("watch: unable to read %v:%v: %v", n.host, n.serverLog, err)
+		}
+		var first string
+		var firstPattern logparser.Pattern
+		var matched int
+		for _, line := range lines {
+			if p, ok := logparser.MatchLine(w.args.LogPatterns, line); ok {
+				if matched == 0 {
+					first, firstPattern = line, p
+				}
+				matched++
+			}
+		}
+		if matched > 0 {
+			fire(WatchTriggerLogPattern, fmt.Sprintf("%v matched %d new server.log line(s), first: %v", firstPattern.Name, matched, truncateLine(first)))
+		}
+	}
+
+	if w.args.HeapPercent > 0 && n.pid > 0 {
+		out, err := jcmdExec(w.c, n.host, strconv.Itoa(n.pid), "GC.heap_info")
+		if err != nil {
+			simplelog.Warningf("watch: GC.heap_info failed on %v (pid %v), rediscovering: %v", n.host, n.pid, err)
+			n.stale = true
+		} else if usedKB, committedKB, ok := parseHeapInfo(out); !ok {
+			simplelog.Warningf("watch: no heap occupancy in the GC.heap_info output of %v", n.host)
+		} else {
+			maxKB, of := n.maxHeapKB, "max"
+			if maxKB == 0 {
+				maxKB, of = committedKB, "committed"
+			}
+			if percent := int(usedKB * 100 / maxKB); percent >= w.args.HeapPercent {
+				fire(WatchTriggerHeap, fmt.Sprintf("heap %d%% used (%v of %v %v), threshold %d%%", percent, formatFileSize(usedKB*1024), of, formatFileSize(maxKB*1024), w.args.HeapPercent))
+			} else {
+				simplelog.Debugf("watch: %v heap %d%% used (%v of %v %v)", n.host, percent, formatFileSize(usedKB*1024), of, formatFileSize(maxKB*1024))
+			}
+		}
+	}
+
+	if w.args.GCPause > 0 && n.gcLog != "" {
+		lines, err := readNewLines(w.c, n.host, n.gcLog, &n.gcPos)
+		if err != nil {
+			simplelog.Warningf("watch: unable to read %v:%v: %v", n.host, n.gcLog, err)
+		}
+		var longest time.Duration
+		var longestLine string
+		for _, line := range lines {
+			if pause, ok := parseGCPause(line); ok && pause > longest {
+				longest, longestLine = pause, line
+			}
+		}
+		if longest >= w.args.GCPause {
+			fire(WatchTriggerGCPause, fmt.Sprintf("GC pause of %v, threshold %v: %v", longest, w.args.GCPause, truncateLine(longestLine)))
+		}
+	}
+	return triggers
+}
+
+// decide collects for a trigger unless its node is cooling down, and logs the decision.
+func (w *watcher) decide(n *watchNode, t WatchTrigger) {
+	if now := w.now(); now.Before(n.cooldown) {
+		w.logDecision(t, watchDecisionCooldown, fmt.Sprintf("skipped, %v cools down until %v", n.host, n.cooldown.UTC().Format(time.RFC3339)))
+		return
+	}
+	w.logDecision(t, watchDecisionCollect, "starting a scoped collection")
+	w.collections++
+	if err := w.collect(t); err != nil {
+		simplelog.Errorf("watch: collection for the %v trigger on %v failed: %v", t.Kind, t.Host, err)
+	}
+	n.cooldown = w.now().Add(w.args.Cooldown)
+	simplelog.Infof("watch: %v cools down until %v", n.host, n.cooldown.UTC().Format(time.RFC3339))
+}
+
+func (w *watcher) logDecision(t WatchTrigger, decision, detail string) {
+	line := fmt.Sprintf("%v %v trigger on %v (%v): %v -> %v: %v", t.Time.Format(time.RFC3339), t.Kind, t.Host, t.NodeType, t.Detail, decision, detail)
+	simplelog.Infof("watch: %v", line)
+	if w.args.Out != nil {
+		fmt.Fprintln(w.args.Out, line)
+	}
+}
+
+// remoteFileSize returns the size in bytes of a remote file.
+func remoteFileSize(c Collector, host, remotePath string) (int64, error) {
+	var out bytes.Buffer
+	if err := c.StreamCommandFromHost(host, "wc -c < "+shellQuote(remotePath), &out); err != nil {
+		return 0, err
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(out.String()), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected wc output %q: %w", out.String(), err)
+	}
+	return size, nil
+}
+
+// readNewLines returns the complete lines appended to a remote file since
+// *pos and moves *pos past them. A file smaller than *pos was rotated and is
+// read from the start.
+func readNewLines(c Collector, host, remotePath string, pos *int64) ([]string, error) {
+	size, err := remoteFileSize(c, host, remotePath)
+	if err != nil {
+		return nil, err
+	}
+	if size < *pos {
+		simplelog.Infof("watch: %v:%v was rotated, reading the new file from the start", host, remotePath)
+		*pos = 0
+	}
+	if size == *pos {
+		return nil, nil
+	}
+	start := *pos
+	// starting mid-file the first line is cut, it is dropped
+	midLine := false
+	if size-start > watchMaxReadBytes {
+		start = size - watchMaxReadBytes
+		midLine = true
+		simplelog.Warningf("watch: %v:%v grew by %v since the last poll, checking only the last %v", host, remotePath, formatFileSize(size-*pos), formatFileSize(watchMaxReadBytes))
+	}
+	var buf bytes.Buffer
+	if err := c.StreamFromHostAt(host, remotePath, start, &buf, false); err != nil {
+		return nil, err
+	}
+	data := buf.Bytes()
+	if int64(len(data)) > size-start {
+		// appended while streaming, left for the next poll
+		data = data[:size-start]
+	}
+	end := bytes.LastIndexByte(data, '\n')
+	if end < 0 {
+		return nil, nil
+	}
+	data = data[:end+1]
+	*pos = start + int64(len(data))
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if midLine {
+		lines = lines[1:]
+	}
+	return lines, nil
+}
+
+// heapInfoRE matches the per-space totals of jcmd GC.heap_info, e.g.
+// " garbage-first heap   total 8388608K, used 5242880K [0x..." (G1) or
+// " PSYoungGen      total 76288K, used 3932K [..." (parallel).
+var heapInfoRE = regexp.MustCompile(`total (\d+)K, used (\d+)K`)
+
+// parseHeapInfo sums the used and total KB of the heap spaces in a jcmd
+// GC.heap_info output. The total is the committed heap, not -Xmx. Metaspace is
+// reported as used/committed and not counted.
+func parseHeapInfo(out string) (usedKB, totalKB int64, ok bool) {
+	for _, m := range heapInfoRE.FindAllStringSubmatch(out, -1) {
+		total, _ := strconv.ParseInt(m[1], 10, 64)
+		used, _ := strconv.ParseInt(m[2], 10, 64)
+		totalKB += total
+		usedKB += used
+	}
+	return usedKB, totalKB, totalKB > 0
+}
+
+var (
+	// unified logging (JDK 9+): "[...][gc] GC(12) Pause Young (Normal) (G1 Evacuation Pause) 100M->50M(1024M) 12.345ms"
+	gcPauseUnifiedRE = regexp.MustCompile(`Pause.*\s(\d+(?:\.\d+)?)ms\s*$`)
+	// JDK 8: "[GC pause (G1 Evacuation Pause) (young), 0.0123456 secs]" or "[Full GC (Allocation Failure) ..., 5.1234567 secs]"
+	gcPauseLegacyRE = regexp.MustCompile(`(?:GC|Pause).*, (\d+(?:\.\d+)?) secs\]`)
+	// concurrent phases run next to the application and log their duration the same
+	// way, e.g. "[GC concurrent-mark-end, 7.1234567 secs]" or "[CMS-concurrent-sweep: 0.1/0.2 secs]"
+	gcConcurrentRE = regexp.MustCompile(`(?i)concurrent`)
+)
+
+// parseGCPause returns the pause time of a GC log line that records a pause.
+func parseGCPause(line string) (time.Duration, bool) {
+	if gcConcurrentRE.MatchString(line) {
+		return 0, false
+	}
+	if m := gcPauseUnifiedRE.FindStringSubmatch(line); m != nil {
+		ms, err := strconv.ParseFloat(m[1], 64)
+		return time.Duration(ms * float64(time.Millisecond)), err == nil
+	}
+	if m := gcPauseLegacyRE.FindStringSubmatch(line); m != nil {
+		secs, err := strconv.ParseFloat(m[1], 64)
+		return time.Duration(secs * float64(time.Second)), err == nil
+	}
+	return 0, false
+}
+
+// truncateLine shortens a log line for a trigger detail.
+func truncateLine(line string) string {
+	const maxLen = 200
+	line = strings.TrimSpace(line)
+	if len(line) > maxLen {
+		return line[:maxLen] + "..."
+	}
+	return line
+}
@@ -0,0 +1,343 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
+)
+
+// watchFiles backs a mockStreamCollector with in-memory remote files, keyed by path
+func watchFiles(mc *mockStreamCollector, files map[string]*bytes.Buffer) {
+	mc.streamCmdFunc = func(_, streamCmd string, w io.Writer) error {
+		for path, f := range files {
+			if streamCmd == "wc -c < "+shellQuote(path) {
+				_, err := fmt.Fprintf(w, "%d\n", f.Len())
+				return err
+			}
+		}
+		return fmt.Errorf("unexpected command %q", streamCmd)
+	}
+	mc.streamAtFunc = func(_, remotePath string, offset int64, w io.Writer) error {
+		f, ok := files[remotePath]
+		if !ok {
+			return errors.New("no such file")
+		}
+		_, err := w.Write(f.Bytes()[offset:])
+		return err
+	}
+}
+
+func TestReadNewLines(t *testing.T) {
+	f := bytes.NewBufferString("old line\n")
+	mc := &mockStreamCollector{}
+	watchFiles(mc, map[string]*bytes.Buffer{"/log/server.log": f})
+
+	pos := int64(f.Len())
+	f.WriteString("first\nsecond\npart")
+	lines, err := readNewLines(mc, "h", "/log/server.log", &pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(lines, []string{"first", "second"}) {
+		t.Errorf("expected the complete new lines, got %q", lines)
+	}
+
+	f.WriteString("ial\n")
+	if lines, _ = readNewLines(mc, "h", "/log/server.log", &pos); !reflect.DeepEqual(lines, []string{"partial"}) {
+		t.Errorf("expected the line completed since the last read, got %q", lines)
+	}
+	if lines, _ = readNewLines(mc, "h", "/log/server.log", &pos); lines != nil {
+		t.Errorf("expected nothing new, got %q", lines)
+	}
+
+	// rotated: the new file is smaller than the position
+	f.Reset()
+	f.WriteString("after rotation\n")
+	if lines, _ = readNewLines(mc, "h", "/log/server.log", &pos); !reflect.DeepEqual(lines, []string{"after rotation"}) {
+		t.Errorf("expected the rotated file read from the start, got %q", lines)
+	}
+	if pos != int64(f.Len()) {
+		t.Errorf("expected the position at the end of the file, got %v", pos)
+	}
+}
+
+func TestReadNewLines_Burst(t *testing.T) {
+	f := &bytes.Buffer{}
+	mc := &mockStreamCollector{}
+	watchFiles(mc, map[string]*bytes.Buffer{"/log/server.log": f})
+	pos := int64(0)
+	line := strings.Repeat("x", 99) + "\n"
+	for int64(f.Len()) <= watchMaxReadBytes {
+		f.WriteString(line)
+	}
+	f.WriteString("last\n")
+	lines, err := readNewLines(mc, "h", "/log/server.log", &pos)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[len(lines)-1] != "last" || pos != int64(f.Len()) {
+		t.Errorf("expected the end of the burst read, got %q at %v", lines[len(lines)-1], pos)
+	}
+	for _, l := range lines[:len(lines)-1] {
+		if len(l) != 99 {
+			t.Fatalf("expected the cut first line dropped, got a line of %d bytes", len(l))
+		}
+	}
+}
+
+func TestParseHeapInfo(t *testing.T) {
+	g1 := `12345:
+ garbage-first heap   total 8388608K, used 7549747K [0x0000000600000000, 0x0000000800000000)
+  region size 4096K, 1200 young (4915200K), 20 survivors (81920K)
+ Metaspace       used 180000K, committed 182000K, reserved 1214464K
+`
+	used, total, ok := parseHeapInfo(g1)
+	if !ok || used != 7549747 || total != 8388608 {
+		t.Errorf("unexpected G1 occupancy %v/%v (%v)", used, total, ok)
+	}
+
+	parallel := `12345:
+ PSYoungGen      total 76288K, used 3932K [0x000000076ab00000, 0x0000000770000000, 0x00000007c0000000)
+  eden space 65536K, 6% used [0x000000076ab00000,0x000000076aed7240,0x000000076eb00000)
+ ParOldGen       total 175104K, used 100000K [0x00000006c0000000, 0x00000006cab00000, 0x000000076ab00000)
+ Metaspace       used 3300K, committed 4480K, reserved 1056768K
+`
+	if used, total, ok = parseHeapInfo(parallel); !ok || used != 103932 || total != 251392 {
+		t.Errorf("unexpected parallel occupancy %v/%v (%v)", used, total, ok)
+	}
+
+	if _, _, ok = parseHeapInfo("12345:\nCommand executed successfully\n"); ok {
+		t.Error("expected no occupancy without heap totals")
+	}
+}
+
+func TestCheck_HeapAgainstMaxHeap(t *testing.T) {
+	// G1 right before a young GC: 3.8 of 4 GB committed used, -Xmx 16 GB
+	heapInfo := `12345:
+ garbage-first heap   total 4194304K, used 3984588K [0x0000000400000000, 0x0000000800000000)
+  region size 4096K, 900 young (3686400K), 10 survivors (40960K)
+ Metaspace       used 180000K, committed 182000K, reserved 1214464K
+`
+	vmFlags := "12345:\n-XX:CICompilerCount=4 -XX:InitialHeapSize=4294967296 -XX:MaxHeapSize=17179869184 -XX:+UseG1GC\n"
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		discoverFunc: func(string) (*RemoteNodeInfo, error) {
+			return &RemoteNodeInfo{DremioPID: 42}, nil
+		},
+		hostExecuteFunc: func(_ bool, _ string, args ...string) (string, error) {
+			if strings.Contains(args[0], "VM.flags") {
+				return vmFlags, nil
+			}
+			return heapInfo, nil
+		},
+	}
+	w := &watcher{c: mc, now: time.Now, args: WatchArgs{HeapPercent: 90}}
+	if err := w.discover(); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.nodes[0].maxHeapKB; got != 16*1024*1024 {
+		t.Fatalf("expected -Xmx of 16 GB, got %v KB", got)
+	}
+	if triggers := w.check(w.nodes[0]); len(triggers) != 0 {
+		t.Errorf("expected no trigger at 95%% of the committed but 23%% of the max heap, got %+v", triggers)
+	}
+
+	heapInfo = " garbage-first heap   total 16777216K, used 15938356K [0x0000000400000000, 0x0000000800000000)\n"
+	triggers := w.check(w.nodes[0])
+	if len(triggers) != 1 || !strings.Contains(triggers[0].Detail, "heap 95% used") || !strings.Contains(triggers[0].Detail, "of max 16.0 GB") {
+		t.Errorf("expected a heap trigger at 95%% of the max heap, got %+v", triggers)
+	}
+
+	// without VM.flags the committed heap is the only reference
+	vmFlags = "12345:\nCommand executed successfully\n"
+	heapInfo = " garbage-first heap   total 4194304K, used 3984588K [0x0, 0x1)\n"
+	w.refresh(w.nodes[0])
+	if triggers := w.check(w.nodes[0]); len(triggers) != 1 || !strings.Contains(triggers[0].Detail, "of committed") {
+		t.Errorf("expected the committed heap used without MaxHeapSize, got %+v", triggers)
+	}
+}
+
+func TestParseGCPause(t *testing.T) {
+	tests := []struct {
+		line string
+		want time.Duration
+		ok   bool
+	}{
+		{"[2026-10-18T10:00:00.000+0000][info][gc] GC(12) Pause Young (Normal) (G1 Evacuation Pause) 100M->50M(1024M) 12.500ms", 12500 * time.Microsecond, true},
+		{"[2026-10-18T10:00:00.000+0000][info][gc] GC(13) Pause Full (G1 Compaction Pause) 1000M->900M(1024M) 6123.000ms", 6123 * time.Millisecond, true},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [GC pause (G1 Evacuation Pause) (young), 0.0123000 secs]", 12300 * time.Microsecond, true},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [Full GC (Allocation Failure)  1000M->900M(1024M), 5.5000000 secs]", 5500 * time.Millisecond, true},
+		{"[2026-10-18T10:00:00.000+0000][info][gc,heap] GC(12) Eden regions: 25->0(30)", 0, false},
+		{"[2026-10-18T10:00:00.000+0000][info][gc] GC(14) Concurrent Mark Cycle 120.000ms", 0, false},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [GC (Allocation Failure) [PSYoungGen: 1000K->100K(2000K)] 3000K->2100K(8000K), 0.0234000 secs]", 23400 * time.Microsecond, true},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [GC concurrent-mark-end, 7.1234567 secs]", 0, false},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [GC concurrent-root-region-scan-end, 0.0012345 secs]", 0, false},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [GC concurrent-cleanup-end, 0.0001234 secs]", 0, false},
+		{"2026-10-18T10:00:00.000+0000: 12.345: [CMS-concurrent-sweep: 0.123/0.456 secs] [Times: user=0.10 sys=0.00, real=0.46 secs]", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseGCPause(tt.line)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parseGCPause(%q) = %v, %v; want %v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRunWatch(t *testing.T) {
+	serverLog := bytes.NewBufferString("2026-10-18 10:00:00,000 [main] INFO old OutOfMemoryError line, before the watch\n")
+	gcLog := &bytes.Buffer{}
+	heapUsed := 1000
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		executors:    []string{"exec1"},
+		discoverFunc: func(host string) (*RemoteNodeInfo, error) {
+			return &RemoteNodeInfo{LogDir: "/log/" + host, DremioPID: 42, Files: []RemoteFileInfo{
+				{Path: "/log/" + host + "/gc.log", FileType: "gc-log", ModTime: 1},
+			}}, nil
+		},
+		hostExecuteFunc: func(_ bool, host string, args ...string) (string, error) {
+			if strings.Contains(args[0], "VM.flags") {
+				return "-XX:InitialHeapSize=1024000 -XX:MaxHeapSize=10240000 -XX:+UseG1GC\n", nil
+			}
+			if host != "exec1" || !strings.Contains(args[0], "GC.heap_info") {
+				return " garbage-first heap   total 10000K, used 1000K [0x0, 0x1)\n", nil
+			}
+			return fmt.Sprintf(" garbage-first heap   total 10000K, used %dK [0x0, 0x1)\n", heapUsed), nil
+		},
+	}
+	files := map[string]*bytes.Buffer{
+		"/log/coord1/server.log": serverLog,
+		"/log/coord1/gc.log":     gcLog,
+		"/log/exec1/server.log":  {},
+		"/log/exec1/gc.log":      {},
+	}
+	watchFiles(mc, files)
+
+	patterns, err := logparser.SelectPatterns([]string{"oom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	w := &watcher{c: mc, now: time.Now, args: WatchArgs{
+		Cooldown:       time.Hour,
+		LogPatterns:    patterns,
+		HeapPercent:    90,
+		GCPause:        5 * time.Second,
+		MaxCollections: 3,
+		Out:            &out,
+	}}
+	var collected []WatchTrigger
+	w.collect = func(tr WatchTrigger) error {
+		collected = append(collected, tr)
+		return nil
+	}
+	if err := w.discover(); err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing written since the watch started
+	if w.poll(context.Background()) || len(collected) != 0 {
+		t.Fatalf("expected no trigger on existing lines, got %+v", collected)
+	}
+
+	serverLog.WriteString("2026-10-18 10:01:00,000 [1b2c3d4e-0000-1111-2222-333344445555:foreman-planning] ERROR OUT_OF_MEMORY while planning\n")
+	gcLog.WriteString("[2026-10-18T10:01:00.000+0000][info][gc] GC(7) Pause Full (G1 Compaction Pause) 9G->9G(10G) 7000.000ms\n")
+	heapUsed = 9500
+	if w.poll(context.Background()) {
+		t.Fatal("expected MaxCollections not reached yet")
+	}
+	kinds := make([]string, 0, len(collected))
+	for _, tr := range collected {
+		kinds = append(kinds, tr.Host+":"+tr.Kind)
+	}
+	// coord1 collects on its first trigger and cools down for the second; exec1 collects on its own
+	if !reflect.DeepEqual(kinds, []string{"coord1:" + WatchTriggerLogPattern, "exec1:" + WatchTriggerHeap}) {
+		t.Errorf("unexpected collections %v", kinds)
+	}
+	if !strings.Contains(out.String(), "gc-pause trigger on coord1 (coordinator)") || !strings.Contains(out.String(), "-> cooldown") {
+		t.Errorf("expected the GC pause trigger logged as cooling down, got:\n%v", out.String())
+	}
+	if !strings.Contains(collected[0].Detail, "oom_error") || !strings.Contains(collected[1].Detail, "heap 95% used (9.3 MB of max 9.8 MB)") {
+		t.Errorf("unexpected trigger details %q, %q", collected[0].Detail, collected[1].Detail)
+	}
+
+	// the cooldown is over: the next trigger collects and reaches MaxCollections
+	w.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	if !w.poll(context.Background()) || len(collected) != 3 {
+		t.Errorf("expected the third collection to stop the watch, got %d", len(collected))
+	}
+}
+
+func TestPoll_StopsBetweenNodesOnCancel(t *testing.T) {
+	var polled []string
+	mc := &mockStreamCollector{
+		coordinators: []string{"coord1"},
+		executors:    []string{"exec1"},
+		discoverFunc: func(string) (*RemoteNodeInfo, error) {
+			return &RemoteNodeInfo{DremioPID: 42}, nil
+		},
+		hostExecuteFunc: func(_ bool, host string, args ...string) (string, error) {
+			if strings.Contains(args[0], "GC.heap_info") {
+				polled = append(polled, host)
+			}
+			return " garbage-first heap   total 10000K, used 9500K [0x0, 0x1)\n", nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var collected []string
+	w := &watcher{c: mc, now: time.Now, args: WatchArgs{Cooldown: time.Hour, HeapPercent: 90}, collect: func(tr WatchTrigger) error {
+		collected = append(collected, tr.Host)
+		// Ctrl+C during the collection
+		cancel()
+		return nil
+	}}
+	if err := w.discover(); err != nil {
+		t.Fatal(err)
+	}
+	if w.poll(ctx) {
+		t.Error("expected an interrupted poll not to report MaxCollections")
+	}
+	if !reflect.DeepEqual(collected, []string{"coord1"}) || !reflect.DeepEqual(polled, []string{"coord1"}) {
+		t.Errorf("expected the nodes after the interrupt left alone, collected %v, polled %v", collected, polled)
+	}
+}
+
+func TestRunWatch_StopsOnCancel(t *testing.T) {
+	mc := &mockStreamCollector{coordinators: []string{"coord1"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := RunWatch(ctx, mc, WatchArgs{Interval: time.Hour, HeapPercent: 90}, func(WatchTrigger) error {
+		t.Error("unexpected collection")
+		return nil
+	})
+	if err != nil {
+		t.Errorf("expected a clean stop, got %v", err)
+	}
+
+	if err := RunWatch(ctx, &mockStreamCollector{}, WatchArgs{Interval: time.Hour}, nil); err == nil {
+		t.Error("expected an error without nodes")
+	}
+}
@@ -0,0 +1,388 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// docker package uses the docker or podman CLI to execute commands in Dremio containers and translate the results back to the calling node
+package docker
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+)
+
+type Args struct {
+	// CLIBinary is docker, podman or a path to either; empty tries docker, then podman.
+	CLIBinary string
+	// CoordinatorFilter and ExecutorFilter are comma separated "docker ps --filter"
+	// expressions, e.g. label=com.docker.compose.service=dremio-coordinator or
+	// ancestor=dremio/dremio-ee. Empty matches running containers whose image
+	// contains "dremio", split on whether the container name contains "executor".
+	CoordinatorFilter string
+	ExecutorFilter    string
+}
+
+// cliBinaries are the CLIs tried, in order, when none is named. podman accepts every
+// docker command DDC runs.
+var cliBinaries = []string{"docker", "podman"}
+
+// NewCmdDockerActions is the only supported way to initialize the CmdDockerActions struct
+func NewCmdDockerActions(dockerArgs Args, hook shutdown.Hook) (*CmdDockerActions, error) {
+	cliPath, err := helpers.LookupCLI(dockerArgs.CLIBinary, cliBinaries...)
+	if err != nil {
+		return &CmdDockerActions{}, err
+	}
+	simplelog.Infof("using %v for container operations", cliPath)
+	return &CmdDockerActions{
+		hook:              hook,
+		cli:               cli.NewCli(hook),
+		cliPath:           cliPath,
+		coordinatorFilter: dockerArgs.CoordinatorFilter,
+		executorFilter:    dockerArgs.ExecutorFilter,
+		pidHosts:          make(map[string]string),
+	}, nil
+}
+
+// CmdDockerActions depends on the docker or podman CLI being present and able to
+// reach the engine running the Dremio containers; hosts are container names
+type CmdDockerActions struct {
+	cli               cli.CmdExecutor
+	cliPath           string
+	coordinatorFilter string
+	executorFilter    string
+	pidHosts          map[string]string
+	m                 sync.Mutex
+	hook              shutdown.Hook
+}
+
+func (c *CmdDockerActions) isPodman() bool {
+	return strings.Contains(filepath.Base(c.cliPath), "podman")
+}
+
+func (c *CmdDockerActions) Name() string {
+	if c.isPodman() {
+		return "Podman"
+	}
+	return "Docker"
+}
+
+func (c *CmdDockerActions) Protocol() string {
+	return c.Name()
+}
+
+func (c *CmdDockerActions) SetHostPid(host, pidFile string) {
+	c.m.Lock()
+	c.pidHosts[host] = pidFile
+	c.m.Unlock()
+}
+
+func (c *CmdDockerActions) CleanupRemote() error {
+	kill := func(host string, pidFile string) {
+		if pidFile == "" {
+			simplelog.Debugf("pidfile is blank for %v skipping", host)
+			return
+		}
+		out, err := c.HostExecute(false, host, "cat", pidFile)
+		if err != nil {
+			simplelog.Warningf("output of pidfile failed for container %v: %v", host, err)
+			return
+		}
+		out = strings.TrimSpace(out)
+		if matched, _ := regexp.MatchString(`^\d+$`, out); !matched {
+			simplelog.Warningf("invalid PID %q from pidfile on container %v, skipping kill", out, host)
+			return
+		}
+		if killOut, err := c.HostExecute(false, host, "kill", "-15", out); err != nil {
+			simplelog.Warningf("failed killing process %v container %v: %v - %v", out, host, err, killOut)
+			return
+		}
+		consoleprint.UpdateNodeState(consoleprint.NodeState{
+			Node:     host,
+			Status:   consoleprint.Starting,
+			StatusUX: "FAILED - CANCELLED",
+			Result:   consoleprint.ResultFailure,
+		})
+		c.m.Lock()
+		// cancel out so we can skip if it's called again
+		c.pidHosts[host] = ""
+		c.m.Unlock()
+	}
+	c.m.Lock()
+	pidHosts := make(map[string]string, len(c.pidHosts))
+	for host, pidFile := range c.pidHosts {
+		pidHosts[host] = pidFile
+	}
+	c.m.Unlock()
+	var wg sync.WaitGroup
+	for host, pidFile := range pidHosts {
+		wg.Add(1)
+		go func(host, pidFile string) {
+			defer wg.Done()
+			kill(host, pidFile)
+		}(host, pidFile)
+	}
+	wg.Wait()
+	return nil
+}
+
+func (c *CmdDockerActions) HostExecuteAndStream(mask bool, hostString string, output cli.OutputHandler, pat string, args ...string) (err error) {
+	dockerArgs := []string{c.cliPath, "exec"}
+	if pat != "" {
+		dockerArgs = append(dockerArgs, "-i")
+	}
+	dockerArgs = append(dockerArgs, hostString, "sh", "-c", strings.Join(args, " "))
+	return c.cli.ExecuteAndStreamOutput(mask, output, pat, dockerArgs...)
+}
+
+func (c *CmdDockerActions) HostExecute(mask bool, hostString string, args ...string) (string, error) {
+	return cli.CollectOutput(c.HostExecuteAndStream, mask, hostString, args...)
+}
+
+func (c *CmdDockerActions) CopyToHost(hostString string, source, destination string) (out string, err error) {
+	return c.cli.Execute(false, c.cliPath, "cp", source, fmt.Sprintf("%v:%v", hostString, destination))
+}
+
+// dremioContainer is one line of "docker ps"
+type dremioContainer struct {
+	Name  string
+	Image string
+}
+
+// listContainers returns the running containers matching every filter, sorted by name.
+func (c *CmdDockerActions) listContainers(filters []string) ([]dremioContainer, error) {
+	args := []string{c.cliPath, "ps", "--filter", "status=running"}
+	for _, f := range filters {
+		args = append(args, "--filter", f)
+	}
+	args = append(args, "--format", "{{.Names}}\t{{.Image}}")
+	out, err := c.cli.Execute(false, args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list containers: %w - %v", err, strings.TrimSpace(out))
+	}
+	var containers []dremioContainer
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, image, _ := strings.Cut(line, "\t")
+		containers = append(containers, dremioContainer{Name: name, Image: image})
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].Name < containers[j].Name })
+	return containers, nil
+}
+
+// findHosts lists containers with the given filters, or when there are none, running
+// Dremio containers accepted by compare.
+func (c *CmdDockerActions) findHosts(filter string, compare func(name string) bool) (hosts []string, err error) {
+	var filters []string
+	for _, f := range strings.Split(filter, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			filters = append(filters, f)
+		}
+	}
+	containers, err := c.listContainers(filters)
+	if err != nil {
+		return []string{}, err
+	}
+	for _, container := range containers {
+		if len(filters) == 0 && (!strings.Contains(strings.ToLower(container.Image), "dremio") || !compare(container.Name)) {
+			continue
+		}
+		hosts = append(hosts, container.Name)
+	}
+	return hosts, nil
+}
+
+func (c *CmdDockerActions) GetCoordinators() (hosts []string, err error) {
+	return c.findHosts(c.coordinatorFilter, func(name string) bool {
+		return !strings.Contains(strings.ToLower(name), "executor")
+	})
+}
+
+func (c *CmdDockerActions) GetExecutors() (hosts []string, err error) {
+	return c.findHosts(c.executorFilter, func(name string) bool {
+		return strings.Contains(strings.ToLower(name), "executor")
+	})
+}
+
+func (c *CmdDockerActions) HelpText() string {
+	return "no Dremio containers found: run 'docker ps' (or 'podman ps') to check they are running, and if their image does not contain 'dremio' pass filters such as --coordinator-filter label=com.docker.compose.service=dremio-coordinator --executor-filter label=com.docker.compose.service=dremio-executor"
+}
+
+// StreamFromHost streams the raw bytes of a container file to writer by executing
+// "docker exec <container> sh -c <cmd>" as a subprocess where <cmd> is "cat" or
+// "gzip -c" depending on useGzip. Binary data integrity is preserved — stdout
+// goes directly to writer with no line splitting or encoding.
+func (c *CmdDockerActions) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return c.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+
+// StreamFromHostAt is StreamFromHost from offset bytes into the file, read with
+// "tail -c +N" in the container.
+func (c *CmdDockerActions) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
+	if remotePath == "" {
+		return fmt.Errorf("StreamFromHost: remotePath is empty for host %v", host)
+	}
+
+	compress := ""
+	if useGzip {
+		compress = "gzip -c"
+	}
+	streamCmd := collection.RemoteReadCommand(remotePath, offset, compress)
+	simplelog.Infof("StreamFromHost: streaming %v:%v via %v exec (cmd=%s)", host, remotePath, c.Name(), streamCmd)
+	if err := c.streamCommand(host, host+":"+remotePath, streamCmd, writer); err != nil {
+		return err
+	}
+	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
+	return nil
+}
+
+// StreamCommandFromHost runs streamCmd in the container and streams its raw
+// stdout to writer.
+func (c *CmdDockerActions) StreamCommandFromHost(host, streamCmd string, writer io.Writer) error {
+	simplelog.Infof("StreamCommandFromHost: streaming from %v via %v exec (cmd=%s)", host, c.Name(), streamCmd)
+	return c.streamCommand(host, host, streamCmd, writer)
+}
+
+// streamCommand executes "docker exec <container> sh -c <streamCmd>" with stdout
+// going directly to writer. target names what is streamed in error messages.
+func (c *CmdDockerActions) streamCommand(host, target, streamCmd string, writer io.Writer) error {
+	// #nosec G204 -- arguments are controlled by the caller
+	cmd := exec.Command(c.cliPath, "exec", host, "sh", "-c", streamCmd)
+	cmd.Stdout = writer
+
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("StreamFromHost: failed to create stderr pipe for %v: %w", target, err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("StreamFromHost: failed to start %v for %v: %w", c.cliPath, target, err)
+	}
+
+	stderrBytes, _ := io.ReadAll(stderrPipe)
+
+	if err := cmd.Wait(); err != nil {
+		stderrMsg := strings.TrimSpace(string(stderrBytes))
+		if stderrMsg != "" {
+			return fmt.Errorf("StreamFromHost: %s failed on %v: %w (stderr: %s)", streamCmd, target, err, stderrMsg)
+		}
+		return fmt.Errorf("StreamFromHost: %s failed on %v: %w", streamCmd, target, err)
+	}
+	return nil
+}
+
+// DiscoverFiles runs remote discovery shell commands in a container (via docker exec)
+// to enumerate log files, config files, GC logs, and the Dremio PID.
+func (c *CmdDockerActions) DiscoverFiles(host, logDir, confDir string) (*collection.RemoteNodeInfo, error) {
+	return collection.RunDiscovery(func(h string, args ...string) (string, error) {
+		return c.HostExecute(false, h, args...)
+	}, host, logDir, confDir)
+}
+
+// ClusterExecute is the container engine's counterpart of collection.ClusterK8sExecute:
+// it writes docker/info.json, docker/inspect.json for the Dremio containers with
+// credentials in their environment masked and, with collectLogs, each container's
+// log to docker/container-logs/<container>.txt.gz bounded by opts.
+func (c *CmdDockerActions) ClusterExecute(cs collection.CopyStrategy, ddfs helpers.Filesystem, collectLogs bool, opts collection.ContainerLogOptions) error {
+	coordinators, err := c.GetCoordinators()
+	if err != nil {
+		return err
+	}
+	executors, err := c.GetExecutors()
+	if err != nil {
+		return err
+	}
+	containers := append(append([]string{}, coordinators...), executors...)
+	if len(containers) == 0 {
+		return fmt.Errorf("no Dremio containers found to inspect")
+	}
+	path, err := cs.CreatePath("docker", "", "")
+	if err != nil {
+		return fmt.Errorf("trying to construct docker path %v with error %w", path, err)
+	}
+
+	consoleprint.UpdateResult(fmt.Sprintf("Collecting %v engine info...", c.Name()))
+	if out, err := c.cli.Execute(false, c.cliPath, "info", "--format", "{{json .}}"); err != nil {
+		simplelog.Warningf("unable to get %v info: %v - %v", c.Name(), err, strings.TrimSpace(out))
+	} else if err := ddfs.WriteFile(filepath.Join(path, "info.json"), []byte(out), collection.DirPerms); err != nil {
+		simplelog.Errorf("trying to write file %v, error was %v", filepath.Join(path, "info.json"), err)
+	}
+
+	consoleprint.UpdateResult(fmt.Sprintf("Inspecting %d container(s)...", len(containers)))
+	inspectArgs := append([]string{c.cliPath, "inspect"}, containers...)
+	out, err := c.cli.Execute(false, inspectArgs...)
+	if err != nil {
+		simplelog.Errorf("unable to inspect containers %v: %v - %v", containers, err, strings.TrimSpace(out))
+	} else if masked, err := masking.RemoveSecretsFromDockerInspect([]byte(out)); err != nil {
+		simplelog.Errorf("unable to mask secrets in container inspect output: %v", err)
+	} else if err := ddfs.WriteFile(filepath.Join(path, "inspect.json"), []byte(masked), collection.DirPerms); err != nil {
+		simplelog.Errorf("trying to write file %v, error was %v", filepath.Join(path, "inspect.json"), err)
+	}
+
+	if !collectLogs {
+		simplelog.Info("skipping container log collection (disabled)")
+		return nil
+	}
+	logPath, err := cs.CreatePath("docker", "container-logs", "")
+	if err != nil {
+		return fmt.Errorf("trying to create container log path %v with error %w", logPath, err)
+	}
+	for i, container := range containers {
+		consoleprint.UpdateResult(fmt.Sprintf("Collecting container logs (%d/%d): %s...", i+1, len(containers), container))
+		c.copyContainerLog(ddfs, container, filepath.Join(logPath, container+".txt.gz"), opts)
+	}
+	return nil
+}
+
+// copyContainerLog streams "docker logs" (stdout and stderr) through gzip to outFile.
+func (c *CmdDockerActions) copyContainerLog(ddfs helpers.Filesystem, container, outFile string, opts collection.ContainerLogOptions) {
+	args := []string{c.cliPath, "logs"}
+	if !opts.SinceTime.IsZero() {
+		args = append(args, "--since", opts.SinceTime.UTC().Format(time.RFC3339))
+	}
+	if tail := opts.TailLines(); tail > 0 {
+		args = append(args, "--tail", strconv.FormatInt(tail, 10))
+	}
+	args = append(args, container)
+	simplelog.Debugf("getting logs for container: %v", container)
+	pr, pw := io.Pipe()
+	go func() {
+		err := c.cli.ExecuteAndStreamOutput(false, func(line string) {
+			_, _ = io.WriteString(pw, line+"\n")
+		}, "", args...)
+		_ = pw.CloseWithError(err)
+	}()
+	err := collection.WriteContainerLog(ddfs, container, outFile, pr, opts.LimitBytes)
+	// unblock the command if the log stopped being read early
+	_ = pr.Close()
+	if err != nil {
+		simplelog.Warningf("unable to copy log for container: %v with error: %v", container, err)
+	}
+}
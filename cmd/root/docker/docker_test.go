@@ -0,0 +1,204 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// docker package uses the docker or podman CLI to execute commands in Dremio containers and translate the results back to the calling node
+package docker
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/tests"
+)
+
+const psOutput = "compose-dremio-coordinator-1\tdremio/dremio-oss:25.0\n" +
+	"compose-dremio-executor-1\tdremio/dremio-oss:25.0\n" +
+	"compose-zookeeper-1\tzookeeper:3.8\n"
+
+func TestDockerExec(t *testing.T) {
+	mockCli := &tests.MockCli{
+		StoredResponse: []string{"success"},
+		StoredErrors:   []error{nil},
+	}
+	c := &CmdDockerActions{cli: mockCli, cliPath: "docker"}
+	out, err := c.HostExecute(false, "dremio", "ls", "-l")
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	if out != "success" {
+		t.Errorf("expected success but got %v", out)
+	}
+	expectedCall := []string{"docker", "exec", "dremio", "sh", "-c", "ls -l"}
+	if len(mockCli.Calls) != 1 || !reflect.DeepEqual(mockCli.Calls[0], expectedCall) {
+		t.Errorf("expected %v call but got %v", expectedCall, mockCli.Calls)
+	}
+}
+
+func TestDockerCopyToHost(t *testing.T) {
+	mockCli := &tests.MockCli{
+		StoredResponse: []string{""},
+		StoredErrors:   []error{nil},
+	}
+	c := &CmdDockerActions{cli: mockCli, cliPath: "/usr/bin/podman"}
+	if _, err := c.CopyToHost("dremio", "/tmp/ddc", "/opt/dremio/ddc"); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	expectedCall := []string{"/usr/bin/podman", "cp", "/tmp/ddc", "dremio:/opt/dremio/ddc"}
+	if !reflect.DeepEqual(mockCli.Calls[0], expectedCall) {
+		t.Errorf("expected %v call but got %v", expectedCall, mockCli.Calls[0])
+	}
+	if c.Name() != "Podman" {
+		t.Errorf("expected Podman but got %v", c.Name())
+	}
+}
+
+func TestFindHosts_DefaultsToDremioImages(t *testing.T) {
+	mockCli := &tests.MockCli{
+		StoredResponse: []string{psOutput, psOutput},
+		StoredErrors:   []error{nil, nil},
+	}
+	c := &CmdDockerActions{cli: mockCli, cliPath: "docker"}
+	coordinators, err := c.GetCoordinators()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(coordinators, []string{"compose-dremio-coordinator-1"}) {
+		t.Errorf("unexpected coordinators %v", coordinators)
+	}
+	executors, err := c.GetExecutors()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(executors, []string{"compose-dremio-executor-1"}) {
+		t.Errorf("unexpected executors %v", executors)
+	}
+	expectedCall := []string{"docker", "ps", "--filter", "status=running", "--format", "{{.Names}}\t{{.Image}}"}
+	if !reflect.DeepEqual(mockCli.Calls[0], expectedCall) {
+		t.Errorf("expected %v call but got %v", expectedCall, mockCli.Calls[0])
+	}
+}
+
+func TestFindHosts_Filters(t *testing.T) {
+	mockCli := &tests.MockCli{
+		StoredResponse: []string{"dremio-main\tregistry.local/custom:1\n", ""},
+		StoredErrors:   []error{nil, fmt.Errorf("exit status 1")},
+	}
+	c := &CmdDockerActions{cli: mockCli, cliPath: "docker", coordinatorFilter: "label=role=main, name=dremio-main"}
+	coordinators, err := c.GetCoordinators()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(coordinators, []string{"dremio-main"}) {
+		t.Errorf("expected the filtered container regardless of image, got %v", coordinators)
+	}
+	expectedCall := []string{"docker", "ps", "--filter", "status=running", "--filter", "label=role=main", "--filter", "name=dremio-main", "--format", "{{.Names}}\t{{.Image}}"}
+	if !reflect.DeepEqual(mockCli.Calls[0], expectedCall) {
+		t.Errorf("expected %v call but got %v", expectedCall, mockCli.Calls[0])
+	}
+	if _, err := c.GetExecutors(); err == nil {
+		t.Error("expected the ps failure to be returned")
+	}
+}
+
+func TestClusterExecute(t *testing.T) {
+	inspect := `[{"Name": "/compose-dremio-coordinator-1", "Config": {"Env": ["LDAP_PASSWORD=ldap-pw", "DREMIO_MAX_MEMORY_SIZE_MB=8192"]}},
+		{"Name": "/compose-dremio-executor-1", "Config": {"Env": []}}]`
+	mockCli := &tests.MockCli{
+		StoredResponse: []string{psOutput, psOutput, `{"ServerVersion": "24.0.7"}`, inspect, "coordinator started\nready", "executor started"},
+		StoredErrors:   []error{nil, nil, nil, nil, nil, nil},
+	}
+	c := &CmdDockerActions{cli: mockCli, cliPath: "docker"}
+	cs := helpers.NewHCCopyStrategy(helpers.NewRealFileSystem(), &helpers.RealTimeService{}, t.TempDir())
+	since := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := c.ClusterExecute(cs, helpers.NewRealFileSystem(), true, collection.ContainerLogOptions{SinceTime: since}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	dir, err := cs.CreatePath("docker", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.ReadFile(filepath.Join(dir, "info.json"))
+	if err != nil || !strings.Contains(string(info), "24.0.7") {
+		t.Errorf("expected info.json to be written, got %q, %v", info, err)
+	}
+	inspected, err := os.ReadFile(filepath.Join(dir, "inspect.json"))
+	if err != nil {
+		t.Fatalf("inspect.json not written: %v", err)
+	}
+	if strings.Contains(string(inspected), "ldap-pw") || !strings.Contains(string(inspected), "DREMIO_MAX_MEMORY_SIZE_MB=8192") {
+		t.Errorf("expected the password to be masked, got %v", string(inspected))
+	}
+	expectedInspect := []string{"docker", "inspect", "compose-dremio-coordinator-1", "compose-dremio-executor-1"}
+	if !reflect.DeepEqual(mockCli.Calls[3], expectedInspect) {
+		t.Errorf("expected %v call but got %v", expectedInspect, mockCli.Calls[3])
+	}
+	expectedLogs := []string{"docker", "logs", "--since", "2024-05-01T00:00:00Z", "compose-dremio-coordinator-1"}
+	if !reflect.DeepEqual(mockCli.Calls[4], expectedLogs) {
+		t.Errorf("expected %v call but got %v", expectedLogs, mockCli.Calls[4])
+	}
+
+	f, err := os.Open(filepath.Join(dir, "container-logs", "compose-dremio-coordinator-1.txt.gz"))
+	if err != nil {
+		t.Fatalf("coordinator log not written: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "coordinator started\nready\n" {
+		t.Errorf("unexpected log content %q", b)
+	}
+	var found bool
+	for _, s := range collection.ContainerLogSummaries() {
+		if s.Pod == "compose-dremio-executor-1" && s.Files == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the executor log in the summaries, got %+v", collection.ContainerLogSummaries())
+	}
+}
+
+func TestLookupCLI(t *testing.T) {
+	orig := helpers.LookPath
+	defer func() { helpers.LookPath = orig }()
+	helpers.LookPath = func(file string) (string, error) {
+		if file == "podman" {
+			return "/usr/bin/podman", nil
+		}
+		return "", fmt.Errorf("%v: executable file not found in $PATH", file)
+	}
+	if got, err := helpers.LookupCLI("", cliBinaries...); err != nil || got != "/usr/bin/podman" {
+		t.Errorf("expected podman when docker is missing, got %q, %v", got, err)
+	}
+	if _, err := NewCmdDockerActions(Args{CLIBinary: "docker"}, nil); err == nil || !strings.Contains(err.Error(), "no docker found") {
+		t.Errorf("expected an error naming docker, got %v", err)
+	}
+}
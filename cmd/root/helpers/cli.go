@@ -0,0 +1,46 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// helpers package provides some general functions that do not have a good home
+package helpers
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// LookPath is exec.LookPath, a variable so tests can control what is installed.
+var LookPath = exec.LookPath
+
+// LookupCLI resolves the CLI to run: name may be one of candidates or a path to one;
+// empty tries candidates in order and returns the first one installed.
+func LookupCLI(name string, candidates ...string) (string, error) {
+	if name != "" {
+		p, err := LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("no %v found: %w", name, err)
+		}
+		return p, nil
+	}
+	var errs []string
+	for _, candidate := range candidates {
+		p, err := LookPath(candidate)
+		if err == nil {
+			return p, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("no %v found: %v", strings.Join(candidates, " or "), strings.Join(errs, "; "))
+}
@@ -0,0 +1,53 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// helpers package provides different functionality
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLookupCLI(t *testing.T) {
+	installed := map[string]bool{}
+	orig := LookPath
+	defer func() { LookPath = orig }()
+	LookPath = func(file string) (string, error) {
+		if installed[file] {
+			return "/usr/bin/" + file, nil
+		}
+		return "", fmt.Errorf("%v: executable file not found in $PATH", file)
+	}
+
+	installed["oc"] = true
+	if got, err := LookupCLI("", "kubectl", "oc"); err != nil || got != "/usr/bin/oc" {
+		t.Errorf("expected oc when kubectl is missing, got %q, %v", got, err)
+	}
+	installed["kubectl"] = true
+	if got, err := LookupCLI("", "kubectl", "oc"); err != nil || got != "/usr/bin/kubectl" {
+		t.Errorf("expected the first candidate to be preferred, got %q, %v", got, err)
+	}
+	if got, err := LookupCLI("oc", "kubectl", "oc"); err != nil || got != "/usr/bin/oc" {
+		t.Errorf("expected the named oc, got %q, %v", got, err)
+	}
+	if _, err := LookupCLI("/opt/bin/oc", "kubectl", "oc"); err == nil || !strings.Contains(err.Error(), "/opt/bin/oc") {
+		t.Errorf("expected an error naming the missing binary, got %v", err)
+	}
+	installed = map[string]bool{}
+	if _, err := LookupCLI("", "kubectl", "oc"); err == nil || !strings.Contains(err.Error(), "kubectl or oc") {
+		t.Errorf("expected an error naming every candidate, got %v", err)
+	}
+}
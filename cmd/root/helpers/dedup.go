@@ -0,0 +1,226 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// helpers package provides some general functions that do not have a good home
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DedupIndexFile is written at the root of the collection when identical files
+// were stored once; ddc extract reads it to restore the full layout.
+const DedupIndexFile = "dedup-index.json"
+
+// DedupEntry is one unique file body: Stored is the copy kept in the archive and
+// Duplicates the other paths, relative to the collection root with forward
+// slashes, that had the same content. Grouping by hash also shows which nodes
+// share a configuration and which have drifted.
+type DedupEntry struct {
+	SHA256     string   `json:"sha256"`
+	Size       int64    `json:"size"`
+	Stored     string   `json:"stored"`
+	Duplicates []string `json:"duplicates"`
+}
+
+// DedupIndex is the content of dedup-index.json.
+type DedupIndex struct {
+	Algorithm  string       `json:"algorithm"`
+	BytesSaved int64        `json:"bytesSaved"`
+	Files      []DedupEntry `json:"files"`
+}
+
+// DedupTree stores each file body under root once. Only per-node files
+// (<category>/<node>/...) take part, since those are what repeat across a
+// cluster. Files are grouped by size first so only possible duplicates are
+// hashed, reusing the SHA-256 in known (keyed by absolute path) when the
+// collector already computed it. The index is written before any duplicate is
+// removed, so a failure part way leaves extra copies rather than lost ones. It
+// returns an empty index, and writes nothing, when no file repeats.
+func DedupTree(root string, known map[string]string) (DedupIndex, error) {
+	index := DedupIndex{Algorithm: "sha256"}
+	bySize := make(map[int64][]string)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if len(strings.Split(filepath.ToSlash(rel), "/")) < 3 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > 0 {
+			bySize[info.Size()] = append(bySize[info.Size()], p)
+		}
+		return nil
+	})
+	if err != nil {
+		return index, fmt.Errorf("walking %v for duplicate files: %w", root, err)
+	}
+
+	for size, paths := range bySize {
+		if len(paths) < 2 {
+			continue
+		}
+		byHash := make(map[string][]string)
+		for _, p := range paths {
+			sum, ok := known[p]
+			if !ok {
+				if sum, err = sha256File(p); err != nil {
+					return index, err
+				}
+			}
+			byHash[sum] = append(byHash[sum], p)
+		}
+		for sum, same := range byHash {
+			if len(same) < 2 {
+				continue
+			}
+			sort.Strings(same)
+			entry := DedupEntry{SHA256: sum, Size: size}
+			for i, p := range same {
+				rel, err := filepath.Rel(root, p)
+				if err != nil {
+					return index, err
+				}
+				if i == 0 {
+					entry.Stored = filepath.ToSlash(rel)
+				} else {
+					entry.Duplicates = append(entry.Duplicates, filepath.ToSlash(rel))
+				}
+			}
+			index.BytesSaved += size * int64(len(entry.Duplicates))
+			index.Files = append(index.Files, entry)
+		}
+	}
+	if len(index.Files) == 0 {
+		return index, nil
+	}
+	sort.Slice(index.Files, func(i, j int) bool { return index.Files[i].Stored < index.Files[j].Stored })
+
+	b, err := json.MarshalIndent(index, "", "\t")
+	if err != nil {
+		return index, err
+	}
+	if err := os.WriteFile(filepath.Join(root, DedupIndexFile), b, 0o600); err != nil {
+		return index, fmt.Errorf("writing %v: %w", DedupIndexFile, err)
+	}
+	for _, e := range index.Files {
+		for _, dup := range e.Duplicates {
+			if err := os.Remove(filepath.Join(root, filepath.FromSlash(dup))); err != nil {
+				return index, fmt.Errorf("removing duplicate %v: %w", dup, err)
+			}
+		}
+	}
+	return index, nil
+}
+
+// RehydrateTree restores the duplicates listed in root's dedup-index.json by
+// copying each stored file back to its other paths, then removes the index. It
+// returns the number of files restored, 0 when root has no index.
+func RehydrateTree(root string) (int, error) {
+	indexPath := filepath.Join(root, DedupIndexFile)
+	b, err := os.ReadFile(filepath.Clean(indexPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var index DedupIndex
+	if err := json.Unmarshal(b, &index); err != nil {
+		return 0, fmt.Errorf("parsing %v: %w", indexPath, err)
+	}
+	var restored int
+	for _, e := range index.Files {
+		src, err := pathUnder(root, e.Stored)
+		if err != nil {
+			return restored, err
+		}
+		for _, dup := range e.Duplicates {
+			dest, err := pathUnder(root, dup)
+			if err != nil {
+				return restored, err
+			}
+			if err := copyFile(src, dest); err != nil {
+				return restored, fmt.Errorf("restoring %v from %v: %w", dup, e.Stored, err)
+			}
+			restored++
+		}
+	}
+	if err := os.Remove(indexPath); err != nil {
+		return restored, err
+	}
+	return restored, nil
+}
+
+// pathUnder resolves an index path below root, refusing paths that escape it.
+func pathUnder(root, rel string) (string, error) {
+	p := filepath.Join(root, filepath.FromSlash(rel))
+	if r, err := filepath.Rel(root, p); err != nil || r == ".." || strings.HasPrefix(r, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%v path %v is outside %v", DedupIndexFile, rel, root)
+	}
+	return p, nil
+}
+
+func sha256File(p string) (string, error) {
+	f, err := os.Open(filepath.Clean(p))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck // read-only file; close error is non-fatal
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hashing %v: %w", p, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck // read-only file; close error is non-fatal
+	if err := os.MkdirAll(filepath.Dir(dest), DirPerms); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(filepath.Clean(dest), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,141 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// helpers package provides different functionality
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDedupTreeAndRehydrate(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"configuration/node1-C/dremio.conf": "paths.local: /data\n",
+		"configuration/node2-E/dremio.conf": "paths.local: /data\n",
+		"configuration/node3-E/dremio.conf": "paths.local: /data\n",
+		"configuration/node4-E/dremio.conf": "paths.local: /dat2\n", // same size, drifted
+		"logs/node1-C/server.log":           "coordinator\n",
+		"logs/node2-E/server.log":           "executor\n",
+		"system-tables/a.json":              "[]",
+		"system-tables/b.json":              "[]", // not per-node, left alone
+	}
+	writeTestFiles(t, root, files)
+
+	// a digest from the collector is trusted as-is
+	known := map[string]string{filepath.Join(root, "logs", "node2-E", "server.log"): "from-stream"}
+	index, err := DedupTree(root, known)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(index.Files) != 1 {
+		t.Fatalf("expected one deduplicated body, got %+v", index.Files)
+	}
+	entry := index.Files[0]
+	if entry.Stored != "configuration/node1-C/dremio.conf" || !reflect.DeepEqual(entry.Duplicates, []string{"configuration/node2-E/dremio.conf", "configuration/node3-E/dremio.conf"}) {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	if index.BytesSaved != 2*int64(len(files["configuration/node1-C/dremio.conf"])) {
+		t.Errorf("unexpected bytes saved %d", index.BytesSaved)
+	}
+	for _, gone := range entry.Duplicates {
+		if _, err := os.Stat(filepath.Join(root, gone)); !os.IsNotExist(err) {
+			t.Errorf("expected %v to be removed, got %v", gone, err)
+		}
+	}
+	for _, kept := range []string{"configuration/node4-E/dremio.conf", "system-tables/b.json", DedupIndexFile} {
+		if _, err := os.Stat(filepath.Join(root, kept)); err != nil {
+			t.Errorf("expected %v to be kept: %v", kept, err)
+		}
+	}
+
+	restored, err := RehydrateTree(root)
+	if err != nil || restored != 2 {
+		t.Fatalf("expected 2 files restored, got %d, %v", restored, err)
+	}
+	for rel, content := range files {
+		b, err := os.ReadFile(filepath.Join(root, rel))
+		if err != nil || string(b) != content {
+			t.Errorf("%v: expected %q, got %q, %v", rel, content, b, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, DedupIndexFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the index to be removed after rehydration, got %v", err)
+	}
+}
+
+func TestDedupTree_NothingToDo(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{"logs/node1-C/server.log": "a", "logs/node2-E/server.log": "bb"})
+	index, err := DedupTree(root, nil)
+	if err != nil || len(index.Files) != 0 {
+		t.Fatalf("expected no duplicates, got %+v, %v", index, err)
+	}
+	if _, err := os.Stat(filepath.Join(root, DedupIndexFile)); !os.IsNotExist(err) {
+		t.Errorf("expected no index to be written, got %v", err)
+	}
+	if n, err := RehydrateTree(root); n != 0 || err != nil {
+		t.Errorf("expected nothing to rehydrate, got %d, %v", n, err)
+	}
+}
+
+func TestRehydrateTree_RejectsEscapingPaths(t *testing.T) {
+	root := t.TempDir()
+	writeTestFiles(t, root, map[string]string{
+		"configuration/node1-C/dremio.conf": "x",
+		DedupIndexFile:                      `{"algorithm":"sha256","files":[{"stored":"configuration/node1-C/dremio.conf","duplicates":["../../etc/evil"]}]}`,
+	})
+	if _, err := RehydrateTree(root); err == nil {
+		t.Error("expected an error for a duplicate outside the collection")
+	}
+}
+
+func TestDeduplicateHC_BeforeArchive(t *testing.T) {
+	cs := NewHCCopyStrategy(NewRealFileSystem(), &MockTimeService{Time: time.Now()}, t.TempDir())
+	files := map[string]string{"configuration/node1-E/dremio.conf": "same", "configuration/node2-E/dremio.conf": "same"}
+	writeTestFiles(t, cs.GetTmpDir(), files)
+
+	if index := cs.Deduplicate(nil); index != nil {
+		t.Fatalf("expected no deduplication without --dedup, got %+v", index)
+	}
+	if _, err := os.Stat(filepath.Join(cs.GetTmpDir(), "configuration/node2-E/dremio.conf")); err != nil {
+		t.Fatalf("expected every copy to be kept: %v", err)
+	}
+
+	cs.Dedup = true
+	index := cs.Deduplicate(nil)
+	if index == nil || len(index.Files) != 1 || index.BytesSaved != int64(len("same")) {
+		t.Fatalf("expected the index for the summary, got %+v", index)
+	}
+	if err := cs.ArchiveDiag("{}", filepath.Join(t.TempDir(), "diag.tgz")); err != nil {
+		t.Fatal(err)
+	}
+}
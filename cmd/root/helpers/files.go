@@ -16,6 +16,7 @@
 package helpers
 
 type CollectedFile struct {
-	Path string `json:"path"`
-	Size int64  `json:"size"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"` // set when the collector hashed the bytes while writing them
 }
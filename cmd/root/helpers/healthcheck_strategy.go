@@ -58,6 +58,8 @@ type CopyStrategyHC struct {
 	Fs           Filesystem // filesystem interface (so we can pass in realof fake filesystem, assists testing)
 	TimeService  TimeService
 	IsK8s        bool // when true, pod names are descriptive enough — skip -C/-E suffix
+	Dedup        bool // when true (--dedup), identical per-node files are stored once (see DedupTree)
+	knownHashes  map[string]string
 }
 
 /*
@@ -97,8 +99,8 @@ func (s *CopyStrategyHC) CreatePath(fileType, source, nodeType string) (path str
 	tmpDir := s.TmpDir
 
 	// We only tag a suffix of '-C' / '-E' for ssh nodes, the K8s pods are descriptive enough to determine the coordinator / executor.
-	// Skip suffix when running in K8s mode (IsK8s) or for the general "kubernetes" fileType directory.
-	if s.IsK8s || fileType == "kubernetes" {
+	// Skip suffix when running in K8s mode (IsK8s) or for the general "kubernetes" and "docker" fileType directories.
+	if s.IsK8s || fileType == "kubernetes" || fileType == "docker" {
 		path = filepath.Join(tmpDir, baseDir, fileType, source)
 	} else {
 		// ssh node types
@@ -161,6 +163,34 @@ func (s *CopyStrategyHC) ArchiveDiag(o string, outputLoc string) error {
 	return archive.TarDDCWithProgress(s.TmpDir, outputLoc, s.BaseDir, consoleprint.UpdateArchiveProgress)
 }
 
+// Deduplicate stores identical per-node files once, reusing the SHA-256 the collector
+// computed while writing files so they are not read again. It runs before summary.json
+// is written so the summary can record the index. It is advisory: on failure, or when
+// Dedup is off, it returns nil and the collection is archived with every copy.
+func (s *CopyStrategyHC) Deduplicate(files []CollectedFile) *DedupIndex {
+	if !s.Dedup {
+		return nil
+	}
+	if s.knownHashes == nil {
+		s.knownHashes = make(map[string]string)
+	}
+	for _, f := range files {
+		if f.SHA256 != "" {
+			s.knownHashes[f.Path] = f.SHA256
+		}
+	}
+	index, err := DedupTree(s.GetTmpDir(), s.knownHashes)
+	if err != nil {
+		simplelog.Warningf("deduplication of %v failed, archiving remaining files as they are: %v", s.GetTmpDir(), err)
+		return nil
+	}
+	if len(index.Files) == 0 {
+		return nil
+	}
+	simplelog.Infof("deduplicated %d file bodies, saving %d bytes; see %v", len(index.Files), index.BytesSaved, DedupIndexFile)
+	return &index
+}
+
 // This function creates a couple of supplemental files required for the HC data to be uploaded
 func (s *CopyStrategyHC) createHCFiles() (file string, err error) {
 	baseDir := s.BaseDir
@@ -88,6 +88,13 @@ func TestGetPathHC(t *testing.T) {
 		t.Errorf("\nERROR: kubernetes fileType path: \nexpected:\t%v\nactual:\t\t%v\n", expected, actual)
 	}
 
+	// SSH mode — fileType "docker" never gets a suffix either
+	expected = filepath.Join(tmpDir, testStrat.BaseDir, "docker", "container-logs")
+	actual, _ = testStrat.CreatePath("docker", "container-logs", "")
+	if expected != actual {
+		t.Errorf("\nERROR: docker fileType path: \nexpected:\t%v\nactual:\t\t%v\n", expected, actual)
+	}
+
 	// K8s mode (IsK8s=true) — coordinator: no suffix
 	k8sStrat := NewHCCopyStrategy(ddcfs, &MockTimeService{Time: time.Now()}, tmpDir)
 	k8sStrat.IsK8s = true
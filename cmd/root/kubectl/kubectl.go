@@ -40,12 +40,13 @@ type KubeArgs struct {
 }
 
 // NewKubectlK8sActions is the only supported way to initialize the KubectlK8sActions struct
-// one must pass the path to kubectl
+// kubeArgs.CLIBinary picks the CLI: kubectl, oc or a path; empty tries kubectl, then oc
 func NewKubectlK8sActions(hook shutdown.CancelHook, kubeArgs kubernetes.KubeArgs) (*CliK8sActions, error) {
-	kubectl, err := exec.LookPath("kubectl")
+	kubectl, err := kubernetes.LookupCLI(kubeArgs.CLIBinary)
 	if err != nil {
-		return &CliK8sActions{}, fmt.Errorf("no kubectl found: %w", err)
+		return &CliK8sActions{}, err
 	}
+	simplelog.Infof("using %v for kubectl operations", kubectl)
 	cliInstance := cli.NewCli(hook)
 	k8sContext := kubeArgs.K8SContext
 	if k8sContext == "" {
@@ -447,24 +448,46 @@ func (c *CliK8sActions) CleanupRemote() error {
 // "gzip -c" depending on useGzip. Binary data integrity is preserved — stdout
 // goes directly to writer with no line splitting or encoding.
 func (c *CliK8sActions) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return c.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+
+// StreamFromHostAt is StreamFromHost from offset bytes into the file, read with
+// "tail -c +N" in the container.
+func (c *CliK8sActions) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
 	if remotePath == "" {
 		return fmt.Errorf("StreamFromHost: remotePath is empty for host %v", host)
 	}
 
-	streamCmd := "cat"
+	compress := ""
 	if useGzip {
-		streamCmd = "gzip -c"
+		compress = "gzip -c"
 	}
+	streamCmd := collection.RemoteReadCommand(remotePath, offset, compress)
 	simplelog.Infof("StreamFromHost: streaming %v:%v via kubectl exec (cmd=%s)", host, remotePath, streamCmd)
+	if err := c.streamCommand(host, host+":"+remotePath, streamCmd, writer); err != nil {
+		return err
+	}
+	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
+	return nil
+}
+
+// StreamCommandFromHost runs streamCmd in the pod via kubectl exec and streams
+// its raw stdout to writer.
+func (c *CliK8sActions) StreamCommandFromHost(host, streamCmd string, writer io.Writer) error {
+	simplelog.Infof("StreamCommandFromHost: streaming from %v via kubectl exec (cmd=%s)", host, streamCmd)
+	return c.streamCommand(host, host, streamCmd, writer)
+}
 
+// streamCommand executes "kubectl exec ... sh -c <streamCmd>" with stdout going
+// directly to writer. target names what is streamed in error messages.
+func (c *CliK8sActions) streamCommand(host, target, streamCmd string, writer io.Writer) error {
 	containerName, err := c.getContainerName(host)
 	if err != nil {
 		return fmt.Errorf("StreamFromHost: failed to get container for pod %v: %w", host, err)
 	}
 
-	escapedPath := strings.ReplaceAll(remotePath, "'", "'\\''")
 	args := append([]string{}, c.k8sFlags()...)
-	args = append(args, "exec", host, "-n", c.namespace, "-c", containerName, "--", "sh", "-c", fmt.Sprintf("%s '%s'", streamCmd, escapedPath))
+	args = append(args, "exec", host, "-n", c.namespace, "-c", containerName, "--", "sh", "-c", streamCmd)
 
 	// #nosec G204 -- arguments are controlled by the caller
 	cmd := exec.Command(c.kubectlPath, args...)
@@ -472,11 +495,11 @@ func (c *CliK8sActions) StreamFromHost(host, remotePath string, writer io.Writer
 
 	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("StreamFromHost: failed to create stderr pipe for %v:%v: %w", host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: failed to create stderr pipe for %v: %w", target, err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("StreamFromHost: failed to start kubectl for %v:%v: %w", host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: failed to start kubectl for %v: %w", target, err)
 	}
 
 	stderrBytes, _ := io.ReadAll(stderrPipe)
@@ -484,12 +507,10 @@ func (c *CliK8sActions) StreamFromHost(host, remotePath string, writer io.Writer
 	if err := cmd.Wait(); err != nil {
 		stderrMsg := strings.TrimSpace(string(stderrBytes))
 		if stderrMsg != "" {
-			return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w (stderr: %s)", streamCmd, host, remotePath, err, stderrMsg)
+			return fmt.Errorf("StreamFromHost: %s failed on %v: %w (stderr: %s)", streamCmd, target, err, stderrMsg)
 		}
-		return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w", streamCmd, host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: %s failed on %v: %w", streamCmd, target, err)
 	}
-
-	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
 	return nil
 }
 
@@ -0,0 +1,338 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubernetes package provides access to log collections on k8s
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// debugTools are the commands discovery, checksums and JVM collection run inside the
+// Dremio container. Hardened images often ship without some of them.
+var debugTools = []string{"jcmd", "gzip", "sha256sum", "find", "stat", "top"}
+
+// debugFallbackRoot is the Dremio container's filesystem as seen from the debug container
+// when the Dremio JVM cannot be found: with targetContainerName set the debug container
+// joins the target's PID namespace, where PID 1 is the target's entrypoint. That is a
+// wrapper script or an init in many images, and with shareProcessNamespace it is the pause
+// container, so the JVM's own root is preferred (see findDremioRoot).
+const debugFallbackRoot = "/proc/1/root"
+
+// debugFindDremioPID prints the PID of the Dremio JVM as seen from the debug container. The
+// class name is written as a regex so the search never matches its own command line.
+const debugFindDremioPID = `for p in /proc/[0-9]*; do tr '\000' ' ' < $p/cmdline 2>/dev/null | grep -q 'com[.]dremio[.]dremio[.]DremioDaemon' && echo ${p#/proc/} && break; done`
+
+// debugAliveFile keeps the debug container running; removing it lets the container exit.
+const debugAliveFile = "/tmp/ddc-debug-alive"
+
+// debugContainerMaxLifetime stops the debug container on its own if DDC never cleans up.
+const debugContainerMaxLifetime = 4 * time.Hour
+
+// debugContainerStartTimeout and debugContainerPollInterval bound the wait for the
+// ephemeral container to be running. They are variables so tests can shorten them.
+var (
+	debugContainerStartTimeout = 2 * time.Minute
+	debugContainerPollInterval = 2 * time.Second
+)
+
+// debugTarget is the tool probe result and debug container for one pod.
+type debugTarget struct {
+	once      sync.Once
+	missing   []string // tools absent from the Dremio container
+	noShell   bool     // the Dremio container could not run sh at all
+	container string   // ephemeral container name, "" when none is attached
+	root      string   // the Dremio container's filesystem in the debug container
+	stopped   bool
+}
+
+var (
+	reDebugPath   = regexp.MustCompile(`(^|[\s'"<>])(/[^\s'";|&<>]*)`)
+	reCommandWord = regexp.MustCompile("(^|[\\s;|&(`])([^\\s;|&()`]+)")
+)
+
+// rewriteForDebugRoot prefixes absolute paths in a shell command, including redirect
+// targets, with the target's root so a command meant for the Dremio container reads the
+// same files from the debug one. /proc and /dev are left alone, and so are jcmd commands,
+// whose paths are resolved by the target JVM rather than the shell.
+func rewriteForDebugRoot(command, root string) string {
+	if commandUses(command, "jcmd") {
+		return command
+	}
+	return reDebugPath.ReplaceAllStringFunc(command, func(m string) string {
+		i := strings.Index(m, "/")
+		p := m[i:]
+		if p == "/" || strings.HasPrefix(p, "/proc/") || strings.HasPrefix(p, "/dev/") {
+			return m
+		}
+		return m[:i] + root + p
+	})
+}
+
+// stripDebugRoot removes the target root prefix from output lines so callers see the
+// paths they asked for.
+func stripDebugRoot(output cli.OutputHandler, root string) cli.OutputHandler {
+	return func(line string) {
+		output(strings.ReplaceAll(line, root+"/", "/"))
+	}
+}
+
+// commandUses reports whether tool appears as a command word in a shell command line.
+func commandUses(command, tool string) bool {
+	for _, m := range reCommandWord.FindAllStringSubmatch(command, -1) {
+		if m[2] == tool {
+			return true
+		}
+	}
+	return false
+}
+
+// debugContainerFor returns the debug container a command should run in instead of the
+// Dremio container: when the Dremio container has no shell, or the command uses a tool
+// it lacks. The first call for a pod probes its tools and attaches the debug container.
+func (c *KubeCtlAPIActions) debugContainerFor(host, command string) (*debugTarget, bool) {
+	if c.debugImage == "" {
+		return nil, false
+	}
+	t := c.debugTargetFor(host)
+	if t.container == "" {
+		return nil, false
+	}
+	if t.noShell {
+		return t, true
+	}
+	for _, tool := range t.missing {
+		if commandUses(command, tool) {
+			simplelog.Debugf("running %q in debug container %v on pod %v (%v missing)", command, t.container, host, tool)
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (c *KubeCtlAPIActions) debugTargetFor(host string) *debugTarget {
+	c.m.Lock()
+	t, ok := c.debugTargets[host]
+	if !ok {
+		t = &debugTarget{}
+		c.debugTargets[host] = t
+	}
+	c.m.Unlock()
+	t.once.Do(func() {
+		c.prepareDebugTarget(host, t)
+	})
+	return t
+}
+
+func (c *KubeCtlAPIActions) prepareDebugTarget(host string, t *debugTarget) {
+	containerName, missing, noShell, err := c.probeDebugTools(host)
+	if err != nil {
+		simplelog.Warningf("debug container: unable to probe the tools of pod %v, not attaching one: %v", host, err)
+		return
+	}
+	t.missing, t.noShell = missing, noShell
+	if !noShell && len(missing) == 0 {
+		simplelog.Debugf("debug container: %v on pod %v has all tools, not attaching one", containerName, host)
+		return
+	}
+	name, err := c.attachDebugContainer(host, containerName)
+	if err != nil {
+		simplelog.Errorf("debug container: unable to attach %v to pod %v, collection will continue without it: %v", c.debugImage, host, err)
+		return
+	}
+	t.container = name
+	t.root = c.findDremioRoot(host, name)
+	simplelog.Infof("debug container: attached %v (%v) to pod %v for missing tools %v, Dremio root %v", name, c.debugImage, host, t.missing, t.root)
+}
+
+// probeDebugTools returns the Dremio container of host and the debugTools it lacks, or
+// noShell when the container has no sh. The probe only runs command -v. Any other exec
+// failure, RBAC, a timeout or a cancelled collection, is returned as an error: an
+// ephemeral container can never be removed from the pod, so it is only attached when
+// the probe proved it is needed.
+func (c *KubeCtlAPIActions) probeDebugTools(host string) (containerName string, missing []string, noShell bool, err error) {
+	containerName, err = c.getPrimaryContainer(host)
+	if err != nil {
+		return "", nil, false, err
+	}
+	probe := fmt.Sprintf("for t in %s; do command -v $t >/dev/null 2>&1 || echo $t; done", strings.Join(debugTools, " "))
+	var out, errOut bytes.Buffer
+	ctx, cancel := context.WithTimeout(c.hook.GetContext(), 30*time.Second)
+	defer cancel()
+	if err := c.execIn(ctx, host, containerName, []string{"sh", "-c", probe}, &out, &errOut); err != nil {
+		if !shellMissing(err) {
+			return containerName, nil, false, fmt.Errorf("probing %v: %w - %v", containerName, err, errOut.String())
+		}
+		simplelog.Warningf("debug container: no sh in %v on pod %v, all commands will use the debug container: %v - %v", containerName, host, err, errOut.String())
+		return containerName, nil, true, nil
+	}
+	return containerName, strings.Fields(out.String()), false, nil
+}
+
+// shellMissing reports whether an exec of sh failed because the container has no sh:
+// the runtime cannot find the executable, or the exec exits with 126 or 127.
+func shellMissing(err error) bool {
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus() == 126 || exitErr.ExitStatus() == 127
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "executable file") && strings.Contains(msg, "not found")
+}
+
+// PlanDebugContainer probes host like the first command of a collection with
+// --debug-container-image does and reports whether a debug container would be
+// attached, without attaching one. It is how a dry run records the attach.
+func (c *KubeCtlAPIActions) PlanDebugContainer(host string) (missing []string, noShell, attach bool) {
+	_, missing, noShell, err := c.probeDebugTools(host)
+	if err != nil {
+		simplelog.Warningf("debug container: unable to probe the tools of pod %v: %v", host, err)
+		return nil, false, false
+	}
+	return missing, noShell, noShell || len(missing) > 0
+}
+
+// findDremioRoot returns /proc/<pid>/root for the Dremio JVM seen from the debug container,
+// or debugFallbackRoot when it cannot be found.
+func (c *KubeCtlAPIActions) findDremioRoot(host, debugContainer string) string {
+	var out, errOut bytes.Buffer
+	ctx, cancel := context.WithTimeout(c.hook.GetContext(), 30*time.Second)
+	defer cancel()
+	if err := c.execIn(ctx, host, debugContainer, []string{"sh", "-c", debugFindDremioPID}, &out, &errOut); err != nil {
+		simplelog.Warningf("debug container: unable to find the Dremio process on pod %v, using %v: %v - %v", host, debugFallbackRoot, err, errOut.String())
+		return debugFallbackRoot
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(out.String()))
+	if err != nil || pid <= 0 {
+		simplelog.Warningf("debug container: no Dremio process visible on pod %v, using %v", host, debugFallbackRoot)
+		return debugFallbackRoot
+	}
+	return fmt.Sprintf("/proc/%d/root", pid)
+}
+
+// attachDebugContainer adds an ephemeral container targeting the Dremio container and
+// waits for it to be running. It runs as the Dremio container's user when that is set,
+// so jcmd can attach to the JVM.
+func (c *KubeCtlAPIActions) attachDebugContainer(host, targetContainer string) (string, error) {
+	ctx, cancel := context.WithTimeout(c.hook.GetContext(), debugContainerStartTimeout)
+	defer cancel()
+	pods := c.client.CoreV1().Pods(c.namespace)
+	pod, err := pods.Get(ctx, host, meta_v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("ddc-debug-%d", time.Now().Unix())
+	keepAlive := fmt.Sprintf("touch %[1]s; i=0; while [ -f %[1]s ] && [ $i -lt %[2]d ]; do sleep 5; i=$((i+5)); done", debugAliveFile, int(debugContainerMaxLifetime.Seconds()))
+	ec := v1.EphemeralContainer{
+		EphemeralContainerCommon: v1.EphemeralContainerCommon{
+			Name:    name,
+			Image:   c.debugImage,
+			Command: []string{"sh", "-c", keepAlive},
+		},
+		TargetContainerName: targetContainer,
+	}
+	for _, container := range pod.Spec.Containers {
+		if container.Name == targetContainer && container.SecurityContext != nil {
+			ec.SecurityContext = &v1.SecurityContext{
+				RunAsUser:  container.SecurityContext.RunAsUser,
+				RunAsGroup: container.SecurityContext.RunAsGroup,
+			}
+		}
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ec)
+	if _, err := pods.UpdateEphemeralContainers(ctx, host, pod, meta_v1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("adding ephemeral container: %w", err)
+	}
+	for {
+		current, err := pods.Get(ctx, host, meta_v1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		for _, s := range current.Status.EphemeralContainerStatuses {
+			if s.Name != name {
+				continue
+			}
+			if s.State.Running != nil {
+				return name, nil
+			}
+			if s.State.Terminated != nil {
+				return "", fmt.Errorf("ephemeral container %v terminated: %v", name, s.State.Terminated.Reason)
+			}
+			if w := s.State.Waiting; w != nil && (w.Reason == "ErrImagePull" || w.Reason == "ImagePullBackOff" || w.Reason == "InvalidImageName") {
+				return "", fmt.Errorf("ephemeral container %v cannot start: %v %v", name, w.Reason, w.Message)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("ephemeral container %v not running after %v", name, debugContainerStartTimeout)
+		case <-time.After(debugContainerPollInterval):
+		}
+	}
+}
+
+// execIn runs cmd in a container of a pod, without any debug container routing.
+func (c *KubeCtlAPIActions) execIn(ctx context.Context, host, containerName string, cmd []string, stdout, stderr *bytes.Buffer) error {
+	executor, err := c.newExecutor("POST", c.execURL(host, containerName, cmd, false))
+	if err != nil {
+		return err
+	}
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+// StopDebugContainers lets every attached debug container exit. Ephemeral containers
+// cannot be removed from a pod spec, but once stopped they hold no resources.
+func (c *KubeCtlAPIActions) StopDebugContainers() {
+	c.m.Lock()
+	var hosts []string
+	var targets []*debugTarget
+	for host, t := range c.debugTargets {
+		hosts = append(hosts, host)
+		targets = append(targets, t)
+	}
+	c.m.Unlock()
+	for i, t := range targets {
+		if t.container == "" || t.stopped {
+			continue
+		}
+		// the hook context is already cancelled during cleanup
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		var out, errOut bytes.Buffer
+		err := c.execIn(ctx, hosts[i], t.container, []string{"rm", "-f", debugAliveFile}, &out, &errOut)
+		cancel()
+		if err != nil {
+			simplelog.Warningf("debug container: unable to stop %v on pod %v, it will exit within %v: %v - %v", t.container, hosts[i], debugContainerMaxLifetime, err, errOut.String())
+			continue
+		}
+		t.stopped = true
+		simplelog.Infof("debug container: stopped %v on pod %v", t.container, hosts[i])
+	}
+}
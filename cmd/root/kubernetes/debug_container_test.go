@@ -0,0 +1,355 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// kubernetes package provides access to log collections on k8s
+package kubernetes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+func TestRewriteForDebugRoot(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"sha256sum /opt/dremio/log/server.log", "sha256sum /proc/1/root/opt/dremio/log/server.log"},
+		{"gzip -1 -c '/opt/dremio/log/it'\\''s.log'", "gzip -1 -c '/proc/1/root/opt/dremio/log/it'\\''s.log'"},
+		{"find /opt/dremio/log -name '*.log' 2>/dev/null", "find /proc/1/root/opt/dremio/log -name '*.log' 2>/dev/null"},
+		{"stat -c %s /proc/self/status", "stat -c %s /proc/self/status"},
+		{"timeout 30 jcmd 42 GC.heap_dump /opt/dremio/data/heap.hprof", "timeout 30 jcmd 42 GC.heap_dump /opt/dremio/data/heap.hprof"},
+		{"top -H -p 42 -d 1 -n 5 -bw 512", "top -H -p 42 -d 1 -n 5 -bw 512"},
+		{"top -bn1 >/tmp/top.txt 2>/dev/null", "top -bn1 >/proc/1/root/tmp/top.txt 2>/dev/null"},
+		{"gzip -c </opt/dremio/log/gc.log >>/tmp/gc.log.gz", "gzip -c </proc/1/root/opt/dremio/log/gc.log >>/proc/1/root/tmp/gc.log.gz"},
+	}
+	for _, tt := range tests {
+		if got := rewriteForDebugRoot(tt.in, "/proc/1/root"); got != tt.want {
+			t.Errorf("rewriteForDebugRoot(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCommandUses(t *testing.T) {
+	if !commandUses("timeout 30 jcmd 42 VM.flags", "jcmd") {
+		t.Error("expected jcmd to be found")
+	}
+	if !commandUses("ls /opt | gzip -c", "gzip") {
+		t.Error("expected gzip after a pipe to be found")
+	}
+	if commandUses("cat /opt/dremio/find/stat.txt", "find") || commandUses("cat /opt/dremio/find/stat.txt", "stat") {
+		t.Error("path segments should not count as commands")
+	}
+}
+
+// execCall is one pods/exec request seen by the scripted executor.
+type execCall struct {
+	container string
+	command   string
+}
+
+// scriptedExecs records every exec and answers with respond.
+type scriptedExecs struct {
+	mu      sync.Mutex
+	calls   []execCall
+	respond func(call execCall, stdout io.Writer) error
+}
+
+func (s *scriptedExecs) factory(_ *rest.Config, _ string, u *url.URL) (remotecommand.Executor, error) {
+	q := u.Query()
+	call := execCall{container: q.Get("container"), command: strings.Join(q["command"], " ")}
+	return &scriptedExecutor{s: s, call: call}, nil
+}
+
+func (s *scriptedExecs) callsIn(container string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []string
+	for _, c := range s.calls {
+		if c.container == container {
+			out = append(out, c.command)
+		}
+	}
+	return out
+}
+
+type scriptedExecutor struct {
+	s    *scriptedExecs
+	call execCall
+}
+
+func (e *scriptedExecutor) Stream(opts remotecommand.StreamOptions) error {
+	return e.StreamWithContext(context.Background(), opts)
+}
+
+func (e *scriptedExecutor) StreamWithContext(_ context.Context, opts remotecommand.StreamOptions) error {
+	e.s.mu.Lock()
+	e.s.calls = append(e.s.calls, e.call)
+	e.s.mu.Unlock()
+	stdout := opts.Stdout
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	return e.s.respond(e.call, stdout)
+}
+
+func debugTestPod() *v1.Pod {
+	uid := int64(999)
+	return &v1.Pod{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "dremio-executor-0", Namespace: "dremio", Labels: map[string]string{"role": "dremio-cluster-pod"}},
+		Spec: v1.PodSpec{Containers: []v1.Container{{
+			Name:            "dremio-executor",
+			SecurityContext: &v1.SecurityContext{RunAsUser: &uid},
+		}}},
+		Status: v1.PodStatus{Phase: v1.PodRunning},
+	}
+}
+
+// fakeWithEphemeralStatus reports every ephemeral container in the stored pod as being
+// in state, which the fake clientset never does on its own.
+func fakeWithEphemeralStatus(state v1.ContainerState) *fake.Clientset {
+	fc := fake.NewSimpleClientset(debugTestPod())
+	fc.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		get := action.(k8stesting.GetAction)
+		obj, err := fc.Tracker().Get(v1.SchemeGroupVersion.WithResource("pods"), get.GetNamespace(), get.GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		pod := obj.(*v1.Pod).DeepCopy()
+		for _, ec := range pod.Spec.EphemeralContainers {
+			pod.Status.EphemeralContainerStatuses = append(pod.Status.EphemeralContainerStatuses, v1.ContainerStatus{Name: ec.Name, State: state})
+		}
+		return true, pod, nil
+	})
+	return fc
+}
+
+type backgroundHook struct{}
+
+func (backgroundHook) GetContext() context.Context { return context.Background() }
+
+func newDebugTestActions(t *testing.T, fc *fake.Clientset, execs *scriptedExecs, image string) *KubeCtlAPIActions {
+	t.Helper()
+	oldPoll := debugContainerPollInterval
+	debugContainerPollInterval = time.Millisecond
+	t.Cleanup(func() { debugContainerPollInterval = oldPoll })
+	return &KubeCtlAPIActions{
+		namespace:           "dremio",
+		detectLabelSelector: "role=dremio-cluster-pod",
+		client:              fc,
+		config:              &rest.Config{Host: "https://fake"},
+		hook:                backgroundHook{},
+		pidHosts:            make(map[string]string),
+		containerCache:      make(map[string]string),
+		debugImage:          image,
+		debugTargets:        make(map[string]*debugTarget),
+		timeoutMinutes:      1,
+		spdyExecutorFn:      execs.factory,
+		protocol:            "SPDY",
+	}
+}
+
+func TestHostExecute_RunsMissingToolsInDebugContainer(t *testing.T) {
+	fc := fakeWithEphemeralStatus(v1.ContainerState{Running: &v1.ContainerStateRunning{}})
+	execs := &scriptedExecs{respond: func(call execCall, stdout io.Writer) error {
+		switch {
+		case strings.Contains(call.command, "command -v"):
+			_, err := fmt.Fprintln(stdout, "sha256sum\ngzip")
+			return err
+		case strings.HasPrefix(call.container, "ddc-debug-") && strings.Contains(call.command, "DremioDaemon"):
+			_, err := fmt.Fprintln(stdout, "57")
+			return err
+		case strings.HasPrefix(call.container, "ddc-debug-") && strings.Contains(call.command, "sha256sum"):
+			_, err := fmt.Fprintln(stdout, "abc123  /proc/57/root/opt/dremio/log/server.log")
+			return err
+		}
+		return nil
+	}}
+	actions := newDebugTestActions(t, fc, execs, "busybox:1.36")
+
+	out, err := actions.HostExecute(false, "dremio-executor-0", "sha256sum", "/opt/dremio/log/server.log")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.TrimSpace(out) != "abc123  /opt/dremio/log/server.log" {
+		t.Errorf("expected the debug root to be stripped from output, got %q", out)
+	}
+	if _, err := actions.HostExecute(false, "dremio-executor-0", "cat", "/opt/dremio/conf/dremio.conf"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := actions.StreamFromHost("dremio-executor-0", "/opt/dremio/log/server.log", &buf, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	obj, err := fc.Tracker().Get(v1.SchemeGroupVersion.WithResource("pods"), "dremio", "dremio-executor-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecs := obj.(*v1.Pod).Spec.EphemeralContainers
+	if len(ecs) != 1 {
+		t.Fatalf("expected exactly one ephemeral container, got %d", len(ecs))
+	}
+	ec := ecs[0]
+	if ec.Image != "busybox:1.36" || ec.TargetContainerName != "dremio-executor" {
+		t.Errorf("unexpected ephemeral container %+v", ec)
+	}
+	if ec.SecurityContext == nil || ec.SecurityContext.RunAsUser == nil || *ec.SecurityContext.RunAsUser != 999 {
+		t.Errorf("expected the debug container to run as the Dremio user, got %+v", ec.SecurityContext)
+	}
+
+	primary := actions.debugTargets["dremio-executor-0"]
+	if primary.container != ec.Name || primary.root != "/proc/57/root" {
+		t.Errorf("expected debug target %v rooted at the Dremio JVM, got %v at %v", ec.Name, primary.container, primary.root)
+	}
+	inDremio := execs.callsIn("dremio-executor")
+	if len(inDremio) != 2 || !strings.Contains(inDremio[0], "command -v") || !strings.Contains(inDremio[1], "cat /opt/dremio/conf/dremio.conf") {
+		t.Errorf("expected the probe and cat in the Dremio container, got %v", inDremio)
+	}
+	inDebug := execs.callsIn(ec.Name)
+	if len(inDebug) != 3 || inDebug[0] != "sh -c "+debugFindDremioPID ||
+		!strings.Contains(inDebug[1], "sha256sum /proc/57/root/opt/dremio/log/server.log") ||
+		!strings.Contains(inDebug[2], "gzip -1 -c '/proc/57/root/opt/dremio/log/server.log'") {
+		t.Errorf("expected sha256sum and gzip in the debug container against the target root, got %v", inDebug)
+	}
+
+	actions.StopDebugContainers()
+	actions.StopDebugContainers()
+	inDebug = execs.callsIn(ec.Name)
+	if len(inDebug) != 4 || !strings.Contains(inDebug[3], "rm -f "+debugAliveFile) {
+		t.Errorf("expected one stop exec in the debug container, got %v", inDebug)
+	}
+}
+
+func TestHostExecute_AllToolsPresentNoDebugContainer(t *testing.T) {
+	fc := fakeWithEphemeralStatus(v1.ContainerState{Running: &v1.ContainerStateRunning{}})
+	execs := &scriptedExecs{respond: func(_ execCall, _ io.Writer) error { return nil }}
+	actions := newDebugTestActions(t, fc, execs, "busybox:1.36")
+
+	if _, err := actions.HostExecute(false, "dremio-executor-0", "sha256sum", "/opt/dremio/log/server.log"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, a := range fc.Actions() {
+		if a.GetSubresource() == "ephemeralcontainers" {
+			t.Fatalf("no ephemeral container should be attached, got %v", a)
+		}
+	}
+	if got := execs.callsIn("dremio-executor"); len(got) != 2 {
+		t.Errorf("expected the probe and the command in the Dremio container, got %v", got)
+	}
+}
+
+func TestHostExecute_DebugImageUnset_NoProbe(t *testing.T) {
+	fc := fake.NewSimpleClientset(debugTestPod())
+	execs := &scriptedExecs{respond: func(_ execCall, _ io.Writer) error { return nil }}
+	actions := newDebugTestActions(t, fc, execs, "")
+
+	if _, err := actions.HostExecute(false, "dremio-executor-0", "find", "/opt/dremio/log"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := execs.callsIn("dremio-executor")
+	if len(got) != 1 || !strings.Contains(got[0], "find /opt/dremio/log") {
+		t.Errorf("expected only the command itself, got %v", got)
+	}
+}
+
+func TestHostExecute_DebugImagePullFails_FallsBackToDremioContainer(t *testing.T) {
+	fc := fakeWithEphemeralStatus(v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: "ImagePullBackOff"}})
+	execs := &scriptedExecs{respond: func(call execCall, stdout io.Writer) error {
+		if strings.Contains(call.command, "command -v") {
+			_, err := fmt.Fprintln(stdout, "find")
+			return err
+		}
+		return nil
+	}}
+	actions := newDebugTestActions(t, fc, execs, "registry.invalid/tools:1")
+
+	if _, err := actions.HostExecute(false, "dremio-executor-0", "find", "/opt/dremio/log"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := execs.callsIn("dremio-executor")
+	if len(got) != 2 || !strings.Contains(got[1], "find /opt/dremio/log") {
+		t.Errorf("expected find to run in the Dremio container after the attach failed, got %v", got)
+	}
+	if actions.debugTargets["dremio-executor-0"].container != "" {
+		t.Error("expected no debug container to be recorded")
+	}
+}
+
+func TestHostExecute_ProbeForbidden_NoDebugContainer(t *testing.T) {
+	fc := fakeWithEphemeralStatus(v1.ContainerState{Running: &v1.ContainerStateRunning{}})
+	forbidden := apierrors.NewForbidden(v1.Resource("pods/exec"), "dremio-executor-0", fmt.Errorf("user cannot create pods/exec"))
+	execs := &scriptedExecs{respond: func(_ execCall, _ io.Writer) error { return forbidden }}
+	actions := newDebugTestActions(t, fc, execs, "busybox:1.36")
+
+	if missing, noShell, attach := actions.PlanDebugContainer("dremio-executor-0"); attach || noShell || len(missing) > 0 {
+		t.Errorf("expected no planned debug container when exec is forbidden, got missing %v, no shell %v, attach %v", missing, noShell, attach)
+	}
+	if _, err := actions.HostExecute(false, "dremio-executor-0", "find", "/opt/dremio/log"); err == nil {
+		t.Error("expected the forbidden exec to be returned")
+	}
+	for _, a := range fc.Actions() {
+		if a.GetSubresource() == "ephemeralcontainers" {
+			t.Fatalf("no ephemeral container should be attached when exec is forbidden, got %v", a)
+		}
+	}
+	if target := actions.debugTargets["dremio-executor-0"]; target.noShell || target.container != "" {
+		t.Errorf("expected the pod not to be marked as shell-less, got %+v", target)
+	}
+}
+
+func TestShellMissing(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 127"), Code: 127}, true},
+		{utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 126"), Code: 126}, true},
+		{utilexec.CodeExitError{Err: fmt.Errorf("command terminated with exit code 1"), Code: 1}, false},
+		{fmt.Errorf(`OCI runtime exec failed: exec failed: unable to start container process: exec: "sh": executable file not found in $PATH: unknown`), true},
+		{apierrors.NewForbidden(v1.Resource("pods/exec"), "dremio-executor-0", fmt.Errorf("denied")), false},
+		{context.DeadlineExceeded, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := shellMissing(tt.err); got != tt.want {
+			t.Errorf("shellMissing(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestFindDremioRoot_FallsBackToPID1(t *testing.T) {
+	execs := &scriptedExecs{respond: func(_ execCall, _ io.Writer) error { return nil }}
+	actions := newDebugTestActions(t, fake.NewSimpleClientset(debugTestPod()), execs, "busybox:1.36")
+	if got := actions.findDremioRoot("dremio-executor-0", "ddc-debug-1"); got != debugFallbackRoot {
+		t.Errorf("expected %v without a Dremio process, got %v", debugFallbackRoot, got)
+	}
+}
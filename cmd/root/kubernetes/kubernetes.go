@@ -33,6 +33,7 @@ import (
 
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/cli"
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/archive"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/consoleprint"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/dirs"
@@ -53,6 +54,12 @@ type KubeArgs struct {
 	K8SContext          string
 	DetectLabelSelector string
 	KubeconfigPath      string
+	// DebugImage, when set, is the image of the ephemeral debug container attached to
+	// pods whose Dremio container lacks the tools DDC needs (see debug_container.go).
+	DebugImage string
+	// CLIBinary is the CLI used with --enable-kubectl and for the RBAC check: kubectl,
+	// oc or a path to either. Empty tries kubectl, then oc.
+	CLIBinary string
 }
 
 // NewK8sAPI is the only supported way to initialize the NewK8sAPI struct
@@ -70,6 +77,8 @@ func NewK8sAPI(kubeArgs KubeArgs, hook shutdown.CancelHook) (*KubeCtlAPIActions,
 		hook:                hook,
 		pidHosts:            make(map[string]string),
 		containerCache:      make(map[string]string),
+		debugImage:          kubeArgs.DebugImage,
+		debugTargets:        make(map[string]*debugTarget),
 		timeoutMinutes:      30,
 		protocol:            "SPDY",
 		spdyExecutorFn: func(config *rest.Config, method string, u *url.URL) (remotecommand.Executor, error) {
@@ -138,6 +147,8 @@ type KubeCtlAPIActions struct {
 	hook                shutdown.CancelHook
 	pidHosts            map[string]string
 	containerCache      map[string]string // cached pod→container name lookups
+	debugImage          string
+	debugTargets        map[string]*debugTarget // per pod tool probe and debug container
 	timeoutMinutes      int
 	m                   sync.Mutex
 	spdyExecutorFn      ExecutorFactory
@@ -149,6 +160,33 @@ func (c *KubeCtlAPIActions) newExecutor(method string, u *url.URL) (remotecomman
 	return c.spdyExecutorFn(c.config, method, u)
 }
 
+// execURL builds the pods/exec URL that runs cmd in the given container of a pod.
+func (c *KubeCtlAPIActions) execURL(host, containerName string, cmd []string, stdin bool) *url.URL {
+	var req *rest.Request
+	if rc, ok := c.client.CoreV1().RESTClient().(*rest.RESTClient); ok && rc != nil {
+		req = rc.Post()
+	} else {
+		// the fake clientset has no REST client, build the same request against the configured host
+		base := &url.URL{}
+		if c.config != nil {
+			if u, err := url.Parse(c.config.Host); err == nil {
+				base = u
+			}
+		}
+		req = rest.NewRequestWithClient(base, "/api/v1", rest.ClientContentConfig{GroupVersion: v1.SchemeGroupVersion}, nil).Verb("POST")
+	}
+	req = req.Resource("pods").Name(host).Namespace(c.namespace).SubResource("exec")
+	option := &v1.PodExecOptions{
+		Container: containerName,
+		Command:   cmd,
+		Stdin:     stdin,
+		Stdout:    true,
+		Stderr:    true,
+		TTY:       false,
+	}
+	return req.VersionedParams(option, scheme.ParameterCodec).URL()
+}
+
 func (c *KubeCtlAPIActions) Protocol() string {
 	c.m.Lock()
 	defer c.m.Unlock()
@@ -325,22 +363,12 @@ func (c *KubeCtlAPIActions) HostExecuteAndStream(mask bool, hostString string, o
 	if err != nil {
 		return fmt.Errorf("failed looking for pod %v: %w", hostString, err)
 	}
-	req := c.client.CoreV1().RESTClient().Post().Resource("pods").Name(hostString).
-		Namespace(c.namespace).SubResource("exec")
-	option := &v1.PodExecOptions{
-		Container: containerName,
-		Command:   cmd,
-		Stdin:     pat != "",
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       false,
+	if debug, ok := c.debugContainerFor(hostString, cmd[2]); ok {
+		containerName = debug.container
+		cmd[2] = rewriteForDebugRoot(cmd[2], debug.root)
+		output = stripDebugRoot(output, debug.root)
 	}
-
-	req = req.VersionedParams(
-		option,
-		scheme.ParameterCodec,
-	)
-	executor, err := c.newExecutor("POST", req.URL())
+	executor, err := c.newExecutor("POST", c.execURL(hostString, containerName, cmd, pat != ""))
 	if err != nil {
 		return err
 	}
@@ -512,24 +540,14 @@ func (c *KubeCtlAPIActions) CopyToHost(hostString string, source, destination st
 		return "", fmt.Errorf("failed looking for pod %v: %w", hostString, err)
 	}
 	simplelog.Debugf("k8s API transfer unarchive %v to send file %v to make it visible on host %v", destDir, destination, hostString)
-	cmdArr := []string{"sh", "-c", fmt.Sprintf("tar -xzmf - -C %v", destDir)}
-	req := c.client.CoreV1().RESTClient().Post().Resource("pods").Name(hostString).
-		Namespace(c.namespace).SubResource("exec")
-	option := &v1.PodExecOptions{
-		Container: containerName,
-		Command:   cmdArr,
-		Stdin:     true,
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       false,
+	untar := fmt.Sprintf("tar -xzmf - -C %v", destDir)
+	if debug, ok := c.debugContainerFor(hostString, untar); ok {
+		containerName = debug.container
+		untar = rewriteForDebugRoot(untar, debug.root)
 	}
+	cmdArr := []string{"sh", "-c", untar}
 
-	req.VersionedParams(
-		option,
-		scheme.ParameterCodec,
-	)
-
-	executor, err := c.newExecutor("POST", req.URL())
+	executor, err := c.newExecutor("POST", c.execURL(hostString, containerName, cmdArr, true))
 	if err != nil {
 		return "", fmt.Errorf("executor creation failed: %w", err)
 	}
@@ -640,41 +658,54 @@ func DiscoverPods(k8sContext, namespace, labelSelector, kubeconfigPath string) (
 // "cat" (or "gzip -c" when useGzip is true) via SPDY exec. Binary data integrity
 // is preserved — stdout goes directly to writer with no line splitting or encoding.
 func (c *KubeCtlAPIActions) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return c.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+
+// StreamFromHostAt is StreamFromHost from offset bytes into the file, read with
+// "tail -c +N" in the container, so a dropped exec stream can be resumed.
+func (c *KubeCtlAPIActions) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
 	if remotePath == "" {
 		return fmt.Errorf("StreamFromHost: remotePath is empty for host %v", host)
 	}
 
-	streamCmd := "cat"
+	compress := ""
 	if useGzip {
-		streamCmd = "gzip -1 -c"
+		compress = "gzip -1 -c"
 	}
+	streamCmd := collection.RemoteReadCommand(remotePath, offset, compress)
 	simplelog.Infof("StreamFromHost: streaming %v:%v via K8s SPDY exec (cmd=%s)", host, remotePath, streamCmd)
+	if err := c.streamCommand(host, host+":"+remotePath, streamCmd, writer); err != nil {
+		return err
+	}
+	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
+	return nil
+}
+
+// StreamCommandFromHost runs streamCmd in the pod via SPDY exec and streams its
+// raw stdout to writer.
+func (c *KubeCtlAPIActions) StreamCommandFromHost(host, streamCmd string, writer io.Writer) error {
+	simplelog.Infof("StreamCommandFromHost: streaming from %v via K8s SPDY exec (cmd=%s)", host, streamCmd)
+	return c.streamCommand(host, host, streamCmd, writer)
+}
 
+// streamCommand executes "sh -c <streamCmd>" in the pod, or in its debug
+// container when the command needs a tool the Dremio container lacks, with
+// stdout going directly to writer. target names what is streamed in error messages.
+func (c *KubeCtlAPIActions) streamCommand(host, target, streamCmd string, writer io.Writer) error {
 	containerName, err := c.getPrimaryContainer(host)
 	if err != nil {
 		return fmt.Errorf("StreamFromHost: failed looking for pod %v: %w", host, err)
 	}
 
-	// Escape single quotes in remotePath to prevent shell injection:
-	// replace ' with '\'' (end quote, escaped quote, start quote).
-	escapedPath := strings.ReplaceAll(remotePath, "'", "'\\''")
-	cmd := []string{"sh", "-c", fmt.Sprintf("%s '%s'", streamCmd, escapedPath)}
-
-	req := c.client.CoreV1().RESTClient().Post().Resource("pods").Name(host).
-		Namespace(c.namespace).SubResource("exec")
-	option := &v1.PodExecOptions{
-		Container: containerName,
-		Command:   cmd,
-		Stdin:     false,
-		Stdout:    true,
-		Stderr:    true,
-		TTY:       false,
+	cmd := []string{"sh", "-c", streamCmd}
+	if debug, ok := c.debugContainerFor(host, cmd[2]); ok {
+		containerName = debug.container
+		cmd[2] = rewriteForDebugRoot(cmd[2], debug.root)
 	}
-	req = req.VersionedParams(option, scheme.ParameterCodec)
 
-	executor, err := c.newExecutor("POST", req.URL())
+	executor, err := c.newExecutor("POST", c.execURL(host, containerName, cmd, false))
 	if err != nil {
-		return fmt.Errorf("StreamFromHost: executor creation failed for %v:%v: %w", host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: executor creation failed for %v: %w", target, err)
 	}
 
 	var stderrBuf bytes.Buffer
@@ -691,12 +722,10 @@ func (c *KubeCtlAPIActions) StreamFromHost(host, remotePath string, writer io.Wr
 	if err != nil {
 		stderrMsg := strings.TrimSpace(stderrBuf.String())
 		if stderrMsg != "" {
-			return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w (stderr: %s)", streamCmd, host, remotePath, err, stderrMsg)
+			return fmt.Errorf("StreamFromHost: %s failed on %v: %w (stderr: %s)", streamCmd, target, err, stderrMsg)
 		}
-		return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w", streamCmd, host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: %s failed on %v: %w", streamCmd, target, err)
 	}
-
-	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
 	return nil
 }
 
@@ -712,39 +741,114 @@ func (c *KubeCtlAPIActions) DiscoverFiles(host, logDir, confDir string) (*collec
 	}, host, logDir, confDir)
 }
 
-// CheckRBAC verifies minimum RBAC permissions for DDC collection on the given namespace.
-// It checks: get pods, list pods, create pods/exec.
-// Returns an error listing any missing permissions.
-func CheckRBAC(k8sContext, namespace, kubeconfigPath string) error {
-	checks := []struct {
-		verb     string
-		resource string
-	}{
-		{"get", "pods"},
-		{"list", "pods"},
-		{"create", "pods/exec"},
+// cliBinaries are the CLIs tried, in order, when none is named. oc accepts every
+// kubectl command DDC runs, so OpenShift hosts without kubectl still work.
+var cliBinaries = []string{"kubectl", "oc"}
+
+// LookupCLI resolves the kubectl compatible CLI to run: name may be "kubectl", "oc" or
+// a path to either; empty tries kubectl, then oc.
+func LookupCLI(name string) (string, error) {
+	return helpers.LookupCLI(name, cliBinaries...)
+}
+
+// RBACPermission is a verb on a resource the collection uses.
+type RBACPermission struct {
+	Verb     string
+	Resource string
+	// Purpose is the part of the collection that needs it.
+	Purpose string
+	// Required permissions are needed to collect at all; without the others only the
+	// part of the collection named by Purpose is skipped.
+	Required bool
+}
+
+func (p RBACPermission) String() string {
+	return p.Verb + " " + p.Resource
+}
+
+// rbacPermissions are the permissions checked in the namespace. Resources the server
+// does not serve (metrics.k8s.io without metrics-server, the OpenShift groups on
+// other distributions) are not reported.
+var rbacPermissions = []RBACPermission{
+	{Verb: "get", Resource: "pods", Purpose: "node discovery", Required: true},
+	{Verb: "list", Resource: "pods", Purpose: "node discovery", Required: true},
+	{Verb: "create", Resource: "pods/exec", Purpose: "file and tool collection", Required: true},
+	{Verb: "get", Resource: "pods/log", Purpose: "container logs"},
+	{Verb: "list", Resource: "configmaps", Purpose: "kubernetes/configmaps"},
+	{Verb: "list", Resource: "secrets", Purpose: "secret metadata and Helm releases"},
+	{Verb: "list", Resource: "events.events.k8s.io", Purpose: "events and the pod restart report"},
+	{Verb: "list", Resource: "pods.metrics.k8s.io", Purpose: "pod usage in kubernetes/metrics"},
+	{Verb: "list", Resource: "nodes.metrics.k8s.io", Purpose: "node usage in kubernetes/metrics"},
+	{Verb: "list", Resource: "routes.route.openshift.io", Purpose: "OpenShift routes"},
+	{Verb: "list", Resource: "securitycontextconstraints.security.openshift.io", Purpose: "OpenShift SCC admission"},
+	{Verb: "use", Resource: "securitycontextconstraints.security.openshift.io", Purpose: "OpenShift SCC admission"},
+}
+
+// debugContainerPermission is checked when a debug container image is set.
+var debugContainerPermission = RBACPermission{Verb: "update", Resource: "pods/ephemeralcontainers", Purpose: "the debug container", Required: true}
+
+// canI runs "auth can-i" with the given arguments. It is a variable so tests can
+// stand in for the cluster.
+var canI = func(cliPath string, args []string) (string, error) {
+	cmd := exec.Command(cliPath, args...) // #nosec G204 -- cliPath is resolved from PATH or the --kubectl-binary flag
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// CheckRBAC returns the permissions the collection needs in the namespace of kubeArgs
+// that the current user lacks, required ones first. It fails only when the CLI
+// cannot be found.
+func CheckRBAC(kubeArgs KubeArgs) ([]RBACPermission, error) {
+	cliPath, err := LookupCLI(kubeArgs.CLIBinary)
+	if err != nil {
+		return nil, fmt.Errorf("unable to check RBAC: %w", err)
+	}
+	checks := rbacPermissions
+	if kubeArgs.DebugImage != "" {
+		checks = append(append([]RBACPermission{}, rbacPermissions...), debugContainerPermission)
 	}
-	var missing []string
+	var missing []RBACPermission
 	for _, check := range checks {
 		var args []string
-		if kubeconfigPath != "" {
-			args = append(args, "--kubeconfig", kubeconfigPath)
+		if kubeArgs.KubeconfigPath != "" {
+			args = append(args, "--kubeconfig", kubeArgs.KubeconfigPath)
 		}
-		if k8sContext != "" {
-			args = append(args, "--context", k8sContext)
+		if kubeArgs.K8SContext != "" {
+			args = append(args, "--context", kubeArgs.K8SContext)
 		}
-		args = append(args, "auth", "can-i", check.verb, check.resource, "-n", namespace)
-		cmd := exec.Command("kubectl", args...)
-		output, err := cmd.CombinedOutput()
-		result := strings.TrimSpace(string(output))
-		if err != nil || result != "yes" {
-			missing = append(missing, fmt.Sprintf("%s %s", check.verb, check.resource))
+		args = append(args, "auth", "can-i", check.Verb, check.Resource, "-n", kubeArgs.Namespace)
+		output, err := canI(cliPath, args)
+		if strings.Contains(output, "doesn't have a resource type") {
+			simplelog.Debugf("RBAC check: %v is not served, skipping", check.Resource)
+			continue
+		}
+		if err != nil || !hasYes(output) {
+			missing = append(missing, check)
 		}
 	}
-	if len(missing) > 0 {
-		return fmt.Errorf("insufficient RBAC permissions in namespace %q: missing %s. Ensure your ServiceAccount has a Role/ClusterRole with these permissions", namespace, strings.Join(missing, ", "))
+	sort.SliceStable(missing, func(i, j int) bool {
+		return missing[i].Required && !missing[j].Required
+	})
+	return missing, nil
+}
+
+// hasYes reports whether the last line of an "auth can-i" output, after any
+// warnings, is yes.
+func hasYes(output string) bool {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]) == "yes"
+}
+
+// RBACError describes missing permissions as an error, or returns nil when none are.
+func RBACError(namespace string, missing []RBACPermission) error {
+	if len(missing) == 0 {
+		return nil
 	}
-	return nil
+	var names []string
+	for _, p := range missing {
+		names = append(names, fmt.Sprintf("%v (%v)", p, p.Purpose))
+	}
+	return fmt.Errorf("insufficient RBAC permissions in namespace %q: missing %s. Ensure your ServiceAccount has a Role/ClusterRole with these permissions", namespace, strings.Join(names, ", "))
 }
 
 // ListContexts parses the kubeconfig and returns all context names (sorted)
@@ -26,6 +26,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
 	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
 	v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -432,3 +433,71 @@ users:
 		t.Error("VerifyConnectivity expected error, got nil")
 	}
 }
+
+func TestLookupCLI(t *testing.T) {
+	installed := map[string]bool{"kubectl": true, "oc": true}
+	orig := helpers.LookPath
+	defer func() { helpers.LookPath = orig }()
+	helpers.LookPath = func(file string) (string, error) {
+		if installed[file] {
+			return "/usr/bin/" + file, nil
+		}
+		return "", fmt.Errorf("%v: executable file not found in $PATH", file)
+	}
+
+	if got, err := LookupCLI(""); err != nil || got != "/usr/bin/kubectl" {
+		t.Errorf("expected kubectl to be preferred, got %q, %v", got, err)
+	}
+	delete(installed, "kubectl")
+	if got, err := LookupCLI(""); err != nil || got != "/usr/bin/oc" {
+		t.Errorf("expected oc when kubectl is missing, got %q, %v", got, err)
+	}
+}
+
+func TestCheckRBAC(t *testing.T) {
+	origLook, origCanI := helpers.LookPath, canI
+	defer func() { helpers.LookPath, canI = origLook, origCanI }()
+	helpers.LookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	denied := map[string]bool{"list secrets": true, "create pods/exec": true}
+	var asked []string
+	canI = func(_ string, args []string) (string, error) {
+		// ... auth can-i <verb> <resource> -n <namespace>
+		perm := args[len(args)-4] + " " + args[len(args)-3]
+		asked = append(asked, perm)
+		switch {
+		case strings.HasSuffix(perm, ".openshift.io"):
+			return "Warning: the server doesn't have a resource type '" + args[len(args)-3] + "'\nno\n", fmt.Errorf("exit status 1")
+		case denied[perm]:
+			return "no\n", fmt.Errorf("exit status 1")
+		}
+		return "yes\n", nil
+	}
+
+	missing, err := CheckRBAC(KubeArgs{Namespace: "dremio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range missing {
+		got = append(got, p.String())
+	}
+	if want := []string{"create pods/exec", "list secrets"}; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected the required permission first and resources the server does not serve skipped, got %v", got)
+	}
+	for _, want := range []string{"list configmaps", "list events.events.k8s.io", "list pods.metrics.k8s.io", "get pods/log", "use securitycontextconstraints.security.openshift.io"} {
+		if !strings.Contains(strings.Join(asked, ","), want) {
+			t.Errorf("expected %q to be checked, checked %v", want, asked)
+		}
+	}
+	if err := RBACError("dremio", missing); err == nil || !strings.Contains(err.Error(), "list secrets (secret metadata and Helm releases)") {
+		t.Errorf("expected the missing permissions and their purpose in the error, got %v", err)
+	}
+
+	asked = nil
+	if _, err := CheckRBAC(KubeArgs{Namespace: "dremio", DebugImage: "busybox"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(asked, ","), "update pods/ephemeralcontainers") {
+		t.Errorf("expected the debug container permission checked with a debug image, checked %v", asked)
+	}
+}
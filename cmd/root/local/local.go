@@ -167,17 +167,33 @@ func (c *LocalCollector) GetExecutors() ([]string, error) {
 	return []string{host}, nil
 }
 
-// StreamFromHost streams a local file to writer. When useGzip is true, it uses
-// "gzip -c <path>" to stream compressed data. When false, it reads the file directly.
-func (c *LocalCollector) StreamFromHost(_, remotePath string, writer io.Writer, useGzip bool) error {
+// StreamFromHost streams a local file to writer. When useGzip is true, it pipes
+// the file through "gzip -c" to stream compressed data. When false, it reads the file directly.
+func (c *LocalCollector) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return c.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+
+// StreamFromHostAt is StreamFromHost starting offset bytes into the file.
+func (c *LocalCollector) StreamFromHostAt(_, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
 	if remotePath == "" {
 		return fmt.Errorf("StreamFromHost: remotePath is empty")
 	}
 
+	simplelog.Infof("StreamFromHost: streaming %v from byte %d (gzip=%v)", remotePath, offset, useGzip)
+	src, err := os.Open(filepath.Clean(remotePath))
+	if err != nil {
+		return fmt.Errorf("StreamFromHost: failed to open %v (gzip=%v): %w", remotePath, useGzip, err)
+	}
+	defer src.Close() //nolint:errcheck // read-only file; close error is non-fatal
+	if offset > 0 {
+		if _, err := src.Seek(offset, io.SeekStart); err != nil {
+			return fmt.Errorf("StreamFromHost: failed to seek %v to %d: %w", remotePath, offset, err)
+		}
+	}
+
 	if useGzip {
-		simplelog.Infof("StreamFromHost: streaming %v with gzip", remotePath)
-		// #nosec G204 -- remotePath is a discovered file path, not user input
-		cmd := exec.Command("gzip", "-c", remotePath)
+		cmd := exec.Command("gzip", "-c")
+		cmd.Stdin = src
 		cmd.Stdout = writer
 
 		stderrPipe, err := cmd.StderrPipe()
@@ -203,12 +219,6 @@ func (c *LocalCollector) StreamFromHost(_, remotePath string, writer io.Writer,
 		return nil
 	}
 
-	simplelog.Infof("StreamFromHost: streaming %v (gzip=false)", remotePath)
-	src, err := os.Open(filepath.Clean(remotePath))
-	if err != nil {
-		return fmt.Errorf("StreamFromHost: failed to open %v (gzip=false): %w", remotePath, err)
-	}
-	defer src.Close() //nolint:errcheck // read-only file; close error is non-fatal
 	_, err = io.Copy(writer, src)
 	if err != nil {
 		return fmt.Errorf("StreamFromHost: failed to copy %v (gzip=false): %w", remotePath, err)
@@ -217,9 +227,42 @@ func (c *LocalCollector) StreamFromHost(_, remotePath string, writer io.Writer,
 	return nil
 }
 
-// DiscoverFiles runs local discovery commands.
+// StreamCommandFromHost runs streamCmd through the local shell and streams its
+// stdout to writer.
+func (c *LocalCollector) StreamCommandFromHost(_, streamCmd string, writer io.Writer) error {
+	simplelog.Infof("StreamCommandFromHost: streaming local command (cmd=%s)", streamCmd)
+	// #nosec G204 -- the command is built by the collection package from discovered paths
+	cmd := exec.Command("sh", "-c", streamCmd)
+	cmd.Stdout = writer
+
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("StreamCommandFromHost: failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("StreamCommandFromHost: failed to start %s: %w", streamCmd, err)
+	}
+
+	stderrBytes, _ := io.ReadAll(stderrPipe)
+
+	if err := cmd.Wait(); err != nil {
+		stderrMsg := strings.TrimSpace(string(stderrBytes))
+		if stderrMsg != "" {
+			return fmt.Errorf("StreamCommandFromHost: %s failed: %w (stderr: %s)", streamCmd, err, stderrMsg)
+		}
+		return fmt.Errorf("StreamCommandFromHost: %s failed: %w", streamCmd, err)
+	}
+	return nil
+}
+
+// DiscoverFiles runs local discovery commands. Local files are read directly, so
+// the tar batch is turned off: piping them through tar would only add a copy.
 func (c *LocalCollector) DiscoverFiles(host, logDir, confDir string) (*collection.RemoteNodeInfo, error) {
-	return collection.RunDiscovery(func(h string, args ...string) (string, error) {
+	info, err := collection.RunDiscovery(func(h string, args ...string) (string, error) {
 		return c.HostExecute(false, h, args...)
 	}, host, logDir, confDir)
+	if info != nil {
+		info.TarAvailable = false
+	}
+	return info, err
 }
@@ -157,6 +157,32 @@ func TestStreamFromHostGzip(t *testing.T) {
 	assert.Equal(t, content, decompressed)
 }
 
+func TestStreamFromHostAtOffset(t *testing.T) {
+	tmpDir := t.TempDir()
+	content := []byte("0123456789resume from here\n")
+	srcPath := filepath.Join(tmpDir, "testfile.txt")
+	require.NoError(t, os.WriteFile(srcPath, content, 0o600))
+
+	hook := shutdown.NewHook()
+	lc := NewLocalCollector(hook, "/nonexistent/dremio.conf", "/opt/dremio")
+
+	var buf bytes.Buffer
+	require.NoError(t, lc.StreamFromHostAt("", srcPath, 10, &buf, false))
+	assert.Equal(t, content[10:], buf.Bytes())
+
+	if _, err := osexec.LookPath("gzip"); err != nil {
+		return
+	}
+	buf.Reset()
+	require.NoError(t, lc.StreamFromHostAt("", srcPath, 10, &buf, true))
+	gz, err := gzip.NewReader(&buf)
+	require.NoError(t, err)
+	defer gz.Close()
+	decompressed, err := io.ReadAll(gz)
+	require.NoError(t, err)
+	assert.Equal(t, content[10:], decompressed)
+}
+
 func TestStreamFromHostEmptyPath(t *testing.T) {
 	hook := shutdown.NewHook()
 	lc := NewLocalCollector(hook, "/nonexistent/dremio.conf", "/opt/dremio")
@@ -297,23 +297,43 @@ func (c *CmdSSHActions) HelpText() string {
 // directly to writer with no line splitting or encoding.
 // This bypasses cli.ExecuteAndStreamOutput which is line-oriented.
 func (c *CmdSSHActions) StreamFromHost(host, remotePath string, writer io.Writer, useGzip bool) error {
+	return c.StreamFromHostAt(host, remotePath, 0, writer, useGzip)
+}
+
+// StreamFromHostAt is StreamFromHost from offset bytes into the file, read with
+// "tail -c +N" on the remote side.
+func (c *CmdSSHActions) StreamFromHostAt(host, remotePath string, offset int64, writer io.Writer, useGzip bool) error {
 	if remotePath == "" {
 		return fmt.Errorf("StreamFromHost: remotePath is empty for host %v", host)
 	}
 
-	streamCmd := "cat"
+	compress := ""
 	if useGzip {
-		streamCmd = "gzip -c"
+		compress = "gzip -c"
 	}
+	streamCmd := collection.RemoteReadCommand(remotePath, offset, compress)
 	simplelog.Infof("StreamFromHost: streaming %v:%v via SSH (cmd=%s)", host, remotePath, streamCmd)
+	if err := c.streamCommand(host, host+":"+remotePath, streamCmd, writer); err != nil {
+		return err
+	}
+	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
+	return nil
+}
+
+// StreamCommandFromHost runs streamCmd on the host over SSH and streams its raw
+// stdout to writer.
+func (c *CmdSSHActions) StreamCommandFromHost(host, streamCmd string, writer io.Writer) error {
+	simplelog.Infof("StreamCommandFromHost: streaming from %v via SSH (cmd=%s)", host, streamCmd)
+	return c.streamCommand(host, host, streamCmd, writer)
+}
 
+// streamCommand executes "ssh ... <streamCmd>" with stdout going directly to
+// writer. target names what is streamed in error messages.
+func (c *CmdSSHActions) streamCommand(host, target, streamCmd string, writer io.Writer) error {
 	sshArgs := c.baseSSHArgs()
 	sshArgs = append(sshArgs, fmt.Sprintf("%v@%v", c.sshUser, host))
 	sshArgs = c.addSSHUser(sshArgs)
-
-	// Escape single quotes in remotePath to prevent shell injection.
-	escapedPath := strings.ReplaceAll(remotePath, "'", "'\\''")
-	sshArgs = append(sshArgs, fmt.Sprintf("%s '%s'", streamCmd, escapedPath))
+	sshArgs = append(sshArgs, streamCmd)
 
 	// sshArgs[0] is "ssh", rest are arguments.
 	// #nosec G204 -- arguments are controlled by the caller (CLI flags and discovered paths)
@@ -322,11 +342,11 @@ func (c *CmdSSHActions) StreamFromHost(host, remotePath string, writer io.Writer
 
 	stderrPipe, err := cmd.StderrPipe()
 	if err != nil {
-		return fmt.Errorf("StreamFromHost: failed to create stderr pipe for %v:%v: %w", host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: failed to create stderr pipe for %v: %w", target, err)
 	}
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("StreamFromHost: failed to start ssh for %v:%v: %w", host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: failed to start ssh for %v: %w", target, err)
 	}
 
 	stderrBytes, _ := io.ReadAll(stderrPipe)
@@ -334,12 +354,10 @@ func (c *CmdSSHActions) StreamFromHost(host, remotePath string, writer io.Writer
 	if err := cmd.Wait(); err != nil {
 		stderrMsg := strings.TrimSpace(string(stderrBytes))
 		if stderrMsg != "" {
-			return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w (stderr: %s)", streamCmd, host, remotePath, err, stderrMsg)
+			return fmt.Errorf("StreamFromHost: %s failed on %v: %w (stderr: %s)", streamCmd, target, err, stderrMsg)
 		}
-		return fmt.Errorf("StreamFromHost: %s failed on %v:%v: %w", streamCmd, host, remotePath, err)
+		return fmt.Errorf("StreamFromHost: %s failed on %v: %w", streamCmd, target, err)
 	}
-
-	simplelog.Infof("StreamFromHost: completed streaming %v:%v", host, remotePath)
 	return nil
 }
 
@@ -355,23 +373,39 @@ func (c *CmdSSHActions) DiscoverFiles(host, logDir, confDir string) (*collection
 // collection begins. It runs a lightweight "echo ok" command with a
 // connect timeout so unreachable nodes are detected early.
 func CheckSSHConnectivity(host, user, keyPath string, timeout time.Duration) error {
+	output, err := checkCommand(host, user, keyPath, timeout, "echo", "ok").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ssh connectivity check failed for %s@%s: %w (output: %s)", user, host, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// CheckSudo verifies that user can run commands as sudoUser on host without a
+// password prompt, as every collection command is wrapped in "sudo -u" when
+// --sudo-user is set. It runs "true", so nothing changes on the host.
+func CheckSudo(host, user, keyPath, sudoUser string, timeout time.Duration) error {
+	output, err := checkCommand(host, user, keyPath, timeout, "sudo", "-n", "-u", sudoUser, "true").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("sudo -u %s check failed for %s@%s: %w (output: %s)", sudoUser, user, host, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// checkCommand builds a non-interactive ssh invocation of remote with a
+// connect timeout, for the pre-checks above.
+func checkCommand(host, user, keyPath string, timeout time.Duration, remote ...string) *exec.Cmd {
 	connectTimeout := fmt.Sprintf("%d", int(timeout.Seconds()))
 	if connectTimeout == "0" {
 		connectTimeout = "5"
 	}
-	// #nosec G204 -- arguments are controlled by the caller (CLI flags)
-	cmd := exec.Command("ssh",
+	args := []string{
 		"-o", fmt.Sprintf("ConnectTimeout=%s", connectTimeout),
 		"-o", "BatchMode=yes",
 		"-o", "StrictHostKeyChecking=no",
 		"-o", "UserKnownHostsFile=/dev/null",
 		"-i", keyPath,
 		fmt.Sprintf("%s@%s", user, host),
-		"echo", "ok",
-	)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("ssh connectivity check failed for %s@%s: %w (output: %s)", user, host, err, strings.TrimSpace(string(output)))
 	}
-	return nil
+	// #nosec G204 -- arguments are controlled by the caller (CLI flags)
+	return exec.Command("ssh", append(args, remote...)...)
 }
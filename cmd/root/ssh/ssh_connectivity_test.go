@@ -61,3 +61,15 @@ func containsAll(s string, subs ...string) bool {
 	}
 	return true
 }
+
+// TestCheckSudo_Unreachable verifies that CheckSudo fails, naming the sudo
+// user, when the host cannot be reached.
+func TestCheckSudo_Unreachable(t *testing.T) {
+	err := CheckSudo("192.0.2.1", "testuser", "/nonexistent/key", "dremio", 2*time.Second)
+	if err == nil {
+		t.Fatal("expected an error for unreachable host, got nil")
+	}
+	if got := err.Error(); !containsAll(got, "192.0.2.1", "testuser", "dremio") {
+		t.Errorf("error should reference the host, user and sudo user, got: %s", got)
+	}
+}
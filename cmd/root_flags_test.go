@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/spf13/cobra"
 )
 
 var perLogNumDaysFlags = []string{
@@ -128,3 +129,24 @@ func TestResolveMetaRefresh(t *testing.T) {
 		t.Error("TUI must trust global=false even when confData=true")
 	}
 }
+
+func TestCatalogInventoryOnlyOnStandard(t *testing.T) {
+	for _, name := range []string{conf.KeyCollectCatalogInventory, conf.KeyCatalogMaxDepth, conf.KeyCatalogMaxItems, "dremio-pat-token", "dremio-endpoint"} {
+		if SSHStandardCmd.Flags().Lookup(name) == nil {
+			t.Errorf("SSHStandardCmd should have --%s", name)
+		}
+	}
+	if SSHDiagnosisCmd.Flags().Lookup(conf.KeyCollectCatalogInventory) != nil {
+		t.Errorf("SSHDiagnosisCmd should not have --%s", conf.KeyCollectCatalogInventory)
+	}
+}
+
+func TestRocksDBTypesFlagsOnAllCollectCommands(t *testing.T) {
+	for _, cmd := range []*cobra.Command{SSHStandardCmd, K8sStandardCmd, LocalStandardCmd, LocalK8sStandardCmd, DockerStandardCmd, SSHDiagnosisCmd, K8sDiagnosisCmd, LocalDiagnosisCmd, LocalK8sDiagnosisCmd, DockerDiagnosisCmd} {
+		for _, name := range []string{conf.KeyRocksDBTypes, conf.KeyRocksDBTypesConfig} {
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("%s should have --%s", cmd.CommandPath(), name)
+			}
+		}
+	}
+}
@@ -367,6 +367,32 @@ func TestCollectSubcommandHelp_IncludesLocalK8s(t *testing.T) {
 	}
 }
 
+func TestBareDockerShowsHelp(t *testing.T) {
+	err := Execute([]string{"ddc", "collect", "docker"})
+	if err != nil {
+		t.Errorf("bare 'ddc collect docker' should show help without error, got: %v", err)
+	}
+}
+
+func TestCollectSubcommandHelp_IncludesDocker(t *testing.T) {
+	usage := CollectCmd.UsageString()
+	if !strings.Contains(usage, "docker") {
+		t.Errorf("collect usage should list 'docker' subcommand, got:\n%s", usage)
+	}
+}
+
+func TestDockerFlagsAcceptedOnDockerOnly(t *testing.T) {
+	for _, name := range []string{"container-cli", "coordinator-filter", "executor-filter", "collect-container-logs", "container-log-limit-bytes"} {
+		if buildMergedFlagSet(DockerStandardCmd).Lookup(name) == nil {
+			t.Errorf("docker standard should accept --%s", name)
+		}
+	}
+	err := Execute([]string{"ddc", "collect", "k8s", "standard", "--container-cli", "podman"})
+	if err == nil || !strings.Contains(err.Error(), "unknown flag") {
+		t.Errorf("expected --container-cli to be rejected on k8s, got: %v", err)
+	}
+}
+
 func TestK8sFlagRejectedOnSSH(t *testing.T) {
 	err := Execute([]string{"ddc", "collect", "ssh", "standard", "--namespace", "test"})
 	if err == nil || !strings.Contains(err.Error(), "unknown flag") {
@@ -0,0 +1,430 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// watch package polls a cluster and runs a scoped diagnosis collection when a node shows a problem
+package watch
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/local/conf"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/docker"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/helpers"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubectl"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/kubernetes"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/local"
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/ssh"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/logparser"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/shutdown"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/simplelog"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// ssh
+	coordinatorStr string
+	executorsStr   string
+	sshKeyLoc      string
+	sshUser        string
+	sudoUser       string
+	// k8s
+	namespace      string
+	k8sContext     string
+	kubeconfigPath string
+	labelSelector  string
+	enableKubeCtl  bool
+	kubectlBinary  string
+	// local
+	dremioHome  string
+	localLogDir string
+	// docker
+	containerCLI      string
+	coordinatorFilter string
+	executorFilter    string
+	// shared
+	nodesFlag         string
+	excludeNodesFlag  string
+	coordinatorLogDir string
+	executorLogDir    string
+	dremioConfDir     string
+	// triggers
+	interval         time.Duration
+	cooldown         time.Duration
+	logPatterns      []string
+	logRegexes       []string
+	heapThreshold    int
+	gcPauseThreshold time.Duration
+	maxCollections   int
+	// scoped collection
+	outputDir       string
+	logWindow       time.Duration
+	collectJStack   bool
+	collectTop      bool
+	collectJFR      bool
+	diagTimeSeconds int
+)
+
+var WatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch a cluster and run a diagnosis collection on a node as soon as it shows a problem",
+	Long: `Poll every node and run a scoped diagnosis collection the moment a trigger fires,
+so the jstack, top and JFR capture the problem while it happens instead of after
+someone noticed it. Between collections only reads run on the nodes: the lines
+appended to server.log and the GC log, and jcmd GC.heap_info (VM.flags once per JVM).
+
+triggers:
+
+	--log-patterns / --log-regex  a new server.log line matches a logparser pattern or a regex
+	--heap-threshold              the used heap of jcmd GC.heap_info reaches the percentage of -Xmx
+	--gc-pause-threshold          a new GC log line records a stop-the-world pause at least
+	                              this long (concurrent phases are not pauses)
+
+A trigger collects jstack, top and JFR on the node it fired on, plus its server,
+GC and hs_err logs and queries.json trimmed to the last --log-window, into
+ddc-watch-<trigger>-<node>-<time>.tgz. The node is then left alone for --cooldown;
+triggers during the cooldown are logged and skipped. Every decision is printed and
+written to ddc.log. Stop with Ctrl+C: it cancels a running collection and no other
+starts; a second Ctrl+C exits at once.
+
+Collections run one at a time inside the poll loop: while one runs, for at least
+--diag-time-seconds, no node is polled. Log lines written meanwhile are read on the
+next poll, but heap usage is only sampled then, so a spike that comes and goes
+during a collection is not seen.
+
+examples:
+
+	ddc watch ssh --coordinator 10.0.0.1 --executors 10.0.0.2,10.0.0.3 --ssh-user ubuntu --ssh-key ~/.ssh/id_rsa
+	ddc watch k8s --namespace dremio --heap-threshold 95 --cooldown 30m
+	ddc watch local --log-patterns oom --gc-pause-threshold 0 --max-collections 1
+`,
+}
+
+var SSHWatchCmd = &cobra.Command{
+	Use:   "ssh",
+	Short: "Watch a cluster reached over SSH",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if coordinatorStr == "" {
+			return fmt.Errorf("--coordinator is required for SSH transport")
+		}
+		if sshKeyLoc == "" || sshUser == "" {
+			return fmt.Errorf("--ssh-key and --ssh-user are required for SSH transport")
+		}
+		args, err := watchArgs(cmd)
+		if err != nil {
+			return err
+		}
+		hook := shutdown.NewHook()
+		defer hook.Cleanup()
+		c := ssh.NewCmdSSHActions(ssh.Args{
+			SSHKeyLoc:      sshKeyLoc,
+			SSHUser:        sshUser,
+			SudoUser:       sudoUser,
+			ExecutorStr:    executorsStr,
+			CoordinatorStr: coordinatorStr,
+		}, hook)
+		return run(cmd, c, args, hook)
+	},
+}
+
+var K8sWatchCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "Watch a cluster running on Kubernetes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		if namespace == "" {
+			namespace = "default"
+		}
+		args, err := watchArgs(cmd)
+		if err != nil {
+			return err
+		}
+		hook := shutdown.NewHook()
+		defer hook.Cleanup()
+		kubeArgs := kubernetes.KubeArgs{
+			Namespace:           namespace,
+			DetectLabelSelector: labelSelector,
+			K8SContext:          k8sContext,
+			KubeconfigPath:      kubeconfigPath,
+			CLIBinary:           kubectlBinary,
+		}
+		k8sActions, err := kubernetes.NewK8sAPI(kubeArgs, hook)
+		if err != nil {
+			return err
+		}
+		var c collection.Collector = k8sActions
+		if enableKubeCtl {
+			potentialStrategy, err := kubectl.NewKubectlK8sActions(hook, kubeArgs)
+			if err != nil {
+				simplelog.Warningf("kubectl not available, using embedded k8s api: %v", err)
+			} else {
+				c = potentialStrategy
+			}
+		}
+		return run(cmd, c, args, hook)
+	},
+}
+
+var LocalWatchCmd = &cobra.Command{
+	Use:   "local",
+	Short: "Watch the Dremio node DDC runs on",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		args, err := watchArgs(cmd)
+		if err != nil {
+			return err
+		}
+		if localLogDir != "" {
+			args.CoordinatorLogDir = localLogDir
+			args.ExecutorLogDir = localLogDir
+		}
+		hook := shutdown.NewHook()
+		defer hook.Cleanup()
+		c := local.NewLocalCollector(hook, filepath.Join(dremioConfDir, "dremio.conf"), dremioHome)
+		return run(cmd, c, args, hook)
+	},
+}
+
+var DockerWatchCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Watch a cluster running in docker or podman containers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		args, err := watchArgs(cmd)
+		if err != nil {
+			return err
+		}
+		hook := shutdown.NewHook()
+		defer hook.Cleanup()
+		c, err := docker.NewCmdDockerActions(docker.Args{
+			CLIBinary:         containerCLI,
+			CoordinatorFilter: coordinatorFilter,
+			ExecutorFilter:    executorFilter,
+		}, hook)
+		if err != nil {
+			return err
+		}
+		return run(cmd, c, args, hook)
+	},
+}
+
+// watchArgs validates the trigger flags and returns the watch arguments.
+func watchArgs(cmd *cobra.Command) (collection.WatchArgs, error) {
+	if interval <= 0 {
+		return collection.WatchArgs{}, fmt.Errorf("--interval must be positive, got %v", interval)
+	}
+	if cooldown < 0 || gcPauseThreshold < 0 || maxCollections < 0 {
+		return collection.WatchArgs{}, fmt.Errorf("--cooldown, --gc-pause-threshold and --max-collections cannot be negative")
+	}
+	if heapThreshold < 0 || heapThreshold > 100 {
+		return collection.WatchArgs{}, fmt.Errorf("--heap-threshold must be a percentage between 0 and 100, got %v", heapThreshold)
+	}
+	patterns, err := logparser.SelectPatterns(splitNodes(strings.Join(logPatterns, ",")))
+	if err != nil {
+		return collection.WatchArgs{}, fmt.Errorf("--log-patterns: %w", err)
+	}
+	for _, expr := range logRegexes {
+		if expr == "" {
+			continue
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return collection.WatchArgs{}, fmt.Errorf("--log-regex %q: %w", expr, err)
+		}
+		patterns = append(patterns, logparser.Pattern{Name: "log-regex " + expr, Category: "custom", Regex: re})
+	}
+	if len(patterns) == 0 && heapThreshold == 0 && gcPauseThreshold == 0 {
+		return collection.WatchArgs{}, fmt.Errorf("every trigger is disabled: set --log-patterns, --log-regex, --heap-threshold or --gc-pause-threshold")
+	}
+	if !collectJStack && !collectTop && !collectJFR {
+		simplelog.Warning("--diag-jstack, --diag-top and --diag-jfr are all off, triggers only collect logs")
+	}
+	return collection.WatchArgs{
+		Interval:          interval,
+		Cooldown:          cooldown,
+		LogPatterns:       patterns,
+		HeapPercent:       heapThreshold,
+		GCPause:           gcPauseThreshold,
+		MaxCollections:    maxCollections,
+		CoordinatorLogDir: coordinatorLogDir,
+		ExecutorLogDir:    executorLogDir,
+		DremioConfDir:     dremioConfDir,
+		IncludeNodes:      splitNodes(nodesFlag),
+		ExcludeNodes:      splitNodes(excludeNodesFlag),
+		Out:               cmd.OutOrStdout(),
+	}, nil
+}
+
+// run watches the nodes of c until Ctrl+C, SIGTERM or --max-collections. The
+// first signal cancels the running collection through hook and no other starts;
+// a second one kills the process.
+func run(cmd *cobra.Command, c collection.Collector, args collection.WatchArgs, hook shutdown.Hook) error {
+	dir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("error when getting the output directory: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("unable to create output directory %v: %w", dir, err)
+	}
+	// from here on errors are about the cluster, not the command line
+	cmd.SilenceUsage = true
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-finished:
+		case <-ctx.Done():
+			select {
+			case <-finished:
+				// ctx is done because run returned, not because of a signal
+				return
+			default:
+			}
+			// back to the default handling: a second Ctrl+C kills the process
+			stop()
+			simplelog.Info("watch: interrupted, cancelling the running collection")
+			hook.Interrupt()
+		}
+	}()
+	fmt.Fprintf(cmd.OutOrStdout(), "watching, collections are written to %v; press Ctrl+C to stop\n", dir)
+	return collection.RunWatch(ctx, c, args, func(t collection.WatchTrigger) error {
+		return collect(c, hook, scopedArgs(t, dir, args))
+	})
+}
+
+// scopedArgs returns the diagnosis collection of a trigger: the JVM captures on
+// the node it fired on and its logs of the last --log-window.
+func scopedArgs(t collection.WatchTrigger, dir string, args collection.WatchArgs) collection.Args {
+	name := fmt.Sprintf("ddc-watch-%v-%v-%v.tgz", t.Kind, fileSafe(t.Host), t.Time.UTC().Format("20060102T150405Z"))
+	return collection.Args{
+		OutputLoc:         filepath.Join(dir, name),
+		DDCfs:             helpers.NewRealFileSystem(),
+		CollectionMode:    collects.DiagnosisCollection,
+		CollectionThreads: 1,
+		CoordinatorLogDir: args.CoordinatorLogDir,
+		ExecutorLogDir:    args.ExecutorLogDir,
+		DremioConfDir:     args.DremioConfDir,
+		DiagLogDays:       int(logWindow.Hours()/24) + 1,
+		IncludeNodes:      []string{t.Host},
+		// JVM captures of the affected node
+		CollectJStack:   collectJStack,
+		CollectTop:      collectTop,
+		CollectJFR:      collectJFR,
+		CollectJVMFlags: true,
+		DiagTimeSeconds: diagTimeSeconds,
+		// recent logs
+		CollectServerLogs:  true,
+		CollectGCLogs:      true,
+		CollectHSErrFiles:  true,
+		CollectQueriesJSON: true,
+		TrimToWindow:       true,
+		TrimSince:          t.Time.Add(-logWindow),
+		WatchTrigger:       &t,
+	}
+}
+
+// collect runs one scoped collection with its own copy strategy.
+func collect(c collection.Collector, hook shutdown.Hook, args collection.Args) error {
+	cs := helpers.NewHCCopyStrategy(args.DDCfs, &helpers.RealTimeService{}, filepath.Dir(args.OutputLoc))
+	defer cs.Close()
+	if err := collection.Execute(c, cs, args, hook, func() {}); err != nil {
+		return err
+	}
+	simplelog.Infof("watch: collection written to %v", args.OutputLoc)
+	return nil
+}
+
+// fileSafe replaces the characters of a node name that do not belong in a file name.
+func fileSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == ':' || r == '\\' || r == ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// splitNodes splits a comma-separated --nodes / --exclude-nodes value.
+func splitNodes(s string) []string {
+	var nodes []string
+	for _, n := range strings.Split(s, ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+func init() {
+	SSHWatchCmd.Flags().StringVarP(&coordinatorStr, "coordinator", "c", "", "set a list of ip addresses separated by commas")
+	SSHWatchCmd.Flags().StringVarP(&executorsStr, "executors", "e", "", "set a list of ip addresses separated by commas")
+	SSHWatchCmd.Flags().StringVarP(&sshKeyLoc, "ssh-key", "s", "", "of ssh key to use to login")
+	SSHWatchCmd.Flags().StringVarP(&sshUser, "ssh-user", "u", "", "user to use during ssh operations to login")
+	SSHWatchCmd.Flags().StringVarP(&sudoUser, "sudo-user", "b", "", "if any diagnostics commands need a sudo user (i.e. for jcmd)")
+
+	K8sWatchCmd.Flags().StringVarP(&namespace, "namespace", "n", "", "namespace to use for kubernetes pods (default: default)")
+	K8sWatchCmd.Flags().StringVarP(&k8sContext, "context", "x", "", "context to use for kubernetes pods")
+	K8sWatchCmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", "", "path to kubeconfig file (overrides $KUBECONFIG and ~/.kube/config)")
+	K8sWatchCmd.Flags().StringVar(&labelSelector, "detect-label-selector", "role=dremio-cluster-pod", "label selector used to identify Dremio coordinator/executor pods")
+	K8sWatchCmd.Flags().BoolVarP(&enableKubeCtl, "enable-kubectl", "d", false, "uses the kubectl CLI (or oc on OpenShift) instead of the embedded k8s api client")
+	K8sWatchCmd.Flags().StringVar(&kubectlBinary, "kubectl-binary", "", "CLI used with --enable-kubectl: kubectl, oc or a path to either (default: kubectl, falling back to oc)")
+
+	LocalWatchCmd.Flags().StringVar(&dremioHome, "dremio-home", "/opt/dremio", "Dremio installation directory")
+	LocalWatchCmd.Flags().StringVar(&localLogDir, "local-log-dir", "", "Log directory on this node (autodetected if not specified)")
+
+	DockerWatchCmd.Flags().StringVar(&containerCLI, "container-cli", "", "container CLI to use: docker, podman or a path to either (default: docker, falling back to podman)")
+	DockerWatchCmd.Flags().StringVar(&coordinatorFilter, "coordinator-filter", "", "comma separated 'docker ps --filter' expressions matching the coordinator containers")
+	DockerWatchCmd.Flags().StringVar(&executorFilter, "executor-filter", "", "comma separated 'docker ps --filter' expressions matching the executor containers")
+
+	for _, cmd := range []*cobra.Command{K8sWatchCmd, DockerWatchCmd} {
+		cmd.Flags().StringVar(&nodesFlag, "nodes", "", "comma-separated list of nodes to watch")
+		cmd.Flags().StringVar(&excludeNodesFlag, "exclude-nodes", "", "comma-separated list of nodes to skip")
+	}
+
+	WatchCmd.PersistentFlags().StringVar(&coordinatorLogDir, "coordinator-log-dir", "", "Coordinator log directory (autodetected if not specified)")
+	WatchCmd.PersistentFlags().StringVar(&executorLogDir, "executor-log-dir", "", "Executor log directory (autodetected if not specified)")
+	WatchCmd.PersistentFlags().StringVar(&dremioConfDir, "dremio-conf-dir", "", "Dremio configuration directory (autodetected if not specified)")
+
+	WatchCmd.PersistentFlags().DurationVar(&interval, "interval", 30*time.Second, "time between two polls of the nodes")
+	WatchCmd.PersistentFlags().DurationVar(&cooldown, "cooldown", 15*time.Minute, "time a node is left alone after a collection; its triggers are logged and skipped")
+	WatchCmd.PersistentFlags().StringSliceVar(&logPatterns, "log-patterns", []string{"oom", "heap_monitor"}, "logparser pattern names or categories (oom, query_failure, cancellation, heap_monitor, planning_failure) that trigger on a new server.log line, empty to disable")
+	WatchCmd.PersistentFlags().StringArrayVar(&logRegexes, "log-regex", []string{`java\.lang\.OutOfMemoryError`}, "regular expression that triggers on a new server.log line, repeat for several, empty to disable")
+	WatchCmd.PersistentFlags().IntVar(&heapThreshold, "heap-threshold", 90, "used heap reported by jcmd GC.heap_info, as a percentage of -Xmx (MaxHeapSize), that triggers, 0 to disable")
+	WatchCmd.PersistentFlags().DurationVar(&gcPauseThreshold, "gc-pause-threshold", 5*time.Second, "GC pause in the GC log that triggers, 0 to disable")
+	WatchCmd.PersistentFlags().IntVar(&maxCollections, "max-collections", 0, "stop watching after this many collections (0 = until Ctrl+C)")
+
+	WatchCmd.PersistentFlags().StringVar(&outputDir, "output-dir", ".", "directory the ddc-watch-*.tgz collections are written to")
+	WatchCmd.PersistentFlags().DurationVar(&logWindow, "log-window", time.Hour, "logs before the trigger to collect, trimmed to the line")
+	WatchCmd.PersistentFlags().BoolVar(&collectJStack, "diag-jstack", true, "collect jstack thread dumps on the affected node")
+	WatchCmd.PersistentFlags().BoolVar(&collectTop, "diag-top", true, "collect top process snapshots on the affected node")
+	WatchCmd.PersistentFlags().BoolVar(&collectJFR, "diag-jfr", true, "collect a JFR recording on the affected node")
+	WatchCmd.PersistentFlags().IntVar(&diagTimeSeconds, "diag-time-seconds", conf.GetIntDefault(conf.DiagnosisDefaultMap(), conf.KeyDiagTimeSeconds), "duration in seconds of the jstack, top and JFR captures")
+
+	WatchCmd.AddCommand(SSHWatchCmd)
+	WatchCmd.AddCommand(K8sWatchCmd)
+	WatchCmd.AddCommand(LocalWatchCmd)
+	WatchCmd.AddCommand(DockerWatchCmd)
+}
@@ -0,0 +1,88 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/cmd/root/collection"
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/collects"
+	"github.com/spf13/cobra"
+)
+
+func TestWatchArgsRejectsBadTriggers(t *testing.T) {
+	defaults := func() {
+		interval, cooldown, maxCollections = 30*time.Second, 15*time.Minute, 0
+		logPatterns, logRegexes = []string{"oom", "heap_monitor"}, []string{`java\.lang\.OutOfMemoryError`}
+		heapThreshold, gcPauseThreshold = 90, 5*time.Second
+	}
+	tests := []struct {
+		set  func()
+		want string
+	}{
+		{func() { logPatterns = []string{"nope"} }, "unknown log pattern 'nope'"},
+		{func() { logRegexes = []string{"("} }, "--log-regex"},
+		{func() { heapThreshold = 120 }, "--heap-threshold must be a percentage"},
+		{func() { interval = 0 }, "--interval must be positive"},
+		{func() { logPatterns, logRegexes, heapThreshold, gcPauseThreshold = nil, []string{""}, 0, 0 }, "every trigger is disabled"},
+	}
+	cmd := &cobra.Command{}
+	for i, tt := range tests {
+		defaults()
+		tt.set()
+		if _, err := watchArgs(cmd); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("case %d: expected an error containing %q, got %v", i, tt.want, err)
+		}
+	}
+
+	defaults()
+	args, err := watchArgs(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the oom and heap_monitor categories plus the OutOfMemoryError regex
+	if len(args.LogPatterns) < 3 || args.LogPatterns[len(args.LogPatterns)-1].Category != "custom" {
+		t.Errorf("unexpected patterns %v", args.LogPatterns)
+	}
+	if args.HeapPercent != 90 || args.GCPause != 5*time.Second || args.Out == nil {
+		t.Errorf("unexpected watch args %+v", args)
+	}
+}
+
+func TestScopedArgs(t *testing.T) {
+	logWindow, collectJStack, collectTop, collectJFR = 2*time.Hour, true, false, true
+	at := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	tr := collection.WatchTrigger{Time: at, Host: "dremio-executor-0", Kind: collection.WatchTriggerHeap}
+	args := scopedArgs(tr, "/out", collection.WatchArgs{ExecutorLogDir: "/var/log/dremio"})
+
+	if args.OutputLoc != filepath.Join("/out", "ddc-watch-heap-dremio-executor-0-20261018T100000Z.tgz") {
+		t.Errorf("unexpected output %v", args.OutputLoc)
+	}
+	if args.CollectionMode != collects.DiagnosisCollection || len(args.IncludeNodes) != 1 || args.IncludeNodes[0] != tr.Host {
+		t.Errorf("expected a diagnosis collection of the affected node, got %v %v", args.CollectionMode, args.IncludeNodes)
+	}
+	if !args.CollectJStack || args.CollectTop || !args.CollectJFR || !args.CollectServerLogs || !args.CollectGCLogs {
+		t.Errorf("expected the JVM captures of the flags and the logs, got %+v", args)
+	}
+	if !args.TrimToWindow || !args.TrimSince.Equal(at.Add(-2*time.Hour)) || args.WatchTrigger.Host != tr.Host {
+		t.Errorf("expected the logs trimmed to the window before the trigger, got %v", args.TrimSince)
+	}
+	if fileSafe("10.0.0.1:22") != "10.0.0.1_22" {
+		t.Errorf("unexpected file name %v", fileSafe("10.0.0.1:22"))
+	}
+}
@@ -65,6 +65,7 @@ type CollectionStats struct {
 	activeThreads     int                          // activeThreads is the number of goroutines currently holding a semaphore slot
 	maxThreads        int                          // maxThreads is the concurrency limit (semaphore capacity)
 	queuedNodes       int                          // queuedNodes is the number of goroutines waiting to acquire a semaphore slot
+	warnings          []string                     // warnings are collection findings shown in the TUI and recorded in summary.json
 	mu                sync.RWMutex                 // mu is the mutex to protect access to various fields (nodeCaptureStats, warnings, lastK8sFileCollected, etc)
 }
 
@@ -143,6 +144,35 @@ func WarningPrint(msg string) {
 	}
 }
 
+// AddWarning records a finding that should stay visible for the rest of the
+// collection (for example pods that were OOMKilled). Warnings are rendered in
+// their own TUI section, included in the JSON status snapshot and returned by
+// Warnings so they can be written to summary.json.
+func AddWarning(msg string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = append(c.warnings, msg)
+}
+
+// ResetWarnings drops the warnings of an earlier collection run in the same process.
+func ResetWarnings() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.warnings = nil
+}
+
+// Warnings returns a copy of the warnings recorded with AddWarning.
+func Warnings() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.warnings) == 0 {
+		return nil
+	}
+	out := make([]string, len(c.warnings))
+	copy(out, c.warnings)
+	return out
+}
+
 // ErrorPrint will either output either in json or pure text
 // depending on if statusOut is enabled or not
 func ErrorPrint(msg string) {
@@ -308,6 +338,7 @@ type JSONSnapshot struct {
 	Result            string             `json:"result"`
 	Tarball           string             `json:"tarball,omitempty"`
 	ElapsedMs         int64              `json:"elapsed_ms"`
+	Warnings          []string           `json:"warnings,omitempty"`
 	Nodes             []JSONNodeSnapshot `json:"nodes"`
 }
 
@@ -510,6 +541,11 @@ func PrintState() {
 	archiveTotalBytes := c.archiveTotalBytes
 	totalCoordinators := c.totalCoordinators
 	totalExecutors := c.totalExecutors
+	var warnings []string
+	if len(c.warnings) > 0 {
+		warnings = make([]string, len(c.warnings))
+		copy(warnings, c.warnings)
+	}
 
 	ddcVersion := "Unknown Version"
 	if c.ddcVersion != "" {
@@ -574,6 +610,7 @@ func PrintState() {
 			Result:            result,
 			Tarball:           tarball,
 			ElapsedMs:         (now - startTime) * 1000,
+			Warnings:          warnings,
 			Nodes:             nodes,
 		}
 		b, err := json.Marshal(snap)
@@ -823,6 +860,14 @@ func PrintState() {
 	renderNodes("coordinator nodes", coordKeys)
 	renderNodes("executor nodes", execKeys)
 
+	if len(warnings) > 0 {
+		sb.WriteString("  " + renderSection("warnings") + "\n\n")
+		for _, w := range warnings {
+			sb.WriteString(warnStyle.Render(fmt.Sprintf("  ! %v", w)) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
 	// Error detail for failed nodes.
 	if errLogs.Len() > 0 {
 		sb.WriteString("  " + renderSection("errors") + "\n\n")
@@ -83,3 +83,42 @@ func TestPrintState_JSONMode(t *testing.T) {
 		t.Error("expected node to be coordinator")
 	}
 }
+
+func TestAddWarning_InSnapshotAndWarnings(t *testing.T) {
+	consoleprint.Clear()
+	consoleprint.EnableStatusOutput()
+	defer consoleprint.DisableStatusOutput()
+
+	consoleprint.AddWarning("pod dremio-executor-0 container dremio-executor was OOMKilled (restarts: 2)")
+
+	out, err := output.CaptureOutput(func() {
+		consoleprint.PrintState()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap consoleprint.JSONSnapshot
+	if err := json.Unmarshal([]byte(out), &snap); err != nil {
+		t.Fatalf("failed to parse JSON snapshot: %v\nraw: %s", err, out)
+	}
+	if len(snap.Warnings) != 1 || !strings.Contains(snap.Warnings[0], "OOMKilled") {
+		t.Errorf("expected the OOMKilled warning in the snapshot, got %v", snap.Warnings)
+	}
+	warnings := consoleprint.Warnings()
+	if len(warnings) != 1 {
+		t.Fatalf("expected 1 warning, got %v", warnings)
+	}
+	warnings[0] = "changed"
+	if consoleprint.Warnings()[0] == "changed" {
+		t.Error("Warnings should return a copy")
+	}
+	consoleprint.Clear()
+	if got := consoleprint.Warnings(); got != nil {
+		t.Errorf("expected Clear to reset warnings, got %v", got)
+	}
+	consoleprint.AddWarning("from an earlier collection")
+	consoleprint.ResetWarnings()
+	if got := consoleprint.Warnings(); got != nil {
+		t.Errorf("expected ResetWarnings to drop the warnings, got %v", got)
+	}
+}
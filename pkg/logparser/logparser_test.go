@@ -558,3 +558,28 @@ func TestScanReader_LargeInput(t *testing.T) {
 		t.Errorf("expected %d unique job IDs, got %d: %v", len(knownIDs), len(res.Matches), res.JobIDs())
 	}
 }
+
+func TestSelectPatterns(t *testing.T) {
+	patterns, err := SelectPatterns([]string{"heap_monitor", "oom_error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range patterns {
+		names = append(names, p.Name)
+	}
+	if strings.Join(names, ",") != "heap_monitor_cancel,csv_heap_dump,heap_monitor_dump,oom_error" {
+		t.Errorf("unexpected patterns %v", names)
+	}
+	line := "2024-01-15 10:00:00,000 [HeapMonitorThread] WARN  HEAP_MONITOR initiated canceling of query 1a2b3c4d-0000-1111-2222-333344445555"
+	if p, ok := MatchLine(patterns, line); !ok || p.Name != "heap_monitor_cancel" {
+		t.Errorf("expected heap_monitor_cancel to match, got %v %v", p.Name, ok)
+	}
+	if _, ok := MatchLine(patterns, "2024-01-15 10:00:00,000 [main] INFO  all good"); ok {
+		t.Error("expected no match")
+	}
+
+	if _, err := SelectPatterns([]string{"exclusion"}); err == nil || !strings.Contains(err.Error(), "heap_monitor") {
+		t.Errorf("expected exclusion patterns to be unknown and the categories listed, got %v", err)
+	}
+}
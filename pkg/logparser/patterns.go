@@ -16,7 +16,11 @@
 // failures, OOM events, and cancellations. Supports Dremio 24.x–26.x log formats.
 package logparser
 
-import "regexp"
+import (
+	"fmt"
+	"regexp"
+	"slices"
+)
 
 // Pattern holds a compiled regex that matches a log line of interest,
 // along with metadata for categorisation.
@@ -88,6 +92,51 @@ func buildPatterns() []Pattern {
 	}
 }
 
+// SelectPatterns returns the line-matching patterns whose name or category is
+// in names, e.g. "heap_monitor" or "oom_error". Exclusion patterns are never
+// returned. An entry that matches no pattern is an error.
+func SelectPatterns(names []string) ([]Pattern, error) {
+	all := buildPatterns()
+	var selected []Pattern
+	for _, name := range names {
+		found := false
+		for _, p := range all {
+			if p.Exclude || (p.Name != name && p.Category != name) {
+				continue
+			}
+			found = true
+			if !slices.ContainsFunc(selected, func(s Pattern) bool { return s.Name == p.Name }) {
+				selected = append(selected, p)
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown log pattern '%v': use a pattern name or one of the categories %v", name, patternCategories(all))
+		}
+	}
+	return selected, nil
+}
+
+// MatchLine returns the first pattern matching line.
+func MatchLine(patterns []Pattern, line string) (Pattern, bool) {
+	for _, p := range patterns {
+		if p.Regex.MatchString(line) {
+			return p, true
+		}
+	}
+	return Pattern{}, false
+}
+
+// patternCategories lists the categories of the non-exclusion patterns in order of appearance.
+func patternCategories(patterns []Pattern) []string {
+	var categories []string
+	for _, p := range patterns {
+		if !p.Exclude && !slices.Contains(categories, p.Category) {
+			categories = append(categories, p.Category)
+		}
+	}
+	return categories
+}
+
 // extractJobIDsFromMatches pulls the UUID explicitly from the regex submatch
 func extractJobIDsFromMatches(matches []string) []string {
 	seen := map[string]bool{}
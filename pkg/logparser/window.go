@@ -0,0 +1,141 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logparser
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxTimestampPrefix is how much of a line is handed to extractTimestamp; the
+// timestamp is at the start and long stack trace lines need not be copied.
+const maxTimestampPrefix = 64
+
+// windowLayouts are the accepted --since / --until formats. Times without a
+// zone are UTC.
+var windowLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"}
+
+// ParseWindowTime parses a --since / --until value with minute (or second)
+// precision, e.g. 2026-03-20T13:30.
+func ParseWindowTime(s string) (time.Time, error) {
+	for _, layout := range windowLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected a time like 2026-03-20T13:30, got %q", s)
+}
+
+// ParseTimestamp returns the time of the leading logback or ISO-8601
+// timestamp of a log line. Timestamps without a zone (logback's default
+// pattern) are read as UTC.
+func ParseTimestamp(line string) (time.Time, bool) {
+	return ParseTimestampIn(line, time.UTC)
+}
+
+// ParseTimestampIn is ParseTimestamp reading timestamps without a zone in loc,
+// the zone of the JVM that wrote them. The result is in UTC.
+func ParseTimestampIn(line string, loc *time.Location) (time.Time, bool) {
+	if len(line) > maxTimestampPrefix {
+		line = line[:maxTimestampPrefix]
+	}
+	ts := extractTimestamp(line)
+	if ts == "" {
+		return time.Time{}, false
+	}
+	if ts[10] == ' ' {
+		// logback: 2024-01-15 10:30:45,123
+		t, err := time.ParseInLocation("2006-01-02 15:04:05.000", strings.Replace(ts, ",", ".", 1), loc)
+		return t.UTC(), err == nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700", "2006-01-02T15:04:05.000"} {
+		if t, err := time.ParseInLocation(layout, ts, loc); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Window is a time range of log lines; a zero Since or Until leaves that side
+// open. Both ends are inclusive. Location is the zone of log timestamps that
+// carry none; nil is UTC.
+type Window struct {
+	Since    time.Time
+	Until    time.Time
+	Location *time.Location
+}
+
+// Contains reports whether t falls in the window.
+func (w Window) Contains(t time.Time) bool {
+	if !w.Since.IsZero() && t.Before(w.Since) {
+		return false
+	}
+	if !w.Until.IsZero() && t.After(w.Until) {
+		return false
+	}
+	return true
+}
+
+// TrimStats counts the bytes TrimLines kept and dropped.
+type TrimStats struct {
+	BytesKept    int64
+	BytesDropped int64
+}
+
+// TrimLines copies the log lines of r whose leading timestamp is inside win
+// to w. Lines without a timestamp (stack traces, multi-line messages) go with
+// the line they continue, and any before the first timestamp are kept. Like
+// ScanReader it works line by line, so file size does not matter.
+func TrimLines(r io.Reader, w io.Writer, win Window) (TrimStats, error) {
+	var stats TrimStats
+	loc := win.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	br := bufio.NewReaderSize(r, 256*1024)
+	keep := true
+	atLineStart := true
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if len(chunk) > 0 {
+			if atLineStart {
+				if ts, ok := ParseTimestampIn(string(chunk[:min(len(chunk), maxTimestampPrefix)]), loc); ok {
+					keep = win.Contains(ts)
+				}
+			}
+			if keep {
+				if _, werr := w.Write(chunk); werr != nil {
+					return stats, werr
+				}
+				stats.BytesKept += int64(len(chunk))
+			} else {
+				stats.BytesDropped += int64(len(chunk))
+			}
+			// a line longer than the buffer arrives in several chunks
+			atLineStart = chunk[len(chunk)-1] == '\n'
+		}
+		switch {
+		case err == nil, errors.Is(err, bufio.ErrBufferFull):
+		case errors.Is(err, io.EOF):
+			return stats, nil
+		default:
+			return stats, err
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logparser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2026, 3, 20, 14, 5, 1, 123000000, time.UTC)
+	for _, line := range []string{
+		"2026-03-20 14:05:01,123 [main] INFO  c.d.dac.server.DremioServer - started",
+		"2026-03-20T14:05:01.123Z [main] INFO c.d.dac.server.DremioServer - started",
+		"2026-03-20T16:05:01.123+02:00 [main] INFO c.d.dac.server.DremioServer - started",
+		"2026-03-20T14:05:01.123 [main] INFO c.d.dac.server.DremioServer - started",
+	} {
+		got, ok := ParseTimestamp(line)
+		if !ok || !got.Equal(want) {
+			t.Errorf("ParseTimestamp(%q) = %v, %v; want %v", line, got, ok, want)
+		}
+	}
+	for _, line := range []string{"\tat com.dremio.Foo.bar(Foo.java:42)", "", "2026-13-45 99:99:99,999 bad"} {
+		if _, ok := ParseTimestamp(line); ok {
+			t.Errorf("expected no timestamp in %q", line)
+		}
+	}
+}
+
+func TestParseTimestampIn(t *testing.T) {
+	berlin := time.FixedZone("CET", 3600)
+	want := time.Date(2026, 3, 20, 13, 5, 1, 123000000, time.UTC)
+	for _, line := range []string{
+		"2026-03-20 14:05:01,123 [main] INFO  c.d.dac.server.DremioServer - started",
+		"2026-03-20T14:05:01.123 [main] INFO c.d.dac.server.DremioServer - started",
+		// an explicit zone wins over the node's
+		"2026-03-20T13:05:01.123Z [main] INFO c.d.dac.server.DremioServer - started",
+	} {
+		got, ok := ParseTimestampIn(line, berlin)
+		if !ok || !got.Equal(want) || got.Location() != time.UTC {
+			t.Errorf("ParseTimestampIn(%q) = %v, %v; want %v", line, got, ok, want)
+		}
+	}
+}
+
+func TestTrimLines_NodeTimezone(t *testing.T) {
+	input := "2026-03-20 08:29:00,000 [main] INFO before\n2026-03-20 08:30:00,000 [main] ERROR inside\n"
+	// 13:30 UTC is 08:30 on a node five hours behind
+	win := Window{Since: time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC), Location: time.FixedZone("", -5*3600)}
+	var out bytes.Buffer
+	if _, err := TrimLines(strings.NewReader(input), &out, win); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "2026-03-20 08:30:00,000 [main] ERROR inside\n" {
+		t.Errorf("expected the window read in the node's zone, got %q", out.String())
+	}
+}
+
+func TestParseWindowTime(t *testing.T) {
+	got, err := ParseWindowTime("2026-03-20T13:30")
+	if err != nil || !got.Equal(time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected %v, %v", got, err)
+	}
+	got, err = ParseWindowTime("2026-03-20T15:30:00+02:00")
+	if err != nil || !got.Equal(time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected %v, %v", got, err)
+	}
+	if _, err := ParseWindowTime("13:30"); err == nil {
+		t.Error("expected an error without a date")
+	}
+}
+
+func TestTrimLines(t *testing.T) {
+	input := strings.Join([]string{
+		"header without timestamp",
+		"2026-03-20 13:00:00,000 [main] INFO before",
+		"java.lang.RuntimeException: before",
+		"\tat com.dremio.Foo.bar(Foo.java:1)",
+		"2026-03-20 13:30:00,000 [main] ERROR inside",
+		"java.lang.OutOfMemoryError: inside",
+		"\tat com.dremio.Foo.baz(Foo.java:2)",
+		"2026-03-20 14:10:00,000 [main] INFO inside at the end",
+		"2026-03-20 14:10:00,001 [main] INFO after",
+		"continuation after",
+		"",
+	}, "\n")
+	win := Window{Since: time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC), Until: time.Date(2026, 3, 20, 14, 10, 0, 0, time.UTC)}
+	var out bytes.Buffer
+	stats, err := TrimLines(strings.NewReader(input), &out, win)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Join([]string{
+		"header without timestamp",
+		"2026-03-20 13:30:00,000 [main] ERROR inside",
+		"java.lang.OutOfMemoryError: inside",
+		"\tat com.dremio.Foo.baz(Foo.java:2)",
+		"2026-03-20 14:10:00,000 [main] INFO inside at the end",
+		"",
+	}, "\n")
+	if out.String() != want {
+		t.Errorf("unexpected output:\n%v\nwant:\n%v", out.String(), want)
+	}
+	if stats.BytesKept != int64(len(want)) || stats.BytesKept+stats.BytesDropped != int64(len(input)) {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTrimLines_LongLines(t *testing.T) {
+	long := strings.Repeat("x", 600*1024)
+	input := "2026-03-20 13:00:00,000 dropped " + long + "\n" + long + "\n2026-03-20 14:00:00,000 kept " + long + "\n" + long
+	var out bytes.Buffer
+	stats, err := TrimLines(strings.NewReader(input), &out, Window{Since: time.Date(2026, 3, 20, 13, 30, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2026-03-20 14:00:00,000 kept " + long + "\n" + long
+	if out.String() != want || stats.BytesDropped != int64(len(input)-len(want)) {
+		t.Errorf("expected only the kept entry and its continuation, got %d bytes (%+v)", out.Len(), stats)
+	}
+}
@@ -0,0 +1,61 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// masking hides secrets in files and replaces them with redacted text
+package masking
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// RemoveSecretsFromDockerInspect masks the output of docker or podman inspect, a JSON
+// array of containers. Environment entries ("NAME=value") whose name looks like a
+// credential keep the name and lose the value.
+func RemoveSecretsFromDockerInspect(inspectJSON []byte) (string, error) {
+	var containers []map[string]interface{}
+	if err := json.Unmarshal(inspectJSON, &containers); err != nil {
+		return "", err
+	}
+	for i, container := range containers {
+		config, ok := container["Config"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		envRaw, ok := config["Env"]
+		if !ok || envRaw == nil {
+			continue
+		}
+		env, ok := envRaw.([]interface{})
+		if !ok {
+			return "", fmt.Errorf("container %d: Config.Env must be an array but was '%T'", i, envRaw)
+		}
+		for j, entry := range env {
+			s, ok := entry.(string)
+			if !ok {
+				continue
+			}
+			name, _, found := strings.Cut(s, "=")
+			if found && checkHelmKeyForSecret(name) {
+				env[j] = name + "=" + removedSourceSecret
+			}
+		}
+	}
+	outBytes, err := json.Marshal(containers)
+	if err != nil {
+		return "", err
+	}
+	return string(outBytes), nil
+}
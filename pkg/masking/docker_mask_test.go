@@ -0,0 +1,52 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package masking_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+)
+
+func TestRemoveSecretsFromDockerInspect(t *testing.T) {
+	input := `[
+		{"Name": "/dremio-coordinator", "Config": {"Image": "dremio/dremio-oss", "Env": [
+			"DREMIO_MAX_MEMORY_SIZE_MB=8192",
+			"AWS_SECRET_ACCESS_KEY=aws-secret",
+			"LDAP_PASSWORD=ldap=pw",
+			"PATH=/usr/bin"
+		]}},
+		{"Name": "/dremio-executor", "Config": {"Env": null}}
+	]`
+	out, err := masking.RemoveSecretsFromDockerInspect([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, leaked := range []string{"aws-secret", "ldap=pw"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("expected %q to be masked in %v", leaked, out)
+		}
+	}
+	for _, kept := range []string{"DREMIO_MAX_MEMORY_SIZE_MB=8192", "AWS_SECRET_ACCESS_KEY=REMOVED_POTENTIAL_SECRET", "LDAP_PASSWORD=REMOVED_POTENTIAL_SECRET", "PATH=/usr/bin", "dremio/dremio-oss"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %q in %v", kept, out)
+		}
+	}
+
+	if _, err := masking.RemoveSecretsFromDockerInspect([]byte(`{"not": "an array"}`)); err == nil {
+		t.Error("expected an error for output that is not a container array")
+	}
+}
@@ -0,0 +1,63 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// masking hides secrets in files and replaces them with redacted text
+package masking
+
+import "strings"
+
+func checkHelmKeyForSecret(key string) bool {
+	return checkSourceKeyForSecret(key) || checkStringForSecret(key) || checkK8sStringForSecret(key)
+}
+
+// MaskHelmValues walks Helm chart values in place. Any value under a key that looks
+// like a credential (accessKey, password, secret, token...) is removed, whole maps
+// included, env style {"name": ..., "value": ...} entries are masked by name, and
+// multi-line strings such as an embedded dremio.conf go through the config masker.
+func MaskHelmValues(values map[string]interface{}) {
+	for k, v := range values {
+		if checkHelmKeyForSecret(k) {
+			if v != nil {
+				values[k] = removedSourceSecret
+			}
+			continue
+		}
+		values[k] = maskHelmValue(v)
+	}
+}
+
+func maskHelmValue(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if name, ok := typed["name"].(string); ok {
+			if _, hasValue := typed["value"]; hasValue && checkHelmKeyForSecret(name) {
+				typed["value"] = removedSourceSecret
+			}
+		}
+		MaskHelmValues(typed)
+		return typed
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = maskHelmValue(item)
+		}
+		return typed
+	case string:
+		if strings.Contains(typed, "\n") {
+			return string(MaskConfigData([]byte(typed)))
+		}
+		return typed
+	default:
+		return v
+	}
+}
@@ -0,0 +1,67 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package masking_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+)
+
+func TestMaskHelmValues(t *testing.T) {
+	input := `{
+		"coordinator": {"count": 1, "memory": 16384},
+		"distStorage": {
+			"type": "aws",
+			"aws": {
+				"bucketName": "dremio-dist",
+				"credentials": {"accessKey": "AKIAEXAMPLE", "secret": "aws-secret"}
+			}
+		},
+		"executor": {
+			"extraEnvs": [
+				{"name": "DREMIO_JAVA_SERVER_EXTRA_OPTS", "value": "-Xss2m"},
+				{"name": "LDAP_PASSWORD", "value": "ldap-pw"}
+			]
+		},
+		"extraConf": "paths.dist: \"s3a://bucket\"\nservices.coordinator.web.ssl.keyStorePassword: \"ks-pw\"\n",
+		"authToken": null
+	}`
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &values); err != nil {
+		t.Fatalf("unable to parse input: %v", err)
+	}
+	masking.MaskHelmValues(values)
+	out, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("unable to marshal output: %v", err)
+	}
+	masked := string(out)
+	for _, secret := range []string{"AKIAEXAMPLE", "aws-secret", "ldap-pw", "ks-pw"} {
+		if strings.Contains(masked, secret) {
+			t.Errorf("expected %q to be masked in %v", secret, masked)
+		}
+	}
+	for _, kept := range []string{"dremio-dist", "-Xss2m", "LDAP_PASSWORD", "s3a://bucket", "16384"} {
+		if !strings.Contains(masked, kept) {
+			t.Errorf("expected %q to be kept in %v", kept, masked)
+		}
+	}
+	if values["authToken"] != nil {
+		t.Errorf("expected null values to stay null, got %v", values["authToken"])
+	}
+}
@@ -248,3 +248,15 @@ func RemoveSecretsFromK8sJSON(k8sJSON []byte) (string, error) {
 
 	return string(outBytes), nil
 }
+
+// MaskConfigMapData runs each ConfigMap value through the config masker. Values stored
+// under a key that itself looks like a secret (e.g. "db-password") are removed outright.
+func MaskConfigMapData(data map[string]string) {
+	for key, value := range data {
+		if checkStringForSecret(key) || checkK8sStringForSecret(key) {
+			data[key] = "REMOVED_POTENTIAL_SECRET"
+			continue
+		}
+		data[key] = string(MaskConfigData([]byte(value)))
+	}
+}
@@ -149,3 +149,21 @@ func jsonCompact(s string) string {
 	}
 	return buf.String()
 }
+
+func TestMaskConfigMapData(t *testing.T) {
+	data := map[string]string{
+		"dremio.conf": "paths: {\n  local: \"/opt/dremio/data\"\n}\nservices.coordinator.web.ssl.keyStorePassword: \"hunter2\"\n",
+		"db-password": "hunter2",
+		"plain":       "nothing to see",
+	}
+	masking.MaskConfigMapData(data)
+	if strings.Contains(data["dremio.conf"], "hunter2") || !strings.Contains(data["dremio.conf"], "/opt/dremio/data") {
+		t.Errorf("expected only the secret line masked, got %q", data["dremio.conf"])
+	}
+	if data["db-password"] != "REMOVED_POTENTIAL_SECRET" {
+		t.Errorf("expected value under a secret-looking key removed, got %q", data["db-password"])
+	}
+	if data["plain"] != "nothing to see" {
+		t.Errorf("expected plain value untouched, got %q", data["plain"])
+	}
+}
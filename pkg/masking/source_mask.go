@@ -0,0 +1,78 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// masking hides secrets in files and replaces them with redacted text
+package masking
+
+import "strings"
+
+// secretSourceKeywords matches Dremio source config keys that carry credentials.
+// Source configs use camelCase keys (accessSecret, accountKey, clientSecret...)
+// so the match is done against the lower-cased key.
+var secretSourceKeywords = []string{
+	"passw",
+	"secret",
+	"token",
+	"accesskey",
+	"accountkey",
+	"access_key",
+	"privatekey",
+	"credential",
+	"sasurl",
+	"sas_url",
+	"sassignature",
+}
+
+const removedSourceSecret = "REMOVED_POTENTIAL_SECRET"
+
+func checkSourceKeyForSecret(key string) bool {
+	lower := strings.ToLower(key)
+	for _, keyword := range secretSourceKeywords {
+		if strings.Contains(lower, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaskDremioSourceConfig walks the config section of a Dremio source returned by
+// /api/v3/catalog/{id} and replaces any credential-looking value in place.
+// Property lists ({"name": ..., "value": ...} entries) are masked by name.
+func MaskDremioSourceConfig(config map[string]interface{}) {
+	for k, v := range config {
+		if checkSourceKeyForSecret(k) {
+			if v != nil {
+				config[k] = removedSourceSecret
+			}
+			continue
+		}
+		maskSourceValue(v)
+	}
+}
+
+func maskSourceValue(v interface{}) {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		if name, ok := typed["name"].(string); ok {
+			if _, hasValue := typed["value"]; hasValue && checkSourceKeyForSecret(name) {
+				typed["value"] = removedSourceSecret
+			}
+		}
+		MaskDremioSourceConfig(typed)
+	case []interface{}:
+		for _, item := range typed {
+			maskSourceValue(item)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+//	Copyright 2023 Dremio Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package masking_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dremio/dremio-diagnostic-collector/v4/pkg/masking"
+)
+
+func TestMaskDremioSourceConfig(t *testing.T) {
+	input := `{
+		"accessKey": "AKIAEXAMPLE",
+		"accessSecret": "very-secret",
+		"secure": true,
+		"rootPath": "/bucket",
+		"hostname": "db.example.com",
+		"password": "hunter2",
+		"propertyList": [
+			{"name": "fs.s3a.secret.key", "value": "s3-secret"},
+			{"name": "fs.s3a.endpoint", "value": "s3.example.com"}
+		],
+		"nested": {"clientSecret": "oauth-secret"}
+	}`
+	var config map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("unable to parse input: %v", err)
+	}
+	masking.MaskDremioSourceConfig(config)
+	out, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("unable to marshal output: %v", err)
+	}
+	masked := string(out)
+	for _, secret := range []string{"AKIAEXAMPLE", "very-secret", "hunter2", "s3-secret", "oauth-secret"} {
+		if strings.Contains(masked, secret) {
+			t.Errorf("expected %q to be masked in %v", secret, masked)
+		}
+	}
+	for _, kept := range []string{"/bucket", "db.example.com", "s3.example.com", "fs.s3a.secret.key"} {
+		if !strings.Contains(masked, kept) {
+			t.Errorf("expected %q to be kept in %v", kept, masked)
+		}
+	}
+}